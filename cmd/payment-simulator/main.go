@@ -0,0 +1,51 @@
+// Command payment-simulator runs the simulated payment gateway for local
+// development. Point the server at it with PAYMENT_GATEWAY=http and
+// PAYMENT_GATEWAY_URL; it reports payments to the server's payment callback
+// signed with PAYMENT_CALLBACK_SECRET. It collects no real money and lets
+// anyone pay any top-up, so never expose it outside a development machine.
+package main
+
+import (
+	"ewallet/internal/payment"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Share the server's .env file when there is one
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+
+	apiKey := os.Getenv("PAYMENT_GATEWAY_API_KEY")
+	if apiKey == "" {
+		log.Fatal("PAYMENT_GATEWAY_API_KEY is not set")
+	}
+	secret := os.Getenv("PAYMENT_CALLBACK_SECRET")
+	if secret == "" {
+		log.Fatal("PAYMENT_CALLBACK_SECRET is not set")
+	}
+
+	simulator := payment.NewSimulator(getEnv("PAYMENT_SIMULATOR_CALLBACK_URL", "http://localhost:8080/api/payments/callback"), secret, nil)
+	server := &http.Server{
+		Addr:              getEnv("PAYMENT_SIMULATOR_ADDR", "localhost:8090"),
+		Handler:           simulator.Handler(apiKey),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("Payment simulator listening on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Payment simulator stopped: %v", err)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
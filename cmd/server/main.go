@@ -1,14 +1,29 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"ewallet/config"
 	_ "ewallet/docs"
+	"ewallet/internal/bank"
+	"ewallet/internal/events"
+	"ewallet/internal/fx"
 	"ewallet/internal/handlers"
 	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/payment"
+	"ewallet/internal/realtime"
 	"ewallet/internal/repository"
 	"ewallet/internal/service"
+	"ewallet/pkg/storage"
 	"ewallet/pkg/utils"
+	"fmt"
 	"log"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -59,20 +74,98 @@ func main() {
 	userRepo := repository.NewUserRepository(db)
 	walletRepo := repository.NewWalletRepository(db)
 	transactionRepo := repository.NewTransactionRepository(db)
+	ledgerRepo := repository.NewLedgerRepository(db)
+	idempotencyRepo := repository.NewIdempotencyRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	limitRepo := repository.NewLimitRepository(db)
+	kycRepo := repository.NewKYCRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	notificationRepo := repository.NewNotificationRepository(db)
+	paymentRequestRepo := repository.NewPaymentRequestRepository(db)
+	scheduledTransferRepo := repository.NewScheduledTransferRepository(db)
+	fxQuoteRepo := repository.NewFXQuoteRepository(db)
+	feeRepo := repository.NewFeeRepository(db)
+	bankAccountRepo := repository.NewBankAccountRepository(db)
+	withdrawalRepo := repository.NewWithdrawalRepository(db)
+	topUpRepo := repository.NewTopUpRepository(db)
+
+	// Initialize storage for uploaded KYC documents
+	kycStorage := storage.NewLocalStorage(cfg.KYC.UploadDir)
+
+	// Initialize the provider of exchange rates
+	rates, err := newRateProvider(&cfg.FX)
+	if err != nil {
+		log.Fatalf("Failed to load exchange rates: %v", err)
+	}
+
+	// Initialize the provider that pays withdrawals out to bank accounts
+	payouts, err := newPayoutProvider(&cfg.Payout)
+	if err != nil {
+		log.Fatalf("Failed to set up payouts: %v", err)
+	}
+
+	// Initialize the gateway that collects top-up payments
+	gateway, err := newPaymentGateway(&cfg.Payment)
+	if err != nil {
+		log.Fatalf("Failed to set up payments: %v", err)
+	}
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, walletRepo, jwtUtil, db)
-	walletService := service.NewWalletService(walletRepo, transactionRepo, db)
-	transactionService := service.NewTransactionService(walletRepo, transactionRepo, userRepo, db)
+	auditService := service.NewAuditService(auditRepo, db)
+	outboxService := service.NewOutboxService(outboxRepo)
+	webhookService := service.NewWebhookService(webhookRepo, cfg.Webhook.AllowLoopback)
+	notificationService := service.NewNotificationService(notificationRepo)
+	ledgerService := service.NewLedgerService(ledgerRepo, walletRepo)
+	walletLocker := service.NewWalletLocker(walletRepo, db)
+	pinService := service.NewPINService(userRepo)
+	fxService := service.NewFXService(fxQuoteRepo, rates, cfg.FX.QuoteTTL)
+	feeService := service.NewFeeService(feeRepo, userRepo, walletRepo)
+	limitService := service.NewLimitService(limitRepo, transactionRepo, userRepo, walletRepo, fxService)
+	kycService := service.NewKYCService(kycRepo, userRepo, kycStorage, auditService, db)
+	authService := service.NewAuthService(userRepo, walletRepo, sessionRepo, ledgerService, auditService, outboxService, jwtUtil, cfg.JWT.RefreshExpiry, db)
+	walletService := service.NewWalletService(walletRepo, transactionRepo, ledgerService, walletLocker, limitService, fxService, auditService, notificationService, db)
+	transactionService := service.NewTransactionService(walletRepo, transactionRepo, userRepo, ledgerService, walletLocker, pinService, limitService, fxService, feeService, kycService, auditService, outboxService, notificationService, db)
+	paymentRequestService := service.NewPaymentRequestService(paymentRequestRepo, userRepo, walletRepo, transactionService, auditService, notificationService, db)
+	scheduledTransferService := service.NewScheduledTransferService(scheduledTransferRepo, userRepo, walletRepo, pinService, auditService, db)
+	withdrawalService := service.NewWithdrawalService(bankAccountRepo, withdrawalRepo, walletRepo, transactionRepo, ledgerService, walletLocker, limitService, fxService, pinService, kycService, auditService, outboxService, notificationService, db)
+	topUpService := service.NewTopUpService(topUpRepo, walletRepo, transactionRepo, ledgerService, walletLocker, limitService, fxService, feeService, kycService, auditService, outboxService, notificationService, gateway, cfg.Payment.TopUpTTL, db)
+	adminService := service.NewAdminService(userRepo, walletRepo, sessionRepo, ledgerService, walletService, transactionService, auditService, db)
+
+	// Initialize the broker that pushes wallet notifications to open streams
+	broker := realtime.NewBroker(cfg.Database.ConnectionString())
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userRepo)
-	walletHandler := handlers.NewWalletHandler(walletService)
+	userHandler := handlers.NewUserHandler(userRepo, pinService, auditService)
+	walletHandler := handlers.NewWalletHandler(walletService, broker)
 	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	kycHandler := handlers.NewKYCHandler(kycService)
+	adminHandler := handlers.NewAdminHandler(adminService)
+	auditHandler := handlers.NewAuditHandler(auditService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	paymentRequestHandler := handlers.NewPaymentRequestHandler(paymentRequestService)
+	scheduledTransferHandler := handlers.NewScheduledTransferHandler(scheduledTransferService)
+	fxHandler := handlers.NewFXHandler(fxService)
+	feeHandler := handlers.NewFeeHandler(feeService)
+	withdrawalHandler := handlers.NewWithdrawalHandler(withdrawalService, cfg.Payout.CallbackSecret)
+	topUpHandler := handlers.NewTopUpHandler(topUpService, cfg.Payment.CallbackSecret)
+	idempotencyHandler := handlers.NewIdempotencyHandler(idempotencyRepo)
 
 	// Setup Gin router
 	router := gin.Default()
+	router.Use(middleware.RequestIDMiddleware())
+	authRequired := middleware.AuthMiddleware(jwtUtil, sessionRepo)
+	idempotency := middleware.IdempotencyMiddleware(idempotencyRepo)
+
+	// Back-office roles: staff can read, support and admins can review KYC
+	// and freeze wallets, only admins can change account status or reverse transfers, and only
+	// admins and auditors can read the audit log
+	staffOnly := middleware.RequireRole(models.RoleSupport, models.RoleAdmin, models.RoleAuditor)
+	supportOnly := middleware.RequireRole(models.RoleSupport, models.RoleAdmin)
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+	auditorOnly := middleware.RequireRole(models.RoleAdmin, models.RoleAuditor)
 
 	// Public routes
 	api := router.Group("/api")
@@ -81,27 +174,130 @@ func main() {
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authRequired, authHandler.Logout)
 		}
 
 		// Protected routes
 		users := api.Group("/users")
-		users.Use(middleware.AuthMiddleware(jwtUtil))
+		users.Use(authRequired)
 		{
 			users.GET("/profile", userHandler.GetProfile)
+			users.POST("/pin", userHandler.SetPIN)
+			users.PUT("/pin", userHandler.ChangePIN)
+			users.GET("/kyc", kycHandler.GetStatus)
+			users.POST("/kyc", kycHandler.Submit)
 		}
 
 		wallets := api.Group("/wallets")
-		wallets.Use(middleware.AuthMiddleware(jwtUtil))
+		wallets.Use(authRequired)
 		{
+			wallets.GET("", walletHandler.ListWallets)
+			wallets.POST("", walletHandler.CreateWallet)
+			wallets.PUT("/:id", walletHandler.UpdateWallet)
 			wallets.GET("/balance", walletHandler.GetBalance)
-			wallets.POST("/topup", walletHandler.TopUp)
+			wallets.GET("/limits", walletHandler.GetLimits)
+			wallets.GET("/stream", walletHandler.Stream)
+			wallets.POST("/topup", idempotency, topUpHandler.Create)
+			wallets.GET("/topups", topUpHandler.List)
+			wallets.GET("/topups/:id", topUpHandler.Get)
+			wallets.POST("/move", idempotency, walletHandler.Move)
 		}
 
 		transactions := api.Group("/transactions")
-		transactions.Use(middleware.AuthMiddleware(jwtUtil))
+		transactions.Use(authRequired)
 		{
-			transactions.POST("/transfer", transactionHandler.Transfer)
+			transactions.POST("/transfer", idempotency, transactionHandler.Transfer)
 			transactions.GET("/history", transactionHandler.GetHistory)
+			transactions.POST("/scheduled", scheduledTransferHandler.Create)
+			transactions.GET("/scheduled", scheduledTransferHandler.List)
+			transactions.GET("/scheduled/:id", scheduledTransferHandler.Get)
+			transactions.PUT("/scheduled/:id", scheduledTransferHandler.Update)
+			transactions.DELETE("/scheduled/:id", scheduledTransferHandler.Cancel)
+			transactions.GET("/:id", transactionHandler.GetTransaction)
+			transactions.POST("/:id/refund", idempotency, transactionHandler.Refund)
+		}
+
+		fxRoutes := api.Group("/fx")
+		fxRoutes.Use(authRequired)
+		{
+			fxRoutes.GET("/currencies", fxHandler.Currencies)
+			fxRoutes.POST("/quotes", fxHandler.CreateQuote)
+			fxRoutes.GET("/quotes/:id", fxHandler.GetQuote)
+		}
+
+		fees := api.Group("/fees")
+		fees.Use(authRequired)
+		{
+			fees.GET("/preview", feeHandler.Preview)
+		}
+
+		paymentRequests := api.Group("/payment-requests")
+		paymentRequests.Use(authRequired)
+		{
+			paymentRequests.POST("", paymentRequestHandler.Create)
+			paymentRequests.GET("", paymentRequestHandler.List)
+			paymentRequests.GET("/:id", paymentRequestHandler.Get)
+			paymentRequests.POST("/:id/accept", idempotency, paymentRequestHandler.Accept)
+			paymentRequests.POST("/:id/decline", paymentRequestHandler.Decline)
+			paymentRequests.POST("/:id/cancel", paymentRequestHandler.Cancel)
+		}
+
+		bankAccounts := api.Group("/bank-accounts")
+		bankAccounts.Use(authRequired)
+		{
+			bankAccounts.GET("", withdrawalHandler.ListBankAccounts)
+			bankAccounts.POST("", withdrawalHandler.AddBankAccount)
+			bankAccounts.DELETE("/:id", withdrawalHandler.RemoveBankAccount)
+		}
+
+		withdrawals := api.Group("/withdrawals")
+		withdrawals.Use(authRequired)
+		{
+			withdrawals.POST("", idempotency, withdrawalHandler.Withdraw)
+			withdrawals.GET("", withdrawalHandler.List)
+			withdrawals.GET("/:id", withdrawalHandler.Get)
+		}
+
+		// Called by the payout provider; authenticated by signature
+		api.POST("/payouts/callback", withdrawalHandler.PayoutCallback)
+
+		// Called by the payment gateway; authenticated by signature
+		api.POST("/payments/callback", topUpHandler.PaymentCallback)
+
+		webhooks := api.Group("/webhooks")
+		webhooks.Use(authRequired)
+		{
+			webhooks.POST("", webhookHandler.CreateEndpoint)
+			webhooks.GET("", webhookHandler.ListEndpoints)
+			webhooks.DELETE("/:id", webhookHandler.DeleteEndpoint)
+			webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+			webhooks.POST("/:id/deliveries/:deliveryId/replay", webhookHandler.ReplayDelivery)
+		}
+
+		api.GET("/idempotency-keys/:key", authRequired, idempotencyHandler.GetKey)
+
+		// Back-office routes
+		admin := api.Group("/admin")
+		admin.Use(authRequired, staffOnly)
+		{
+			admin.GET("/users", adminHandler.SearchUsers)
+			admin.GET("/users/:id", adminHandler.GetUser)
+			admin.GET("/users/:id/wallet", adminHandler.InspectWallet)
+			admin.PUT("/users/:id/wallet/status", supportOnly, adminHandler.UpdateWalletStatus)
+			admin.GET("/users/:id/wallet/status-history", adminHandler.GetWalletStatusHistory)
+			admin.PUT("/users/:id/status", adminOnly, adminHandler.UpdateUserStatus)
+			admin.GET("/transactions", adminHandler.SearchTransactions)
+			admin.POST("/transactions/:id/reverse", adminOnly, idempotency, adminHandler.ReverseTransaction)
+
+			admin.GET("/kyc", kycHandler.ListSubmissions)
+			admin.GET("/kyc/:id", kycHandler.GetSubmission)
+			admin.GET("/kyc/:id/documents/:documentId", kycHandler.GetDocument)
+			admin.POST("/kyc/:id/approve", supportOnly, kycHandler.Approve)
+			admin.POST("/kyc/:id/reject", supportOnly, kycHandler.Reject)
+
+			admin.GET("/audit-events", auditorOnly, auditHandler.ListEvents)
+			admin.GET("/audit-events/verify", auditorOnly, auditHandler.VerifyChain)
 		}
 	}
 
@@ -115,9 +311,121 @@ func main() {
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Stop background workers and the server on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Start the outbox relay; webhooks are queued as one of its sinks
+	sinks := []events.Sink{webhookService}
+	if cfg.Outbox.LogSink {
+		sinks = append(sinks, events.NewLogSink())
+	}
+	if cfg.Outbox.HTTPSinkURL != "" {
+		sinks = append(sinks, events.NewHTTPSink(cfg.Outbox.HTTPSinkURL, nil))
+	}
+	outboxRelay := service.NewOutboxRelay(outboxRepo, sinks, db, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize)
+
+	// Start the chainer that appends recorded audit events to the hash chain
+	auditChainer := service.NewAuditChainer(auditRepo, db, cfg.Audit.ChainPollInterval, cfg.Audit.ChainBatchSize)
+
+	// Start the webhook dispatcher
+	webhookClient := service.NewWebhookClient(cfg.Webhook.Timeout, cfg.Webhook.AllowLoopback)
+	webhookDispatcher := service.NewWebhookDispatcher(webhookRepo, db, webhookClient, cfg.Webhook.MaxAttempts, cfg.Webhook.PollInterval, cfg.Webhook.BatchSize)
+
+	// Start the scheduler that makes scheduled transfers
+	transferScheduler := service.NewTransferScheduler(scheduledTransferRepo, transactionService, db, cfg.Scheduler.PollInterval, cfg.Scheduler.BatchSize, cfg.Scheduler.MaxFailures)
+
+	// Start the processor that submits withdrawals to the payout provider
+	payoutProcessor := service.NewPayoutProcessor(withdrawalRepo, withdrawalService, payouts, db, cfg.Payout.MaxAttempts, cfg.Payout.PollInterval, cfg.Payout.BatchSize)
+
+	// Start the expirer of top-ups that were not paid in time
+	topUpExpirer := service.NewTopUpExpirer(topUpRepo, topUpService, db, cfg.Payment.ExpiryPollInterval, cfg.Payment.ExpiryBatchSize)
+
+	var workers sync.WaitGroup
+	workers.Add(7)
+	go func() {
+		defer workers.Done()
+		broker.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		outboxRelay.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		auditChainer.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		webhookDispatcher.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		transferScheduler.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		payoutProcessor.Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		topUpExpirer.Run(ctx)
+	}()
+
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Server.Port)
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:    ":" + cfg.Server.Port,
+		Handler: router,
+	}
+	go func() {
+		log.Printf("Server starting on port %s", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	workers.Wait()
+}
+
+// newRateProvider builds the exchange rate provider selected in the
+// configuration.
+func newRateProvider(cfg *config.FXConfig) (fx.RateProvider, error) {
+	switch cfg.Provider {
+	case "file":
+		return fx.NewFileProvider(cfg.RatesFile)
+	case "static", "":
+		return fx.NewStaticProvider(cfg.Rates)
+	default:
+		return nil, fmt.Errorf("unknown FX provider %q", cfg.Provider)
+	}
+}
+
+// newPayoutProvider builds the payout provider selected in the
+// configuration.
+func newPayoutProvider(cfg *config.PayoutConfig) (bank.PayoutProvider, error) {
+	switch cfg.Provider {
+	case "fake", "":
+		return bank.NewFakeProvider(cfg.FakeCallbackURL, cfg.CallbackSecret, cfg.FakeDelay, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown payout provider %q", cfg.Provider)
+	}
+}
+
+// newPaymentGateway builds the payment gateway selected in the
+// configuration.
+func newPaymentGateway(cfg *config.PaymentConfig) (payment.PaymentGateway, error) {
+	switch cfg.Gateway {
+	case "http":
+		return payment.NewHTTPGateway(cfg.GatewayURL, cfg.GatewayAPIKey, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown payment gateway %q", cfg.Gateway)
 	}
 }
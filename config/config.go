@@ -1,18 +1,29 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	JWT       JWTConfig
+	KYC       KYCConfig
+	Outbox    OutboxConfig
+	Audit     AuditConfig
+	Webhook   WebhookConfig
+	Scheduler SchedulerConfig
+	FX        FXConfig
+	Payout    PayoutConfig
+	Payment   PaymentConfig
 }
 
 type ServerConfig struct {
@@ -29,8 +40,86 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	Secret string
-	Expiry time.Duration
+	Secret        string
+	Expiry        time.Duration
+	RefreshExpiry time.Duration
+}
+
+type KYCConfig struct {
+	UploadDir string
+}
+
+// OutboxConfig controls the relay that delivers domain events. An empty
+// HTTPSinkURL disables the HTTP sink.
+type OutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	LogSink      bool
+	HTTPSinkURL  string
+}
+
+// AuditConfig controls the chainer that appends recorded audit events to
+// the hash chain.
+type AuditConfig struct {
+	ChainPollInterval time.Duration
+	ChainBatchSize    int
+}
+
+// WebhookConfig controls the dispatcher that sends webhook deliveries.
+// Endpoints on loopback addresses are refused unless AllowLoopback is set,
+// which is meant for local testing only.
+type WebhookConfig struct {
+	MaxAttempts   int
+	PollInterval  time.Duration
+	BatchSize     int
+	Timeout       time.Duration
+	AllowLoopback bool
+}
+
+// SchedulerConfig controls the scheduler that makes scheduled transfers.
+// MaxFailures is how many failed attempts skip an occurrence.
+type SchedulerConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxFailures  int
+}
+
+// FXConfig selects the exchange rate provider. The static provider reads
+// Rates, such as "USD/IDR=16250,SGD/IDR=12100"; the file provider reads
+// RatesFile. QuoteTTL is how long a quoted rate stays locked.
+type FXConfig struct {
+	Provider  string
+	Rates     string
+	RatesFile string
+	QuoteTTL  time.Duration
+}
+
+// PayoutConfig selects the payout provider that pays withdrawals out and
+// controls the processor that submits them. CallbackSecret verifies the
+// provider's result callbacks. The fake provider reports its results to
+// FakeCallbackURL after FakeDelay.
+type PayoutConfig struct {
+	Provider        string
+	CallbackSecret  string
+	MaxAttempts     int
+	PollInterval    time.Duration
+	BatchSize       int
+	FakeCallbackURL string
+	FakeDelay       time.Duration
+}
+
+// PaymentConfig selects the payment gateway that collects top-ups and
+// controls the expirer of unpaid ones. The http gateway calls the API at
+// GatewayURL with GatewayAPIKey. CallbackSecret verifies the gateway's
+// result callbacks. Top-ups must be paid within TopUpTTL.
+type PaymentConfig struct {
+	Gateway            string
+	GatewayURL         string
+	GatewayAPIKey      string
+	CallbackSecret     string
+	TopUpTTL           time.Duration
+	ExpiryPollInterval time.Duration
+	ExpiryBatchSize    int
 }
 
 func Load() (*Config, error) {
@@ -39,9 +128,109 @@ func Load() (*Config, error) {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	jwtExpiry, err := time.ParseDuration(getEnv("JWT_EXPIRY", "24h"))
+	jwtExpiry, err := time.ParseDuration(getEnv("JWT_EXPIRY", "15m"))
+	if err != nil {
+		jwtExpiry = 15 * time.Minute
+	}
+
+	refreshExpiry, err := time.ParseDuration(getEnv("JWT_REFRESH_EXPIRY", "720h"))
+	if err != nil {
+		refreshExpiry = 720 * time.Hour
+	}
+
+	outboxPollInterval, err := time.ParseDuration(getEnv("OUTBOX_POLL_INTERVAL", "1s"))
+	if err != nil {
+		outboxPollInterval = time.Second
+	}
+
+	outboxBatchSize, err := strconv.Atoi(getEnv("OUTBOX_BATCH_SIZE", "100"))
+	if err != nil {
+		outboxBatchSize = 100
+	}
+
+	auditChainPollInterval, err := time.ParseDuration(getEnv("AUDIT_CHAIN_POLL_INTERVAL", "1s"))
+	if err != nil {
+		auditChainPollInterval = time.Second
+	}
+
+	auditChainBatchSize, err := strconv.Atoi(getEnv("AUDIT_CHAIN_BATCH_SIZE", "500"))
+	if err != nil {
+		auditChainBatchSize = 500
+	}
+
+	webhookMaxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", "6"))
+	if err != nil {
+		webhookMaxAttempts = 6
+	}
+
+	webhookPollInterval, err := time.ParseDuration(getEnv("WEBHOOK_POLL_INTERVAL", "1s"))
+	if err != nil {
+		webhookPollInterval = time.Second
+	}
+
+	webhookBatchSize, err := strconv.Atoi(getEnv("WEBHOOK_BATCH_SIZE", "50"))
+	if err != nil {
+		webhookBatchSize = 50
+	}
+
+	webhookTimeout, err := time.ParseDuration(getEnv("WEBHOOK_TIMEOUT", "10s"))
+	if err != nil {
+		webhookTimeout = 10 * time.Second
+	}
+
+	schedulerPollInterval, err := time.ParseDuration(getEnv("SCHEDULER_POLL_INTERVAL", "10s"))
 	if err != nil {
-		jwtExpiry = 24 * time.Hour
+		schedulerPollInterval = 10 * time.Second
+	}
+
+	schedulerBatchSize, err := strconv.Atoi(getEnv("SCHEDULER_BATCH_SIZE", "20"))
+	if err != nil {
+		schedulerBatchSize = 20
+	}
+
+	schedulerMaxFailures, err := strconv.Atoi(getEnv("SCHEDULER_MAX_FAILURES", "3"))
+	if err != nil {
+		schedulerMaxFailures = 3
+	}
+
+	fxQuoteTTL, err := time.ParseDuration(getEnv("FX_QUOTE_TTL", "30s"))
+	if err != nil {
+		fxQuoteTTL = 30 * time.Second
+	}
+
+	payoutMaxAttempts, err := strconv.Atoi(getEnv("PAYOUT_MAX_ATTEMPTS", "5"))
+	if err != nil {
+		payoutMaxAttempts = 5
+	}
+
+	payoutPollInterval, err := time.ParseDuration(getEnv("PAYOUT_POLL_INTERVAL", "5s"))
+	if err != nil {
+		payoutPollInterval = 5 * time.Second
+	}
+
+	payoutBatchSize, err := strconv.Atoi(getEnv("PAYOUT_BATCH_SIZE", "20"))
+	if err != nil {
+		payoutBatchSize = 20
+	}
+
+	payoutFakeDelay, err := time.ParseDuration(getEnv("PAYOUT_FAKE_DELAY", "5s"))
+	if err != nil {
+		payoutFakeDelay = 5 * time.Second
+	}
+
+	paymentTopUpTTL, err := time.ParseDuration(getEnv("PAYMENT_TOPUP_TTL", "24h"))
+	if err != nil {
+		paymentTopUpTTL = 24 * time.Hour
+	}
+
+	paymentExpiryPollInterval, err := time.ParseDuration(getEnv("PAYMENT_EXPIRY_POLL_INTERVAL", "1m"))
+	if err != nil {
+		paymentExpiryPollInterval = time.Minute
+	}
+
+	paymentExpiryBatchSize, err := strconv.Atoi(getEnv("PAYMENT_EXPIRY_BATCH_SIZE", "50"))
+	if err != nil {
+		paymentExpiryBatchSize = 50
 	}
 
 	config := &Config{
@@ -57,14 +246,95 @@ func Load() (*Config, error) {
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-super-secret-key"),
-			Expiry: jwtExpiry,
+			Secret:        getEnv("JWT_SECRET", "your-super-secret-key"),
+			Expiry:        jwtExpiry,
+			RefreshExpiry: refreshExpiry,
+		},
+		KYC: KYCConfig{
+			UploadDir: getEnv("KYC_UPLOAD_DIR", "uploads/kyc"),
+		},
+		Outbox: OutboxConfig{
+			PollInterval: outboxPollInterval,
+			BatchSize:    outboxBatchSize,
+			LogSink:      getEnv("OUTBOX_LOG_SINK", "true") == "true",
+			HTTPSinkURL:  getEnv("OUTBOX_HTTP_SINK_URL", ""),
+		},
+		Audit: AuditConfig{
+			ChainPollInterval: auditChainPollInterval,
+			ChainBatchSize:    auditChainBatchSize,
+		},
+		Webhook: WebhookConfig{
+			MaxAttempts:   webhookMaxAttempts,
+			PollInterval:  webhookPollInterval,
+			BatchSize:     webhookBatchSize,
+			Timeout:       webhookTimeout,
+			AllowLoopback: getEnv("WEBHOOK_ALLOW_LOOPBACK", "false") == "true",
+		},
+		Scheduler: SchedulerConfig{
+			PollInterval: schedulerPollInterval,
+			BatchSize:    schedulerBatchSize,
+			MaxFailures:  schedulerMaxFailures,
+		},
+		FX: FXConfig{
+			Provider:  getEnv("FX_PROVIDER", "static"),
+			Rates:     getEnv("FX_RATES", ""),
+			RatesFile: getEnv("FX_RATES_FILE", "config/fx_rates.json"),
+			QuoteTTL:  fxQuoteTTL,
+		},
+		Payout: PayoutConfig{
+			Provider:        getEnv("PAYOUT_PROVIDER", "fake"),
+			CallbackSecret:  getEnv("PAYOUT_CALLBACK_SECRET", ""),
+			MaxAttempts:     payoutMaxAttempts,
+			PollInterval:    payoutPollInterval,
+			BatchSize:       payoutBatchSize,
+			FakeCallbackURL: getEnv("PAYOUT_FAKE_CALLBACK_URL", "http://localhost:8080/api/payouts/callback"),
+			FakeDelay:       payoutFakeDelay,
+		},
+		Payment: PaymentConfig{
+			Gateway:            getEnv("PAYMENT_GATEWAY", "http"),
+			GatewayURL:         getEnv("PAYMENT_GATEWAY_URL", ""),
+			GatewayAPIKey:      getEnv("PAYMENT_GATEWAY_API_KEY", ""),
+			CallbackSecret:     getEnv("PAYMENT_CALLBACK_SECRET", ""),
+			TopUpTTL:           paymentTopUpTTL,
+			ExpiryPollInterval: paymentExpiryPollInterval,
+			ExpiryBatchSize:    paymentExpiryBatchSize,
 		},
 	}
 
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// validate rejects settings the server must not run with.
+func (c *Config) validate() error {
+	// Anyone who knows a callback secret can report payouts and payments,
+	// and the gateway API key opens payments in our name
+	if err := checkSecret("PAYOUT_CALLBACK_SECRET", c.Payout.CallbackSecret); err != nil {
+		return err
+	}
+	if err := checkSecret("PAYMENT_CALLBACK_SECRET", c.Payment.CallbackSecret); err != nil {
+		return err
+	}
+	if err := checkSecret("PAYMENT_GATEWAY_API_KEY", c.Payment.GatewayAPIKey); err != nil {
+		return err
+	}
+	if c.Payment.GatewayURL == "" {
+		return errors.New("PAYMENT_GATEWAY_URL is not set")
+	}
+	return nil
+}
+
+// checkSecret rejects an empty secret or a your-...-secret placeholder.
+func checkSecret(key, value string) error {
+	if value == "" || (strings.HasPrefix(value, "your-") && strings.HasSuffix(value, "-secret")) {
+		return fmt.Errorf("%s must be set to a real secret", key)
+	}
+	return nil
+}
+
 func (c *DatabaseConfig) ConnectionString() string {
 	return fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
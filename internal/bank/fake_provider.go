@@ -0,0 +1,133 @@
+package bank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"ewallet/pkg/utils"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultFakeCallbackTimeout = 10 * time.Second
+
+// FakeProvider is a deterministic payout provider for local development
+// and testing. It never moves real money. The account number decides the
+// outcome:
+//   - ending in "0000": rejected when submitted
+//   - ending in "9999": accepted, then reported as failed
+//   - anything else: accepted, then reported as successful
+//
+// Accepted payouts are reported by POSTing a signed PayoutResult to the
+// callback URL after the delay, once; a callback that fails is only logged.
+// Payouts are remembered in memory for Lookup until the process exits.
+type FakeProvider struct {
+	callbackURL string
+	secret      string
+	delay       time.Duration
+	client      *http.Client
+
+	mu      sync.Mutex
+	payouts map[uuid.UUID]PayoutResult
+}
+
+// NewFakeProvider creates a fake that reports to callbackURL, signing with
+// secret. A nil client gets a default client with a short timeout.
+func NewFakeProvider(callbackURL, secret string, delay time.Duration, client *http.Client) *FakeProvider {
+	if client == nil {
+		client = &http.Client{Timeout: defaultFakeCallbackTimeout}
+	}
+	return &FakeProvider{
+		callbackURL: callbackURL,
+		secret:      secret,
+		delay:       delay,
+		client:      client,
+		payouts:     make(map[uuid.UUID]PayoutResult),
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Submit(ctx context.Context, payout Payout) (string, error) {
+	if strings.HasSuffix(payout.AccountNumber, "0000") {
+		return "", fmt.Errorf("%w: account %s does not exist", ErrPayoutRejected, payout.AccountNumber)
+	}
+
+	// The same withdrawal always gets the same reference
+	reference := "FAKE-" + strings.ToUpper(strings.ReplaceAll(payout.ID.String(), "-", "")[:16])
+	result := PayoutResult{
+		WithdrawalID: payout.ID,
+		Reference:    reference,
+		Status:       PayoutSucceeded,
+	}
+	if strings.HasSuffix(payout.AccountNumber, "9999") {
+		result.Status = PayoutFailed
+		result.FailureReason = "account closed"
+	}
+
+	// A payout submitted again is not paid or reported twice
+	p.mu.Lock()
+	if _, ok := p.payouts[payout.ID]; ok {
+		p.mu.Unlock()
+		return reference, nil
+	}
+	p.payouts[payout.ID] = PayoutResult{WithdrawalID: payout.ID, Reference: reference, Status: PayoutPending}
+	p.mu.Unlock()
+
+	time.AfterFunc(p.delay, func() {
+		p.mu.Lock()
+		p.payouts[payout.ID] = result
+		p.mu.Unlock()
+		if err := p.sendResult(result); err != nil {
+			log.Printf("fake payout provider: callback for %s: %v", payout.ID, err)
+		}
+	})
+	return reference, nil
+}
+
+func (p *FakeProvider) Lookup(ctx context.Context, id uuid.UUID) (PayoutResult, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result, ok := p.payouts[id]
+	if !ok {
+		return PayoutResult{}, ErrPayoutNotFound
+	}
+	return result, nil
+}
+
+// sendResult POSTs the signed result to the callback URL.
+func (p *FakeProvider) sendResult(result PayoutResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, utils.SignPayload(p.secret, timestamp, body))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status %d", p.callbackURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package bank sends withdrawals to users' bank accounts through payout
+// providers.
+package bank
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrPayoutRejected is returned, wrapped with the provider's reason, when a
+// provider refuses a payout for good. Other errors from Submit are treated
+// as temporary and the payout is submitted again later.
+var ErrPayoutRejected = errors.New("payout rejected")
+
+// ErrPayoutNotFound is returned by Lookup when the provider never accepted
+// a payout with the ID.
+var ErrPayoutNotFound = errors.New("payout not found")
+
+// Headers of a payout result callback. The signature is made with
+// utils.SignPayload and the shared callback secret.
+const (
+	HeaderTimestamp = "X-Payout-Timestamp"
+	HeaderSignature = "X-Payout-Signature"
+)
+
+// Payout is a request to pay Amount into a bank account. ID identifies the
+// withdrawal and doubles as the idempotency key: submitting the same ID
+// again must not pay twice.
+type Payout struct {
+	ID            uuid.UUID
+	Amount        models.Money
+	Currency      models.Currency
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+}
+
+// PayoutProvider pays money out to bank accounts. Submit only hands the
+// payout over and returns the provider's reference for it; the outcome
+// arrives later as a PayoutResult sent to the payout callback endpoint.
+// Lookup asks the provider for the payout with the given ID, for when it is
+// unknown whether a submission reached the provider: it returns the payout's
+// reference and status, PayoutPending while it is in progress.
+type PayoutProvider interface {
+	Name() string
+	Submit(ctx context.Context, payout Payout) (string, error)
+	Lookup(ctx context.Context, id uuid.UUID) (PayoutResult, error)
+}
+
+// PayoutStatus is the outcome of a payout. Callbacks only report the final
+// ones; PayoutPending is returned by Lookup.
+type PayoutStatus string
+
+const (
+	PayoutPending   PayoutStatus = "pending"
+	PayoutSucceeded PayoutStatus = "success"
+	PayoutFailed    PayoutStatus = "failed"
+)
+
+// PayoutResult is the body of a payout result callback. FailureReason is the
+// provider's explanation of a failed payout.
+type PayoutResult struct {
+	WithdrawalID  uuid.UUID    `json:"withdrawal_id" binding:"required"`
+	Reference     string       `json:"reference"`
+	Status        PayoutStatus `json:"status" binding:"required,oneof=success failed"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+}
@@ -0,0 +1,62 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"ewallet/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const defaultHTTPSinkTimeout = 10 * time.Second
+
+// HTTPSink POSTs every event as JSON to a fixed URL. Any 2xx response
+// counts as delivered.
+type HTTPSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSink creates a sink for url. A nil client gets a default client
+// with a short timeout.
+func NewHTTPSink(url string, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = &http.Client{Timeout: defaultHTTPSinkTimeout}
+	}
+	return &HTTPSink{url: url, client: client}
+}
+
+func (s *HTTPSink) Name() string {
+	return "http"
+}
+
+func (s *HTTPSink) Publish(ctx context.Context, event models.EventEnvelope) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", event.ID.String())
+	req.Header.Set("X-Event-Type", event.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status %d", s.url, resp.StatusCode)
+	}
+	return nil
+}
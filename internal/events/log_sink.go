@@ -0,0 +1,23 @@
+package events
+
+import (
+	"context"
+	"ewallet/internal/models"
+	"log"
+)
+
+// LogSink writes every event to the application log.
+type LogSink struct{}
+
+func NewLogSink() *LogSink {
+	return &LogSink{}
+}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Publish(ctx context.Context, event models.EventEnvelope) error {
+	log.Printf("event %s %s %s/%s: %s", event.ID, event.Type, event.AggregateType, event.AggregateID, event.Data)
+	return nil
+}
@@ -0,0 +1,17 @@
+// Package events delivers domain events relayed from the outbox to the
+// systems that consume them.
+package events
+
+import (
+	"context"
+	"ewallet/internal/models"
+)
+
+// Sink is a destination for domain events. Delivery is at-least-once: an
+// event may reach a sink more than once, for example when another sink
+// failed and the event is retried, so consumers should deduplicate on the
+// event ID.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event models.EventEnvelope) error
+}
@@ -0,0 +1,95 @@
+package fx
+
+import (
+	"encoding/json"
+	"ewallet/internal/models"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileProvider serves rates from a JSON file that maps pairs to rates, for
+// example {"USD/IDR": 16250, "SGD/IDR": "12100.5"}. The file is read again
+// whenever its modification time changes, so rates can be updated without
+// a restart. If a changed file cannot be read, the last good rates stay in
+// use.
+type FileProvider struct {
+	path string
+
+	mu      sync.Mutex
+	rates   *Rates
+	modTime time.Time
+}
+
+// NewFileProvider creates a provider for the file at path, which must exist
+// and hold valid rates.
+func NewFileProvider(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileProvider) Name() string {
+	return "file"
+}
+
+func (p *FileProvider) Rate(from, to models.Currency) (models.Rate, error) {
+	return p.current().Rate(from, to)
+}
+
+func (p *FileProvider) Currencies() []models.Currency {
+	return p.current().Currencies()
+}
+
+// current returns the rates, reloading the file first if it changed.
+func (p *FileProvider) current() *Rates {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		log.Printf("fx: cannot stat rates file %s, keeping previous rates: %v", p.path, err)
+		return p.rates
+	}
+	if !info.ModTime().Equal(p.modTime) {
+		if err := p.loadLocked(); err != nil {
+			// Do not retry until the file changes again
+			p.modTime = info.ModTime()
+			log.Printf("fx: cannot reload rates file %s, keeping previous rates: %v", p.path, err)
+		}
+	}
+	return p.rates
+}
+
+func (p *FileProvider) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loadLocked()
+}
+
+func (p *FileProvider) loadLocked() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]models.Rate
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	rates, err := NewRates(raw)
+	if err != nil {
+		return err
+	}
+
+	p.rates = rates
+	p.modTime = info.ModTime()
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package fx provides the exchange rates used to convert money between
+// wallet currencies.
+package fx
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// ErrUnsupportedPair is returned for a pair of currencies the provider has
+// no rate for.
+var ErrUnsupportedPair = errors.New("unsupported currency pair")
+
+// roundTripLimit is a rate of 1 times a rate of 1, in Rate units squared.
+var roundTripLimit = new(big.Int).Mul(big.NewInt(models.RateScale), big.NewInt(models.RateScale))
+
+// RateProvider quotes mid-market exchange rates. A rate from a currency to
+// itself is always 1, and every supported currency can be converted into
+// every other one.
+type RateProvider interface {
+	Name() string
+	Rate(from, to models.Currency) (models.Rate, error)
+	Currencies() []models.Currency
+}
+
+type pair struct {
+	from models.Currency
+	to   models.Currency
+}
+
+// Rates is a table of exchange rates. Rates missing from the table are
+// derived from the opposite direction or, failing that, by crossing through
+// models.DefaultCurrency.
+type Rates struct {
+	rates map[pair]models.Rate
+}
+
+// ParseRates parses a comma-separated list of rates such as
+// "USD/IDR=16250,SGD/IDR=12100".
+func ParseRates(spec string) (*Rates, error) {
+	rates := make(map[string]models.Rate)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rate %q: want FROM/TO=RATE", item)
+		}
+		rate, err := models.ParseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate %q: %w", item, err)
+		}
+		rates[strings.TrimSpace(name)] = rate
+	}
+	return NewRates(rates)
+}
+
+// NewRates builds a table from rates keyed by pair, such as "USD/IDR".
+func NewRates(rates map[string]models.Rate) (*Rates, error) {
+	table := &Rates{rates: make(map[pair]models.Rate, len(rates))}
+	for name, rate := range rates {
+		fromCode, toCode, ok := strings.Cut(name, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid currency pair %q: want FROM/TO", name)
+		}
+		from, err := models.ParseCurrency(fromCode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid currency pair %q: %w", name, err)
+		}
+		to, err := models.ParseCurrency(toCode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid currency pair %q: %w", name, err)
+		}
+		if from == to {
+			return nil, fmt.Errorf("invalid currency pair %q: currencies must differ", name)
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid currency pair %q: %w", name, models.ErrInvalidRate)
+		}
+		table.rates[pair{from, to}] = rate
+	}
+
+	// Converting there and back at rates that multiply to more than 1 would
+	// create money
+	for p, rate := range table.rates {
+		opposite, ok := table.rates[pair{p.to, p.from}]
+		if ok && new(big.Int).Mul(big.NewInt(int64(rate)), big.NewInt(int64(opposite))).Cmp(roundTripLimit) > 0 {
+			return nil, fmt.Errorf("rates %s/%s and %s/%s multiply to more than 1", p.from, p.to, p.to, p.from)
+		}
+	}
+	return table, nil
+}
+
+// Rate returns the rate from one currency to another.
+func (t *Rates) Rate(from, to models.Currency) (models.Rate, error) {
+	if from == to {
+		return models.RateScale, nil
+	}
+	if rate, ok := t.direct(from, to); ok {
+		return rate, nil
+	}
+
+	base := models.DefaultCurrency
+	if from != base && to != base {
+		first, ok := t.direct(from, base)
+		if ok {
+			second, ok := t.direct(base, to)
+			if ok {
+				if rate := first.Mul(second); rate > 0 {
+					return rate, nil
+				}
+			}
+		}
+	}
+	return 0, fmt.Errorf("%w: %s/%s", ErrUnsupportedPair, from, to)
+}
+
+func (t *Rates) direct(from, to models.Currency) (models.Rate, bool) {
+	if rate, ok := t.rates[pair{from, to}]; ok {
+		return rate, true
+	}
+	if rate, ok := t.rates[pair{to, from}]; ok {
+		if inverse := rate.Inverse(); inverse > 0 {
+			return inverse, true
+		}
+	}
+	return 0, false
+}
+
+// Currencies returns models.DefaultCurrency and every currency that has a
+// rate to it, sorted by code.
+func (t *Rates) Currencies() []models.Currency {
+	currencies := []models.Currency{models.DefaultCurrency}
+	seen := map[models.Currency]bool{models.DefaultCurrency: true}
+	for p := range t.rates {
+		for _, c := range []models.Currency{p.from, p.to} {
+			if seen[c] {
+				continue
+			}
+			if _, err := t.Rate(c, models.DefaultCurrency); err != nil {
+				continue
+			}
+			seen[c] = true
+			currencies = append(currencies, c)
+		}
+	}
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+	return currencies
+}
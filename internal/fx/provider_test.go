@@ -0,0 +1,37 @@
+package fx
+
+import (
+	"ewallet/internal/models"
+	"testing"
+)
+
+func TestNewRatesRejectsProfitableRoundTrip(t *testing.T) {
+	if _, err := ParseRates("USD/IDR=16250,IDR/USD=0.00007"); err == nil {
+		t.Fatal("ParseRates() succeeded for rates that multiply to more than 1")
+	}
+	if _, err := ParseRates("USD/IDR=16250,IDR/USD=0.00006"); err != nil {
+		t.Fatalf("ParseRates() = %v", err)
+	}
+}
+
+func TestRatesDerivedRatesNeverExceedTheTrueRate(t *testing.T) {
+	rates, err := ParseRates("USD/IDR=16250,SGD/IDR=12100")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pairs := [][2]models.Currency{{"USD", "SGD"}, {"SGD", "USD"}, {"IDR", "USD"}, {"USD", "IDR"}}
+	for _, p := range pairs {
+		there, err := rates.Rate(p[0], p[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := rates.Rate(p[1], p[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if product := there.Mul(back); product > models.RateScale {
+			t.Errorf("%s/%s rates multiply to %s", p[0], p[1], product)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package fx
+
+import "ewallet/internal/models"
+
+// StaticProvider serves a fixed table of rates, typically from
+// configuration.
+type StaticProvider struct {
+	rates *Rates
+}
+
+// NewStaticProvider creates a provider from a rate list such as
+// "USD/IDR=16250,SGD/IDR=12100". An empty list supports only
+// models.DefaultCurrency.
+func NewStaticProvider(spec string) (*StaticProvider, error) {
+	rates, err := ParseRates(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticProvider{rates: rates}, nil
+}
+
+func (p *StaticProvider) Name() string {
+	return "static"
+}
+
+func (p *StaticProvider) Rate(from, to models.Currency) (models.Rate, error) {
+	return p.rates.Rate(from, to)
+}
+
+func (p *StaticProvider) Currencies() []models.Currency {
+	return p.rates.Currencies()
+}
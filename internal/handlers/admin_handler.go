@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	adminService service.AdminService
+}
+
+func NewAdminHandler(adminService service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+type UpdateUserStatusRequest struct {
+	Status string `json:"status" binding:"required" example:"suspended"`
+	Reason string `json:"reason" example:"Suspected account takeover"`
+}
+
+type UpdateWalletStatusRequest struct {
+	Status string `json:"status" binding:"required" example:"frozen_debit"`
+	Reason string `json:"reason" binding:"required" example:"Fraud reported by customer"`
+}
+
+type ReverseTransactionRequest struct {
+	Amount *models.Money `json:"amount" binding:"omitempty,gt=0" swaggertype:"number" example:"25000"`
+	Reason string        `json:"reason" binding:"required" example:"Transfer sent to the wrong account"`
+}
+
+// SearchUsers godoc
+// @Summary Search users
+// @Description Find users by ID, or by a partial name or email. Requires the support, admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "User ID, name or email"
+// @Param limit query int false "Maximum results (max 100)" default(20)
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/admin/users [get]
+func (h *AdminHandler) SearchUsers(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultUserSearchLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultUserSearchLimit
+	}
+
+	users, err := h.adminService.SearchUsers(c.Query("q"), limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search users", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Users retrieved successfully", users)
+}
+
+// GetUser godoc
+// @Summary Get user
+// @Description Get a user's account, role, status, KYC tier and wallet. Requires the support, admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/users/{id} [get]
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	user, err := h.adminService.GetUser(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User retrieved successfully", user)
+}
+
+// InspectWallet godoc
+// @Summary Inspect user wallet
+// @Description Get one of a user's wallets, the primary one unless wallet_id is given, with the balance derived from the ledger, all of the user's wallets and the user's limits and usage. Requires the support, admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param wallet_id query string false "Wallet ID (defaults to the primary wallet)"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/users/{id}/wallet [get]
+func (h *AdminHandler) InspectWallet(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	walletID, ok := walletIDQuery(c)
+	if !ok {
+		return
+	}
+
+	inspection, err := h.adminService.InspectWallet(userID, walletID)
+	if err != nil {
+		if errors.Is(err, service.ErrWalletNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to inspect wallet", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet retrieved successfully", inspection)
+}
+
+// UpdateUserStatus godoc
+// @Summary Change account status
+// @Description Suspend or reactivate an account. Suspending revokes all of the user's sessions. Requires the admin role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param request body UpdateUserStatusRequest true "New status"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/users/{id}/status [put]
+func (h *AdminHandler) UpdateUserStatus(c *gin.Context) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	var req UpdateUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	user, err := h.adminService.SetUserStatus(middleware.GetRequestMeta(c), actorID, userID, models.UserStatus(req.Status), req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUserNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Failed to change account status", err)
+		case errors.Is(err, service.ErrInvalidUserStatus), errors.Is(err, service.ErrOwnStatusChange):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to change account status", err)
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to change account status", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Account status changed successfully", user)
+}
+
+// UpdateWalletStatus godoc
+// @Summary Change wallet status
+// @Description Freeze, unfreeze or close one of a user's wallets, the primary one unless wallet_id is given. frozen_debit blocks outgoing money, frozen_all blocks all movements, closed is final and needs a zero balance. Requires the support or admin role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param wallet_id query string false "Wallet ID (defaults to the primary wallet)"
+// @Param request body UpdateWalletStatusRequest true "New status"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/admin/users/{id}/wallet/status [put]
+func (h *AdminHandler) UpdateWalletStatus(c *gin.Context) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	walletID, ok := walletIDQuery(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateWalletStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	wallet, err := h.adminService.SetWalletStatus(middleware.GetRequestMeta(c), actorID, userID, walletID, models.WalletStatus(req.Status), req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrWalletNotFound):
+			utils.ErrorResponse(c, http.StatusNotFound, "Failed to change wallet status", err)
+		case errors.Is(err, service.ErrWalletClosed), errors.Is(err, service.ErrWalletNotEmpty):
+			utils.ErrorResponse(c, http.StatusConflict, "Failed to change wallet status", err)
+		case errors.Is(err, service.ErrInvalidWalletStatus), errors.Is(err, service.ErrWalletStatusReason):
+			utils.ErrorResponse(c, http.StatusBadRequest, "Failed to change wallet status", err)
+		default:
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to change wallet status", err)
+		}
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet status changed successfully", wallet)
+}
+
+// GetWalletStatusHistory godoc
+// @Summary Get wallet status history
+// @Description List every status change of one of a user's wallets, the primary one unless wallet_id is given, with its reason and actor, newest first. Requires the support, admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param wallet_id query string false "Wallet ID (defaults to the primary wallet)"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/users/{id}/wallet/status-history [get]
+func (h *AdminHandler) GetWalletStatusHistory(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid user ID", err)
+		return
+	}
+
+	walletID, ok := walletIDQuery(c)
+	if !ok {
+		return
+	}
+
+	changes, err := h.adminService.WalletStatusHistory(userID, walletID)
+	if err != nil {
+		if errors.Is(err, service.ErrWalletNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve wallet status history", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet status history retrieved successfully", changes)
+}
+
+// SearchTransactions godoc
+// @Summary Search all transactions
+// @Description Search transactions across all users, newest first, using cursor pagination. direction and counterparty_id need user_id. Requires the support, admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string false "Only transactions of this user"
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param cursor query string false "Cursor from the previous page's next_cursor"
+// @Param type query string false "Transaction type" Enums(topup, transfer, reversal, refund, move)
+// @Param status query string false "Transaction status" Enums(pending, success, failed)
+// @Param direction query string false "Direction relative to user_id" Enums(incoming, outgoing)
+// @Param from query string false "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Created before (RFC3339), or on or before (YYYY-MM-DD)"
+// @Param min_amount query number false "Minimum amount"
+// @Param max_amount query number false "Maximum amount"
+// @Param counterparty_id query string false "Other user's ID, relative to user_id"
+// @Param wallet_id query string false "Only transactions that moved money in or out of this wallet"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/admin/transactions [get]
+func (h *AdminHandler) SearchTransactions(c *gin.Context) {
+	userID := uuid.Nil
+	if v := c.Query("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid user_id: %w", err))
+			return
+		}
+		userID = id
+	}
+
+	query, err := parseHistoryQuery(c, userID)
+	if err == nil && userID == uuid.Nil && (query.Direction != nil || query.CounterpartyID != nil) {
+		err = errors.New("direction and counterparty_id require user_id")
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", err)
+		return
+	}
+
+	page, err := h.adminService.SearchTransactions(query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to search transactions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transactions retrieved successfully", page)
+}
+
+// ReverseTransaction godoc
+// @Summary Reverse a transfer
+// @Description Move all or part of a successful transfer back from its receiver to its sender as a new linked reversal transaction. Without an amount, whatever has not been reversed or refunded yet is moved back. Frozen wallets do not block a reversal. Send an Idempotency-Key header to make retries safe. Requires the admin role.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID of the transfer"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Param request body ReverseTransactionRequest true "Reversal"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/admin/transactions/{id}/reverse [post]
+func (h *AdminHandler) ReverseTransaction(c *gin.Context) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid transaction ID", err)
+		return
+	}
+
+	var req ReverseTransactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	transaction, err := h.adminService.ReverseTransaction(middleware.GetRequestMeta(c), actorID, transactionID, req.Amount, req.Reason)
+	if err != nil {
+		utils.ErrorResponse(c, reversalErrorStatus(err), "Failed to reverse transaction", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transaction reversed successfully", transaction.ToResponse())
+}
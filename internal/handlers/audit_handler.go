@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"ewallet/internal/repository"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AuditHandler struct {
+	auditService service.AuditService
+}
+
+func NewAuditHandler(auditService service.AuditService) *AuditHandler {
+	return &AuditHandler{auditService: auditService}
+}
+
+// ListEvents godoc
+// @Summary Search audit events
+// @Description Search the audit log, newest first, using cursor pagination. Requires the admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param actor_id query string false "User who performed the action"
+// @Param action query string false "Action, e.g. auth.login_failed"
+// @Param target_type query string false "Target type" Enums(user, session, wallet, transaction, kyc_submission)
+// @Param target_id query string false "Target ID"
+// @Param request_id query string false "Request ID"
+// @Param from query string false "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Created before (RFC3339), or on or before (YYYY-MM-DD)"
+// @Param limit query int false "Page size (max 200)" default(50)
+// @Param cursor query string false "Cursor from the previous page's next_cursor"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/admin/audit-events [get]
+func (h *AuditHandler) ListEvents(c *gin.Context) {
+	query, err := parseAuditQuery(c)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", err)
+		return
+	}
+
+	page, err := h.auditService.Search(query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve audit events", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Audit events retrieved successfully", page)
+}
+
+// VerifyChain godoc
+// @Summary Verify audit log integrity
+// @Description Recompute the hash chain of the whole audit log. valid is false and broken_at names the first bad event if any event was changed, removed or inserted. Requires the admin or auditor role.
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/admin/audit-events/verify [get]
+func (h *AuditHandler) VerifyChain(c *gin.Context) {
+	result, err := h.auditService.Verify()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to verify audit log", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Audit log verified", result)
+}
+
+// parseAuditQuery reads the audit search filters from the query string.
+func parseAuditQuery(c *gin.Context) (repository.AuditQuery, error) {
+	query := repository.AuditQuery{}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultAuditLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultAuditLimit
+	}
+	query.Limit = limit
+
+	if v := c.Query("cursor"); v != "" {
+		sequence, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || sequence <= 0 {
+			return query, fmt.Errorf("invalid cursor %q", v)
+		}
+		query.BeforeSequence = &sequence
+	}
+
+	if v := c.Query("actor_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid actor_id: %w", err)
+		}
+		query.ActorID = &id
+	}
+
+	for name, field := range map[string]**string{
+		"action":      &query.Action,
+		"target_type": &query.TargetType,
+		"target_id":   &query.TargetID,
+		"request_id":  &query.RequestID,
+	} {
+		if v := c.Query(name); v != "" {
+			*field = &v
+		}
+	}
+
+	if v := c.Query("from"); v != "" {
+		from, _, err := parseHistoryTime(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid from: %w", err)
+		}
+		query.From = &from
+	}
+
+	if v := c.Query("to"); v != "" {
+		to, dateOnly, err := parseHistoryTime(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid to: %w", err)
+		}
+		if dateOnly {
+			// A plain date includes the whole day
+			to = to.AddDate(0, 0, 1)
+		}
+		query.To = &to
+	}
+
+	return query, nil
+}
@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"ewallet/internal/middleware"
 	"ewallet/internal/service"
 	"ewallet/pkg/utils"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -28,8 +30,15 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Token string      `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
-	User  interface{} `json:"user"`
+	Token            string      `json:"token" example:"eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9..."`
+	ExpiresAt        time.Time   `json:"expires_at"`
+	RefreshToken     string      `json:"refresh_token" example:"3q2-7wY8cJ1rW0xZ..."`
+	RefreshExpiresAt time.Time   `json:"refresh_expires_at"`
+	User             interface{} `json:"user,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required" example:"3q2-7wY8cJ1rW0xZ..."`
 }
 
 // Register godoc
@@ -49,7 +58,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Register(req.Name, req.Email, req.Password)
+	user, err := h.authService.Register(middleware.GetRequestMeta(c), req.Name, req.Email, req.Password)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Registration failed", err)
 		return
@@ -76,16 +85,76 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, user, err := h.authService.Login(req.Email, req.Password)
+	tokens, user, err := h.authService.Login(middleware.GetRequestMeta(c), req.Email, req.Password)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Login failed", err)
 		return
 	}
 
-	response := LoginResponse{
-		Token: token,
-		User:  user.ToResponse(),
-	}
+	response := newLoginResponse(tokens)
+	response.User = user.ToResponse()
 
 	utils.SuccessResponse(c, http.StatusOK, "Login successful", response)
 }
+
+// Refresh godoc
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes the whole session.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh Request"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(middleware.GetRequestMeta(c), req.RefreshToken)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Refresh failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Token refreshed successfully", newLoginResponse(tokens))
+}
+
+// Logout godoc
+// @Summary Logout user
+// @Description Revoke the current session so its access and refresh tokens stop working
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	sessionID, ok := middleware.GetSessionID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	if err := h.authService.Logout(middleware.GetRequestMeta(c), sessionID); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Logout failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Logout successful", nil)
+}
+
+func newLoginResponse(tokens *service.TokenPair) LoginResponse {
+	return LoginResponse{
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.AccessExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
+	}
+}
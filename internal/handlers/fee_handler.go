@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FeeHandler struct {
+	feeService service.FeeService
+}
+
+func NewFeeHandler(feeService service.FeeService) *FeeHandler {
+	return &FeeHandler{feeService: feeService}
+}
+
+// Preview godoc
+// @Summary Preview a fee
+// @Description Quote the fee on a top-up of, or transfer from, one of the authenticated user's wallets without moving any money. The fee is in the wallet's currency and is paid on top of the amount; total is amount plus fee.
+// @Tags Fees
+// @Produce json
+// @Security BearerAuth
+// @Param type query string true "Transaction type" Enums(topup, transfer)
+// @Param amount query number true "Amount"
+// @Param wallet_id query string false "Wallet ID (default: primary wallet)"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/fees/preview [get]
+func (h *FeeHandler) Preview(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	txType := models.TransactionType(c.Query("type"))
+	amount, err := models.ParseMoney(c.Query("amount"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid amount", err)
+		return
+	}
+	var walletID *uuid.UUID
+	if v := c.Query("wallet_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", err)
+			return
+		}
+		walletID = &id
+	}
+
+	quote, err := h.feeService.Preview(userID, txType, walletID, amount)
+	if err != nil {
+		feeErrorResponse(c, "Failed to preview fee", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Fee previewed successfully", quote)
+}
+
+// feeErrorResponse maps fee errors to HTTP statuses.
+func feeErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type FXHandler struct {
+	fxService service.FXService
+}
+
+func NewFXHandler(fxService service.FXService) *FXHandler {
+	return &FXHandler{fxService: fxService}
+}
+
+type CreateFXQuoteRequest struct {
+	FromCurrency string       `json:"from_currency" binding:"required" example:"IDR"`
+	ToCurrency   string       `json:"to_currency" binding:"required" example:"USD"`
+	Amount       models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"1000000"`
+}
+
+// Currencies godoc
+// @Summary List supported currencies
+// @Description List the currencies wallets can be opened in
+// @Tags FX
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/fx/currencies [get]
+func (h *FXHandler) Currencies(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Currencies retrieved successfully", h.fxService.Currencies())
+}
+
+// CreateQuote godoc
+// @Summary Quote a currency conversion
+// @Description Lock the current exchange rate for converting amount, in from_currency, into to_currency. Pass the quote's ID as quote_id to a transfer or move between wallets of these currencies before expires_at; a quote can be used once.
+// @Tags FX
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateFXQuoteRequest true "Conversion to quote"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/fx/quotes [post]
+func (h *FXHandler) CreateQuote(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req CreateFXQuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	from, err := models.ParseCurrency(req.FromCurrency)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid from_currency", err)
+		return
+	}
+	to, err := models.ParseCurrency(req.ToCurrency)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid to_currency", err)
+		return
+	}
+
+	quote, err := h.fxService.Quote(userID, from, to, req.Amount)
+	if err != nil {
+		fxErrorResponse(c, "Failed to quote conversion", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Quote created successfully", quote)
+}
+
+// GetQuote godoc
+// @Summary Get FX quote
+// @Description Get one of the authenticated user's FX quotes, including whether and by which transaction it was used
+// @Tags FX
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Quote ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/fx/quotes/{id} [get]
+func (h *FXHandler) GetQuote(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	quoteID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid quote ID", err)
+		return
+	}
+
+	quote, err := h.fxService.GetQuote(userID, quoteID)
+	if err != nil {
+		fxErrorResponse(c, "Failed to retrieve quote", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Quote retrieved successfully", quote)
+}
+
+// fxErrorResponse maps FX errors to HTTP statuses.
+func fxErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrFXQuoteNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
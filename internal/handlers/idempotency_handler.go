@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/repository"
+	"ewallet/pkg/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type IdempotencyHandler struct {
+	idempotencyRepo repository.IdempotencyRepository
+}
+
+func NewIdempotencyHandler(idempotencyRepo repository.IdempotencyRepository) *IdempotencyHandler {
+	return &IdempotencyHandler{idempotencyRepo: idempotencyRepo}
+}
+
+// IdempotencyKeyStatus is what a client can see of one of its keys.
+// Response is the stored body of a completed request.
+type IdempotencyKeyStatus struct {
+	Key         string          `json:"key" example:"4f6c1c1e-8d1a-4c55-9a57-1b0d2f7e9b10"`
+	Status      string          `json:"status" example:"completed" enums:"processing,completed"`
+	StatusCode  int             `json:"status_code,omitempty" example:"200"`
+	Response    json.RawMessage `json:"response,omitempty" swaggertype:"object"`
+	CreatedAt   time.Time       `json:"created_at"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// GetKey godoc
+// @Summary Look up an Idempotency-Key
+// @Description Show whether the request sent with the key has finished, and its stored response if it has. A key stays processing for good when the server stopped before storing the response; such a request is never run again, so check the transaction history before retrying with a new key.
+// @Tags Idempotency
+// @Produce json
+// @Security BearerAuth
+// @Param key path string true "Idempotency-Key"
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/idempotency-keys/{key} [get]
+func (h *IdempotencyHandler) GetKey(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	record, err := h.idempotencyRepo.FindByUserAndKey(userID, c.Param("key"))
+	if err != nil {
+		if errors.Is(err, repository.ErrIdempotencyKeyNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Idempotency key not found", err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve idempotency key", err)
+		return
+	}
+
+	status := IdempotencyKeyStatus{
+		Key:         record.Key,
+		Status:      "processing",
+		CreatedAt:   record.CreatedAt,
+		CompletedAt: record.CompletedAt,
+	}
+	if record.CompletedAt != nil {
+		status.Status = "completed"
+		status.StatusCode = record.StatusCode
+		status.Response = json.RawMessage(record.ResponseBody)
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Idempotency key retrieved successfully", status)
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type KYCHandler struct {
+	kycService service.KYCService
+}
+
+func NewKYCHandler(kycService service.KYCService) *KYCHandler {
+	return &KYCHandler{kycService: kycService}
+}
+
+type KYCSubmissionRequest struct {
+	Tier        string `form:"tier" binding:"required" example:"verified"`
+	FullName    string `form:"full_name" binding:"required" example:"Alice Wijaya"`
+	IDNumber    string `form:"id_number" binding:"required" example:"3174012345678901"`
+	DateOfBirth string `form:"date_of_birth" binding:"required" example:"1995-08-17"`
+	Address     string `form:"address" binding:"required" example:"Jl. Sudirman No. 1, Jakarta"`
+}
+
+type KYCReviewRequest struct {
+	Note string `json:"note" example:"Documents match"`
+}
+
+type KYCRejectRequest struct {
+	Reason string `json:"reason" binding:"required" example:"ID card photo is blurry"`
+}
+
+var kycDocumentFields = []models.KYCDocumentType{
+	models.KYCDocumentIDCard,
+	models.KYCDocumentSelfie,
+	models.KYCDocumentProofOfAddress,
+}
+
+// Submit godoc
+// @Summary Submit KYC documents
+// @Description Request an upgrade to the verified or premium tier. Verified needs id_card and selfie; premium also needs proof_of_address. Files must be JPEG, PNG or PDF, at most 5 MB each.
+// @Tags KYC
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param tier formData string true "Requested tier" Enums(verified, premium)
+// @Param full_name formData string true "Full name as on the ID card"
+// @Param id_number formData string true "16-digit national identity number (NIK)"
+// @Param date_of_birth formData string true "Date of birth (YYYY-MM-DD)"
+// @Param address formData string true "Residential address"
+// @Param id_card formData file true "Photo or scan of the ID card"
+// @Param selfie formData file true "Selfie holding the ID card"
+// @Param proof_of_address formData file false "Proof of address (premium only)"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/users/kyc [post]
+func (h *KYCHandler) Submit(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req KYCSubmissionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	dateOfBirth, err := time.Parse("2006-01-02", req.DateOfBirth)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", fmt.Errorf("invalid date_of_birth %q", req.DateOfBirth))
+		return
+	}
+
+	var uploads []service.KYCUpload
+	for _, docType := range kycDocumentFields {
+		header, err := c.FormFile(string(docType))
+		if err != nil {
+			if errors.Is(err, http.ErrMissingFile) {
+				continue
+			}
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+		defer func(f multipart.File) { f.Close() }(file)
+		uploads = append(uploads, service.KYCUpload{Type: docType, File: file})
+	}
+
+	input := service.KYCSubmissionInput{
+		Tier:        models.KYCTier(req.Tier),
+		FullName:    req.FullName,
+		IDNumber:    req.IDNumber,
+		DateOfBirth: dateOfBirth,
+		Address:     req.Address,
+	}
+
+	submission, err := h.kycService.Submit(middleware.GetRequestMeta(c), userID, input, uploads)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrKYCSubmissionPending) {
+			status = http.StatusConflict
+		}
+		utils.ErrorResponse(c, status, "KYC submission failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "KYC submission received", submission)
+}
+
+// GetStatus godoc
+// @Summary Get KYC status
+// @Description Get the authenticated user's KYC tier and latest submission
+// @Tags KYC
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/users/kyc [get]
+func (h *KYCHandler) GetStatus(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	status, err := h.kycService.GetStatus(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve KYC status", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "KYC status retrieved successfully", status)
+}
+
+// ListSubmissions godoc
+// @Summary List KYC submissions
+// @Description List KYC submissions for review, oldest first (at most 100)
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Submission status" Enums(pending, approved, rejected)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Router /api/admin/kyc [get]
+func (h *KYCHandler) ListSubmissions(c *gin.Context) {
+	var status *models.KYCStatus
+	if v := c.Query("status"); v != "" {
+		s := models.KYCStatus(v)
+		if s != models.KYCStatusPending && s != models.KYCStatusApproved && s != models.KYCStatusRejected {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid status %q", v))
+			return
+		}
+		status = &s
+	}
+
+	submissions, err := h.kycService.ListSubmissions(status)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve KYC submissions", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "KYC submissions retrieved successfully", submissions)
+}
+
+// GetSubmission godoc
+// @Summary Get KYC submission
+// @Description Get a KYC submission with its documents
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Submission ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/kyc/{id} [get]
+func (h *KYCHandler) GetSubmission(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid submission ID", err)
+		return
+	}
+
+	submission, err := h.kycService.GetSubmission(id)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "KYC submission not found", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "KYC submission retrieved successfully", submission)
+}
+
+// GetDocument godoc
+// @Summary Download KYC document
+// @Description Download an uploaded KYC document
+// @Tags Admin
+// @Produce octet-stream
+// @Security BearerAuth
+// @Param id path string true "Submission ID"
+// @Param documentId path string true "Document ID"
+// @Success 200 {file} file
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/admin/kyc/{id}/documents/{documentId} [get]
+func (h *KYCHandler) GetDocument(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid submission ID", err)
+		return
+	}
+	documentID, err := uuid.Parse(c.Param("documentId"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid document ID", err)
+		return
+	}
+
+	doc, path, err := h.kycService.DocumentPath(id, documentID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "KYC document not found", err)
+		return
+	}
+
+	c.Header("Content-Type", doc.ContentType)
+	c.File(path)
+}
+
+// Approve godoc
+// @Summary Approve KYC submission
+// @Description Approve a pending submission and move the user to the requested tier
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Submission ID"
+// @Param request body KYCReviewRequest false "Review note"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/admin/kyc/{id}/approve [post]
+func (h *KYCHandler) Approve(c *gin.Context) {
+	reviewerID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid submission ID", err)
+		return
+	}
+
+	var req KYCReviewRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+			return
+		}
+	}
+
+	submission, err := h.kycService.Approve(middleware.GetRequestMeta(c), reviewerID, id, req.Note)
+	if err != nil {
+		reviewErrorResponse(c, "Failed to approve KYC submission", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "KYC submission approved", submission)
+}
+
+// Reject godoc
+// @Summary Reject KYC submission
+// @Description Reject a pending submission. The reason is shown to the user.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Submission ID"
+// @Param request body KYCRejectRequest true "Rejection reason"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 403 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/admin/kyc/{id}/reject [post]
+func (h *KYCHandler) Reject(c *gin.Context) {
+	reviewerID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid submission ID", err)
+		return
+	}
+
+	var req KYCRejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	submission, err := h.kycService.Reject(middleware.GetRequestMeta(c), reviewerID, id, req.Reason)
+	if err != nil {
+		reviewErrorResponse(c, "Failed to reject KYC submission", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "KYC submission rejected", submission)
+}
+
+func reviewErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrKYCSubmissionNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrKYCSubmissionNotPending):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	case errors.Is(err, service.ErrKYCReasonRequired):
+		utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PaymentRequestHandler struct {
+	paymentRequestService service.PaymentRequestService
+}
+
+func NewPaymentRequestHandler(paymentRequestService service.PaymentRequestService) *PaymentRequestHandler {
+	return &PaymentRequestHandler{paymentRequestService: paymentRequestService}
+}
+
+type CreatePaymentRequestRequest struct {
+	PayerID   uuid.UUID    `json:"payer_id" binding:"required"`
+	Amount    models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"50000"`
+	Note      string       `json:"note" example:"Dinner on Friday"`
+	ExpiresAt *time.Time   `json:"expires_at" example:"2026-01-31T23:59:59Z"`
+}
+
+type AcceptPaymentRequestRequest struct {
+	WalletID *uuid.UUID `json:"wallet_id"`
+	PIN      string     `json:"pin" binding:"required" example:"123456"`
+}
+
+// Create godoc
+// @Summary Request money from another user
+// @Description Ask another user to pay an amount, in the currency of the requester's primary wallet. The request expires at expires_at, or after 72 hours by default, and at most 30 days ahead.
+// @Tags Payment Requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreatePaymentRequestRequest true "Payment request"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/payment-requests [post]
+func (h *PaymentRequestHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req CreatePaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	request, err := h.paymentRequestService.Create(middleware.GetRequestMeta(c), userID, req.PayerID, req.Amount, req.Note, req.ExpiresAt)
+	if err != nil {
+		paymentRequestErrorResponse(c, "Failed to create payment request", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Payment request created successfully", request)
+}
+
+// List godoc
+// @Summary List payment requests
+// @Description List payment requests the authenticated user made (outgoing) or was asked to pay (incoming), newest first
+// @Tags Payment Requests
+// @Produce json
+// @Security BearerAuth
+// @Param role query string false "Requests relative to the user" Enums(incoming, outgoing)
+// @Param status query string false "Request status" Enums(pending, accepted, declined, cancelled, expired)
+// @Param limit query int false "Maximum results (max 100)" default(50)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/payment-requests [get]
+func (h *PaymentRequestHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var role *models.PaymentRequestRole
+	if v := c.Query("role"); v != "" {
+		r := models.PaymentRequestRole(v)
+		if r != models.PaymentRequestIncoming && r != models.PaymentRequestOutgoing {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid role %q", v))
+			return
+		}
+		role = &r
+	}
+
+	var status *models.PaymentRequestStatus
+	if v := c.Query("status"); v != "" {
+		s := models.PaymentRequestStatus(v)
+		if !s.IsValid() {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid status %q", v))
+			return
+		}
+		status = &s
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultPaymentRequestLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultPaymentRequestLimit
+	}
+
+	requests, err := h.paymentRequestService.List(userID, role, status, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve payment requests", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment requests retrieved successfully", requests)
+}
+
+// Get godoc
+// @Summary Get payment request
+// @Description Get a payment request the authenticated user made or was asked to pay
+// @Tags Payment Requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Payment request ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/payment-requests/{id} [get]
+func (h *PaymentRequestHandler) Get(c *gin.Context) {
+	userID, requestID, ok := paymentRequestParams(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.paymentRequestService.Get(userID, requestID)
+	if err != nil {
+		paymentRequestErrorResponse(c, "Failed to retrieve payment request", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment request retrieved successfully", request)
+}
+
+// Accept godoc
+// @Summary Accept payment request
+// @Description Pay a pending request made to the authenticated user. The money is sent as a normal transfer from wallet_id, or from the primary wallet if it is omitted, with the same checks and limits; transaction_id links the request to it. The paying wallet must hold the request's currency. Requires the user's transaction PIN.
+// @Tags Payment Requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Payment request ID"
+// @Param request body AcceptPaymentRequestRequest true "Transaction PIN"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/payment-requests/{id}/accept [post]
+func (h *PaymentRequestHandler) Accept(c *gin.Context) {
+	userID, requestID, ok := paymentRequestParams(c)
+	if !ok {
+		return
+	}
+
+	var req AcceptPaymentRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	request, err := h.paymentRequestService.Accept(middleware.GetRequestMeta(c), userID, requestID, req.WalletID, req.PIN)
+	if err != nil {
+		paymentRequestErrorResponse(c, "Failed to accept payment request", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment request accepted successfully", request)
+}
+
+// Decline godoc
+// @Summary Decline payment request
+// @Description Refuse a pending request made to the authenticated user
+// @Tags Payment Requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Payment request ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/payment-requests/{id}/decline [post]
+func (h *PaymentRequestHandler) Decline(c *gin.Context) {
+	userID, requestID, ok := paymentRequestParams(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.paymentRequestService.Decline(middleware.GetRequestMeta(c), userID, requestID)
+	if err != nil {
+		paymentRequestErrorResponse(c, "Failed to decline payment request", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment request declined successfully", request)
+}
+
+// Cancel godoc
+// @Summary Cancel payment request
+// @Description Withdraw a pending request the authenticated user made
+// @Tags Payment Requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Payment request ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/payment-requests/{id}/cancel [post]
+func (h *PaymentRequestHandler) Cancel(c *gin.Context) {
+	userID, requestID, ok := paymentRequestParams(c)
+	if !ok {
+		return
+	}
+
+	request, err := h.paymentRequestService.Cancel(middleware.GetRequestMeta(c), userID, requestID)
+	if err != nil {
+		paymentRequestErrorResponse(c, "Failed to cancel payment request", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment request cancelled successfully", request)
+}
+
+// paymentRequestParams reads the authenticated user and the request ID from
+// the path, writing the error response if either is missing or invalid.
+func paymentRequestParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	requestID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid payment request ID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, requestID, true
+}
+
+// paymentRequestErrorResponse maps payment request errors to HTTP statuses.
+// Errors from the transfer behind an acceptance are client errors, as they
+// are for a direct transfer.
+func paymentRequestErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrPaymentRequestNotFound), errors.Is(err, service.ErrPayerNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrPaymentRequestNotPending), errors.Is(err, service.ErrPaymentRequestExpired):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ScheduledTransferHandler struct {
+	scheduledTransferService service.ScheduledTransferService
+}
+
+func NewScheduledTransferHandler(scheduledTransferService service.ScheduledTransferService) *ScheduledTransferHandler {
+	return &ScheduledTransferHandler{scheduledTransferService: scheduledTransferService}
+}
+
+type CreateScheduledTransferRequest struct {
+	WalletID   *uuid.UUID   `json:"wallet_id"`
+	ReceiverID uuid.UUID    `json:"receiver_id" binding:"required"`
+	Amount     models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"500000"`
+	Note       string       `json:"note" example:"Monthly allowance"`
+	Frequency  string       `json:"frequency" binding:"required" example:"monthly"`
+	StartAt    time.Time    `json:"start_at" binding:"required" example:"2026-01-01T08:00:00Z"`
+	EndAt      *time.Time   `json:"end_at" example:"2026-12-31T23:59:59Z"`
+	MaxRuns    *int         `json:"max_runs" example:"12"`
+	PIN        string       `json:"pin" binding:"required" example:"123456"`
+}
+
+type UpdateScheduledTransferRequest struct {
+	Amount       *models.Money `json:"amount" binding:"omitempty,gt=0" swaggertype:"number" example:"600000"`
+	Note         *string       `json:"note" example:"Monthly allowance"`
+	EndAt        *time.Time    `json:"end_at" example:"2026-12-31T23:59:59Z"`
+	ClearEndAt   bool          `json:"clear_end_at"`
+	MaxRuns      *int          `json:"max_runs" example:"12"`
+	ClearMaxRuns bool          `json:"clear_max_runs"`
+	PIN          string        `json:"pin" example:"123456"`
+}
+
+// Create godoc
+// @Summary Schedule a transfer
+// @Description Schedule a one-time transfer, or a daily, weekly or monthly one that ends at end_at or after max_runs transfers. The money is sent from wallet_id, or from the primary wallet if it is omitted. The PIN is verified now and authorizes every run; balance, limits and wallet status are checked at each run.
+// @Tags Scheduled Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateScheduledTransferRequest true "Scheduled transfer"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/transactions/scheduled [post]
+func (h *ScheduledTransferHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req CreateScheduledTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	schedule, err := h.scheduledTransferService.Create(middleware.GetRequestMeta(c), userID, service.ScheduledTransferInput{
+		WalletID:   req.WalletID,
+		ReceiverID: req.ReceiverID,
+		Amount:     req.Amount,
+		Note:       req.Note,
+		Frequency:  models.ScheduleFrequency(req.Frequency),
+		StartAt:    req.StartAt,
+		EndAt:      req.EndAt,
+		MaxRuns:    req.MaxRuns,
+	}, req.PIN)
+	if err != nil {
+		scheduledTransferErrorResponse(c, "Failed to schedule transfer", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Transfer scheduled successfully", schedule)
+}
+
+// List godoc
+// @Summary List scheduled transfers
+// @Description List the authenticated user's scheduled transfers, newest first
+// @Tags Scheduled Transfers
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Schedule status" Enums(active, completed, cancelled, failed)
+// @Param limit query int false "Maximum results (max 100)" default(50)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/transactions/scheduled [get]
+func (h *ScheduledTransferHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var status *models.ScheduledTransferStatus
+	if v := c.Query("status"); v != "" {
+		s := models.ScheduledTransferStatus(v)
+		if !s.IsValid() {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid status %q", v))
+			return
+		}
+		status = &s
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultScheduledTransferLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultScheduledTransferLimit
+	}
+
+	schedules, err := h.scheduledTransferService.List(userID, status, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve scheduled transfers", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled transfers retrieved successfully", schedules)
+}
+
+// Get godoc
+// @Summary Get scheduled transfer
+// @Description Get one of the authenticated user's scheduled transfers with its latest runs, newest first
+// @Tags Scheduled Transfers
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Scheduled transfer ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/transactions/scheduled/{id} [get]
+func (h *ScheduledTransferHandler) Get(c *gin.Context) {
+	userID, scheduleID, ok := scheduledTransferParams(c)
+	if !ok {
+		return
+	}
+
+	detail, err := h.scheduledTransferService.Get(userID, scheduleID)
+	if err != nil {
+		scheduledTransferErrorResponse(c, "Failed to retrieve scheduled transfer", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled transfer retrieved successfully", detail)
+}
+
+// Update godoc
+// @Summary Update scheduled transfer
+// @Description Change the amount, note, end date or run limit of an active scheduled transfer. Only the fields sent are changed; clear_end_at and clear_max_runs remove the end date and the run limit. The PIN is required when the change raises the amount, or moves or removes the end date or run limit so that more transfers can be made.
+// @Tags Scheduled Transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Scheduled transfer ID"
+// @Param request body UpdateScheduledTransferRequest true "Changes"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/transactions/scheduled/{id} [put]
+func (h *ScheduledTransferHandler) Update(c *gin.Context) {
+	userID, scheduleID, ok := scheduledTransferParams(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateScheduledTransferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	schedule, err := h.scheduledTransferService.Update(middleware.GetRequestMeta(c), userID, scheduleID, service.ScheduledTransferUpdate{
+		Amount:       req.Amount,
+		Note:         req.Note,
+		EndAt:        req.EndAt,
+		ClearEndAt:   req.ClearEndAt,
+		MaxRuns:      req.MaxRuns,
+		ClearMaxRuns: req.ClearMaxRuns,
+	}, req.PIN)
+	if err != nil {
+		scheduledTransferErrorResponse(c, "Failed to update scheduled transfer", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled transfer updated successfully", schedule)
+}
+
+// Cancel godoc
+// @Summary Cancel scheduled transfer
+// @Description Stop an active scheduled transfer. Transfers already made are not affected.
+// @Tags Scheduled Transfers
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Scheduled transfer ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/transactions/scheduled/{id} [delete]
+func (h *ScheduledTransferHandler) Cancel(c *gin.Context) {
+	userID, scheduleID, ok := scheduledTransferParams(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduledTransferService.Cancel(middleware.GetRequestMeta(c), userID, scheduleID)
+	if err != nil {
+		scheduledTransferErrorResponse(c, "Failed to cancel scheduled transfer", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Scheduled transfer cancelled successfully", schedule)
+}
+
+// scheduledTransferParams reads the authenticated user and the scheduled
+// transfer ID from the path, writing the error response if either is
+// missing or invalid.
+func scheduledTransferParams(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	scheduleID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid scheduled transfer ID", err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, scheduleID, true
+}
+
+// scheduledTransferErrorResponse maps scheduled transfer errors to HTTP
+// statuses.
+func scheduledTransferErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrScheduledTransferNotFound), errors.Is(err, service.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrScheduledTransferNotActive), errors.Is(err, service.ErrScheduledTransferLimitReached):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
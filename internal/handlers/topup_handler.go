@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/payment"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+const (
+	// How far a payment callback's timestamp may be from now
+	paymentCallbackTolerance = 5 * time.Minute
+	paymentCallbackBodyLimit = 64 << 10
+)
+
+type TopUpHandler struct {
+	topUpService   service.TopUpService
+	callbackSecret string
+}
+
+// NewTopUpHandler creates the handler. callbackSecret verifies the
+// signatures of payment result callbacks.
+func NewTopUpHandler(topUpService service.TopUpService, callbackSecret string) *TopUpHandler {
+	return &TopUpHandler{
+		topUpService:   topUpService,
+		callbackSecret: callbackSecret,
+	}
+}
+
+type TopUpRequest struct {
+	WalletID *uuid.UUID   `json:"wallet_id"`
+	Amount   models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"100000"`
+}
+
+// Create godoc
+// @Summary Top up wallet balance
+// @Description Start a top-up of one of the authenticated user's wallets, the primary one unless wallet_id is given. The amount is in the wallet's currency; the user pays it plus any fee to the returned virtual account or payment URL before expires_at. The top-up is recorded before the payment gateway is asked to open the payment; if the gateway cannot be reached the request fails with 503 and the top-up fails with payment_gateway_unavailable. The top-up stays pending, and counts against the top-up limits, until the payment gateway confirms the payment; then the wallet receives the full amount. Send an Idempotency-Key header to make retries safe.
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TopUpRequest true "Top Up Request"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 503 {object} utils.Response
+// @Router /api/wallets/topup [post]
+func (h *TopUpHandler) Create(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req TopUpRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	topUp, err := h.topUpService.Create(c.Request.Context(), middleware.GetRequestMeta(c), userID, req.WalletID, req.Amount)
+	if err != nil {
+		topUpErrorResponse(c, "Top up failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Top up created successfully", topUp)
+}
+
+// List godoc
+// @Summary List top-ups
+// @Description List the authenticated user's top-ups, newest first
+// @Tags Wallets
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Top-up status" Enums(pending, success, failed, expired)
+// @Param limit query int false "Maximum results (max 100)" default(50)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/wallets/topups [get]
+func (h *TopUpHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var status *models.TopUpStatus
+	if v := c.Query("status"); v != "" {
+		s := models.TopUpStatus(v)
+		if !s.IsValid() {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid status %q", v))
+			return
+		}
+		status = &s
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultTopUpLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultTopUpLimit
+	}
+
+	topUps, err := h.topUpService.List(userID, status, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve top-ups", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Top-ups retrieved successfully", topUps)
+}
+
+// Get godoc
+// @Summary Get top-up
+// @Description Get one of the authenticated user's top-ups with its payment instruction
+// @Tags Wallets
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Top-up ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/wallets/topups/{id} [get]
+func (h *TopUpHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	topUpID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid top-up ID", err)
+		return
+	}
+
+	topUp, err := h.topUpService.Get(userID, topUpID)
+	if err != nil {
+		topUpErrorResponse(c, "Failed to retrieve top-up", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Top-up retrieved successfully", topUp)
+}
+
+// PaymentCallback godoc
+// @Summary Receive a payment result
+// @Description Called by the payment gateway with the outcome of a top-up payment. The body must be signed like webhook deliveries, with the payment callback secret, in the X-Payment-Timestamp and X-Payment-Signature headers. A paid result must carry the top-up's total. A paid result for an expired top-up, or one that failed because the gateway could not be reached, is still credited. Money a closed wallet, or the user's maximum balance, can no longer take is held in suspense and the top-up becomes held. A result that repeats how the top-up ended, paid (success or held) or not (failed or expired), is accepted again; a contradicting one is a conflict.
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Param X-Payment-Timestamp header string true "Unix time the body was signed at"
+// @Param X-Payment-Signature header string true "sha256=<hex HMAC-SHA256 of timestamp.body>"
+// @Param request body payment.PaymentResult true "Payment result"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/payments/callback [post]
+func (h *TopUpHandler) PaymentCallback(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, paymentCallbackBodyLimit))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	timestamp := c.GetHeader(payment.HeaderTimestamp)
+	signature := c.GetHeader(payment.HeaderSignature)
+	if !utils.VerifyPayloadSignature(h.callbackSecret, timestamp, signature, body, paymentCallbackTolerance) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid signature", nil)
+		return
+	}
+
+	var result payment.PaymentResult
+	if err := binding.JSON.BindBody(body, &result); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	topUp, err := h.topUpService.HandleResult(middleware.GetRequestMeta(c), result)
+	if err != nil {
+		topUpErrorResponse(c, "Failed to record payment result", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payment result recorded successfully", gin.H{
+		"topup_id": topUp.ID,
+		"status":   topUp.Status,
+	})
+}
+
+// topUpErrorResponse maps top-up errors to HTTP statuses.
+func topUpErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrTopUpNotFound), errors.Is(err, service.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrWalletClosed), errors.Is(err, service.ErrWalletFrozen),
+		errors.Is(err, service.ErrTopUpCompleted):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	case errors.Is(err, service.ErrPaymentGatewayUnavailable):
+		utils.ErrorResponse(c, http.StatusServiceUnavailable, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
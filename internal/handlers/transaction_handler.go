@@ -1,11 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
 	"ewallet/internal/service"
 	"ewallet/pkg/utils"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,21 +25,27 @@ func NewTransactionHandler(transactionService service.TransactionService) *Trans
 }
 
 type TransferRequest struct {
-	ReceiverID uuid.UUID `json:"receiver_id" binding:"required"`
-	Amount     float64   `json:"amount" binding:"required,gt=0" example:"50000"`
+	WalletID   *uuid.UUID   `json:"wallet_id"`
+	ReceiverID uuid.UUID    `json:"receiver_id" binding:"required"`
+	Amount     models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"50000"`
+	QuoteID    *uuid.UUID   `json:"quote_id"`
+	PIN        string       `json:"pin" binding:"required" example:"123456"`
 }
 
 // Transfer godoc
 // @Summary Transfer money to another user
-// @Description Transfer funds from authenticated user's wallet to another user
+// @Description Transfer funds from one of the authenticated user's wallets, the primary one unless wallet_id is given, to another user's primary wallet. Requires the user's transaction PIN. The amount is in the sending wallet's currency; if the receiver's wallet holds another currency, quote_id must name an unexpired FX quote for exactly this conversion, and the receiver gets the quoted amount. Any fee is debited from the sending wallet on top of the amount.
 // @Tags Transactions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
 // @Param request body TransferRequest true "Transfer Request"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Success 200 {object} utils.Response
 // @Failure 400 {object} utils.Response
 // @Failure 401 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 422 {object} utils.Response
 // @Router /api/transactions/transfer [post]
 func (h *TransactionHandler) Transfer(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
@@ -49,7 +60,7 @@ func (h *TransactionHandler) Transfer(c *gin.Context) {
 		return
 	}
 
-	transaction, err := h.transactionService.Transfer(userID, req.ReceiverID, req.Amount)
+	transaction, err := h.transactionService.Transfer(middleware.GetRequestMeta(c), userID, req.WalletID, req.ReceiverID, req.Amount, req.QuoteID, req.PIN)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Transfer failed", err)
 		return
@@ -58,15 +69,90 @@ func (h *TransactionHandler) Transfer(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Transfer successful", transaction.ToResponse())
 }
 
+type RefundRequest struct {
+	Amount *models.Money `json:"amount" binding:"omitempty,gt=0" swaggertype:"number" example:"25000"`
+	PIN    string        `json:"pin" binding:"required" example:"123456"`
+	Reason string        `json:"reason" example:"Paid twice by mistake"`
+}
+
+// Refund godoc
+// @Summary Refund a received transfer
+// @Description Send all or part of a received transfer back to its sender, within 7 days of the transfer. Without an amount, whatever has not been refunded yet is sent back. Requires the user's transaction PIN.
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID of the received transfer"
+// @Param request body RefundRequest true "Refund Request"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/transactions/{id}/refund [post]
+func (h *TransactionHandler) Refund(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid transaction ID", err)
+		return
+	}
+
+	var req RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	transaction, err := h.transactionService.Refund(middleware.GetRequestMeta(c), userID, transactionID, req.Amount, req.PIN, req.Reason)
+	if err != nil {
+		utils.ErrorResponse(c, reversalErrorStatus(err), "Refund failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Refund successful", transaction.ToResponse())
+}
+
+// reversalErrorStatus maps reversal and refund errors to HTTP statuses.
+func reversalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTransactionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAlreadyReversed), errors.Is(err, service.ErrTransactionNotReversible),
+		errors.Is(err, service.ErrWalletClosed), errors.Is(err, service.ErrWalletFrozen):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrRefundNotAllowed):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
 // GetHistory godoc
 // @Summary Get transaction history
-// @Description Get authenticated user's transaction history
+// @Description Get authenticated user's transaction history, newest first, using cursor pagination
 // @Tags Transactions
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param limit query int false "Limit number of transactions" default(50)
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param cursor query string false "Cursor from the previous page's next_cursor"
+// @Param type query string false "Transaction type" Enums(topup, transfer, reversal, refund, move)
+// @Param status query string false "Transaction status" Enums(pending, success, failed)
+// @Param direction query string false "Direction relative to the user" Enums(incoming, outgoing)
+// @Param from query string false "Created at or after (RFC3339 or YYYY-MM-DD)"
+// @Param to query string false "Created before (RFC3339), or on or before (YYYY-MM-DD)"
+// @Param min_amount query number false "Minimum amount"
+// @Param max_amount query number false "Maximum amount"
+// @Param counterparty_id query string false "Other user's ID"
+// @Param wallet_id query string false "Only transactions that moved money in or out of this wallet"
 // @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
 // @Failure 401 {object} utils.Response
 // @Failure 500 {object} utils.Response
 // @Router /api/transactions/history [get]
@@ -77,18 +163,169 @@ func (h *TransactionHandler) GetHistory(c *gin.Context) {
 		return
 	}
 
+	query, err := parseHistoryQuery(c, userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", err)
+		return
+	}
+
+	page, err := h.transactionService.GetHistory(query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve history", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Transaction history retrieved successfully", page)
+}
+
+// parseHistoryQuery builds the history query from the request's query string.
+func parseHistoryQuery(c *gin.Context, userID uuid.UUID) (repository.TransactionQuery, error) {
+	query := repository.TransactionQuery{UserID: userID}
+
 	// Get limit from query parameter (default 50)
-	limitStr := c.DefaultQuery("limit", "50")
+	limitStr := c.DefaultQuery("limit", strconv.Itoa(service.DefaultHistoryLimit))
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit <= 0 {
-		limit = 50
+		limit = service.DefaultHistoryLimit
 	}
+	query.Limit = limit
 
-	transactions, err := h.transactionService.GetHistory(userID, limit)
+	if v := c.Query("cursor"); v != "" {
+		cursor, err := repository.DecodeTransactionCursor(v)
+		if err != nil {
+			return query, err
+		}
+		query.After = cursor
+	}
+
+	if v := c.Query("type"); v != "" {
+		t := models.TransactionType(v)
+		if !t.IsValid() {
+			return query, fmt.Errorf("invalid type %q", v)
+		}
+		query.Type = &t
+	}
+
+	if v := c.Query("status"); v != "" {
+		st := models.TransactionStatus(v)
+		if st != models.TransactionStatusPending && st != models.TransactionStatusSuccess && st != models.TransactionStatusFailed {
+			return query, fmt.Errorf("invalid status %q", v)
+		}
+		query.Status = &st
+	}
+
+	if v := c.Query("direction"); v != "" {
+		d := models.TransactionDirection(v)
+		if d != models.TransactionDirectionIncoming && d != models.TransactionDirectionOutgoing {
+			return query, fmt.Errorf("invalid direction %q", v)
+		}
+		query.Direction = &d
+	}
+
+	if v := c.Query("from"); v != "" {
+		from, _, err := parseHistoryTime(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid from: %w", err)
+		}
+		query.From = &from
+	}
+
+	if v := c.Query("to"); v != "" {
+		to, dateOnly, err := parseHistoryTime(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid to: %w", err)
+		}
+		if dateOnly {
+			// A plain date includes the whole day
+			to = to.AddDate(0, 0, 1)
+		}
+		query.To = &to
+	}
+
+	if v := c.Query("min_amount"); v != "" {
+		amount, err := models.ParseMoney(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid min_amount: %w", err)
+		}
+		query.MinAmount = &amount
+	}
+
+	if v := c.Query("max_amount"); v != "" {
+		amount, err := models.ParseMoney(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid max_amount: %w", err)
+		}
+		query.MaxAmount = &amount
+	}
+
+	if v := c.Query("counterparty_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid counterparty_id: %w", err)
+		}
+		query.CounterpartyID = &id
+	}
+
+	if v := c.Query("wallet_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid wallet_id: %w", err)
+		}
+		query.WalletID = &id
+	}
+
+	return query, nil
+}
+
+// parseHistoryTime accepts RFC3339 timestamps or YYYY-MM-DD dates and reports
+// whether the value was a plain date.
+func parseHistoryTime(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse(time.DateOnly, v)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve history", err)
+		return time.Time{}, false, errors.New("expected RFC3339 timestamp or YYYY-MM-DD date")
+	}
+	return t, true, nil
+}
+
+// GetTransaction godoc
+// @Summary Get transaction detail
+// @Description Get a single transaction of the authenticated user, including the counterparty, the direction and the balance after the movement
+// @Tags Transactions
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Transaction ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/transactions/{id} [get]
+func (h *TransactionHandler) GetTransaction(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid transaction ID", err)
+		return
+	}
+
+	detail, err := h.transactionService.GetDetail(userID, transactionID)
+	if err != nil {
+		if errors.Is(err, service.ErrTransactionNotFound) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Transaction not found", err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve transaction", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Transaction history retrieved successfully", transactions)
+	utils.SuccessResponse(c, http.StatusOK, "Transaction retrieved successfully", detail)
 }
@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"ewallet/internal/middleware"
+	"ewallet/internal/models"
 	"ewallet/internal/repository"
+	"ewallet/internal/service"
 	"ewallet/pkg/utils"
 	"net/http"
 
@@ -10,11 +12,23 @@ import (
 )
 
 type UserHandler struct {
-	userRepo repository.UserRepository
+	userRepo     repository.UserRepository
+	pinService   service.PINService
+	auditService service.AuditService
 }
 
-func NewUserHandler(userRepo repository.UserRepository) *UserHandler {
-	return &UserHandler{userRepo: userRepo}
+func NewUserHandler(userRepo repository.UserRepository, pinService service.PINService, auditService service.AuditService) *UserHandler {
+	return &UserHandler{userRepo: userRepo, pinService: pinService, auditService: auditService}
+}
+
+type SetPINRequest struct {
+	Password string `json:"password" binding:"required" example:"password123"`
+	PIN      string `json:"pin" binding:"required" example:"123456"`
+}
+
+type ChangePINRequest struct {
+	CurrentPIN string `json:"current_pin" binding:"required" example:"123456"`
+	NewPIN     string `json:"new_pin" binding:"required" example:"654321"`
 }
 
 // GetProfile godoc
@@ -27,6 +41,7 @@ func NewUserHandler(userRepo repository.UserRepository) *UserHandler {
 // @Success 200 {object} utils.Response
 // @Failure 401 {object} utils.Response
 // @Failure 404 {object} utils.Response
+// @Failure 500 {object} utils.Response
 // @Router /api/users/profile [get]
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
@@ -41,5 +56,82 @@ func (h *UserHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
+	// Profile reads expose personal data, so they are audited too
+	err = h.auditService.Record(nil, middleware.GetRequestMeta(c), &models.AuditEvent{
+		Action:     models.AuditActionProfileViewed,
+		TargetType: models.AuditTargetUser,
+		TargetID:   user.ID.String(),
+	}, nil)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve profile", err)
+		return
+	}
+
 	utils.SuccessResponse(c, http.StatusOK, "Profile retrieved successfully", user.ToResponse())
 }
+
+// SetPIN godoc
+// @Summary Set transaction PIN
+// @Description Set the 6-digit transaction PIN used to authorize transfers and other debits. Requires the account password.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body SetPINRequest true "Set PIN Request"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/users/pin [post]
+func (h *UserHandler) SetPIN(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req SetPINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := h.pinService.SetPIN(userID, req.Password, req.PIN); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to set PIN", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "PIN set successfully", nil)
+}
+
+// ChangePIN godoc
+// @Summary Change transaction PIN
+// @Description Replace the transaction PIN. Wrong current PINs count towards the PIN lock.
+// @Tags Users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePINRequest true "Change PIN Request"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Router /api/users/pin [put]
+func (h *UserHandler) ChangePIN(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req ChangePINRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if err := h.pinService.ChangePIN(userID, req.CurrentPIN, req.NewPIN); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to change PIN", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "PIN changed successfully", nil)
+}
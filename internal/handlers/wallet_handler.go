@@ -1,34 +1,176 @@
 package handlers
 
 import (
+	"errors"
 	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/realtime"
 	"ewallet/internal/service"
 	"ewallet/pkg/utils"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// How often an idle stream sends a comment so proxies keep it open
+const streamKeepAliveInterval = 25 * time.Second
+
 type WalletHandler struct {
 	walletService service.WalletService
+	broker        *realtime.Broker
+}
+
+func NewWalletHandler(walletService service.WalletService, broker *realtime.Broker) *WalletHandler {
+	return &WalletHandler{walletService: walletService, broker: broker}
+}
+
+type CreateWalletRequest struct {
+	Name     string `json:"name" binding:"required" example:"Savings"`
+	Currency string `json:"currency" example:"USD"`
 }
 
-func NewWalletHandler(walletService service.WalletService) *WalletHandler {
-	return &WalletHandler{walletService: walletService}
+type UpdateWalletRequest struct {
+	Name        *string `json:"name" example:"Holiday savings"`
+	MakePrimary bool    `json:"make_primary"`
 }
 
-type TopUpRequest struct {
-	Amount float64 `json:"amount" binding:"required,gt=0" example:"100000"`
+type MoveRequest struct {
+	FromWalletID uuid.UUID    `json:"from_wallet_id" binding:"required"`
+	ToWalletID   uuid.UUID    `json:"to_wallet_id" binding:"required"`
+	Amount       models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"250000"`
+	QuoteID      *uuid.UUID   `json:"quote_id"`
+}
+
+// ListWallets godoc
+// @Summary List wallets
+// @Description List the authenticated user's wallets, the primary one first
+// @Tags Wallets
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/wallets [get]
+func (h *WalletHandler) ListWallets(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	wallets, err := h.walletService.ListWallets(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve wallets", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallets retrieved successfully", wallets)
+}
+
+// CreateWallet godoc
+// @Summary Open a wallet
+// @Description Open another wallet (pocket) for the authenticated user, such as one for savings or bills. Names are unique per user, ignoring case, and at most 10 wallets are allowed. The new wallet is not primary. It holds one currency, IDR unless another supported currency is given.
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateWalletRequest true "Wallet name and currency"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/wallets [post]
+func (h *WalletHandler) CreateWallet(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req CreateWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	var currency models.Currency
+	if req.Currency != "" {
+		parsed, err := models.ParseCurrency(req.Currency)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid currency", err)
+			return
+		}
+		currency = parsed
+	}
+
+	wallet, err := h.walletService.CreateWallet(middleware.GetRequestMeta(c), userID, req.Name, currency)
+	if err != nil {
+		walletErrorResponse(c, "Failed to open wallet", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Wallet opened successfully", wallet)
+}
+
+// UpdateWallet godoc
+// @Summary Update wallet
+// @Description Rename one of the authenticated user's wallets or make it the primary wallet, which receives transfers from other users. The previous primary wallet stays open as a normal wallet.
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Wallet ID"
+// @Param request body UpdateWalletRequest true "Changes"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/wallets/{id} [put]
+func (h *WalletHandler) UpdateWallet(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	walletID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", err)
+		return
+	}
+
+	var req UpdateWalletRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	wallet, err := h.walletService.UpdateWallet(middleware.GetRequestMeta(c), userID, walletID, service.WalletUpdate{
+		Name:        req.Name,
+		MakePrimary: req.MakePrimary,
+	})
+	if err != nil {
+		walletErrorResponse(c, "Failed to update wallet", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Wallet updated successfully", wallet)
 }
 
 // GetBalance godoc
 // @Summary Get wallet balance
-// @Description Get authenticated user's wallet balance
+// @Description Get the balance of one of the authenticated user's wallets, the primary one unless wallet_id is given
 // @Tags Wallets
 // @Accept json
 // @Produce json
 // @Security BearerAuth
+// @Param wallet_id query string false "Wallet ID (defaults to the primary wallet)"
 // @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
 // @Failure 401 {object} utils.Response
 // @Failure 404 {object} utils.Response
 // @Router /api/wallets/balance [get]
@@ -39,7 +181,12 @@ func (h *WalletHandler) GetBalance(c *gin.Context) {
 		return
 	}
 
-	wallet, err := h.walletService.GetBalance(userID)
+	walletID, ok := walletIDQuery(c)
+	if !ok {
+		return
+	}
+
+	wallet, err := h.walletService.GetBalance(userID, walletID)
 	if err != nil {
 		utils.ErrorResponse(c, http.StatusNotFound, "Wallet not found", err)
 		return
@@ -48,36 +195,167 @@ func (h *WalletHandler) GetBalance(c *gin.Context) {
 	utils.SuccessResponse(c, http.StatusOK, "Balance retrieved successfully", wallet)
 }
 
-// TopUp godoc
-// @Summary Top up wallet balance
-// @Description Add funds to authenticated user's wallet
+// GetLimits godoc
+// @Summary Get wallet limits
+// @Description Get the authenticated user's effective transaction and balance limits and the current daily and monthly usage
+// @Tags Wallets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/wallets/limits [get]
+func (h *WalletHandler) GetLimits(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	overview, err := h.walletService.GetLimits(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve limits", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Limits retrieved successfully", overview)
+}
+
+// Move godoc
+// @Summary Move money between own wallets
+// @Description Move money from one of the authenticated user's wallets to another. No PIN is needed and transfer limits do not apply, since the money stays with the user. The amount is in the source wallet's currency; between wallets of different currencies, quote_id must name an unexpired FX quote for exactly this conversion.
 // @Tags Wallets
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param request body TopUpRequest true "Top Up Request"
+// @Param request body MoveRequest true "Move Request"
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
 // @Success 200 {object} utils.Response
 // @Failure 400 {object} utils.Response
 // @Failure 401 {object} utils.Response
-// @Router /api/wallets/topup [post]
-func (h *WalletHandler) TopUp(c *gin.Context) {
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Failure 422 {object} utils.Response
+// @Router /api/wallets/move [post]
+func (h *WalletHandler) Move(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
 		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
 		return
 	}
 
-	var req TopUpRequest
+	var req MoveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
 		return
 	}
 
-	wallet, err := h.walletService.TopUp(userID, req.Amount)
+	transaction, err := h.walletService.Move(middleware.GetRequestMeta(c), userID, req.FromWalletID, req.ToWalletID, req.Amount, req.QuoteID)
+	if err != nil {
+		walletErrorResponse(c, "Move failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Move successful", transaction.ToResponse())
+}
+
+// Stream godoc
+// @Summary Stream wallet notifications
+// @Description Server-Sent Events stream of the authenticated user's wallets. It starts with a balance_changed event carrying the current balance of each wallet, then pushes balance_changed and transfer_received events as changes commit. A resync event means notifications may have been missed; refetch the balance. The stream ends if the client falls too far behind and should be reopened.
+// @Tags Wallets
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Success 200 {object} models.WalletNotification
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/wallets/stream [get]
+func (h *WalletHandler) Stream(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	// Subscribe before reading the balance so no change falls in between
+	sub := h.broker.Subscribe(userID)
+	defer h.broker.Unsubscribe(sub)
+
+	wallets, err := h.walletService.ListWallets(userID)
 	if err != nil {
-		utils.ErrorResponse(c, http.StatusBadRequest, "Top up failed", err)
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve wallets", err)
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Top up successful", wallet)
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	now := time.Now()
+	for i := range wallets {
+		c.SSEvent(models.NotificationBalanceChanged, models.WalletNotification{
+			UserID:     userID,
+			Type:       models.NotificationBalanceChanged,
+			WalletID:   &wallets[i].ID,
+			Balance:    &wallets[i].Balance,
+			Currency:   &wallets[i].Currency,
+			OccurredAt: now,
+		})
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case n, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent(n.Type, n)
+			return true
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// walletIDQuery reads the optional wallet_id query parameter, writing the
+// error response if it is invalid. A missing parameter means the primary
+// wallet.
+func walletIDQuery(c *gin.Context) (*uuid.UUID, bool) {
+	v := c.Query("wallet_id")
+	if v == "" {
+		return nil, true
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid wallet ID", err)
+		return nil, false
+	}
+	return &id, true
+}
+
+// walletErrorResponse maps wallet errors to HTTP statuses.
+func walletErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrWalletNotFound), errors.Is(err, service.ErrFXQuoteNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrWalletLimitReached), errors.Is(err, service.ErrWalletNameTaken),
+		errors.Is(err, service.ErrWalletClosed), errors.Is(err, service.ErrWalletFrozen),
+		errors.Is(err, service.ErrFXQuoteExpired), errors.Is(err, service.ErrFXQuoteUsed):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
 }
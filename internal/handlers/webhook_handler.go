@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required" example:"https://example.com/hooks/ewallet"`
+	EventTypes []string `json:"event_types" binding:"required" example:"wallet.topped_up,transfer.completed"`
+}
+
+// CreateEndpoint godoc
+// @Summary Register webhook endpoint
+// @Description Register a URL to receive events about the authenticated user's account. The response contains the signing secret, which is not shown again. Every delivery carries X-Webhook-Timestamp and X-Webhook-Signature: "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateWebhookRequest true "Endpoint"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/webhooks [post]
+func (h *WebhookHandler) CreateEndpoint(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	endpoint, err := h.webhookService.CreateEndpoint(userID, req.URL, req.EventTypes)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrWebhookLimitReached) {
+			status = http.StatusConflict
+		}
+		utils.ErrorResponse(c, status, "Failed to register webhook", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Webhook registered successfully", endpoint)
+}
+
+// ListEndpoints godoc
+// @Summary List webhook endpoints
+// @Description List the authenticated user's webhook endpoints
+// @Tags Webhooks
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/webhooks [get]
+func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	endpoints, err := h.webhookService.ListEndpoints(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve webhooks", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Webhooks retrieved successfully", endpoints)
+}
+
+// DeleteEndpoint godoc
+// @Summary Delete webhook endpoint
+// @Description Delete a webhook endpoint together with its pending and dead-lettered deliveries
+// @Tags Webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Endpoint ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteEndpoint(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return
+	}
+
+	if err := h.webhookService.DeleteEndpoint(userID, endpointID); err != nil {
+		webhookErrorResponse(c, "Failed to delete webhook", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Webhook deleted successfully", nil)
+}
+
+// ListDeliveries godoc
+// @Summary List webhook deliveries
+// @Description List deliveries of a webhook endpoint, newest first. Use status=dead for the dead-letter queue: deliveries that failed every attempt.
+// @Tags Webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Endpoint ID"
+// @Param status query string false "Delivery status" Enums(pending, delivered, dead)
+// @Param limit query int false "Maximum results (max 100)" default(50)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return
+	}
+
+	var status *models.WebhookDeliveryStatus
+	if v := c.Query("status"); v != "" {
+		s := models.WebhookDeliveryStatus(v)
+		if !s.IsValid() {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid status %q", v))
+			return
+		}
+		status = &s
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultWebhookDeliveryLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultWebhookDeliveryLimit
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(userID, endpointID, status, limit)
+	if err != nil {
+		webhookErrorResponse(c, "Failed to retrieve deliveries", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Deliveries retrieved successfully", deliveries)
+}
+
+// ReplayDelivery godoc
+// @Summary Replay webhook delivery
+// @Description Queue a dead-lettered or delivered delivery again with a fresh set of attempts. The same payload is sent with a new timestamp and signature.
+// @Tags Webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Endpoint ID"
+// @Param deliveryId path string true "Delivery ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/webhooks/{id}/deliveries/{deliveryId}/replay [post]
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	endpointID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid endpoint ID", err)
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("deliveryId"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid delivery ID", err)
+		return
+	}
+
+	delivery, err := h.webhookService.ReplayDelivery(userID, endpointID, deliveryID)
+	if err != nil {
+		webhookErrorResponse(c, "Failed to replay delivery", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Delivery queued for replay", delivery)
+}
+
+// webhookErrorResponse maps webhook service errors to HTTP statuses.
+func webhookErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrWebhookDeliveryNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrWebhookDeliveryPending):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"ewallet/internal/bank"
+	"ewallet/internal/middleware"
+	"ewallet/internal/models"
+	"ewallet/internal/service"
+	"ewallet/pkg/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+)
+
+const (
+	// How far a payout callback's timestamp may be from now
+	payoutCallbackTolerance = 5 * time.Minute
+	payoutCallbackBodyLimit = 64 << 10
+)
+
+type WithdrawalHandler struct {
+	withdrawalService service.WithdrawalService
+	callbackSecret    string
+}
+
+// NewWithdrawalHandler creates the handler. callbackSecret verifies the
+// signatures of payout result callbacks.
+func NewWithdrawalHandler(withdrawalService service.WithdrawalService, callbackSecret string) *WithdrawalHandler {
+	return &WithdrawalHandler{
+		withdrawalService: withdrawalService,
+		callbackSecret:    callbackSecret,
+	}
+}
+
+type AddBankAccountRequest struct {
+	BankCode      string `json:"bank_code" binding:"required" example:"BCA"`
+	AccountNumber string `json:"account_number" binding:"required" example:"1234567890"`
+	AccountName   string `json:"account_name" binding:"required" example:"John Doe"`
+}
+
+type WithdrawRequest struct {
+	WalletID      *uuid.UUID   `json:"wallet_id"`
+	BankAccountID uuid.UUID    `json:"bank_account_id" binding:"required"`
+	Amount        models.Money `json:"amount" binding:"required,gt=0" swaggertype:"number" example:"250000"`
+	PIN           string       `json:"pin" binding:"required" example:"123456"`
+}
+
+// ListBankAccounts godoc
+// @Summary List bank accounts
+// @Description List the bank accounts the authenticated user can withdraw to, oldest first
+// @Tags Withdrawals
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/bank-accounts [get]
+func (h *WithdrawalHandler) ListBankAccounts(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	accounts, err := h.withdrawalService.ListBankAccounts(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve bank accounts", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Bank accounts retrieved successfully", accounts)
+}
+
+// AddBankAccount godoc
+// @Summary Add a bank account
+// @Description Add a bank account to withdraw to, at most 5 per user. bank_code is 3 to 11 letters or digits and account_number 5 to 20 digits. The account is checked with the bank only when a payout is made to it.
+// @Tags Withdrawals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body AddBankAccountRequest true "Bank account"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/bank-accounts [post]
+func (h *WithdrawalHandler) AddBankAccount(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req AddBankAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	account, err := h.withdrawalService.AddBankAccount(middleware.GetRequestMeta(c), userID, service.BankAccountInput{
+		BankCode:      req.BankCode,
+		AccountNumber: req.AccountNumber,
+		AccountName:   req.AccountName,
+	})
+	if err != nil {
+		withdrawalErrorResponse(c, "Failed to add bank account", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Bank account added successfully", account)
+}
+
+// RemoveBankAccount godoc
+// @Summary Remove a bank account
+// @Description Remove one of the authenticated user's bank accounts. Withdrawals already requested to it are still paid out.
+// @Tags Withdrawals
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Bank account ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/bank-accounts/{id} [delete]
+func (h *WithdrawalHandler) RemoveBankAccount(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid bank account ID", err)
+		return
+	}
+
+	if err := h.withdrawalService.RemoveBankAccount(middleware.GetRequestMeta(c), userID, accountID); err != nil {
+		withdrawalErrorResponse(c, "Failed to remove bank account", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Bank account removed successfully", nil)
+}
+
+// Withdraw godoc
+// @Summary Withdraw to a bank account
+// @Description Pay money out of wallet_id, or the primary wallet if it is omitted, into one of the user's bank accounts. Requires a verified identity and the transaction PIN. The amount is in the wallet's currency and leaves the wallet right away; the withdrawal stays pending until it is sent to the payout provider, then processing until the provider reports the result. A failed payout returns the money to the wallet. Withdrawals are free and count against the withdrawal limits. Send an Idempotency-Key header to make retries safe.
+// @Tags Withdrawals
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param Idempotency-Key header string false "Unique key that makes retries of this request safe"
+// @Param request body WithdrawRequest true "Withdrawal"
+// @Success 201 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/withdrawals [post]
+func (h *WithdrawalHandler) Withdraw(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var req WithdrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	withdrawal, err := h.withdrawalService.Request(middleware.GetRequestMeta(c), userID, service.WithdrawalInput{
+		WalletID:      req.WalletID,
+		BankAccountID: req.BankAccountID,
+		Amount:        req.Amount,
+	}, req.PIN)
+	if err != nil {
+		withdrawalErrorResponse(c, "Withdrawal failed", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Withdrawal requested successfully", withdrawal)
+}
+
+// List godoc
+// @Summary List withdrawals
+// @Description List the authenticated user's withdrawals, newest first
+// @Tags Withdrawals
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Withdrawal status" Enums(pending, processing, unknown, success, failed)
+// @Param limit query int false "Maximum results (max 100)" default(50)
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 500 {object} utils.Response
+// @Router /api/withdrawals [get]
+func (h *WithdrawalHandler) List(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	var status *models.WithdrawalStatus
+	if v := c.Query("status"); v != "" {
+		s := models.WithdrawalStatus(v)
+		if !s.IsValid() {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query parameter", fmt.Errorf("invalid status %q", v))
+			return
+		}
+		status = &s
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(service.DefaultWithdrawalLimit)))
+	if err != nil || limit <= 0 {
+		limit = service.DefaultWithdrawalLimit
+	}
+
+	withdrawals, err := h.withdrawalService.List(userID, status, limit)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to retrieve withdrawals", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Withdrawals retrieved successfully", withdrawals)
+}
+
+// Get godoc
+// @Summary Get withdrawal
+// @Description Get one of the authenticated user's withdrawals with its bank account
+// @Tags Withdrawals
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Withdrawal ID"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Router /api/withdrawals/{id} [get]
+func (h *WithdrawalHandler) Get(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+		return
+	}
+
+	withdrawalID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid withdrawal ID", err)
+		return
+	}
+
+	withdrawal, err := h.withdrawalService.Get(userID, withdrawalID)
+	if err != nil {
+		withdrawalErrorResponse(c, "Failed to retrieve withdrawal", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Withdrawal retrieved successfully", withdrawal)
+}
+
+// PayoutCallback godoc
+// @Summary Receive a payout result
+// @Description Called by the payout provider with the outcome of a withdrawal. The body must be signed like webhook deliveries, with the payout callback secret, in the X-Payout-Timestamp and X-Payout-Signature headers. Reporting the same result again is accepted; a different result for a completed withdrawal is a conflict.
+// @Tags Withdrawals
+// @Accept json
+// @Produce json
+// @Param X-Payout-Timestamp header string true "Unix time the body was signed at"
+// @Param X-Payout-Signature header string true "sha256=<hex HMAC-SHA256 of timestamp.body>"
+// @Param request body bank.PayoutResult true "Payout result"
+// @Success 200 {object} utils.Response
+// @Failure 400 {object} utils.Response
+// @Failure 401 {object} utils.Response
+// @Failure 404 {object} utils.Response
+// @Failure 409 {object} utils.Response
+// @Router /api/payouts/callback [post]
+func (h *WithdrawalHandler) PayoutCallback(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, payoutCallbackBodyLimit))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	timestamp := c.GetHeader(bank.HeaderTimestamp)
+	signature := c.GetHeader(bank.HeaderSignature)
+	if !utils.VerifyPayloadSignature(h.callbackSecret, timestamp, signature, body, payoutCallbackTolerance) {
+		utils.ErrorResponse(c, http.StatusUnauthorized, "Invalid signature", nil)
+		return
+	}
+
+	var result bank.PayoutResult
+	if err := binding.JSON.BindBody(body, &result); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	withdrawal, err := h.withdrawalService.HandleResult(middleware.GetRequestMeta(c), result)
+	if err != nil {
+		withdrawalErrorResponse(c, "Failed to record payout result", err)
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Payout result recorded successfully", gin.H{
+		"withdrawal_id": withdrawal.ID,
+		"status":        withdrawal.Status,
+	})
+}
+
+// withdrawalErrorResponse maps bank account and withdrawal errors to HTTP
+// statuses.
+func withdrawalErrorResponse(c *gin.Context, message string, err error) {
+	switch {
+	case errors.Is(err, service.ErrBankAccountNotFound), errors.Is(err, service.ErrWithdrawalNotFound), errors.Is(err, service.ErrWalletNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, message, err)
+	case errors.Is(err, service.ErrBankAccountExists), errors.Is(err, service.ErrBankAccountLimitReached),
+		errors.Is(err, service.ErrWalletClosed), errors.Is(err, service.ErrWalletFrozen),
+		errors.Is(err, service.ErrWithdrawalCompleted):
+		utils.ErrorResponse(c, http.StatusConflict, message, err)
+	default:
+		var serviceErr *service.Error
+		if errors.As(err, &serviceErr) {
+			utils.ErrorResponse(c, http.StatusBadRequest, message, err)
+			return
+		}
+		utils.ErrorResponse(c, http.StatusInternalServerError, message, err)
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
 	"ewallet/pkg/utils"
 	"net/http"
 	"strings"
@@ -9,7 +11,9 @@ import (
 	"github.com/google/uuid"
 )
 
-func AuthMiddleware(jwtUtil *utils.JWTUtil) gin.HandlerFunc {
+// AuthMiddleware accepts a valid access token whose session has not been
+// revoked by logout or refresh token reuse.
+func AuthMiddleware(jwtUtil *utils.JWTUtil, sessionRepo repository.SessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -33,12 +37,37 @@ func AuthMiddleware(jwtUtil *utils.JWTUtil) gin.HandlerFunc {
 			return
 		}
 
+		session, err := sessionRepo.FindByID(claims.SessionID)
+		if err != nil || session.UserID != claims.UserID || session.IsRevoked() {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Session has been revoked", nil)
+			c.Abort()
+			return
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		c.Set("role", models.Role(claims.Role))
 		c.Next()
 	}
 }
 
+// RequireRole only lets through users whose token carries one of the roles.
+// It must be registered after AuthMiddleware.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := GetRole(c)
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		utils.ErrorResponse(c, http.StatusForbidden, "Forbidden", nil)
+		c.Abort()
+	}
+}
+
 func GetUserID(c *gin.Context) (uuid.UUID, bool) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -48,3 +77,23 @@ func GetUserID(c *gin.Context) (uuid.UUID, bool) {
 	id, ok := userID.(uuid.UUID)
 	return id, ok
 }
+
+func GetSessionID(c *gin.Context) (uuid.UUID, bool) {
+	sessionID, exists := c.Get("session_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+
+	id, ok := sessionID.(uuid.UUID)
+	return id, ok
+}
+
+func GetRole(c *gin.Context) (models.Role, bool) {
+	role, exists := c.Get("role")
+	if !exists {
+		return "", false
+	}
+
+	r, ok := role.(models.Role)
+	return r, ok
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"ewallet/pkg/utils"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	jsonContentType          = "application/json; charset=utf-8"
+)
+
+// responseRecorder holds back everything the handler writes, so the
+// response reaches the client only once it has been stored.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// WriteHeaderNow is deferred to flush.
+func (w *responseRecorder) WriteHeaderNow() {}
+
+// flush sends the held back response.
+func (w *responseRecorder) flush() {
+	w.ResponseWriter.WriteHeaderNow()
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// IdempotencyMiddleware makes a route safe to retry when the client sends an
+// Idempotency-Key header. The first response for a user and key is stored and
+// replayed for later requests with the same key and payload. Reusing a key
+// with a different payload is rejected, and a duplicate that arrives while
+// the first request is still running gets 409 instead of running again. A
+// key whose request never stored its response, for example because the
+// server stopped while handling it, is never run again: the request may have
+// moved money, so retries keep getting 409 and the client can look the key
+// up instead. The response is sent only after it has been stored; if it
+// cannot be stored the request fails with 500. Requests without the header
+// are passed through unchanged. It must be registered after AuthMiddleware.
+func IdempotencyMiddleware(repo repository.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid Idempotency-Key header", errors.New("key must be at most 255 characters"))
+			c.Abort()
+			return
+		}
+
+		userID, ok := GetUserID(c)
+		if !ok {
+			utils.ErrorResponse(c, http.StatusUnauthorized, "Unauthorized", nil)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid request body", err)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Hash the concrete path and query, so one key cannot replay the
+		// response for another resource of the same route
+		record := &models.IdempotencyKey{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: hashRequest(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, body),
+		}
+
+		claimed, err := repo.Claim(record)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process Idempotency-Key", err)
+			c.Abort()
+			return
+		}
+
+		if !claimed {
+			replayIdempotent(c, repo, record)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+
+		// Give the key back if the handler panics so the client can retry,
+		// and let the recovery handler answer
+		defer func() {
+			if r := recover(); r != nil {
+				c.Writer = recorder.ResponseWriter
+				if err := repo.Release(record.ID); err != nil {
+					log.Printf("failed to release idempotency key %s: %v", record.ID, err)
+				}
+				panic(r)
+			}
+		}()
+
+		c.Writer = recorder
+		c.Next()
+		c.Writer = recorder.ResponseWriter
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			// Server errors are not final; let the client retry with the same key
+			if err := repo.Release(record.ID); err != nil {
+				log.Printf("failed to release idempotency key %s: %v", record.ID, err)
+			}
+			recorder.flush()
+			return
+		}
+
+		// The request may have moved money, so the key stays claimed and
+		// every retry gets 409
+		if err := repo.Complete(record.ID, status, recorder.body.String()); err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to store idempotent response", err)
+			return
+		}
+		recorder.flush()
+	}
+}
+
+func replayIdempotent(c *gin.Context, repo repository.IdempotencyRepository, record *models.IdempotencyKey) {
+	existing, err := repo.FindByUserAndKey(record.UserID, record.Key)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to process Idempotency-Key", err)
+		c.Abort()
+		return
+	}
+
+	if existing.RequestHash != record.RequestHash {
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, "Idempotency-Key already used",
+			errors.New("the key was used with a different request"))
+		c.Abort()
+		return
+	}
+
+	if existing.CompletedAt == nil {
+		utils.ErrorResponse(c, http.StatusConflict, "Request already in progress",
+			errors.New("a request with this Idempotency-Key is still being processed or did not finish"))
+		c.Abort()
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.StatusCode, jsonContentType, []byte(existing.ResponseBody))
+	c.Abort()
+}
+
+func hashRequest(method, path, query string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write([]byte(query))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"ewallet/internal/models"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID limits client-supplied request IDs to short, printable
+// tokens so they are safe to log and store.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,100}$`)
+
+// RequestIDMiddleware tags every request with an ID, reusing the caller's
+// X-Request-ID when it is well formed, and echoes it in the response.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		c.Set("request_id", requestID)
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+func GetRequestID(c *gin.Context) string {
+	return c.GetString("request_id")
+}
+
+// GetRequestMeta describes the request for the audit log. The actor is set
+// only on routes behind AuthMiddleware.
+func GetRequestMeta(c *gin.Context) models.RequestMeta {
+	meta := models.RequestMeta{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: GetRequestID(c),
+	}
+	if userID, ok := GetUserID(c); ok {
+		meta.ActorID = &userID
+	}
+	return meta
+}
@@ -0,0 +1,214 @@
+package models
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Audited actions
+const (
+	AuditActionUserRegistered             = "user.registered"
+	AuditActionLoginSucceeded             = "auth.login_succeeded"
+	AuditActionLoginFailed                = "auth.login_failed"
+	AuditActionLogout                     = "auth.logout"
+	AuditActionRefreshTokenReused         = "auth.refresh_token_reused"
+	AuditActionProfileViewed              = "user.profile_viewed"
+	AuditActionUserStatusChanged          = "user.status_changed"
+	AuditActionTopUpRequested             = "wallet.topup_requested"
+	AuditActionWalletToppedUp             = "wallet.topped_up"
+	AuditActionTopUpFailed                = "wallet.topup_failed"
+	AuditActionTopUpHeld                  = "wallet.topup_held"
+	AuditActionWalletStatusChanged        = "wallet.status_changed"
+	AuditActionWalletCreated              = "wallet.created"
+	AuditActionWalletUpdated              = "wallet.updated"
+	AuditActionWalletMoved                = "wallet.moved"
+	AuditActionTransferSucceeded          = "transfer.succeeded"
+	AuditActionTransferFailed             = "transfer.failed"
+	AuditActionTransferReversed           = "transfer.reversed"
+	AuditActionTransferRefunded           = "transfer.refunded"
+	AuditActionPaymentRequested           = "payment_request.created"
+	AuditActionPaymentAccepted            = "payment_request.accepted"
+	AuditActionPaymentDeclined            = "payment_request.declined"
+	AuditActionPaymentCancelled           = "payment_request.cancelled"
+	AuditActionScheduledTransferCreated   = "scheduled_transfer.created"
+	AuditActionScheduledTransferUpdated   = "scheduled_transfer.updated"
+	AuditActionScheduledTransferCancelled = "scheduled_transfer.cancelled"
+	AuditActionKYCSubmitted               = "kyc.submitted"
+	AuditActionKYCApproved                = "kyc.approved"
+	AuditActionKYCRejected                = "kyc.rejected"
+	AuditActionBankAccountAdded           = "bank_account.added"
+	AuditActionBankAccountRemoved         = "bank_account.removed"
+	AuditActionWithdrawalRequested        = "withdrawal.requested"
+	AuditActionWithdrawalCompleted        = "withdrawal.completed"
+	AuditActionWithdrawalFailed           = "withdrawal.failed"
+)
+
+// Kinds of objects an audit event can point at
+const (
+	AuditTargetUser              = "user"
+	AuditTargetSession           = "session"
+	AuditTargetWallet            = "wallet"
+	AuditTargetTransaction       = "transaction"
+	AuditTargetKYCSubmission     = "kyc_submission"
+	AuditTargetPaymentRequest    = "payment_request"
+	AuditTargetScheduledTransfer = "scheduled_transfer"
+	AuditTargetBankAccount       = "bank_account"
+	AuditTargetWithdrawal        = "withdrawal"
+	AuditTargetTopUp             = "topup"
+)
+
+// RequestMeta describes the HTTP request that caused a change, for auditing.
+// ActorID is the authenticated user, if any.
+type RequestMeta struct {
+	ActorID   *uuid.UUID
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+// AuditPayload is event-specific JSON stored exactly as written, so the hash
+// computed at insert time can be recomputed from the stored row.
+type AuditPayload string
+
+// NewAuditPayload encodes v as the payload of an event.
+func NewAuditPayload(v interface{}) (AuditPayload, error) {
+	if v == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return AuditPayload(b), nil
+}
+
+func (p AuditPayload) MarshalJSON() ([]byte, error) {
+	if p == "" {
+		return []byte("null"), nil
+	}
+	return []byte(p), nil
+}
+
+func (p *AuditPayload) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = ""
+	case []byte:
+		*p = AuditPayload(v)
+	case string:
+		*p = AuditPayload(v)
+	default:
+		return fmt.Errorf("cannot scan %T into AuditPayload", value)
+	}
+	return nil
+}
+
+func (p AuditPayload) Value() (driver.Value, error) {
+	if p == "" {
+		return nil, nil
+	}
+	return string(p), nil
+}
+
+// AuditEvent is one entry of the append-only audit log. Events form a hash
+// chain: each Hash covers the event's fields and the previous event's Hash,
+// so changing or removing a stored event breaks every hash after it.
+type AuditEvent struct {
+	ID         uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Sequence   int64        `gorm:"not null;uniqueIndex" json:"sequence"`
+	ActorID    *uuid.UUID   `gorm:"type:uuid;index" json:"actor_id,omitempty"`
+	Action     string       `gorm:"type:varchar(100);not null" json:"action"`
+	TargetType string       `gorm:"type:varchar(50)" json:"target_type,omitempty"`
+	TargetID   string       `gorm:"type:varchar(255)" json:"target_id,omitempty"`
+	IP         string       `gorm:"column:ip;type:varchar(45)" json:"ip,omitempty"`
+	UserAgent  string       `gorm:"type:text" json:"user_agent,omitempty"`
+	RequestID  string       `gorm:"type:varchar(100)" json:"request_id,omitempty"`
+	Payload    AuditPayload `gorm:"type:json" json:"payload,omitempty" swaggertype:"object"`
+	PrevHash   string       `gorm:"type:varchar(64);not null" json:"prev_hash"`
+	Hash       string       `gorm:"type:varchar(64);not null" json:"hash"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// QueuedAuditEvent is a recorded event waiting to be appended to the hash
+// chain. Events are chained in Position order.
+type QueuedAuditEvent struct {
+	Position   int64        `gorm:"primaryKey;autoIncrement"`
+	ID         uuid.UUID    `gorm:"type:uuid;not null;uniqueIndex"`
+	ActorID    *uuid.UUID   `gorm:"type:uuid"`
+	Action     string       `gorm:"type:varchar(100);not null"`
+	TargetType string       `gorm:"type:varchar(50)"`
+	TargetID   string       `gorm:"type:varchar(255)"`
+	IP         string       `gorm:"column:ip;type:varchar(45)"`
+	UserAgent  string       `gorm:"type:text"`
+	RequestID  string       `gorm:"type:varchar(100)"`
+	Payload    AuditPayload `gorm:"type:json"`
+	CreatedAt  time.Time
+}
+
+// Event returns the event to append, without its chain fields.
+func (q *QueuedAuditEvent) Event() AuditEvent {
+	return AuditEvent{
+		ID:         q.ID,
+		ActorID:    q.ActorID,
+		Action:     q.Action,
+		TargetType: q.TargetType,
+		TargetID:   q.TargetID,
+		IP:         q.IP,
+		UserAgent:  q.UserAgent,
+		RequestID:  q.RequestID,
+		Payload:    q.Payload,
+		CreatedAt:  q.CreatedAt,
+	}
+}
+
+// ComputeHash returns the chain hash of the event from its fields and
+// PrevHash. CreatedAt is hashed at microsecond precision, which is what
+// Postgres stores.
+func (e *AuditEvent) ComputeHash() string {
+	actor := ""
+	if e.ActorID != nil {
+		actor = e.ActorID.String()
+	}
+	fields := []string{
+		e.PrevHash,
+		fmt.Sprint(e.Sequence),
+		e.ID.String(),
+		actor,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		e.IP,
+		e.UserAgent,
+		e.RequestID,
+		string(e.Payload),
+		e.CreatedAt.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano),
+	}
+
+	h := sha256.New()
+	for _, f := range fields {
+		// Length-prefix every field so no two field lists hash the same
+		fmt.Fprintf(h, "%d:%s", len(f), f)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AuditPage is one page of audit events, newest first.
+type AuditPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// AuditVerification is the result of checking the audit hash chain.
+type AuditVerification struct {
+	Valid        bool   `json:"valid"`
+	Checked      int64  `json:"checked"`
+	BrokenAt     *int64 `json:"broken_at,omitempty"`
+	LastSequence int64  `json:"last_sequence"`
+	LastHash     string `json:"last_hash,omitempty"`
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Currency is an ISO 4217 currency code such as "IDR" or "USD". Amounts in
+// every currency are kept with two decimal places.
+type Currency string
+
+// DefaultCurrency is the currency of wallets opened without one, and the
+// currency limits are expressed in.
+const DefaultCurrency Currency = "IDR"
+
+// IsValid reports whether the code is made of three upper-case letters.
+func (c Currency) IsValid() bool {
+	if len(c) != 3 {
+		return false
+	}
+	for _, r := range c {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCurrency normalizes a currency code, so "usd" becomes "USD".
+func ParseCurrency(s string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(s)))
+	if !c.IsValid() {
+		return "", ErrInvalidCurrency
+	}
+	return c, nil
+}
+
+// Rate is an exchange rate stored as an integer number of 10^-8 units,
+// matching the NUMERIC(20,8) columns in the database. A rate from A to B
+// is the amount of B one unit of A buys.
+type Rate int64
+
+// RateScale is the number of Rate units in a rate of 1.
+const RateScale = 100000000
+
+var (
+	ErrInvalidCurrency = errors.New("invalid currency code")
+	ErrInvalidRate     = errors.New("invalid exchange rate")
+	ErrRateTooPrecise  = errors.New("rate must not have more than eight decimal places")
+)
+
+var rateScaleRat = big.NewRat(RateScale, 1)
+
+// ParseRate parses a positive decimal string such as "16250" or "0.0000615".
+func ParseRate(s string) (Rate, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok || r.Sign() <= 0 {
+		return 0, ErrInvalidRate
+	}
+
+	r.Mul(r, rateScaleRat)
+	if !r.IsInt() {
+		return 0, ErrRateTooPrecise
+	}
+	if !r.Num().IsInt64() {
+		return 0, ErrInvalidRate
+	}
+	return Rate(r.Num().Int64()), nil
+}
+
+// String formats the rate with its significant decimals, e.g. "16250" or
+// "0.00006154".
+func (r Rate) String() string {
+	s := fmt.Sprintf("%d.%08d", r/RateScale, r%RateScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Convert applies the rate to an amount, rounding toward zero to the minor
+// unit. The result is never worth more than the amount, so converting back
+// and forth can lose a fraction of a minor unit but never create one.
+func (r Rate) Convert(amount Money) (Money, error) {
+	n := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(r)))
+	converted := new(big.Rat).SetFrac(n, big.NewInt(RateScale))
+	m, err := truncRat(converted)
+	if err != nil {
+		return 0, err
+	}
+	if Money(m) > MaxMoney || Money(m) < -MaxMoney {
+		return 0, ErrMoneyOutOfRange
+	}
+	return Money(m), nil
+}
+
+// Inverse returns the rate of the opposite direction, rounded down to eight
+// decimal places so the two rates multiply to at most 1.
+func (r Rate) Inverse() Rate {
+	inverse, err := truncRat(new(big.Rat).SetFrac(big.NewInt(RateScale*RateScale), big.NewInt(int64(r))))
+	if err != nil || inverse <= 0 {
+		return 0
+	}
+	return Rate(inverse)
+}
+
+// Mul chains two rates, A to B followed by B to C, into a rate from A to C,
+// rounded down to eight decimal places.
+func (r Rate) Mul(other Rate) Rate {
+	n := new(big.Int).Mul(big.NewInt(int64(r)), big.NewInt(int64(other)))
+	product, err := truncRat(new(big.Rat).SetFrac(n, big.NewInt(RateScale)))
+	if err != nil || product <= 0 {
+		return 0
+	}
+	return Rate(product)
+}
+
+// truncRat rounds toward zero to an integer.
+func truncRat(r *big.Rat) (int64, error) {
+	q := new(big.Int).Quo(r.Num(), r.Denom())
+	if !q.IsInt64() {
+		return 0, ErrMoneyOutOfRange
+	}
+	return q.Int64(), nil
+}
+
+// MarshalJSON encodes the rate as a JSON number.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return ErrInvalidRate
+		}
+		raw = s
+	}
+
+	parsed, err := ParseRate(raw)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so the rate is written as an exact decimal.
+func (r Rate) Value() (driver.Value, error) {
+	return r.String(), nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns.
+func (r *Rate) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = 0
+		return nil
+	case []byte:
+		parsed, err := ParseRate(string(v))
+		if err != nil {
+			return err
+		}
+		*r = parsed
+		return nil
+	case string:
+		parsed, err := ParseRate(v)
+		if err != nil {
+			return err
+		}
+		*r = parsed
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Rate", value)
+	}
+}
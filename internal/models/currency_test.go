@@ -0,0 +1,81 @@
+package models
+
+import "testing"
+
+func TestRateConvertRoundsTowardZero(t *testing.T) {
+	usdToIDR, err := ParseRate("16250")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idrToUSD := usdToIDR.Inverse()
+	if want := Rate(6153); idrToUSD != want {
+		t.Fatalf("Inverse() = %s, want %s", idrToUSD, want)
+	}
+
+	tests := []struct {
+		name   string
+		rate   Rate
+		amount Money
+		want   Money
+	}{
+		{"exact", usdToIDR, 1, 16250},
+		{"half a cent is dropped", idrToUSD, 8125, 0},
+		{"just under a cent is dropped", idrToUSD, 16250, 0},
+		{"whole cents", idrToUSD, 1625000, 99},
+		{"negative amounts round toward zero", idrToUSD, -8125, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rate.Convert(tt.amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Convert(%s) = %s, want %s", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateRoundTripNeverCreatesMoney(t *testing.T) {
+	for _, spec := range []string{"16250", "12100", "1.35", "0.7", "3"} {
+		rate, err := ParseRate(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inverse := rate.Inverse()
+		for amount := Money(1); amount <= 50000; amount += 7 {
+			there, err := rate.Convert(amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err := inverse.Convert(there)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if back > amount {
+				t.Fatalf("rate %s: %s converted there and back became %s", rate, amount, back)
+			}
+
+			there, err = inverse.Convert(amount)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, err = rate.Convert(there)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if back > amount {
+				t.Fatalf("rate %s: %s converted back and there became %s", inverse, amount, back)
+			}
+		}
+	}
+}
+
+func TestRateMulRoundsDown(t *testing.T) {
+	// 1/3 chained with 3 is just under 1
+	third := Rate(3 * RateScale).Inverse()
+	if got := third.Mul(Rate(3 * RateScale)); got >= RateScale {
+		t.Errorf("Mul() = %s, want less than 1", got)
+	}
+}
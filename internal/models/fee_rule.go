@@ -0,0 +1,71 @@
+package models
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FeeRule prices transactions of one type and currency whose amount falls
+// in the band from MinAmount (inclusive) to MaxAmount (exclusive; nil means
+// no upper bound). A rule with a KYC tier applies only to users of that
+// tier and takes precedence over rules without one. The fee is FlatFee plus
+// PercentBps basis points of the amount, capped at MaxFee when set and never
+// more than the amount itself.
+type FeeRule struct {
+	ID              uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TransactionType TransactionType `gorm:"type:varchar(20);not null" json:"transaction_type"`
+	KYCTier         *KYCTier        `gorm:"column:kyc_tier;type:varchar(20)" json:"kyc_tier,omitempty"`
+	Currency        Currency        `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	MinAmount       Money           `gorm:"type:decimal(15,2);not null;default:0" json:"min_amount" swaggertype:"number"`
+	MaxAmount       *Money          `gorm:"type:decimal(15,2)" json:"max_amount,omitempty" swaggertype:"number"`
+	FlatFee         Money           `gorm:"type:decimal(15,2);not null;default:0" json:"flat_fee" swaggertype:"number"`
+	PercentBps      int64           `gorm:"not null;default:0" json:"percent_bps"`
+	MaxFee          *Money          `gorm:"type:decimal(15,2)" json:"max_fee,omitempty" swaggertype:"number"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (r *FeeRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// FeeFor returns the fee on amount. The percentage is rounded half up to the
+// nearest minor unit; it is worked out in big integers, as amounts near
+// MaxMoney times the basis points do not fit in an int64.
+func (r *FeeRule) FeeFor(amount Money) Money {
+	n := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(r.PercentBps))
+	n.Add(n, big.NewInt(5000))
+	n.Quo(n, big.NewInt(10000))
+
+	// A percentage of the whole amount or more is capped below anyway
+	fee := amount
+	if n.Cmp(big.NewInt(int64(amount))) < 0 {
+		fee = r.FlatFee + Money(n.Int64())
+	}
+	if r.MaxFee != nil && fee > *r.MaxFee {
+		fee = *r.MaxFee
+	}
+	if fee > amount {
+		fee = amount
+	}
+	return fee
+}
+
+// FeeQuote is the fee a transaction of Amount would be charged, in Currency.
+// Total is what the payer pays: the amount plus the fee. RuleID is the rule
+// that priced it, unset when no rule applies and the transaction is free.
+type FeeQuote struct {
+	Type     TransactionType `json:"type"`
+	Amount   Money           `json:"amount" swaggertype:"number"`
+	Fee      Money           `json:"fee" swaggertype:"number"`
+	Total    Money           `json:"total" swaggertype:"number"`
+	Currency Currency        `json:"currency"`
+	RuleID   *uuid.UUID      `json:"rule_id,omitempty"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "testing"
+
+func TestFeeRuleFeeFor(t *testing.T) {
+	maxFee := NewMoney(5000)
+	tests := []struct {
+		name   string
+		rule   FeeRule
+		amount Money
+		want   Money
+	}{
+		{"flat", FeeRule{FlatFee: NewMoney(2500)}, NewMoney(100000), NewMoney(2500)},
+		{"percentage rounded half up", FeeRule{PercentBps: 15}, 3333, 5},
+		{"flat plus percentage", FeeRule{FlatFee: 100, PercentBps: 100}, NewMoney(1000), 1100},
+		{"capped at max_fee", FeeRule{PercentBps: 100, MaxFee: &maxFee}, NewMoney(1000000), maxFee},
+		{"never more than the amount", FeeRule{FlatFee: NewMoney(2500)}, NewMoney(1000), NewMoney(1000)},
+		{"largest amount does not overflow", FeeRule{PercentBps: 10}, MaxMoney, 1000000000000},
+		{"whole amount at the largest amount", FeeRule{FlatFee: 1, PercentBps: 10000}, MaxMoney, MaxMoney},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.FeeFor(tt.amount); got != tt.want {
+			t.Errorf("%s: FeeFor(%d) = %d, want %d", tt.name, tt.amount, got, tt.want)
+		}
+	}
+}
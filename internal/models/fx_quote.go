@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FXQuote locks an exchange rate for one conversion of SourceAmount into
+// TargetAmount. It can be redeemed once, by the user it was quoted for,
+// before it expires; redeeming it links the transaction that used it.
+type FXQuote struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	FromCurrency  Currency   `gorm:"type:varchar(3);not null" json:"from_currency" swaggertype:"string"`
+	ToCurrency    Currency   `gorm:"type:varchar(3);not null" json:"to_currency" swaggertype:"string"`
+	Rate          Rate       `gorm:"type:numeric(20,8);not null" json:"rate" swaggertype:"number"`
+	SourceAmount  Money      `gorm:"type:decimal(15,2);not null" json:"source_amount" swaggertype:"number"`
+	TargetAmount  Money      `gorm:"type:decimal(15,2);not null" json:"target_amount" swaggertype:"number"`
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	TransactionID *uuid.UUID `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// IsExpired reports whether an unused quote can no longer be redeemed.
+func (q *FXQuote) IsExpired(now time.Time) bool {
+	return q.UsedAt == nil && !now.Before(q.ExpiresAt)
+}
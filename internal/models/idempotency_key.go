@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IdempotencyKey stores the first response produced for a client-supplied
+// Idempotency-Key so retries can be answered without repeating the work.
+// A record with a nil CompletedAt is still being processed.
+type IdempotencyKey struct {
+	ID           uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID       uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_keys_user_key" json:"user_id"`
+	Key          string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_keys_user_key" json:"key"`
+	RequestHash  string     `gorm:"type:varchar(64);not null" json:"request_hash"`
+	StatusCode   int        `gorm:"not null;default:0" json:"status_code"`
+	ResponseBody string     `gorm:"type:text" json:"response_body"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// KYCTier is how far a user's identity has been verified.
+type KYCTier string
+
+const (
+	KYCTierBasic    KYCTier = "basic"
+	KYCTierVerified KYCTier = "verified"
+	KYCTierPremium  KYCTier = "premium"
+)
+
+var kycTierLevels = map[KYCTier]int{
+	KYCTierBasic:    0,
+	KYCTierVerified: 1,
+	KYCTierPremium:  2,
+}
+
+// IsValid reports whether the tier is one of the known tiers.
+func (t KYCTier) IsValid() bool {
+	_, ok := kycTierLevels[t]
+	return ok
+}
+
+// AtLeast reports whether the tier is the same as or above the other tier.
+func (t KYCTier) AtLeast(other KYCTier) bool {
+	return kycTierLevels[t] >= kycTierLevels[other]
+}
+
+// Feature is a wallet capability gated by KYC tier.
+type Feature string
+
+const (
+	FeatureTopUp      Feature = "topup"
+	FeatureTransfer   Feature = "transfer"
+	FeatureWithdrawal Feature = "withdrawal"
+)
+
+// featureTiers is the minimum tier each feature needs.
+var featureTiers = map[Feature]KYCTier{
+	FeatureTopUp:      KYCTierBasic,
+	FeatureTransfer:   KYCTierVerified,
+	FeatureWithdrawal: KYCTierVerified,
+}
+
+// RequiredTier returns the minimum KYC tier needed to use the feature.
+func RequiredTier(feature Feature) KYCTier {
+	if tier, ok := featureTiers[feature]; ok {
+		return tier
+	}
+	return KYCTierPremium
+}
+
+type KYCStatus string
+
+const (
+	KYCStatusPending  KYCStatus = "pending"
+	KYCStatusApproved KYCStatus = "approved"
+	KYCStatusRejected KYCStatus = "rejected"
+)
+
+type KYCDocumentType string
+
+const (
+	KYCDocumentIDCard         KYCDocumentType = "id_card"
+	KYCDocumentSelfie         KYCDocumentType = "selfie"
+	KYCDocumentProofOfAddress KYCDocumentType = "proof_of_address"
+)
+
+// RequiredDocuments returns the documents a submission for the tier must include.
+func RequiredDocuments(tier KYCTier) []KYCDocumentType {
+	if tier == KYCTierPremium {
+		return []KYCDocumentType{KYCDocumentIDCard, KYCDocumentSelfie, KYCDocumentProofOfAddress}
+	}
+	return []KYCDocumentType{KYCDocumentIDCard, KYCDocumentSelfie}
+}
+
+// KYCSubmission is a request to move a user to a higher tier, waiting for or
+// after admin review.
+type KYCSubmission struct {
+	ID            uuid.UUID     `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID     `gorm:"type:uuid;index;not null" json:"user_id"`
+	RequestedTier KYCTier       `gorm:"type:varchar(20);not null" json:"requested_tier"`
+	FullName      string        `gorm:"type:varchar(100);not null" json:"full_name"`
+	IDNumber      string        `gorm:"type:varchar(32);not null" json:"id_number"`
+	DateOfBirth   time.Time     `gorm:"type:date;not null" json:"date_of_birth"`
+	Address       string        `gorm:"type:text;not null" json:"address"`
+	Status        KYCStatus     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	ReviewNote    *string       `gorm:"type:text" json:"review_note,omitempty"`
+	ReviewedBy    *uuid.UUID    `gorm:"type:uuid" json:"reviewed_by,omitempty"`
+	ReviewedAt    *time.Time    `json:"reviewed_at,omitempty"`
+	Documents     []KYCDocument `gorm:"foreignKey:SubmissionID" json:"documents,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (s *KYCSubmission) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// KYCDocument is an uploaded file stored on local disk. FilePath is relative
+// to the upload directory and never exposed to clients.
+type KYCDocument struct {
+	ID           uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SubmissionID uuid.UUID       `gorm:"type:uuid;index;not null" json:"submission_id"`
+	Type         KYCDocumentType `gorm:"type:varchar(30);not null" json:"type"`
+	FilePath     string          `gorm:"type:varchar(500);not null" json:"-"`
+	ContentType  string          `gorm:"type:varchar(100);not null" json:"content_type"`
+	SizeBytes    int64           `gorm:"not null" json:"size_bytes"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (d *KYCDocument) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// KYCStatusResponse is the user's current tier and latest submission.
+type KYCStatusResponse struct {
+	Tier             KYCTier        `json:"tier"`
+	LatestSubmission *KYCSubmission `json:"latest_submission,omitempty"`
+}
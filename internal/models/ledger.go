@@ -0,0 +1,106 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LedgerAccountType string
+type PostingDirection string
+
+const (
+	LedgerAccountTypeWallet LedgerAccountType = "wallet"
+	LedgerAccountTypeSystem LedgerAccountType = "system"
+
+	PostingDirectionDebit  PostingDirection = "debit"
+	PostingDirectionCredit PostingDirection = "credit"
+)
+
+// System ledger account codes. System accounts stand in for money that lives
+// outside the wallets, so every wallet movement has a balancing side. There
+// is one system account per code and currency.
+const (
+	LedgerAccountTopUpClearing  = "topup_clearing"
+	LedgerAccountOpeningBalance = "opening_balance"
+	// Money sold and bought when converting between currencies
+	LedgerAccountFXPosition = "fx_position"
+	// Fees charged on top-ups and transfers
+	LedgerAccountFeeRevenue = "fee_revenue"
+	// Money of withdrawals in progress, and money paid out to bank accounts
+	LedgerAccountWithdrawalHold = "withdrawal_hold"
+	LedgerAccountPayoutClearing = "payout_clearing"
+	// Top-up payments whose wallet could not take them, until support
+	// settles them
+	LedgerAccountTopUpSuspense = "topup_suspense"
+)
+
+// LedgerAccount is either the ledger side of a wallet or a system account.
+// Wallet accounts are credit-normal: credits increase the wallet balance.
+// Every account holds one currency, and the postings of a journal entry
+// balance per currency.
+type LedgerAccount struct {
+	ID        uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Code      *string           `gorm:"type:varchar(50);uniqueIndex:idx_ledger_accounts_code_currency" json:"code,omitempty"`
+	Name      string            `gorm:"type:varchar(100);not null" json:"name"`
+	Type      LedgerAccountType `gorm:"type:varchar(20);not null" json:"type"`
+	Currency  Currency          `gorm:"type:varchar(3);not null;default:'IDR';uniqueIndex:idx_ledger_accounts_code_currency" json:"currency"`
+	WalletID  *uuid.UUID        `gorm:"type:uuid;uniqueIndex" json:"wallet_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *LedgerAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+// JournalEntry groups the postings of one money movement. The debits and
+// credits of an entry always sum to the same amount.
+type JournalEntry struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	TransactionID *uuid.UUID `gorm:"type:uuid;index" json:"transaction_id,omitempty"`
+	Description   string     `gorm:"type:varchar(255);not null" json:"description"`
+	Postings      []Posting  `gorm:"foreignKey:JournalEntryID" json:"postings,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (e *JournalEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// Posting is a single debit or credit line of a journal entry.
+type Posting struct {
+	ID             uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	JournalEntryID uuid.UUID        `gorm:"type:uuid;index;not null" json:"journal_entry_id"`
+	AccountID      uuid.UUID        `gorm:"type:uuid;index;not null" json:"account_id"`
+	Direction      PostingDirection `gorm:"type:varchar(6);not null" json:"direction"`
+	Amount         Money            `gorm:"type:decimal(15,2);not null" json:"amount" swaggertype:"number"`
+	BalanceAfter   *Money           `gorm:"type:decimal(15,2)" json:"balance_after,omitempty" swaggertype:"number"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (p *Posting) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// CreditNormalAmount returns the posting amount signed from the point of view
+// of a credit-normal account such as a wallet.
+func (p *Posting) CreditNormalAmount() Money {
+	if p.Direction == PostingDirectionDebit {
+		return -p.Amount
+	}
+	return p.Amount
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LimitPolicy holds transaction and balance limits. The row without a user
+// or tier is the global policy; a KYC tier's row overrides it, and a user's
+// row overrides both. Each override only replaces the columns it sets. A nil
+// limit means unlimited.
+type LimitPolicy struct {
+	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID            *uuid.UUID `gorm:"type:uuid;uniqueIndex" json:"user_id,omitempty"`
+	KYCTier           *KYCTier   `gorm:"column:kyc_tier;type:varchar(20)" json:"kyc_tier,omitempty"`
+	TopUpMin          *Money     `gorm:"column:topup_min;type:decimal(15,2)" json:"topup_min,omitempty" swaggertype:"number"`
+	TopUpMax          *Money     `gorm:"column:topup_max;type:decimal(15,2)" json:"topup_max,omitempty" swaggertype:"number"`
+	TopUpDaily        *Money     `gorm:"column:topup_daily;type:decimal(15,2)" json:"topup_daily,omitempty" swaggertype:"number"`
+	TopUpMonthly      *Money     `gorm:"column:topup_monthly;type:decimal(15,2)" json:"topup_monthly,omitempty" swaggertype:"number"`
+	TransferMin       *Money     `gorm:"type:decimal(15,2)" json:"transfer_min,omitempty" swaggertype:"number"`
+	TransferMax       *Money     `gorm:"type:decimal(15,2)" json:"transfer_max,omitempty" swaggertype:"number"`
+	TransferDaily     *Money     `gorm:"type:decimal(15,2)" json:"transfer_daily,omitempty" swaggertype:"number"`
+	TransferMonthly   *Money     `gorm:"type:decimal(15,2)" json:"transfer_monthly,omitempty" swaggertype:"number"`
+	WithdrawalMin     *Money     `gorm:"type:decimal(15,2)" json:"withdrawal_min,omitempty" swaggertype:"number"`
+	WithdrawalMax     *Money     `gorm:"type:decimal(15,2)" json:"withdrawal_max,omitempty" swaggertype:"number"`
+	WithdrawalDaily   *Money     `gorm:"type:decimal(15,2)" json:"withdrawal_daily,omitempty" swaggertype:"number"`
+	WithdrawalMonthly *Money     `gorm:"type:decimal(15,2)" json:"withdrawal_monthly,omitempty" swaggertype:"number"`
+	MaxBalance        *Money     `gorm:"type:decimal(15,2)" json:"max_balance,omitempty" swaggertype:"number"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (p *LimitPolicy) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	return nil
+}
+
+// TransactionLimits are the limits that apply to one transaction type.
+type TransactionLimits struct {
+	Min     *Money `json:"min,omitempty" swaggertype:"number"`
+	Max     *Money `json:"max,omitempty" swaggertype:"number"`
+	Daily   *Money `json:"daily,omitempty" swaggertype:"number"`
+	Monthly *Money `json:"monthly,omitempty" swaggertype:"number"`
+}
+
+// EffectiveLimits are the limits that apply to a user after overrides.
+type EffectiveLimits struct {
+	TopUp      TransactionLimits `json:"topup"`
+	Transfer   TransactionLimits `json:"transfer"`
+	Withdrawal TransactionLimits `json:"withdrawal"`
+	MaxBalance *Money            `json:"max_balance,omitempty" swaggertype:"number"`
+}
+
+// ApplyTo overrides the limits with every limit set on the policy.
+func (p *LimitPolicy) ApplyTo(limits *EffectiveLimits) {
+	override(&limits.TopUp.Min, p.TopUpMin)
+	override(&limits.TopUp.Max, p.TopUpMax)
+	override(&limits.TopUp.Daily, p.TopUpDaily)
+	override(&limits.TopUp.Monthly, p.TopUpMonthly)
+	override(&limits.Transfer.Min, p.TransferMin)
+	override(&limits.Transfer.Max, p.TransferMax)
+	override(&limits.Transfer.Daily, p.TransferDaily)
+	override(&limits.Transfer.Monthly, p.TransferMonthly)
+	override(&limits.Withdrawal.Min, p.WithdrawalMin)
+	override(&limits.Withdrawal.Max, p.WithdrawalMax)
+	override(&limits.Withdrawal.Daily, p.WithdrawalDaily)
+	override(&limits.Withdrawal.Monthly, p.WithdrawalMonthly)
+	override(&limits.MaxBalance, p.MaxBalance)
+}
+
+// For returns the limits of the given transaction type.
+func (l *EffectiveLimits) For(txType TransactionType) TransactionLimits {
+	switch txType {
+	case TransactionTypeTopUp:
+		return l.TopUp
+	case TransactionTypeWithdrawal:
+		return l.Withdrawal
+	}
+	return l.Transfer
+}
+
+func override(dst **Money, src *Money) {
+	if src != nil {
+		*dst = src
+	}
+}
+
+// LimitUsage is how much of the daily and monthly limits a user has used.
+type LimitUsage struct {
+	TopUpDaily        Money `json:"topup_daily" swaggertype:"number"`
+	TopUpMonthly      Money `json:"topup_monthly" swaggertype:"number"`
+	TransferDaily     Money `json:"transfer_daily" swaggertype:"number"`
+	TransferMonthly   Money `json:"transfer_monthly" swaggertype:"number"`
+	WithdrawalDaily   Money `json:"withdrawal_daily" swaggertype:"number"`
+	WithdrawalMonthly Money `json:"withdrawal_monthly" swaggertype:"number"`
+}
+
+// LimitsOverview is a user's effective limits together with current usage.
+type LimitsOverview struct {
+	Limits EffectiveLimits `json:"limits"`
+	Usage  LimitUsage      `json:"usage"`
+}
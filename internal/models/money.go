@@ -0,0 +1,151 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount stored as an integer number of minor units (cents),
+// matching the DECIMAL(15,2) columns in the database. It marshals to and
+// from JSON numbers such as 1500.25 without going through float64.
+type Money int64
+
+const (
+	// MinorUnitsPerMajor is the number of minor units in one major unit.
+	MinorUnitsPerMajor = 100
+
+	// MaxMoney is the largest amount a DECIMAL(15,2) column can hold.
+	MaxMoney Money = 999999999999999
+)
+
+var (
+	ErrInvalidMoney    = errors.New("invalid money amount")
+	ErrMoneyTooPrecise = errors.New("amount must not have more than two decimal places")
+	ErrMoneyOutOfRange = errors.New("amount is out of range")
+)
+
+var minorUnitsPerMajorRat = big.NewRat(MinorUnitsPerMajor, 1)
+
+// moneyPattern is a plain decimal: big.Rat alone would also accept
+// fractions such as "1/4" and exponents such as "1e3".
+var moneyPattern = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?$`)
+
+// NewMoney builds a Money value from whole major units.
+func NewMoney(major int64) Money {
+	return Money(major * MinorUnitsPerMajor)
+}
+
+// ParseMoney parses a decimal string such as "1500", "1500.5" or "-3.25".
+// Values with more than two decimal places are rejected rather than rounded.
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if !moneyPattern.MatchString(s) {
+		return 0, ErrInvalidMoney
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, ErrInvalidMoney
+	}
+
+	r.Mul(r, minorUnitsPerMajorRat)
+	if !r.IsInt() {
+		return 0, ErrMoneyTooPrecise
+	}
+
+	n := r.Num()
+	if !n.IsInt64() {
+		return 0, ErrMoneyOutOfRange
+	}
+
+	m := Money(n.Int64())
+	if m > MaxMoney || m < -MaxMoney {
+		return 0, ErrMoneyOutOfRange
+	}
+	return m, nil
+}
+
+// String formats the amount with exactly two decimal places, e.g. "1500.25".
+func (m Money) String() string {
+	sign := ""
+	v := uint64(m)
+	if m < 0 {
+		sign = "-"
+		v = uint64(-(m + 1)) + 1
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/MinorUnitsPerMajor, v%MinorUnitsPerMajor)
+}
+
+// MarshalJSON encodes the amount as a JSON number with two decimal places.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return ErrInvalidMoney
+		}
+		raw = s
+	}
+
+	parsed, err := ParseMoney(raw)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// Value implements driver.Valuer so the amount is written as an exact decimal.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// Scan implements sql.Scanner for DECIMAL columns.
+func (m *Money) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = 0
+		return nil
+	case []byte:
+		parsed, err := ParseMoney(string(v))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := ParseMoney(v)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case int64:
+		*m = NewMoney(v)
+		return nil
+	case float64:
+		parsed, err := ParseMoney(strconv.FormatFloat(v, 'f', -1, 64))
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into Money", value)
+	}
+}
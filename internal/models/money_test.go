@@ -0,0 +1,61 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Money
+	}{
+		{"1500", 150000},
+		{"1500.5", 150050},
+		{"1500.25", 150025},
+		{" 0.01 ", 1},
+		{"-3.25", -325},
+		{"+7", 700},
+		{"1500.00", 150000},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error = %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyRejectsNonDecimals(t *testing.T) {
+	for _, in := range []string{"", " ", "1/4", "100/3", "1e3", "1E-2", "0x10", ".5", "5.", "1,000", "Inf", "NaN", "--1", "1 000"} {
+		if _, err := ParseMoney(in); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("ParseMoney(%q) error = %v, want %v", in, err, ErrInvalidMoney)
+		}
+	}
+}
+
+func TestParseMoneyLimits(t *testing.T) {
+	if _, err := ParseMoney("1.005"); !errors.Is(err, ErrMoneyTooPrecise) {
+		t.Errorf("ParseMoney(1.005) error = %v, want %v", err, ErrMoneyTooPrecise)
+	}
+	if got, err := ParseMoney("9999999999999.99"); err != nil || got != MaxMoney {
+		t.Errorf("ParseMoney(max) = %d, %v, want %d", got, err, MaxMoney)
+	}
+	if _, err := ParseMoney("10000000000000"); !errors.Is(err, ErrMoneyOutOfRange) {
+		t.Errorf("ParseMoney(max+1) error = %v, want %v", err, ErrMoneyOutOfRange)
+	}
+}
+
+func TestMoneyUnmarshalJSON(t *testing.T) {
+	var m Money
+	if err := m.UnmarshalJSON([]byte(`"12.30"`)); err != nil || m != 1230 {
+		t.Errorf(`UnmarshalJSON("12.30") = %d, %v, want 1230`, m, err)
+	}
+	if err := m.UnmarshalJSON([]byte(`"1/4"`)); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf(`UnmarshalJSON("1/4") error = %v, want %v`, err, ErrInvalidMoney)
+	}
+}
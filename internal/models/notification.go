@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WalletNotificationChannel is the Postgres channel wallet notifications are
+// sent on. Every server instance listens on it.
+const WalletNotificationChannel = "wallet_notifications"
+
+// Kinds of real-time wallet notifications
+const (
+	NotificationBalanceChanged   = "balance_changed"
+	NotificationTransferReceived = "transfer_received"
+	// Sent to the payer of a new payment request, and to the other party
+	// when a request is declined or cancelled
+	NotificationPaymentRequested        = "payment_requested"
+	NotificationPaymentRequestDeclined  = "payment_request_declined"
+	NotificationPaymentRequestCancelled = "payment_request_cancelled"
+	// Sent when notifications may have been missed; clients should refetch
+	NotificationResync = "resync"
+)
+
+// WalletNotification is pushed to the wallet owner's open streams once the
+// change it describes has been committed. Currency is the currency of
+// Balance and Amount.
+type WalletNotification struct {
+	UserID        uuid.UUID  `json:"user_id"`
+	Type          string     `json:"type"`
+	WalletID      *uuid.UUID `json:"wallet_id,omitempty"`
+	Balance       *Money     `json:"balance,omitempty" swaggertype:"number"`
+	TransactionID *uuid.UUID `json:"transaction_id,omitempty"`
+	Amount        *Money     `json:"amount,omitempty" swaggertype:"number"`
+	Currency      *Currency  `json:"currency,omitempty"`
+	SenderID      *uuid.UUID `json:"sender_id,omitempty"`
+	// Set on payment request notifications
+	PaymentRequestID *uuid.UUID `json:"payment_request_id,omitempty"`
+	RequesterID      *uuid.UUID `json:"requester_id,omitempty"`
+	OccurredAt       time.Time  `json:"occurred_at"`
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Domain event types published through the outbox
+const (
+	EventUserRegistered      = "user.registered"
+	EventWalletToppedUp      = "wallet.topped_up"
+	EventTopUpFailed         = "wallet.topup_failed"
+	EventTransferCompleted   = "transfer.completed"
+	EventTransferFailed      = "transfer.failed"
+	EventTransferReversed    = "transfer.reversed"
+	EventWithdrawalCompleted = "withdrawal.completed"
+	EventWithdrawalFailed    = "withdrawal.failed"
+)
+
+// Kinds of aggregates a domain event belongs to
+const (
+	AggregateUser        = "user"
+	AggregateTransaction = "transaction"
+	AggregateWithdrawal  = "withdrawal"
+)
+
+// OutboxEvent is a domain event waiting to be relayed to the event sinks.
+// It is written in the same database transaction as the change it
+// describes, so an event exists if and only if the change was committed.
+type OutboxEvent struct {
+	ID            uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EventType     string          `gorm:"type:varchar(100);not null" json:"type"`
+	AggregateType string          `gorm:"type:varchar(50);not null" json:"aggregate_type"`
+	AggregateID   uuid.UUID       `gorm:"type:uuid;not null" json:"aggregate_id"`
+	Payload       json.RawMessage `gorm:"type:jsonb;serializer:json;not null" json:"data" swaggertype:"object"`
+	Attempts      int             `gorm:"not null;default:0" json:"attempts"`
+	LastError     *string         `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `gorm:"not null" json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// EventEnvelope is the form in which an event is handed to sinks.
+type EventEnvelope struct {
+	ID            uuid.UUID       `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   uuid.UUID       `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Data          json.RawMessage `json:"data"`
+}
+
+func (e *OutboxEvent) Envelope() EventEnvelope {
+	return EventEnvelope{
+		ID:            e.ID,
+		Type:          e.EventType,
+		AggregateType: e.AggregateType,
+		AggregateID:   e.AggregateID,
+		OccurredAt:    e.CreatedAt,
+		Data:          e.Payload,
+	}
+}
+
+// UserRegisteredData is the payload of user.registered.
+type UserRegisteredData struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Email    string    `json:"email"`
+	Name     string    `json:"name"`
+	WalletID uuid.UUID `json:"wallet_id"`
+}
+
+// WalletToppedUpData is the payload of wallet.topped_up. Fee is set only
+// when one was charged.
+type WalletToppedUpData struct {
+	TopUpID       uuid.UUID `json:"topup_id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	WalletID      uuid.UUID `json:"wallet_id"`
+	Amount        Money     `json:"amount"`
+	Currency      Currency  `json:"currency"`
+	Fee           Money     `json:"fee,omitempty"`
+	BalanceAfter  Money     `json:"balance_after"`
+}
+
+// TopUpFailedData is the payload of wallet.topup_failed, sent when a
+// top-up's payment failed or expired, or was held because the wallet could
+// not take it.
+type TopUpFailedData struct {
+	TopUpID       uuid.UUID `json:"topup_id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	WalletID      uuid.UUID `json:"wallet_id"`
+	Amount        Money     `json:"amount"`
+	Currency      Currency  `json:"currency"`
+	FailureReason string    `json:"failure_reason"`
+}
+
+// TransferData is the payload of transfer.completed and transfer.failed.
+// FailureReason is set only on transfer.failed, the converted amount only
+// on transfers between currencies, and Fee only when one was charged.
+type TransferData struct {
+	TransactionID     uuid.UUID `json:"transaction_id"`
+	SenderID          uuid.UUID `json:"sender_id"`
+	ReceiverID        uuid.UUID `json:"receiver_id"`
+	Amount            Money     `json:"amount"`
+	Currency          Currency  `json:"currency"`
+	ConvertedAmount   *Money    `json:"converted_amount,omitempty"`
+	ConvertedCurrency *Currency `json:"converted_currency,omitempty"`
+	Fee               Money     `json:"fee,omitempty"`
+	FailureReason     *string   `json:"failure_reason,omitempty"`
+}
+
+// TransferReversedData is the payload of transfer.reversed, sent for both
+// reversals and refunds. The sender is the receiver of the original transfer.
+type TransferReversedData struct {
+	TransactionID         uuid.UUID       `json:"transaction_id"`
+	OriginalTransactionID uuid.UUID       `json:"original_transaction_id"`
+	Type                  TransactionType `json:"type"`
+	SenderID              uuid.UUID       `json:"sender_id"`
+	ReceiverID            uuid.UUID       `json:"receiver_id"`
+	Amount                Money           `json:"amount"`
+	Currency              Currency        `json:"currency"`
+	ConvertedAmount       *Money          `json:"converted_amount,omitempty"`
+	ConvertedCurrency     *Currency       `json:"converted_currency,omitempty"`
+}
+
+// WithdrawalData is the payload of withdrawal.completed and
+// withdrawal.failed. FailureReason is set only on withdrawal.failed.
+type WithdrawalData struct {
+	WithdrawalID  uuid.UUID `json:"withdrawal_id"`
+	TransactionID uuid.UUID `json:"transaction_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	WalletID      uuid.UUID `json:"wallet_id"`
+	BankAccountID uuid.UUID `json:"bank_account_id"`
+	Amount        Money     `json:"amount"`
+	Currency      Currency  `json:"currency"`
+	FailureReason *string   `json:"failure_reason,omitempty"`
+}
+
+// EventTypes lists every domain event type, in the order they are documented.
+var EventTypes = []string{
+	EventUserRegistered,
+	EventWalletToppedUp,
+	EventTopUpFailed,
+	EventTransferCompleted,
+	EventTransferFailed,
+	EventTransferReversed,
+	EventWithdrawalCompleted,
+	EventWithdrawalFailed,
+}
+
+// IsEventType reports whether t is a known domain event type.
+func IsEventType(t string) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Subjects returns the users an event is about, who may be notified of it.
+// A failed transfer concerns only its sender.
+func (e EventEnvelope) Subjects() ([]uuid.UUID, error) {
+	switch e.Type {
+	case EventUserRegistered:
+		var data UserRegisteredData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.UserID}, nil
+	case EventWalletToppedUp:
+		var data WalletToppedUpData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.UserID}, nil
+	case EventTopUpFailed:
+		var data TopUpFailedData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.UserID}, nil
+	case EventTransferCompleted:
+		var data TransferData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.SenderID, data.ReceiverID}, nil
+	case EventTransferFailed:
+		var data TransferData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.SenderID}, nil
+	case EventTransferReversed:
+		var data TransferReversedData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.SenderID, data.ReceiverID}, nil
+	case EventWithdrawalCompleted, EventWithdrawalFailed:
+		var data WithdrawalData
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return nil, err
+		}
+		return []uuid.UUID{data.UserID}, nil
+	}
+	return nil, nil
+}
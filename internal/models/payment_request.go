@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PaymentRequestStatus string
+
+// A payment request starts pending and ends in exactly one of the other
+// states. Only the payer can accept or decline it, only the requester can
+// cancel it, and a pending request past its expiry becomes expired.
+const (
+	PaymentRequestPending   PaymentRequestStatus = "pending"
+	PaymentRequestAccepted  PaymentRequestStatus = "accepted"
+	PaymentRequestDeclined  PaymentRequestStatus = "declined"
+	PaymentRequestCancelled PaymentRequestStatus = "cancelled"
+	PaymentRequestExpired   PaymentRequestStatus = "expired"
+)
+
+func (s PaymentRequestStatus) IsValid() bool {
+	switch s {
+	case PaymentRequestPending, PaymentRequestAccepted, PaymentRequestDeclined, PaymentRequestCancelled, PaymentRequestExpired:
+		return true
+	}
+	return false
+}
+
+// PaymentRequestRole selects requests relative to the user: incoming
+// requests ask the user to pay, outgoing requests were made by the user.
+type PaymentRequestRole string
+
+const (
+	PaymentRequestIncoming PaymentRequestRole = "incoming"
+	PaymentRequestOutgoing PaymentRequestRole = "outgoing"
+)
+
+// PaymentRequest asks the payer to send Amount to the requester, in the
+// currency of the requester's primary wallet when the request was made.
+// Accepting it runs a normal transfer from the payer, linked through
+// TransactionID.
+type PaymentRequest struct {
+	ID            uuid.UUID            `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RequesterID   uuid.UUID            `gorm:"type:uuid;not null;index" json:"requester_id"`
+	PayerID       uuid.UUID            `gorm:"type:uuid;not null;index" json:"payer_id"`
+	Amount        Money                `gorm:"type:decimal(15,2);not null" json:"amount" swaggertype:"number"`
+	Currency      Currency             `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Note          string               `gorm:"type:varchar(255)" json:"note,omitempty"`
+	Status        PaymentRequestStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	ExpiresAt     time.Time            `gorm:"not null" json:"expires_at"`
+	TransactionID *uuid.UUID           `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	RespondedAt   *time.Time           `json:"responded_at,omitempty"`
+	CreatedAt     time.Time            `json:"created_at"`
+	UpdatedAt     time.Time            `json:"updated_at"`
+}
+
+// IsExpired reports whether a pending request can no longer be answered.
+func (r *PaymentRequest) IsExpired(now time.Time) bool {
+	return r.Status == PaymentRequestPending && !now.Before(r.ExpiresAt)
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScheduleFrequency string
+
+const (
+	ScheduleOnce    ScheduleFrequency = "once"
+	ScheduleDaily   ScheduleFrequency = "daily"
+	ScheduleWeekly  ScheduleFrequency = "weekly"
+	ScheduleMonthly ScheduleFrequency = "monthly"
+)
+
+func (f ScheduleFrequency) IsValid() bool {
+	switch f {
+	case ScheduleOnce, ScheduleDaily, ScheduleWeekly, ScheduleMonthly:
+		return true
+	}
+	return false
+}
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledTransferActive    ScheduledTransferStatus = "active"
+	ScheduledTransferCompleted ScheduledTransferStatus = "completed"
+	ScheduledTransferCancelled ScheduledTransferStatus = "cancelled"
+	// A one-time transfer whose only run was skipped after too many failures
+	ScheduledTransferFailed ScheduledTransferStatus = "failed"
+)
+
+func (s ScheduledTransferStatus) IsValid() bool {
+	switch s {
+	case ScheduledTransferActive, ScheduledTransferCompleted, ScheduledTransferCancelled, ScheduledTransferFailed:
+		return true
+	}
+	return false
+}
+
+// ScheduledTransfer sends Amount from the user to the receiver at StartAt
+// and, unless it is a one-time transfer, again every period after that.
+// Occurrences are counted from StartAt, so a late or retried run does not
+// shift the ones after it. A recurring transfer ends after MaxRuns
+// occurrences or at EndAt, whichever comes first, or never if neither is
+// set.
+type ScheduledTransfer struct {
+	ID     uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	// Wallet the money is sent from; the user's primary wallet when unset
+	WalletID            *uuid.UUID              `gorm:"type:uuid" json:"wallet_id,omitempty"`
+	ReceiverID          uuid.UUID               `gorm:"type:uuid;not null" json:"receiver_id"`
+	Amount              Money                   `gorm:"type:decimal(15,2);not null" json:"amount" swaggertype:"number"`
+	Note                string                  `gorm:"type:varchar(255)" json:"note,omitempty"`
+	Frequency           ScheduleFrequency       `gorm:"type:varchar(20);not null" json:"frequency"`
+	StartAt             time.Time               `gorm:"not null" json:"start_at"`
+	EndAt               *time.Time              `json:"end_at,omitempty"`
+	MaxRuns             *int                    `json:"max_runs,omitempty"`
+	Status              ScheduledTransferStatus `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	NextRunAt           *time.Time              `json:"next_run_at,omitempty"`
+	RunCount            int                     `gorm:"not null;default:0" json:"run_count"`
+	ConsecutiveFailures int                     `gorm:"not null;default:0" json:"consecutive_failures"`
+	LastRunAt           *time.Time              `json:"last_run_at,omitempty"`
+	LeasedUntil         *time.Time              `json:"-"`
+	CreatedAt           time.Time               `json:"created_at"`
+	UpdatedAt           time.Time               `json:"updated_at"`
+}
+
+// OccurrenceAt returns the due time of the n-th occurrence, counting from 0.
+// Dates are calculated in UTC. Monthly occurrences stay on the day of month
+// of StartAt, or on the last day of shorter months.
+func (s *ScheduledTransfer) OccurrenceAt(n int) time.Time {
+	start := s.StartAt.UTC()
+	switch s.Frequency {
+	case ScheduleDaily:
+		return start.AddDate(0, 0, n)
+	case ScheduleWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case ScheduleMonthly:
+		return addMonthsClamped(start, n)
+	}
+	return start
+}
+
+// NextOccurrence returns the due time of the occurrence after the first
+// count ones, or false if the schedule has ended by then.
+func (s *ScheduledTransfer) NextOccurrence(count int) (time.Time, bool) {
+	if s.Frequency == ScheduleOnce && count > 0 {
+		return time.Time{}, false
+	}
+	if s.MaxRuns != nil && count >= *s.MaxRuns {
+		return time.Time{}, false
+	}
+	next := s.OccurrenceAt(count)
+	if s.EndAt != nil && next.After(*s.EndAt) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func addMonthsClamped(t time.Time, months int) time.Time {
+	year, month, day := t.Date()
+	first := time.Date(year, month+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+type ScheduledTransferRunStatus string
+
+const (
+	ScheduledTransferRunSuccess ScheduledTransferRunStatus = "success"
+	ScheduledTransferRunFailed  ScheduledTransferRunStatus = "failed"
+)
+
+// ScheduledTransferRun is one attempt at an occurrence. A failed attempt is
+// retried until the schedule's failure limit, after which the occurrence is
+// skipped and Skipped is set on the last attempt.
+type ScheduledTransferRun struct {
+	ID                  uuid.UUID                  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	ScheduledTransferID uuid.UUID                  `gorm:"type:uuid;not null;index" json:"scheduled_transfer_id"`
+	ScheduledFor        time.Time                  `gorm:"not null" json:"scheduled_for"`
+	Attempt             int                        `gorm:"not null" json:"attempt"`
+	Status              ScheduledTransferRunStatus `gorm:"type:varchar(20);not null" json:"status"`
+	TransactionID       *uuid.UUID                 `gorm:"type:uuid" json:"transaction_id,omitempty"`
+	FailureReason       *string                    `gorm:"type:varchar(50)" json:"failure_reason,omitempty"`
+	Skipped             bool                       `gorm:"not null;default:false" json:"skipped"`
+	CreatedAt           time.Time                  `json:"created_at"`
+}
+
+// ScheduledTransferDetail is a scheduled transfer with its latest runs.
+type ScheduledTransferDetail struct {
+	ScheduledTransfer
+	Runs []ScheduledTransferRun `json:"runs"`
+}
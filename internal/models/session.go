@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reasons recorded when a session is revoked
+const (
+	SessionRevokedLogout     = "logout"
+	SessionRevokedTokenReuse = "refresh_token_reuse"
+	SessionRevokedSuspended  = "account_suspended"
+)
+
+// Session is a login and the family of refresh tokens rotated from it.
+// Access tokens carry the session ID, so revoking the session invalidates
+// every token issued for it.
+type Session struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;index;not null" json:"user_id"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason *string    `gorm:"type:varchar(50)" json:"revoked_reason,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// IsRevoked reports whether the session can no longer be used.
+func (s *Session) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
+
+// RefreshToken is a single-use refresh token. Only the SHA-256 hash of the
+// token is stored.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SessionID uuid.UUID  `gorm:"type:uuid;index;not null" json:"session_id"`
+	TokenHash string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
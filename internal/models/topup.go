@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TopUpStatus string
+
+// A top-up starts pending and ends as success once the payment gateway
+// confirms the payment, failed when the gateway reports it failed, or
+// expired when nobody paid before ExpiresAt. A payment the gateway confirms
+// after expiry still moves an expired top-up to success. A paid top-up whose
+// wallet can no longer take the money is held: the money waits in the
+// top-up suspense account for support to settle.
+const (
+	TopUpPending TopUpStatus = "pending"
+	TopUpSuccess TopUpStatus = "success"
+	TopUpFailed  TopUpStatus = "failed"
+	TopUpExpired TopUpStatus = "expired"
+	TopUpHeld    TopUpStatus = "held"
+)
+
+func (s TopUpStatus) IsValid() bool {
+	switch s {
+	case TopUpPending, TopUpSuccess, TopUpFailed, TopUpExpired, TopUpHeld:
+		return true
+	}
+	return false
+}
+
+// IsFinal reports whether the top-up has ended.
+func (s TopUpStatus) IsFinal() bool {
+	return s != TopUpPending
+}
+
+// IsPaid reports whether the gateway confirmed the top-up's payment.
+func (s TopUpStatus) IsPaid() bool {
+	return s == TopUpSuccess || s == TopUpHeld
+}
+
+// Codes stored as the failure reason of failed, expired and held top-ups and
+// their transactions
+const (
+	TopUpFailurePaymentFailed      = "payment_failed"
+	TopUpFailureExpired            = "topup_expired"
+	TopUpFailureGatewayUnavailable = "payment_gateway_unavailable"
+	TopUpFailureWalletClosed       = "wallet_closed"
+	TopUpFailureMaxBalance         = "max_balance_exceeded"
+)
+
+// TopUp adds Amount to a wallet once the user has paid Total, the amount
+// plus the fee, through the payment gateway. Its pending topup transaction
+// is credited to the wallet only when the gateway confirms the payment.
+type TopUp struct {
+	ID            uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID   `gorm:"type:uuid;not null;index" json:"user_id"`
+	WalletID      uuid.UUID   `gorm:"type:uuid;not null" json:"wallet_id"`
+	Amount        Money       `gorm:"type:decimal(15,2);not null" json:"amount" swaggertype:"number"`
+	Fee           Money       `gorm:"type:decimal(15,2);not null;default:0" json:"fee" swaggertype:"number"`
+	Total         Money       `gorm:"type:decimal(15,2);not null" json:"total" swaggertype:"number"`
+	Currency      Currency    `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Status        TopUpStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	TransactionID uuid.UUID   `gorm:"type:uuid;not null" json:"transaction_id"`
+	// How to pay, as given by the payment gateway; empty until the gateway
+	// has opened the payment
+	Gateway              string     `gorm:"type:varchar(50);not null" json:"gateway"`
+	GatewayReference     *string    `gorm:"type:varchar(100)" json:"gateway_reference,omitempty"`
+	VirtualAccountNumber *string    `gorm:"type:varchar(50)" json:"virtual_account_number,omitempty"`
+	PaymentURL           *string    `gorm:"type:text" json:"payment_url,omitempty"`
+	FailureReason        *string    `gorm:"type:varchar(50)" json:"failure_reason,omitempty"`
+	ExpiresAt            time.Time  `gorm:"not null" json:"expires_at"`
+	CompletedAt          *time.Time `json:"completed_at,omitempty"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// AcceptsLatePayment reports whether a payment confirmed now still credits
+// the top-up: it expired, or it failed because the gateway could not be
+// reached while the payment may have been opened all the same.
+func (t *TopUp) AcceptsLatePayment() bool {
+	if t.Status == TopUpExpired {
+		return true
+	}
+	return t.Status == TopUpFailed && t.FailureReason != nil && *t.FailureReason == TopUpFailureGatewayUnavailable
+}
+
+// BeforeCreate hook to generate UUID
+func (t *TopUp) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
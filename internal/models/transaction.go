@@ -9,28 +9,72 @@ import (
 
 type TransactionType string
 type TransactionStatus string
+type TransactionDirection string
 
 const (
 	TransactionTypeTopUp    TransactionType = "topup"
 	TransactionTypeTransfer TransactionType = "transfer"
+	// Money moved back from the receiver of a transfer to its sender, by an
+	// admin (reversal) or by the receiver (refund)
+	TransactionTypeReversal TransactionType = "reversal"
+	TransactionTypeRefund   TransactionType = "refund"
+	// Money moved between two wallets of the same user
+	TransactionTypeMove TransactionType = "move"
+	// Money paid out of a wallet into the user's bank account; pending while
+	// the payout is in progress
+	TransactionTypeWithdrawal TransactionType = "withdrawal"
 
 	TransactionStatusPending TransactionStatus = "pending"
 	TransactionStatusSuccess TransactionStatus = "success"
 	TransactionStatusFailed  TransactionStatus = "failed"
+
+	TransactionDirectionIncoming TransactionDirection = "incoming"
+	TransactionDirectionOutgoing TransactionDirection = "outgoing"
 )
 
+func (t TransactionType) IsValid() bool {
+	switch t {
+	case TransactionTypeTopUp, TransactionTypeTransfer, TransactionTypeReversal, TransactionTypeRefund, TransactionTypeMove, TransactionTypeWithdrawal:
+		return true
+	}
+	return false
+}
+
+// FailureReasonInternalError is stored when a transaction fails for a reason
+// that has no dedicated code, such as a database error.
+const FailureReasonInternalError = "internal_error"
+
 type Transaction struct {
-	ID         uuid.UUID         `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	SenderID   *uuid.UUID        `gorm:"type:uuid;index" json:"sender_id,omitempty"`
-	ReceiverID uuid.UUID         `gorm:"type:uuid;index;not null" json:"receiver_id"`
-	Amount     float64           `gorm:"type:decimal(15,2);not null" json:"amount"`
-	Type       TransactionType   `gorm:"type:varchar(20);not null" json:"type"`
-	Status     TransactionStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
-	Sender     *User             `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
-	Receiver   User              `gorm:"foreignKey:ReceiverID" json:"receiver,omitempty"`
-	CreatedAt  time.Time         `json:"created_at"`
-	UpdatedAt  time.Time         `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt    `gorm:"index" json:"-"`
+	ID       uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	SenderID *uuid.UUID `gorm:"type:uuid;index" json:"sender_id,omitempty"`
+	// Unset on failed transfers, which keep the user they were attempted to
+	// in AttemptedReceiverID; only their sender sees them
+	ReceiverID          *uuid.UUID `gorm:"type:uuid;index" json:"receiver_id,omitempty"`
+	AttemptedReceiverID *uuid.UUID `gorm:"type:uuid" json:"attempted_receiver_id,omitempty"`
+	Amount              Money      `gorm:"type:decimal(15,2);not null" json:"amount" swaggertype:"number"`
+	Currency            Currency   `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	// Charged to the payer on top of Amount, in Currency
+	Fee           Money             `gorm:"type:decimal(15,2);not null;default:0" json:"fee" swaggertype:"number"`
+	Type          TransactionType   `gorm:"type:varchar(20);not null" json:"type"`
+	Status        TransactionStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	FailureReason *string           `gorm:"type:varchar(50)" json:"failure_reason,omitempty"`
+	ReversalOf    *uuid.UUID        `gorm:"type:uuid;index" json:"reversal_of,omitempty"`
+	// Wallets the money left and entered; unset on failed transfers
+	SenderWalletID   *uuid.UUID `gorm:"type:uuid" json:"sender_wallet_id,omitempty"`
+	ReceiverWalletID *uuid.UUID `gorm:"type:uuid" json:"receiver_wallet_id,omitempty"`
+	// Set when the money changed currency on the way: the receiver got
+	// ConvertedAmount at FXRate, locked by the FX quote
+	ConvertedAmount   *Money     `gorm:"type:decimal(15,2)" json:"converted_amount,omitempty" swaggertype:"number"`
+	ConvertedCurrency *Currency  `gorm:"type:varchar(3)" json:"converted_currency,omitempty"`
+	FXRate            *Rate      `gorm:"type:numeric(20,8)" json:"fx_rate,omitempty" swaggertype:"number"`
+	FXQuoteID         *uuid.UUID `gorm:"type:uuid" json:"fx_quote_id,omitempty"`
+	// Amount valued in DefaultCurrency, which limits are counted in
+	BaseAmount Money          `gorm:"type:decimal(15,2);not null;default:0" json:"-"`
+	Sender     *User          `gorm:"foreignKey:SenderID" json:"sender,omitempty"`
+	Receiver   *User          `gorm:"foreignKey:ReceiverID" json:"receiver,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -41,26 +85,125 @@ func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// ReceivedAmount returns the amount that entered the receiver's wallet, in
+// ReceivedCurrency.
+func (t *Transaction) ReceivedAmount() Money {
+	if t.ConvertedAmount != nil {
+		return *t.ConvertedAmount
+	}
+	return t.Amount
+}
+
+// ReceivedCurrency returns the currency of the receiver's wallet.
+func (t *Transaction) ReceivedCurrency() Currency {
+	if t.ConvertedCurrency != nil {
+		return *t.ConvertedCurrency
+	}
+	return t.Currency
+}
+
 // TransactionResponse represents the transaction data returned in API responses
 type TransactionResponse struct {
-	ID         uuid.UUID         `json:"id"`
-	SenderID   *uuid.UUID        `json:"sender_id,omitempty"`
-	ReceiverID uuid.UUID         `json:"receiver_id"`
-	Amount     float64           `json:"amount"`
-	Type       TransactionType   `json:"type"`
-	Status     TransactionStatus `json:"status"`
-	CreatedAt  time.Time         `json:"created_at"`
+	ID                  uuid.UUID         `json:"id"`
+	SenderID            *uuid.UUID        `json:"sender_id,omitempty"`
+	ReceiverID          *uuid.UUID        `json:"receiver_id,omitempty"`
+	AttemptedReceiverID *uuid.UUID        `json:"attempted_receiver_id,omitempty"`
+	Amount              Money             `json:"amount" swaggertype:"number"`
+	Currency            Currency          `json:"currency"`
+	Fee                 Money             `json:"fee" swaggertype:"number"`
+	Type                TransactionType   `json:"type"`
+	Status              TransactionStatus `json:"status"`
+	FailureReason       *string           `json:"failure_reason,omitempty"`
+	ReversalOf          *uuid.UUID        `json:"reversal_of,omitempty"`
+	SenderWalletID      *uuid.UUID        `json:"sender_wallet_id,omitempty"`
+	ReceiverWalletID    *uuid.UUID        `json:"receiver_wallet_id,omitempty"`
+	ConvertedAmount     *Money            `json:"converted_amount,omitempty" swaggertype:"number"`
+	ConvertedCurrency   *Currency         `json:"converted_currency,omitempty"`
+	FXRate              *Rate             `json:"fx_rate,omitempty" swaggertype:"number"`
+	FXQuoteID           *uuid.UUID        `json:"fx_quote_id,omitempty"`
+	CreatedAt           time.Time         `json:"created_at"`
 }
 
 // ToResponse converts Transaction model to TransactionResponse
 func (t *Transaction) ToResponse() TransactionResponse {
 	return TransactionResponse{
-		ID:         t.ID,
-		SenderID:   t.SenderID,
-		ReceiverID: t.ReceiverID,
-		Amount:     t.Amount,
-		Type:       t.Type,
-		Status:     t.Status,
-		CreatedAt:  t.CreatedAt,
+		ID:                  t.ID,
+		SenderID:            t.SenderID,
+		ReceiverID:          t.ReceiverID,
+		AttemptedReceiverID: t.AttemptedReceiverID,
+		Amount:              t.Amount,
+		Currency:            t.Currency,
+		Fee:                 t.Fee,
+		Type:                t.Type,
+		Status:              t.Status,
+		FailureReason:       t.FailureReason,
+		ReversalOf:          t.ReversalOf,
+		SenderWalletID:      t.SenderWalletID,
+		ReceiverWalletID:    t.ReceiverWalletID,
+		ConvertedAmount:     t.ConvertedAmount,
+		ConvertedCurrency:   t.ConvertedCurrency,
+		FXRate:              t.FXRate,
+		FXQuoteID:           t.FXQuoteID,
+		CreatedAt:           t.CreatedAt,
+	}
+}
+
+// TransactionPage is one page of transaction history. NextCursor is empty on
+// the last page.
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+// IsReceiver reports whether the user received the transaction.
+func (t *Transaction) IsReceiver(userID uuid.UUID) bool {
+	return t.ReceiverID != nil && *t.ReceiverID == userID
+}
+
+// DirectionFor returns whether the transaction is incoming or outgoing from
+// the point of view of the given user. Moves between the user's own wallets
+// are outgoing.
+func (t *Transaction) DirectionFor(userID uuid.UUID) TransactionDirection {
+	if t.SenderID != nil && *t.SenderID == userID {
+		return TransactionDirectionOutgoing
+	}
+	return TransactionDirectionIncoming
+}
+
+// CounterpartyFor returns the other user of the transaction, or nil for
+// movements without one such as top-ups, moves between own wallets and
+// withdrawals.
+func (t *Transaction) CounterpartyFor(userID uuid.UUID) *User {
+	if t.Type == TransactionTypeMove || t.Type == TransactionTypeWithdrawal {
+		return nil
+	}
+	if t.DirectionFor(userID) == TransactionDirectionOutgoing {
+		return t.Receiver
+	}
+	return t.Sender
+}
+
+// TransactionDetailResponse is a single transaction as seen by one of its
+// parties, used for receipts
+type TransactionDetailResponse struct {
+	TransactionResponse
+	Direction    TransactionDirection `json:"direction"`
+	Counterparty *UserResponse        `json:"counterparty,omitempty"`
+	BalanceAfter *Money               `json:"balance_after,omitempty" swaggertype:"number"`
+}
+
+// ToDetailResponse converts Transaction model to TransactionDetailResponse for
+// the given user. balanceAfter is the user's wallet balance right after the
+// movement, if known.
+func (t *Transaction) ToDetailResponse(userID uuid.UUID, balanceAfter *Money) TransactionDetailResponse {
+	response := TransactionDetailResponse{
+		TransactionResponse: t.ToResponse(),
+		Direction:           t.DirectionFor(userID),
+		BalanceAfter:        balanceAfter,
+	}
+	if counterparty := t.CounterpartyFor(userID); counterparty != nil {
+		user := counterparty.ToResponse()
+		response.Counterparty = &user
 	}
+	return response
 }
@@ -8,6 +8,24 @@ import (
 	"gorm.io/gorm"
 )
 
+// Role decides which back-office routes a user may call.
+type Role string
+
+const (
+	RoleCustomer Role = "customer"
+	RoleSupport  Role = "support"
+	RoleAdmin    Role = "admin"
+	RoleAuditor  Role = "auditor"
+)
+
+// UserStatus is whether the account may log in and use its wallet.
+type UserStatus string
+
+const (
+	UserStatusActive    UserStatus = "active"
+	UserStatusSuspended UserStatus = "suspended"
+)
+
 type User struct {
 	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
 	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
@@ -17,6 +35,17 @@ type User struct {
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	// Transaction PIN, required to authorize debits
+	PINHash           *string    `gorm:"column:pin_hash;type:varchar(255)" json:"-"`
+	PINFailedAttempts int        `gorm:"column:pin_failed_attempts;not null;default:0" json:"-"`
+	PINLockedUntil    *time.Time `gorm:"column:pin_locked_until" json:"-"`
+
+	KYCTier KYCTier `gorm:"column:kyc_tier;type:varchar(20);not null;default:'basic'" json:"kyc_tier"`
+
+	Role         Role       `gorm:"type:varchar(20);not null;default:'customer'" json:"role"`
+	Status       UserStatus `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	StatusReason *string    `gorm:"type:text" json:"status_reason,omitempty"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -42,11 +71,46 @@ func (u *User) CheckPassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
 
+// IsSuspended reports whether the account has been suspended by an admin
+func (u *User) IsSuspended() bool {
+	return u.Status == UserStatusSuspended
+}
+
+// HashPIN hashes the transaction PIN the same way as the password
+func (u *User) HashPIN(pin string) error {
+	hashedPIN, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	hash := string(hashedPIN)
+	u.PINHash = &hash
+	return nil
+}
+
+// CheckPIN checks if the provided PIN matches the user's transaction PIN
+func (u *User) CheckPIN(pin string) error {
+	if u.PINHash == nil {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return bcrypt.CompareHashAndPassword([]byte(*u.PINHash), []byte(pin))
+}
+
+// HasPIN reports whether the user has set a transaction PIN
+func (u *User) HasPIN() bool {
+	return u.PINHash != nil
+}
+
+// IsPINLocked reports whether PIN entry is locked after too many wrong attempts
+func (u *User) IsPINLocked(now time.Time) bool {
+	return u.PINLockedUntil != nil && now.Before(*u.PINLockedUntil)
+}
+
 // UserResponse represents the user data returned in API responses
 type UserResponse struct {
 	ID    uuid.UUID `json:"id"`
 	Name  string    `json:"name"`
 	Email string    `json:"email"`
+	Tier  KYCTier   `json:"kyc_tier"`
 }
 
 // ToResponse converts User model to UserResponse
@@ -55,5 +119,6 @@ func (u *User) ToResponse() UserResponse {
 		ID:    u.ID,
 		Name:  u.Name,
 		Email: u.Email,
+		Tier:  u.KYCTier,
 	}
 }
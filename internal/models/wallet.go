@@ -7,13 +7,46 @@ import (
 	"gorm.io/gorm"
 )
 
+// WalletStatus controls which money movements a wallet accepts.
+type WalletStatus string
+
+const (
+	WalletStatusActive      WalletStatus = "active"
+	WalletStatusFrozenDebit WalletStatus = "frozen_debit"
+	WalletStatusFrozenAll   WalletStatus = "frozen_all"
+	WalletStatusClosed      WalletStatus = "closed"
+)
+
+// IsValid reports whether the status is one of the known wallet statuses.
+func (s WalletStatus) IsValid() bool {
+	switch s {
+	case WalletStatusActive, WalletStatusFrozenDebit, WalletStatusFrozenAll, WalletStatusClosed:
+		return true
+	}
+	return false
+}
+
+// DefaultWalletName is the name of the primary wallet opened at registration.
+const DefaultWalletName = "Main"
+
+// Wallet is one of a user's pockets. Every user has exactly one primary
+// wallet, which receives transfers from other users. A wallet holds a
+// single currency, fixed when it is opened.
 type Wallet struct {
 	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID      `gorm:"type:uuid;uniqueIndex;not null" json:"user_id"`
-	Balance   float64        `gorm:"type:decimal(15,2);default:0;not null" json:"balance"`
+	UserID    uuid.UUID      `gorm:"type:uuid;index;not null" json:"user_id"`
+	Name      string         `gorm:"type:varchar(50);not null" json:"name"`
+	IsPrimary bool           `gorm:"not null;default:false" json:"is_primary"`
+	Currency  Currency       `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Balance   Money          `gorm:"type:decimal(15,2);default:0;not null" json:"balance" swaggertype:"number"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	Status          WalletStatus `gorm:"type:varchar(20);not null;default:'active'" json:"status"`
+	StatusReason    *string      `gorm:"type:text" json:"status_reason,omitempty"`
+	StatusChangedBy *uuid.UUID   `gorm:"type:uuid" json:"-"`
+	StatusChangedAt *time.Time   `json:"status_changed_at,omitempty"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -23,3 +56,44 @@ func (w *Wallet) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// CanDebit reports whether money may leave the wallet.
+func (w *Wallet) CanDebit() bool {
+	return w.Status == WalletStatusActive
+}
+
+// CanCredit reports whether money may enter the wallet.
+func (w *Wallet) CanCredit() bool {
+	return w.Status == WalletStatusActive || w.Status == WalletStatusFrozenDebit
+}
+
+// WalletStatusChange records one change of a wallet's status, who made it
+// and why. Rows are never updated or deleted.
+type WalletStatusChange struct {
+	ID         uuid.UUID    `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	WalletID   uuid.UUID    `gorm:"type:uuid;index;not null" json:"wallet_id"`
+	FromStatus WalletStatus `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus   WalletStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	Reason     string       `gorm:"type:text;not null" json:"reason"`
+	ActorID    uuid.UUID    `gorm:"type:uuid;not null" json:"actor_id"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (c *WalletStatusChange) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// WalletInspection is the back-office view of a wallet: its stored balance
+// next to the balance derived from the ledger, all of the owner's wallets
+// and the owner's limits.
+type WalletInspection struct {
+	Wallet           Wallet         `json:"wallet"`
+	Wallets          []Wallet       `json:"wallets"`
+	LedgerBalance    Money          `json:"ledger_balance" swaggertype:"number"`
+	LedgerConsistent bool           `json:"ledger_consistent"`
+	Limits           LimitsOverview `json:"limits"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookEndpoint is a URL a user registered to receive domain events about
+// their own account. Deliveries are signed with Secret.
+type WebhookEndpoint struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	URL        string    `gorm:"type:text;not null" json:"url"`
+	Secret     string    `gorm:"type:varchar(100);not null" json:"-"`
+	EventTypes []string  `gorm:"type:jsonb;serializer:json;not null" json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the endpoint wants events of the given type.
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	for _, t := range e.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookEndpointCreated is returned once, when an endpoint is registered.
+// It is the only time the signing secret is shown.
+type WebhookEndpointCreated struct {
+	WebhookEndpoint
+	Secret string `json:"secret"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	// A delivery that failed every attempt is dead-lettered until replayed
+	WebhookDeliveryDead WebhookDeliveryStatus = "dead"
+)
+
+func (s WebhookDeliveryStatus) IsValid() bool {
+	switch s {
+	case WebhookDeliveryPending, WebhookDeliveryDelivered, WebhookDeliveryDead:
+		return true
+	}
+	return false
+}
+
+// WebhookDelivery is one event to be sent to one endpoint. Payload is the
+// request body, so every attempt and replay sends the same bytes.
+type WebhookDelivery struct {
+	ID             uuid.UUID             `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	EndpointID     uuid.UUID             `gorm:"type:uuid;not null" json:"endpoint_id"`
+	EventID        uuid.UUID             `gorm:"type:uuid;not null" json:"event_id"`
+	EventType      string                `gorm:"type:varchar(100);not null" json:"event_type"`
+	Payload        json.RawMessage       `gorm:"type:jsonb;serializer:json;not null" json:"payload" swaggertype:"object"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Attempts       int                   `gorm:"not null;default:0" json:"attempts"`
+	LastStatusCode *int                  `json:"last_status_code,omitempty"`
+	LastError      *string               `gorm:"type:text" json:"last_error,omitempty"`
+	NextAttemptAt  time.Time             `gorm:"not null" json:"next_attempt_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+
+	Endpoint *WebhookEndpoint `gorm:"foreignKey:EndpointID" json:"-"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BankAccount is a bank account the user can withdraw to. Removed accounts
+// are soft-deleted, so earlier withdrawals keep their destination.
+type BankAccount struct {
+	ID            uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	BankCode      string         `gorm:"type:varchar(11);not null" json:"bank_code"`
+	AccountNumber string         `gorm:"type:varchar(20);not null" json:"account_number"`
+	AccountName   string         `gorm:"type:varchar(100);not null" json:"account_name"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *BankAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
+type WithdrawalStatus string
+
+// A withdrawal starts pending, becomes processing once the payout provider
+// has accepted it, and ends as success or failed. It can fail from pending
+// when the provider rejects it. One the provider could not be reached for
+// becomes unknown, since the provider may have accepted it all the same;
+// the money stays held until the provider says what became of it.
+const (
+	WithdrawalPending    WithdrawalStatus = "pending"
+	WithdrawalProcessing WithdrawalStatus = "processing"
+	WithdrawalUnknown    WithdrawalStatus = "unknown"
+	WithdrawalSuccess    WithdrawalStatus = "success"
+	WithdrawalFailed     WithdrawalStatus = "failed"
+)
+
+func (s WithdrawalStatus) IsValid() bool {
+	switch s {
+	case WithdrawalPending, WithdrawalProcessing, WithdrawalUnknown, WithdrawalSuccess, WithdrawalFailed:
+		return true
+	}
+	return false
+}
+
+// IsFinal reports whether the withdrawal has ended.
+func (s WithdrawalStatus) IsFinal() bool {
+	return s == WithdrawalSuccess || s == WithdrawalFailed
+}
+
+// Codes stored as the failure reason of failed withdrawals and their
+// transactions
+const (
+	WithdrawalFailurePayoutFailed      = "payout_failed"
+	WithdrawalFailurePayoutRejected    = "payout_rejected"
+	WithdrawalFailurePayoutUnavailable = "payout_unavailable"
+)
+
+// Withdrawal pays Amount from one of the user's wallets into a bank
+// account. The money is held when the withdrawal is requested: the pending
+// withdrawal transaction moves it out of the wallet into the withdrawal
+// hold account. A successful payout settles the hold, and a failed one
+// releases it back into the wallet.
+type Withdrawal struct {
+	ID            uuid.UUID        `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	UserID        uuid.UUID        `gorm:"type:uuid;not null;index" json:"user_id"`
+	WalletID      uuid.UUID        `gorm:"type:uuid;not null" json:"wallet_id"`
+	BankAccountID uuid.UUID        `gorm:"type:uuid;not null" json:"bank_account_id"`
+	BankAccount   *BankAccount     `gorm:"foreignKey:BankAccountID" json:"bank_account,omitempty"`
+	Amount        Money            `gorm:"type:decimal(15,2);not null" json:"amount" swaggertype:"number"`
+	Currency      Currency         `gorm:"type:varchar(3);not null;default:'IDR'" json:"currency"`
+	Status        WithdrawalStatus `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	TransactionID uuid.UUID        `gorm:"type:uuid;not null" json:"transaction_id"`
+	// Set once the payout provider has accepted the withdrawal
+	Provider          *string    `gorm:"type:varchar(50)" json:"provider,omitempty"`
+	ProviderReference *string    `gorm:"type:varchar(100)" json:"provider_reference,omitempty"`
+	FailureReason     *string    `gorm:"type:varchar(50)" json:"failure_reason,omitempty"`
+	LastError         *string    `gorm:"type:text" json:"last_error,omitempty"`
+	Attempts          int        `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt     time.Time  `gorm:"not null" json:"-"`
+	SubmittedAt       *time.Time `json:"submitted_at,omitempty"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (w *Withdrawal) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
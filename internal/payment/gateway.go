@@ -0,0 +1,63 @@
+// Package payment collects top-up payments through payment gateways.
+package payment
+
+import (
+	"context"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Headers of a payment result callback. The signature is made with
+// utils.SignPayload and the shared callback secret.
+const (
+	HeaderTimestamp = "X-Payment-Timestamp"
+	HeaderSignature = "X-Payment-Signature"
+)
+
+// Payment is a request to collect Amount from the user before ExpiresAt. ID
+// identifies the top-up and doubles as the idempotency key: creating the
+// same ID again must return the same instruction.
+type Payment struct {
+	ID        uuid.UUID
+	Amount    models.Money
+	Currency  models.Currency
+	ExpiresAt time.Time
+}
+
+// Instruction tells the user how to pay: into a virtual account, on a
+// payment page, or either. Reference is the gateway's ID of the payment.
+type Instruction struct {
+	Reference            string
+	VirtualAccountNumber string
+	PaymentURL           string
+}
+
+// PaymentGateway collects payments for top-ups. CreatePayment only opens
+// the payment; the outcome arrives later as a PaymentResult sent to the
+// payment callback endpoint. A gateway must refuse payments after the
+// payment's ExpiresAt.
+type PaymentGateway interface {
+	Name() string
+	CreatePayment(ctx context.Context, payment Payment) (*Instruction, error)
+}
+
+// PaymentStatus is the final outcome of a payment.
+type PaymentStatus string
+
+const (
+	PaymentPaid   PaymentStatus = "paid"
+	PaymentFailed PaymentStatus = "failed"
+)
+
+// PaymentResult is the body of a payment result callback. Amount is what the
+// user paid, and FailureReason the gateway's explanation of a failed
+// payment.
+type PaymentResult struct {
+	TopUpID       uuid.UUID     `json:"topup_id" binding:"required"`
+	Reference     string        `json:"reference"`
+	Status        PaymentStatus `json:"status" binding:"required,oneof=paid failed"`
+	Amount        models.Money  `json:"amount" swaggertype:"number"`
+	FailureReason string        `json:"failure_reason,omitempty"`
+}
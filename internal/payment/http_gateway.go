@@ -0,0 +1,103 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"ewallet/internal/models"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const defaultGatewayTimeout = 10 * time.Second
+
+// CreatePaymentRequest is the body of POST {base URL}/payments. The request
+// carries the top-up ID in the Idempotency-Key header as well.
+type CreatePaymentRequest struct {
+	ID        uuid.UUID       `json:"id"`
+	Amount    models.Money    `json:"amount"`
+	Currency  models.Currency `json:"currency"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// CreatePaymentResponse is the gateway's answer to CreatePaymentRequest.
+type CreatePaymentResponse struct {
+	Reference            string `json:"reference"`
+	VirtualAccountNumber string `json:"virtual_account_number,omitempty"`
+	PaymentURL           string `json:"payment_url,omitempty"`
+}
+
+// HTTPGateway opens payments on a payment gateway's HTTP API. Requests are
+// authenticated with the API key as a bearer token; results come back
+// through the payment callback endpoint.
+type HTTPGateway struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewHTTPGateway creates a gateway client for the API at baseURL. A nil
+// client gets a default client with a short timeout.
+func NewHTTPGateway(baseURL, apiKey string, client *http.Client) *HTTPGateway {
+	if client == nil {
+		client = &http.Client{Timeout: defaultGatewayTimeout}
+	}
+	return &HTTPGateway{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  client,
+	}
+}
+
+func (g *HTTPGateway) Name() string {
+	return "http"
+}
+
+func (g *HTTPGateway) CreatePayment(ctx context.Context, payment Payment) (*Instruction, error) {
+	body, err := json.Marshal(CreatePaymentRequest{
+		ID:        payment.ID,
+		Amount:    payment.Amount,
+		Currency:  payment.Currency,
+		ExpiresAt: payment.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.baseURL+"/payments", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+g.apiKey)
+	req.Header.Set("Idempotency-Key", payment.ID.String())
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("payment gateway responded with status %d", resp.StatusCode)
+	}
+
+	var created CreatePaymentResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&created); err != nil {
+		return nil, fmt.Errorf("invalid payment gateway response: %w", err)
+	}
+	if created.Reference == "" {
+		return nil, errors.New("invalid payment gateway response: missing reference")
+	}
+	return &Instruction{
+		Reference:            created.Reference,
+		VirtualAccountNumber: created.VirtualAccountNumber,
+		PaymentURL:           created.PaymentURL,
+	}, nil
+}
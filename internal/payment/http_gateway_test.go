@@ -0,0 +1,85 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet/internal/models"
+	"ewallet/pkg/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestHTTPGatewayOpensPaymentsOnTheSimulator(t *testing.T) {
+	results := make(chan PaymentResult, 1)
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !utils.VerifyPayloadSignature("callback-secret", r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderSignature), body, time.Minute) {
+			t.Error("callback signature does not verify")
+		}
+		var result PaymentResult
+		if err := json.Unmarshal(body, &result); err != nil {
+			t.Errorf("callback body: %v", err)
+		}
+		results <- result
+	}))
+	t.Cleanup(callback.Close)
+
+	simulator := NewSimulator(callback.URL, "callback-secret", nil)
+	server := httptest.NewServer(simulator.Handler("api-key"))
+	t.Cleanup(server.Close)
+
+	payment := Payment{
+		ID:        uuid.New(),
+		Amount:    models.NewMoney(100),
+		Currency:  models.DefaultCurrency,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	instruction, err := NewHTTPGateway(server.URL, "api-key", nil).CreatePayment(context.Background(), payment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(instruction.Reference, "SIM-") || instruction.VirtualAccountNumber == "" {
+		t.Fatalf("instruction = %+v, want a simulator reference and virtual account", instruction)
+	}
+
+	// Opening the same payment again returns the same instruction
+	again, err := NewHTTPGateway(server.URL, "api-key", nil).CreatePayment(context.Background(), payment)
+	if err != nil || *again != *instruction {
+		t.Fatalf("second instruction = %+v, %v, want %+v", again, err, instruction)
+	}
+
+	resp, err := http.Post(server.URL+"/payments/"+instruction.Reference+"/pay", "application/json", strings.NewReader(`{"status":"paid"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("pay status = %d, want 200", resp.StatusCode)
+	}
+
+	result := <-results
+	if result.TopUpID != payment.ID || result.Reference != instruction.Reference || result.Status != PaymentPaid || result.Amount != payment.Amount {
+		t.Errorf("result = %+v, want the paid payment", result)
+	}
+}
+
+func TestHTTPGatewayRefusedWithoutTheAPIKey(t *testing.T) {
+	server := httptest.NewServer(NewSimulator("http://127.0.0.1:1", "callback-secret", nil).Handler("api-key"))
+	t.Cleanup(server.Close)
+
+	_, err := NewHTTPGateway(server.URL, "wrong-key", nil).CreatePayment(context.Background(), Payment{
+		ID:        uuid.New(),
+		Amount:    models.NewMoney(100),
+		Currency:  models.DefaultCurrency,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want the gateway's 401", err)
+	}
+}
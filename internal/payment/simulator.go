@@ -0,0 +1,161 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"ewallet/pkg/utils"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultSimulatorCallbackTimeout = 10 * time.Second
+
+var (
+	// ErrPaymentNotFound is returned by Simulator.Pay for an unknown
+	// reference.
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentExpired is returned by Simulator.Pay after the payment
+	// expired.
+	ErrPaymentExpired = errors.New("payment has expired")
+	// ErrPaymentSettled is returned by Simulator.Pay for a payment that was
+	// already paid or failed.
+	ErrPaymentSettled = errors.New("payment has already been settled")
+)
+
+// Simulator is a payment gateway for local development and testing. It
+// never collects real money: each payment gets a virtual account number
+// derived from the top-up ID, and Pay plays the user paying into it (or the
+// payment failing) by sending the signed result to the callback URL.
+// Payments are kept in memory, so they are lost on restart.
+type Simulator struct {
+	callbackURL string
+	secret      string
+	client      *http.Client
+
+	mu       sync.Mutex
+	payments map[string]*simulatedPayment
+}
+
+type simulatedPayment struct {
+	payment     Payment
+	instruction Instruction
+	settled     bool
+}
+
+// NewSimulator creates a simulator that reports to callbackURL, signing with
+// secret. A nil client gets a default client with a short timeout.
+func NewSimulator(callbackURL, secret string, client *http.Client) *Simulator {
+	if client == nil {
+		client = &http.Client{Timeout: defaultSimulatorCallbackTimeout}
+	}
+	return &Simulator{
+		callbackURL: callbackURL,
+		secret:      secret,
+		client:      client,
+		payments:    make(map[string]*simulatedPayment),
+	}
+}
+
+func (s *Simulator) Name() string {
+	return "simulator"
+}
+
+func (s *Simulator) CreatePayment(ctx context.Context, payment Payment) (*Instruction, error) {
+	// The same top-up always gets the same reference and account number
+	hex := strings.ToUpper(strings.ReplaceAll(payment.ID.String(), "-", ""))
+	reference := "SIM-" + hex[:16]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.payments[reference]; ok {
+		instruction := existing.instruction
+		return &instruction, nil
+	}
+
+	account := make([]byte, 12)
+	for i := range account {
+		account[i] = '0' + payment.ID[i]%10
+	}
+	instruction := Instruction{
+		Reference:            reference,
+		VirtualAccountNumber: "8808" + string(account),
+	}
+	s.payments[reference] = &simulatedPayment{payment: payment, instruction: instruction}
+	return &instruction, nil
+}
+
+// Pay settles the payment with the given status and reports it to the
+// callback URL. A paid payment is reported with its full amount. It fails
+// if the payment is unknown, expired or already settled, or if the callback
+// is not accepted; a payment whose callback failed can be paid again.
+func (s *Simulator) Pay(ctx context.Context, reference string, status PaymentStatus) error {
+	s.mu.Lock()
+	p, ok := s.payments[reference]
+	switch {
+	case !ok:
+		s.mu.Unlock()
+		return ErrPaymentNotFound
+	case p.settled:
+		s.mu.Unlock()
+		return ErrPaymentSettled
+	case time.Now().After(p.payment.ExpiresAt):
+		s.mu.Unlock()
+		return ErrPaymentExpired
+	}
+	p.settled = true
+	s.mu.Unlock()
+
+	result := PaymentResult{
+		TopUpID:   p.payment.ID,
+		Reference: reference,
+		Status:    status,
+	}
+	if status == PaymentPaid {
+		result.Amount = p.payment.Amount
+	} else {
+		result.FailureReason = "payment cancelled by the user"
+	}
+
+	if err := s.sendResult(ctx, result); err != nil {
+		s.mu.Lock()
+		p.settled = false
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// sendResult POSTs the signed result to the callback URL.
+func (s *Simulator) sendResult(ctx context.Context, result PaymentResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, utils.SignPayload(s.secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s responded with status %d", s.callbackURL, resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package payment
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+)
+
+type simulatePaymentRequest struct {
+	Status PaymentStatus `json:"status"`
+}
+
+type simulatorError struct {
+	Error string `json:"error"`
+}
+
+// Handler serves the simulator over HTTP the way HTTPGateway expects a
+// gateway to, so the server can be run against it in development:
+//   - POST /payments opens a payment; it needs the API key as a bearer token
+//   - POST /payments/{reference}/pay plays the user paying, or the payment
+//     failing, with a body of {"status": "paid"} or {"status": "failed"}
+//
+// The pay route is not authenticated, so the simulator must never be
+// reachable from outside a development machine.
+func (s *Simulator) Handler(apiKey string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /payments", func(w http.ResponseWriter, r *http.Request) {
+		token := []byte("Bearer " + apiKey)
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), token) != 1 {
+			writeSimulatorJSON(w, http.StatusUnauthorized, simulatorError{Error: "invalid API key"})
+			return
+		}
+
+		var req CreatePaymentRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeSimulatorJSON(w, http.StatusBadRequest, simulatorError{Error: err.Error()})
+			return
+		}
+
+		instruction, err := s.CreatePayment(r.Context(), Payment{
+			ID:        req.ID,
+			Amount:    req.Amount,
+			Currency:  req.Currency,
+			ExpiresAt: req.ExpiresAt,
+		})
+		if err != nil {
+			writeSimulatorJSON(w, http.StatusInternalServerError, simulatorError{Error: err.Error()})
+			return
+		}
+		writeSimulatorJSON(w, http.StatusCreated, CreatePaymentResponse{
+			Reference:            instruction.Reference,
+			VirtualAccountNumber: instruction.VirtualAccountNumber,
+			PaymentURL:           instruction.PaymentURL,
+		})
+	})
+	mux.HandleFunc("POST /payments/{reference}/pay", func(w http.ResponseWriter, r *http.Request) {
+		var req simulatePaymentRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeSimulatorJSON(w, http.StatusBadRequest, simulatorError{Error: err.Error()})
+			return
+		}
+		if req.Status != PaymentPaid && req.Status != PaymentFailed {
+			writeSimulatorJSON(w, http.StatusBadRequest, simulatorError{Error: `status must be "paid" or "failed"`})
+			return
+		}
+
+		reference := r.PathValue("reference")
+		err := s.Pay(r.Context(), reference, req.Status)
+		switch {
+		case err == nil:
+			writeSimulatorJSON(w, http.StatusOK, map[string]string{"reference": reference, "status": string(req.Status)})
+		case errors.Is(err, ErrPaymentNotFound):
+			writeSimulatorJSON(w, http.StatusNotFound, simulatorError{Error: err.Error()})
+		case errors.Is(err, ErrPaymentExpired), errors.Is(err, ErrPaymentSettled):
+			writeSimulatorJSON(w, http.StatusConflict, simulatorError{Error: err.Error()})
+		default:
+			writeSimulatorJSON(w, http.StatusBadGateway, simulatorError{Error: err.Error()})
+		}
+	})
+	return mux
+}
+
+func writeSimulatorJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("payment simulator: failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,196 @@
+// Package realtime fans out wallet notifications to the streams open on
+// this server instance.
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet/internal/models"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Notifications buffered per stream before it is considered too slow
+	subscriptionBuffer = 32
+
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = 30 * time.Second
+)
+
+// Subscription receives the notifications of one user. Its channel is
+// closed when the subscriber falls too far behind or the broker stops; the
+// client should then reconnect and refetch its balance.
+type Subscription struct {
+	C      <-chan models.WalletNotification
+	userID uuid.UUID
+	ch     chan models.WalletNotification
+	closed bool
+}
+
+// Broker listens for wallet notifications on a dedicated Postgres
+// connection and hands each to the subscriptions of its user. Because every
+// instance runs its own broker on the same channel, a change committed
+// through any instance reaches streams open on all of them.
+type Broker struct {
+	dsn string
+
+	mu      sync.Mutex
+	subs    map[uuid.UUID]map[*Subscription]struct{}
+	stopped bool
+}
+
+func NewBroker(dsn string) *Broker {
+	return &Broker{
+		dsn:  dsn,
+		subs: make(map[uuid.UUID]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe opens a subscription to the user's notifications. The caller
+// must Unsubscribe when done.
+func (b *Broker) Subscribe(userID uuid.UUID) *Subscription {
+	ch := make(chan models.WalletNotification, subscriptionBuffer)
+	sub := &Subscription{C: ch, userID: userID, ch: ch}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped {
+		sub.closed = true
+		close(ch)
+		return sub
+	}
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[*Subscription]struct{})
+	}
+	b.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+// remove drops the subscription and closes its channel. b.mu must be held.
+func (b *Broker) remove(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+
+	if userSubs := b.subs[sub.userID]; userSubs != nil {
+		delete(userSubs, sub)
+		if len(userSubs) == 0 {
+			delete(b.subs, sub.userID)
+		}
+	}
+}
+
+// dispatch hands the notification to the user's subscriptions without
+// blocking. A subscription whose buffer is full is closed.
+func (b *Broker) dispatch(n models.WalletNotification) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs[n.UserID] {
+		select {
+		case sub.ch <- n:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// broadcastResync tells every subscriber that notifications may have been
+// missed while the broker was not listening.
+func (b *Broker) broadcastResync() {
+	b.mu.Lock()
+	userIDs := make([]uuid.UUID, 0, len(b.subs))
+	for userID := range b.subs {
+		userIDs = append(userIDs, userID)
+	}
+	b.mu.Unlock()
+
+	now := time.Now()
+	for _, userID := range userIDs {
+		b.dispatch(models.WalletNotification{UserID: userID, Type: models.NotificationResync, OccurredAt: now})
+	}
+}
+
+// Run listens until ctx is cancelled, reconnecting with backoff when the
+// connection is lost. When it returns, all subscriptions are closed.
+func (b *Broker) Run(ctx context.Context) {
+	defer b.stop()
+
+	delay := reconnectBaseDelay
+	connectedBefore := false
+	for {
+		err := b.listen(ctx, func() {
+			delay = reconnectBaseDelay
+			if connectedBefore {
+				b.broadcastResync()
+			}
+			connectedBefore = true
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("realtime broker: %v; reconnecting in %s", err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+// listen opens a connection, LISTENs on the notification channel and
+// dispatches notifications until the connection fails or ctx is done.
+// onListening is called once the LISTEN is in place.
+func (b *Broker) listen(ctx context.Context, onListening func()) error {
+	conn, err := pgx.Connect(ctx, b.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{models.WalletNotificationChannel}.Sanitize()); err != nil {
+		return err
+	}
+	onListening()
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var n models.WalletNotification
+		if err := json.Unmarshal([]byte(notification.Payload), &n); err != nil {
+			log.Printf("realtime broker: invalid notification: %v", err)
+			continue
+		}
+		b.dispatch(n)
+	}
+}
+
+func (b *Broker) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+	for _, userSubs := range b.subs {
+		for sub := range userSubs {
+			b.remove(sub)
+		}
+	}
+}
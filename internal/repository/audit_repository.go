@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditQuery selects a page of audit events, newest first. Nil filters are
+// ignored. BeforeSequence is the keyset cursor.
+type AuditQuery struct {
+	ActorID        *uuid.UUID
+	Action         *string
+	TargetType     *string
+	TargetID       *string
+	RequestID      *string
+	From           *time.Time
+	To             *time.Time
+	BeforeSequence *int64
+	Limit          int
+}
+
+// Audit events can only be inserted; there are no update or delete methods,
+// and the table rejects them with a trigger. Events are recorded into the
+// queue and later appended to the chain under the chain lock.
+type AuditRepository interface {
+	Enqueue(tx *gorm.DB, event *models.QueuedAuditEvent) error
+	FindQueued(tx *gorm.DB, limit int) ([]models.QueuedAuditEvent, error)
+	DeleteQueued(tx *gorm.DB, positions []int64) error
+	LockChain(tx *gorm.DB) error
+	FindLast(tx *gorm.DB) (*models.AuditEvent, error)
+	Create(tx *gorm.DB, event *models.AuditEvent) error
+	Find(query AuditQuery) ([]models.AuditEvent, error)
+	FindAfterSequence(sequence int64, limit int) ([]models.AuditEvent, error)
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Enqueue(tx *gorm.DB, event *models.QueuedAuditEvent) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(event).Error
+}
+
+// FindQueued returns the oldest queued events in the order they were queued.
+func (r *auditRepository) FindQueued(tx *gorm.DB, limit int) ([]models.QueuedAuditEvent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var queued []models.QueuedAuditEvent
+	err := tx.Order("position ASC").Limit(limit).Find(&queued).Error
+	return queued, err
+}
+
+func (r *auditRepository) DeleteQueued(tx *gorm.DB, positions []int64) error {
+	if tx == nil {
+		tx = r.db
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+	return tx.Where("position IN ?", positions).Delete(&models.QueuedAuditEvent{}).Error
+}
+
+// LockChain serializes appends to the hash chain until tx ends.
+func (r *auditRepository) LockChain(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "audit_events").Error
+}
+
+// FindLast returns the newest event, or nil if the log is empty.
+func (r *auditRepository) FindLast(tx *gorm.DB) (*models.AuditEvent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var event models.AuditEvent
+	err := tx.Order("sequence DESC").First(&event).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *auditRepository) Create(tx *gorm.DB, event *models.AuditEvent) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(event).Error
+}
+
+func (r *auditRepository) Find(q AuditQuery) ([]models.AuditEvent, error) {
+	query := r.db
+	if q.ActorID != nil {
+		query = query.Where("actor_id = ?", *q.ActorID)
+	}
+	if q.Action != nil {
+		query = query.Where("action = ?", *q.Action)
+	}
+	if q.TargetType != nil {
+		query = query.Where("target_type = ?", *q.TargetType)
+	}
+	if q.TargetID != nil {
+		query = query.Where("target_id = ?", *q.TargetID)
+	}
+	if q.RequestID != nil {
+		query = query.Where("request_id = ?", *q.RequestID)
+	}
+	if q.From != nil {
+		query = query.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("created_at < ?", *q.To)
+	}
+	if q.BeforeSequence != nil {
+		query = query.Where("sequence < ?", *q.BeforeSequence)
+	}
+
+	query = query.Order("sequence DESC")
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
+	}
+
+	var events []models.AuditEvent
+	err := query.Find(&events).Error
+	return events, err
+}
+
+// FindAfterSequence returns events in chain order, starting after sequence.
+func (r *auditRepository) FindAfterSequence(sequence int64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.db.Where("sequence > ?", sequence).Order("sequence ASC").Limit(limit).Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type BankAccountRepository interface {
+	Create(tx *gorm.DB, account *models.BankAccount) error
+	FindOwned(userID, id uuid.UUID) (*models.BankAccount, error)
+	FindByUserID(userID uuid.UUID) ([]models.BankAccount, error)
+	CountByUserID(tx *gorm.DB, userID uuid.UUID) (int64, error)
+	Exists(tx *gorm.DB, userID uuid.UUID, bankCode, accountNumber string) (bool, error)
+	Delete(tx *gorm.DB, id uuid.UUID) error
+}
+
+type bankAccountRepository struct {
+	db *gorm.DB
+}
+
+func NewBankAccountRepository(db *gorm.DB) BankAccountRepository {
+	return &bankAccountRepository{db: db}
+}
+
+func (r *bankAccountRepository) Create(tx *gorm.DB, account *models.BankAccount) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(account).Error
+}
+
+// FindOwned returns the user's bank account; accounts of other users and
+// removed accounts are reported as not found.
+func (r *bankAccountRepository) FindOwned(userID, id uuid.UUID) (*models.BankAccount, error) {
+	var account models.BankAccount
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("bank account not found")
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindByUserID returns the user's bank accounts, oldest first.
+func (r *bankAccountRepository) FindByUserID(userID uuid.UUID) ([]models.BankAccount, error) {
+	var accounts []models.BankAccount
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *bankAccountRepository) CountByUserID(tx *gorm.DB, userID uuid.UUID) (int64, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var count int64
+	err := tx.Model(&models.BankAccount{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// Exists reports whether the user has already registered the account.
+func (r *bankAccountRepository) Exists(tx *gorm.DB, userID uuid.UUID, bankCode, accountNumber string) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var count int64
+	err := tx.Model(&models.BankAccount{}).
+		Where("user_id = ? AND bank_code = ? AND account_number = ?", userID, bankCode, accountNumber).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Delete soft-deletes the account, so withdrawals made to it keep their
+// destination.
+func (r *bankAccountRepository) Delete(tx *gorm.DB, id uuid.UUID) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Delete(&models.BankAccount{}, "id = ?", id).Error
+}
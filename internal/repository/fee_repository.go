@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type FeeRepository interface {
+	FindRule(tx *gorm.DB, txType models.TransactionType, tier models.KYCTier, currency models.Currency, amount models.Money) (*models.FeeRule, error)
+}
+
+type feeRepository struct {
+	db *gorm.DB
+}
+
+func NewFeeRepository(db *gorm.DB) FeeRepository {
+	return &feeRepository{db: db}
+}
+
+// FindRule returns the rule that prices the transaction, or nil if none
+// applies. Rules for the user's tier win over rules for every tier, and
+// among overlapping bands the one with the highest minimum wins.
+func (r *feeRepository) FindRule(tx *gorm.DB, txType models.TransactionType, tier models.KYCTier, currency models.Currency, amount models.Money) (*models.FeeRule, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var rule models.FeeRule
+	err := tx.
+		Where("transaction_type = ? AND currency = ?", txType, currency).
+		Where("kyc_tier = ? OR kyc_tier IS NULL", tier).
+		Where("min_amount <= ? AND (max_amount IS NULL OR max_amount > ?)", amount, amount).
+		Order("kyc_tier IS NULL, min_amount DESC, created_at").
+		First(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &rule, nil
+}
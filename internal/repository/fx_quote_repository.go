@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type FXQuoteRepository interface {
+	Create(tx *gorm.DB, quote *models.FXQuote) error
+	FindByID(tx *gorm.DB, id uuid.UUID) (*models.FXQuote, error)
+	Redeem(tx *gorm.DB, id, userID, transactionID uuid.UUID, now time.Time) (bool, error)
+}
+
+type fxQuoteRepository struct {
+	db *gorm.DB
+}
+
+func NewFXQuoteRepository(db *gorm.DB) FXQuoteRepository {
+	return &fxQuoteRepository{db: db}
+}
+
+func (r *fxQuoteRepository) Create(tx *gorm.DB, quote *models.FXQuote) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(quote).Error
+}
+
+func (r *fxQuoteRepository) FindByID(tx *gorm.DB, id uuid.UUID) (*models.FXQuote, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var quote models.FXQuote
+	err := tx.First(&quote, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("fx quote not found")
+		}
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// Redeem marks the user's unused, unexpired quote as used by the
+// transaction. It reports false when the quote could not be redeemed, so of
+// two concurrent transfers only one gets the quote.
+func (r *fxQuoteRepository) Redeem(tx *gorm.DB, id, userID, transactionID uuid.UUID, now time.Time) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	result := tx.Model(&models.FXQuote{}).
+		Where("id = ? AND user_id = ? AND used_at IS NULL AND expires_at > ?", id, userID, now).
+		Updates(map[string]interface{}{
+			"used_at":        now,
+			"transaction_id": transactionID,
+		})
+	return result.RowsAffected == 1, result.Error
+}
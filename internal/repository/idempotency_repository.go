@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrIdempotencyKeyNotFound is returned when the user never used the key.
+var ErrIdempotencyKeyNotFound = errors.New("idempotency key not found")
+
+type IdempotencyRepository interface {
+	Claim(key *models.IdempotencyKey) (bool, error)
+	FindByUserAndKey(userID uuid.UUID, key string) (*models.IdempotencyKey, error)
+	Complete(id uuid.UUID, statusCode int, body string) error
+	Release(id uuid.UUID) error
+}
+
+type idempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+// Claim inserts the key unless the user already used it. It reports whether
+// this caller won the insert; the unique index makes concurrent claims safe.
+func (r *idempotencyRepository) Claim(key *models.IdempotencyKey) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *idempotencyRepository) FindByUserAndKey(userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIdempotencyKeyNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *idempotencyRepository) Complete(id uuid.UUID, statusCode int, body string) error {
+	return r.db.Model(&models.IdempotencyKey{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"response_body": body,
+			"completed_at":  time.Now(),
+		}).Error
+}
+
+// Release deletes an unfinished claim so the request can be retried.
+func (r *idempotencyRepository) Release(id uuid.UUID) error {
+	return r.db.Where("id = ? AND completed_at IS NULL", id).Delete(&models.IdempotencyKey{}).Error
+}
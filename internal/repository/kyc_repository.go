@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type KYCRepository interface {
+	CreateSubmission(tx *gorm.DB, submission *models.KYCSubmission) error
+	FindSubmissionByID(id uuid.UUID) (*models.KYCSubmission, error)
+	FindSubmissionForUpdate(tx *gorm.DB, id uuid.UUID) (*models.KYCSubmission, error)
+	FindLatestByUserID(userID uuid.UUID) (*models.KYCSubmission, error)
+	HasPending(tx *gorm.DB, userID uuid.UUID) (bool, error)
+	ListSubmissions(status *models.KYCStatus, limit int) ([]models.KYCSubmission, error)
+	UpdateSubmission(tx *gorm.DB, submission *models.KYCSubmission) error
+}
+
+type kycRepository struct {
+	db *gorm.DB
+}
+
+func NewKYCRepository(db *gorm.DB) KYCRepository {
+	return &kycRepository{db: db}
+}
+
+// CreateSubmission inserts the submission together with its documents.
+func (r *kycRepository) CreateSubmission(tx *gorm.DB, submission *models.KYCSubmission) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(submission).Error
+}
+
+func (r *kycRepository) FindSubmissionByID(id uuid.UUID) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	err := r.db.Preload("Documents").First(&submission, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("kyc submission not found")
+		}
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// FindSubmissionForUpdate locks the submission row for a review decision.
+func (r *kycRepository) FindSubmissionForUpdate(tx *gorm.DB, id uuid.UUID) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&submission, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("kyc submission not found")
+		}
+		return nil, err
+	}
+	return &submission, nil
+}
+
+// FindLatestByUserID returns the user's most recent submission, or nil if
+// the user never submitted one.
+func (r *kycRepository) FindLatestByUserID(userID uuid.UUID) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	err := r.db.Preload("Documents").
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		First(&submission).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &submission, nil
+}
+
+func (r *kycRepository) HasPending(tx *gorm.DB, userID uuid.UUID) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var count int64
+	err := tx.Model(&models.KYCSubmission{}).
+		Where("user_id = ? AND status = ?", userID, models.KYCStatusPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListSubmissions returns submissions oldest first, so the review queue is
+// worked in arrival order. A nil status returns every submission.
+func (r *kycRepository) ListSubmissions(status *models.KYCStatus, limit int) ([]models.KYCSubmission, error) {
+	db := r.db.Order("created_at ASC").Limit(limit)
+	if status != nil {
+		db = db.Where("status = ?", *status)
+	}
+	var submissions []models.KYCSubmission
+	err := db.Find(&submissions).Error
+	return submissions, err
+}
+
+func (r *kycRepository) UpdateSubmission(tx *gorm.DB, submission *models.KYCSubmission) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(submission).Select("status", "review_note", "reviewed_by", "reviewed_at").Updates(submission).Error
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LedgerRepository interface {
+	CreateAccount(tx *gorm.DB, account *models.LedgerAccount) error
+	FindAccountsByIDs(tx *gorm.DB, ids []uuid.UUID) ([]models.LedgerAccount, error)
+	FindAccountByWalletID(tx *gorm.DB, walletID uuid.UUID) (*models.LedgerAccount, error)
+	FindSystemAccount(tx *gorm.DB, code string, currency models.Currency) (*models.LedgerAccount, error)
+	CreateSystemAccount(tx *gorm.DB, account *models.LedgerAccount) error
+	CreateEntry(tx *gorm.DB, entry *models.JournalEntry) error
+	SumCreditNormal(tx *gorm.DB, accountID uuid.UUID) (models.Money, error)
+	FindWalletPosting(transactionID, walletID uuid.UUID) (*models.Posting, error)
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) CreateAccount(tx *gorm.DB, account *models.LedgerAccount) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(account).Error
+}
+
+func (r *ledgerRepository) FindAccountsByIDs(tx *gorm.DB, ids []uuid.UUID) ([]models.LedgerAccount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var accounts []models.LedgerAccount
+	err := tx.Where("id IN ?", ids).Find(&accounts).Error
+	return accounts, err
+}
+
+func (r *ledgerRepository) FindAccountByWalletID(tx *gorm.DB, walletID uuid.UUID) (*models.LedgerAccount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var account models.LedgerAccount
+	err := tx.Where("wallet_id = ?", walletID).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("ledger account not found")
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// FindSystemAccount returns the system account with the code in the
+// currency, or nil if it has not been opened yet.
+func (r *ledgerRepository) FindSystemAccount(tx *gorm.DB, code string, currency models.Currency) (*models.LedgerAccount, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var account models.LedgerAccount
+	err := tx.Where("code = ? AND currency = ?", code, currency).First(&account).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &account, nil
+}
+
+// CreateSystemAccount inserts the account unless an account with the same
+// code and currency exists, which happens when two transactions open it at
+// once.
+func (r *ledgerRepository) CreateSystemAccount(tx *gorm.DB, account *models.LedgerAccount) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(account).Error
+}
+
+// CreateEntry inserts the journal entry together with its postings.
+func (r *ledgerRepository) CreateEntry(tx *gorm.DB, entry *models.JournalEntry) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(entry).Error
+}
+
+// SumCreditNormal returns credits minus debits over all postings of the account.
+func (r *ledgerRepository) SumCreditNormal(tx *gorm.DB, accountID uuid.UUID) (models.Money, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var total models.Money
+	err := tx.Model(&models.Posting{}).
+		Select("COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE -amount END), 0)", models.PostingDirectionCredit).
+		Where("account_id = ?", accountID).
+		Row().Scan(&total)
+	return total, err
+}
+
+// FindWalletPosting returns the latest posting a transaction made to a wallet,
+// or nil if the transaction did not touch the wallet.
+func (r *ledgerRepository) FindWalletPosting(transactionID, walletID uuid.UUID) (*models.Posting, error) {
+	var posting models.Posting
+	err := r.db.
+		Joins("JOIN journal_entries ON journal_entries.id = postings.journal_entry_id").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = postings.account_id").
+		Where("journal_entries.transaction_id = ? AND ledger_accounts.wallet_id = ?", transactionID, walletID).
+		Order("postings.created_at DESC").
+		First(&posting).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &posting, nil
+}
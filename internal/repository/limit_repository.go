@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LimitRepository interface {
+	FindGlobal(tx *gorm.DB) (*models.LimitPolicy, error)
+	FindByTier(tx *gorm.DB, tier models.KYCTier) (*models.LimitPolicy, error)
+	FindByUserID(tx *gorm.DB, userID uuid.UUID) (*models.LimitPolicy, error)
+	LockUser(tx *gorm.DB, userID uuid.UUID) error
+	LockUserBalance(tx *gorm.DB, userID uuid.UUID) error
+}
+
+type limitRepository struct {
+	db *gorm.DB
+}
+
+func NewLimitRepository(db *gorm.DB) LimitRepository {
+	return &limitRepository{db: db}
+}
+
+// FindGlobal returns the global policy, or nil if none is configured.
+func (r *limitRepository) FindGlobal(tx *gorm.DB) (*models.LimitPolicy, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var policy models.LimitPolicy
+	err := tx.Where("user_id IS NULL AND kyc_tier IS NULL").First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FindByTier returns the policy of a KYC tier, or nil if there is none.
+func (r *limitRepository) FindByTier(tx *gorm.DB, tier models.KYCTier) (*models.LimitPolicy, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var policy models.LimitPolicy
+	err := tx.Where("kyc_tier = ?", tier).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// FindByUserID returns the user's override policy, or nil if there is none.
+func (r *limitRepository) FindByUserID(tx *gorm.DB, userID uuid.UUID) (*models.LimitPolicy, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	var policy models.LimitPolicy
+	err := tx.Where("user_id = ?", userID).First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// LockUser takes a transaction-scoped advisory lock on the user's limit
+// usage, so concurrent debits of the same user are counted one at a time.
+func (r *limitRepository) LockUser(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "limits:"+userID.String()).Error
+}
+
+// LockUserBalance takes a transaction-scoped advisory lock on the user's
+// total balance, so concurrent credits to different wallets of the same user
+// are checked against the maximum balance one at a time. It uses a different
+// key from LockUser, so a transfer holding the sender's usage lock never
+// waits on a lock held by a transfer in the opposite direction.
+func (r *limitRepository) LockUserBalance(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "balance:"+userID.String()).Error
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+)
+
+// NotificationRepository sends Postgres notifications. A notification sent
+// inside a transaction is delivered to listeners only when it commits, and
+// dropped if it rolls back.
+type NotificationRepository interface {
+	Notify(tx *gorm.DB, channel, payload string) error
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+func (r *notificationRepository) Notify(tx *gorm.DB, channel, payload string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Exec("SELECT pg_notify(?, ?)", channel, payload).Error
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository interface {
+	Create(tx *gorm.DB, event *models.OutboxEvent) error
+	ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error)
+	MarkDelivered(tx *gorm.DB, id uuid.UUID, deliveredAt time.Time) error
+	MarkFailed(tx *gorm.DB, id uuid.UUID, lastError string, nextAttemptAt time.Time) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Create(tx *gorm.DB, event *models.OutboxEvent) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(event).Error
+}
+
+// ClaimDue leases up to limit undelivered events that are due, oldest
+// first, by moving their next attempt to leaseUntil, and commits. Rows
+// locked by another relay are skipped, so several relays can share the
+// outbox; an event whose relay stops before recording the outcome is due
+// again once the lease runs out.
+func (r *outboxRepository) ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var events []models.OutboxEvent
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("delivered_at IS NULL AND next_attempt_at <= ?", now).
+			Order("created_at ASC").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+			events[i].NextAttemptAt = leaseUntil
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("next_attempt_at", leaseUntil).Error
+	})
+	return events, err
+}
+
+func (r *outboxRepository) MarkDelivered(tx *gorm.DB, id uuid.UUID, deliveredAt time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.OutboxEvent{}).
+		Where("id = ? AND delivered_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"attempts":     gorm.Expr("attempts + 1"),
+			"last_error":   nil,
+			"delivered_at": deliveredAt,
+		}).Error
+}
+
+// MarkFailed schedules the next attempt of an event that is still
+// undelivered.
+func (r *outboxRepository) MarkFailed(tx *gorm.DB, id uuid.UUID, lastError string, nextAttemptAt time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.OutboxEvent{}).
+		Where("id = ? AND delivered_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_error":      lastError,
+			"next_attempt_at": nextAttemptAt,
+		}).Error
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PaymentRequestRepository interface {
+	Create(tx *gorm.DB, request *models.PaymentRequest) error
+	FindByID(id uuid.UUID) (*models.PaymentRequest, error)
+	Find(userID uuid.UUID, role *models.PaymentRequestRole, status *models.PaymentRequestStatus, limit int) ([]models.PaymentRequest, error)
+	Resolve(tx *gorm.DB, id uuid.UUID, status models.PaymentRequestStatus, transactionID *uuid.UUID, now time.Time) (bool, error)
+	ExpireDue(tx *gorm.DB, userID uuid.UUID, now time.Time) error
+}
+
+type paymentRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRequestRepository(db *gorm.DB) PaymentRequestRepository {
+	return &paymentRequestRepository{db: db}
+}
+
+func (r *paymentRequestRepository) Create(tx *gorm.DB, request *models.PaymentRequest) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(request).Error
+}
+
+func (r *paymentRequestRepository) FindByID(id uuid.UUID) (*models.PaymentRequest, error) {
+	var request models.PaymentRequest
+	err := r.db.First(&request, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("payment request not found")
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Find returns the requests the user made or was asked to pay, newest first.
+func (r *paymentRequestRepository) Find(userID uuid.UUID, role *models.PaymentRequestRole, status *models.PaymentRequestStatus, limit int) ([]models.PaymentRequest, error) {
+	query := r.db.Model(&models.PaymentRequest{})
+	switch {
+	case role == nil:
+		query = query.Where("requester_id = ? OR payer_id = ?", userID, userID)
+	case *role == models.PaymentRequestIncoming:
+		query = query.Where("payer_id = ?", userID)
+	default:
+		query = query.Where("requester_id = ?", userID)
+	}
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var requests []models.PaymentRequest
+	err := query.Order("created_at DESC").Limit(limit).Find(&requests).Error
+	return requests, err
+}
+
+// Resolve moves a pending, unexpired request to its final status. It reports
+// false when the request was no longer pending, so of two concurrent
+// answers only one wins.
+func (r *paymentRequestRepository) Resolve(tx *gorm.DB, id uuid.UUID, status models.PaymentRequestStatus, transactionID *uuid.UUID, now time.Time) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	updates := map[string]interface{}{
+		"status":       status,
+		"responded_at": now,
+		"updated_at":   now,
+	}
+	if transactionID != nil {
+		updates["transaction_id"] = *transactionID
+	}
+
+	result := tx.Model(&models.PaymentRequest{}).
+		Where("id = ? AND status = ? AND expires_at > ?", id, models.PaymentRequestPending, now).
+		Updates(updates)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ExpireDue marks the user's pending requests past their expiry as expired.
+func (r *paymentRequestRepository) ExpireDue(tx *gorm.DB, userID uuid.UUID, now time.Time) error {
+	if tx == nil {
+		tx = r.db
+	}
+
+	return tx.Model(&models.PaymentRequest{}).
+		Where("(requester_id = ? OR payer_id = ?) AND status = ? AND expires_at <= ?", userID, userID, models.PaymentRequestPending, now).
+		Updates(map[string]interface{}{
+			"status":     models.PaymentRequestExpired,
+			"updated_at": now,
+		}).Error
+}
@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ScheduledTransferRepository interface {
+	Create(tx *gorm.DB, schedule *models.ScheduledTransfer) error
+	FindByID(userID, id uuid.UUID) (*models.ScheduledTransfer, error)
+	FindByIDForUpdate(tx *gorm.DB, userID, id uuid.UUID) (*models.ScheduledTransfer, error)
+	FindByUserID(userID uuid.UUID, status *models.ScheduledTransferStatus, limit int) ([]models.ScheduledTransfer, error)
+	CountActive(userID uuid.UUID) (int64, error)
+	Update(tx *gorm.DB, schedule *models.ScheduledTransfer) error
+	ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.ScheduledTransfer, error)
+	CreateRun(tx *gorm.DB, run *models.ScheduledTransferRun) error
+	FindRuns(scheduleID uuid.UUID, limit int) ([]models.ScheduledTransferRun, error)
+	HasSucceeded(tx *gorm.DB, scheduleID uuid.UUID, scheduledFor time.Time) (bool, error)
+}
+
+type scheduledTransferRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduledTransferRepository(db *gorm.DB) ScheduledTransferRepository {
+	return &scheduledTransferRepository{db: db}
+}
+
+func (r *scheduledTransferRepository) Create(tx *gorm.DB, schedule *models.ScheduledTransfer) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(schedule).Error
+}
+
+// FindByID returns the user's scheduled transfer; those of other users are
+// reported as not found.
+func (r *scheduledTransferRepository) FindByID(userID, id uuid.UUID) (*models.ScheduledTransfer, error) {
+	return r.find(r.db, userID, id)
+}
+
+// FindByIDForUpdate is FindByID that locks the row until tx ends. A
+// scheduler running the transfer records its outcome on the row as it is
+// then, so changes made meanwhile are kept.
+func (r *scheduledTransferRepository) FindByIDForUpdate(tx *gorm.DB, userID, id uuid.UUID) (*models.ScheduledTransfer, error) {
+	return r.find(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID, id)
+}
+
+func (r *scheduledTransferRepository) find(db *gorm.DB, userID, id uuid.UUID) (*models.ScheduledTransfer, error) {
+	var schedule models.ScheduledTransfer
+	err := db.Where("id = ? AND user_id = ?", id, userID).First(&schedule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("scheduled transfer not found")
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// FindByUserID returns the user's scheduled transfers, newest first.
+func (r *scheduledTransferRepository) FindByUserID(userID uuid.UUID, status *models.ScheduledTransferStatus, limit int) ([]models.ScheduledTransfer, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var schedules []models.ScheduledTransfer
+	err := query.Order("created_at DESC").Limit(limit).Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *scheduledTransferRepository) CountActive(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.ScheduledTransfer{}).
+		Where("user_id = ? AND status = ?", userID, models.ScheduledTransferActive).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *scheduledTransferRepository) Update(tx *gorm.DB, schedule *models.ScheduledTransfer) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Save(schedule).Error
+}
+
+// ClaimDue leases up to limit active transfers that are due and not leased
+// by another scheduler, earliest first, by setting their lease to
+// leaseUntil, and commits. Rows locked by another scheduler, or by a user
+// editing them, are skipped; a transfer whose scheduler stops before
+// recording the outcome is due again once the lease runs out.
+func (r *scheduledTransferRepository) ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.ScheduledTransfer, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var schedules []models.ScheduledTransfer
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_run_at <= ?", models.ScheduledTransferActive, now).
+			Where("leased_until IS NULL OR leased_until <= ?", now).
+			Order("next_run_at ASC").
+			Limit(limit).
+			Find(&schedules).Error
+		if err != nil || len(schedules) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(schedules))
+		for i := range schedules {
+			ids[i] = schedules[i].ID
+			schedules[i].LeasedUntil = &leaseUntil
+		}
+		return tx.Model(&models.ScheduledTransfer{}).Where("id IN ?", ids).Update("leased_until", leaseUntil).Error
+	})
+	return schedules, err
+}
+
+func (r *scheduledTransferRepository) CreateRun(tx *gorm.DB, run *models.ScheduledTransferRun) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(run).Error
+}
+
+// FindRuns returns the latest runs of a scheduled transfer, newest first.
+func (r *scheduledTransferRepository) FindRuns(scheduleID uuid.UUID, limit int) ([]models.ScheduledTransferRun, error) {
+	var runs []models.ScheduledTransferRun
+	err := r.db.Where("scheduled_transfer_id = ?", scheduleID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&runs).Error
+	return runs, err
+}
+
+// HasSucceeded reports whether the occurrence due at scheduledFor was
+// already paid.
+func (r *scheduledTransferRepository) HasSucceeded(tx *gorm.DB, scheduleID uuid.UUID, scheduledFor time.Time) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	var count int64
+	err := tx.Model(&models.ScheduledTransferRun{}).
+		Where("scheduled_transfer_id = ? AND scheduled_for = ? AND status = ?", scheduleID, scheduledFor, models.ScheduledTransferRunSuccess).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SessionRepository interface {
+	Create(tx *gorm.DB, session *models.Session) error
+	FindByID(id uuid.UUID) (*models.Session, error)
+	Revoke(tx *gorm.DB, id uuid.UUID, reason string) error
+	RevokeAllForUser(tx *gorm.DB, userID uuid.UUID, reason string) error
+	CreateRefreshToken(tx *gorm.DB, token *models.RefreshToken) error
+	FindRefreshTokenForUpdate(tx *gorm.DB, tokenHash string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(tx *gorm.DB, id uuid.UUID) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(tx *gorm.DB, session *models.Session) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(id uuid.UUID) (*models.Session, error) {
+	var session models.Session
+	err := r.db.First(&session, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("session not found")
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// RevokeAllForUser revokes every active session of the user.
+func (r *sessionRepository) RevokeAllForUser(tx *gorm.DB, userID uuid.UUID, reason string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// Revoke marks the session revoked unless it already is.
+func (r *sessionRepository) Revoke(tx *gorm.DB, id uuid.UUID, reason string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+func (r *sessionRepository) CreateRefreshToken(tx *gorm.DB, token *models.RefreshToken) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(token).Error
+}
+
+// FindRefreshTokenForUpdate loads the token by hash and locks it so two
+// concurrent refreshes with the same token cannot both succeed.
+func (r *sessionRepository) FindRefreshTokenForUpdate(tx *gorm.DB, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("refresh token not found")
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *sessionRepository) MarkRefreshTokenUsed(tx *gorm.DB, id uuid.UUID) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.RefreshToken{}).Where("id = ?", id).Update("used_at", time.Now()).Error
+}
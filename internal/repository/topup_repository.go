@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TopUpRepository interface {
+	Create(tx *gorm.DB, topUp *models.TopUp) error
+	FindByID(id uuid.UUID) (*models.TopUp, error)
+	FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.TopUp, error)
+	Find(userID uuid.UUID, status *models.TopUpStatus, limit int) ([]models.TopUp, error)
+	ClaimExpired(tx *gorm.DB, now time.Time, limit int) ([]models.TopUp, error)
+	Update(tx *gorm.DB, topUp *models.TopUp) error
+}
+
+type topUpRepository struct {
+	db *gorm.DB
+}
+
+func NewTopUpRepository(db *gorm.DB) TopUpRepository {
+	return &topUpRepository{db: db}
+}
+
+func (r *topUpRepository) Create(tx *gorm.DB, topUp *models.TopUp) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(topUp).Error
+}
+
+func (r *topUpRepository) FindByID(id uuid.UUID) (*models.TopUp, error) {
+	var topUp models.TopUp
+	err := r.db.First(&topUp, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("top-up not found")
+		}
+		return nil, err
+	}
+	return &topUp, nil
+}
+
+// FindByIDForUpdate loads the top-up and locks its row until tx ends.
+func (r *topUpRepository) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.TopUp, error) {
+	var topUp models.TopUp
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&topUp, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("top-up not found")
+		}
+		return nil, err
+	}
+	return &topUp, nil
+}
+
+// Find returns the user's top-ups, newest first.
+func (r *topUpRepository) Find(userID uuid.UUID, status *models.TopUpStatus, limit int) ([]models.TopUp, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var topUps []models.TopUp
+	err := query.Order("created_at DESC").Limit(limit).Find(&topUps).Error
+	return topUps, err
+}
+
+// ClaimExpired locks up to limit pending top-ups whose payment window has
+// passed, oldest first. Rows locked by another expirer, or by a payment
+// result being recorded, are skipped.
+func (r *topUpRepository) ClaimExpired(tx *gorm.DB, now time.Time, limit int) ([]models.TopUp, error) {
+	var topUps []models.TopUp
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND expires_at <= ?", models.TopUpPending, now).
+		Order("expires_at ASC").
+		Limit(limit).
+		Find(&topUps).Error
+	return topUps, err
+}
+
+// Update saves the top-up's outcome.
+func (r *topUpRepository) Update(tx *gorm.DB, topUp *models.TopUp) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(topUp).Select("status", "failure_reason", "completed_at", "updated_at").Updates(topUp).Error
+}
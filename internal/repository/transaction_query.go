@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"ewallet/internal/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TransactionQuery selects a page of one user's transactions, or of all
+// transactions when UserID is uuid.Nil. Direction and CounterpartyID are
+// relative to the user and need a UserID. Nil filters are ignored. Results
+// are ordered newest first by (created_at, id).
+type TransactionQuery struct {
+	UserID         uuid.UUID
+	Type           *models.TransactionType
+	Status         *models.TransactionStatus
+	Direction      *models.TransactionDirection
+	From           *time.Time
+	To             *time.Time
+	MinAmount      *models.Money
+	MaxAmount      *models.Money
+	CounterpartyID *uuid.UUID
+	WalletID       *uuid.UUID
+	After          *TransactionCursor
+	Limit          int
+}
+
+// TransactionCursor is a keyset position in the transaction history.
+type TransactionCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// CursorAfter returns the cursor pointing just past the given transaction.
+func CursorAfter(t models.Transaction) TransactionCursor {
+	return TransactionCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+// Encode returns the opaque string form handed to clients.
+func (c TransactionCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeTransactionCursor parses a cursor produced by Encode.
+func DecodeTransactionCursor(s string) (*TransactionCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &TransactionCursor{CreatedAt: createdAt, ID: id}, nil
+}
@@ -1,15 +1,24 @@
 package repository
 
 import (
+	"errors"
 	"ewallet/internal/models"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type TransactionRepository interface {
 	Create(tx *gorm.DB, transaction *models.Transaction) error
-	FindByUserID(userID uuid.UUID, limit int) ([]models.Transaction, error)
+	FindByID(id uuid.UUID) (*models.Transaction, error)
+	FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Transaction, error)
+	SumReversed(tx *gorm.DB, transactionID uuid.UUID) (models.Money, models.Money, error)
+	Find(query TransactionQuery) ([]models.Transaction, error)
+	SumUsage(tx *gorm.DB, userID uuid.UUID, txType models.TransactionType, since time.Time) (models.Money, error)
+	UpdateStatus(tx *gorm.DB, transaction *models.Transaction) error
 }
 
 type transactionRepository struct {
@@ -27,15 +36,133 @@ func (r *transactionRepository) Create(tx *gorm.DB, transaction *models.Transact
 	return tx.Create(transaction).Error
 }
 
-func (r *transactionRepository) FindByUserID(userID uuid.UUID, limit int) ([]models.Transaction, error) {
+// FindByID loads a transaction together with its sender and receiver. A
+// missing transaction is reported as a wrapped gorm.ErrRecordNotFound.
+func (r *transactionRepository) FindByID(id uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := r.db.Preload("Sender").Preload("Receiver").First(&transaction, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("transaction not found: %w", err)
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// FindByIDForUpdate loads the transaction and locks its row until tx ends.
+func (r *transactionRepository) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&transaction, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("transaction not found")
+		}
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// SumReversed totals the successful reversals and refunds of a transaction:
+// the amount taken back from its receiver, and the amount returned to its
+// sender, which differ when the transfer converted currencies.
+func (r *transactionRepository) SumReversed(tx *gorm.DB, transactionID uuid.UUID) (models.Money, models.Money, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	var taken, returned models.Money
+	err := tx.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0), COALESCE(SUM(COALESCE(converted_amount, amount)), 0)").
+		Where("reversal_of = ? AND status = ?", transactionID, models.TransactionStatusSuccess).
+		Row().Scan(&taken, &returned)
+	return taken, returned, err
+}
+
+// Find returns the transactions matching the query, newest first.
+func (r *transactionRepository) Find(q TransactionQuery) ([]models.Transaction, error) {
 	var transactions []models.Transaction
-	query := r.db.Where("sender_id = ? OR receiver_id = ?", userID, userID).
-		Order("created_at DESC")
+	query := r.db
+	if q.UserID != uuid.Nil {
+		query = query.Where("(sender_id = ? OR receiver_id = ?)", q.UserID, q.UserID)
+	}
 
-	if limit > 0 {
-		query = query.Limit(limit)
+	if q.Type != nil {
+		query = query.Where("type = ?", *q.Type)
+	}
+	if q.Status != nil {
+		query = query.Where("status = ?", *q.Status)
+	}
+	if q.Direction != nil {
+		switch *q.Direction {
+		case models.TransactionDirectionOutgoing:
+			query = query.Where("sender_id = ?", q.UserID)
+		case models.TransactionDirectionIncoming:
+			query = query.Where("receiver_id = ? AND (sender_id IS NULL OR sender_id <> ?)", q.UserID, q.UserID)
+		}
+	}
+	if q.From != nil {
+		query = query.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("created_at < ?", *q.To)
+	}
+	if q.MinAmount != nil {
+		query = query.Where("amount >= ?", *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		query = query.Where("amount <= ?", *q.MaxAmount)
+	}
+	if q.CounterpartyID != nil {
+		// Failed transfers only name the user they were attempted to
+		query = query.Where("((sender_id = ? AND COALESCE(receiver_id, attempted_receiver_id) = ?) OR (receiver_id = ? AND sender_id = ?))",
+			q.UserID, *q.CounterpartyID, q.UserID, *q.CounterpartyID)
+	}
+	if q.WalletID != nil {
+		query = query.Where("(sender_wallet_id = ? OR receiver_wallet_id = ?)", *q.WalletID, *q.WalletID)
+	}
+	if q.After != nil {
+		query = query.Where("(created_at, id) < (?, ?)", q.After.CreatedAt, q.After.ID)
+	}
+
+	query = query.Order("created_at DESC").Order("id DESC")
+	if q.Limit > 0 {
+		query = query.Limit(q.Limit)
 	}
 
 	err := query.Find(&transactions).Error
 	return transactions, err
 }
+
+// SumUsage totals the user's successful and pending transactions of a type
+// since the given time, valued in the default currency: money received for
+// top-ups, money sent for transfers and withdrawals. Pending top-ups and
+// withdrawals count, so unfinished ones cannot be used to get around a
+// limit.
+func (r *transactionRepository) SumUsage(tx *gorm.DB, userID uuid.UUID, txType models.TransactionType, since time.Time) (models.Money, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	query := tx.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(base_amount), 0)").
+		Where("type = ? AND status IN ? AND created_at >= ?", txType, []models.TransactionStatus{models.TransactionStatusSuccess, models.TransactionStatusPending}, since)
+	if txType == models.TransactionTypeTopUp {
+		query = query.Where("receiver_id = ?", userID)
+	} else {
+		query = query.Where("sender_id = ?", userID)
+	}
+
+	var total models.Money
+	err := query.Row().Scan(&total)
+	return total, err
+}
+
+// UpdateStatus saves the status and failure reason of a pending transaction
+// that has completed.
+func (r *transactionRepository) UpdateStatus(tx *gorm.DB, transaction *models.Transaction) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(transaction).Select("status", "failure_reason", "updated_at").Updates(transaction).Error
+}
@@ -4,14 +4,23 @@ import (
 	"errors"
 	"ewallet/internal/models"
 	"github.com/google/uuid"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type UserRepository interface {
-	Create(user *models.User) error
+	Create(tx *gorm.DB, user *models.User) error
 	FindByEmail(email string) (*models.User, error)
 	FindByID(id uuid.UUID) (*models.User, error)
+	UpdatePIN(userID uuid.UUID, pinHash string) error
+	ClaimPINAttempt(userID uuid.UUID, now time.Time, maxAttempts int, lockUntil time.Time) (*models.User, bool, error)
+	ResetPINFailures(userID uuid.UUID) error
+	UpdateKYCTier(tx *gorm.DB, userID uuid.UUID, tier models.KYCTier) error
+	Search(term string, limit int) ([]models.User, error)
+	UpdateStatus(tx *gorm.DB, userID uuid.UUID, status models.UserStatus, reason *string) error
 }
 
 type userRepository struct {
@@ -22,8 +31,11 @@ func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(tx *gorm.DB, user *models.User) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(user).Error
 }
 
 func (r *userRepository) FindByEmail(email string) (*models.User, error) {
@@ -49,3 +61,73 @@ func (r *userRepository) FindByID(id uuid.UUID) (*models.User, error) {
 	}
 	return &user, nil
 }
+
+// UpdatePIN stores a new PIN hash and clears any failed attempts or lock.
+func (r *userRepository) UpdatePIN(userID uuid.UUID, pinHash string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"pin_hash":            pinHash,
+		"pin_failed_attempts": 0,
+		"pin_locked_until":    nil,
+	}).Error
+}
+
+// ClaimPINAttempt counts a PIN attempt as failed before the PIN is
+// compared, in one statement, so concurrent attempts cannot all pass the lock
+// check. The attempt that reaches maxAttempts locks the PIN until lockUntil
+// and starts the counter over. It returns false, and does not count the
+// attempt, if the PIN is locked at now.
+func (r *userRepository) ClaimPINAttempt(userID uuid.UUID, now time.Time, maxAttempts int, lockUntil time.Time) (*models.User, bool, error) {
+	var user models.User
+	result := r.db.Model(&user).Clauses(clause.Returning{}).
+		Where("id = ? AND (pin_locked_until IS NULL OR pin_locked_until <= ?)", userID, now).
+		Updates(map[string]interface{}{
+			"pin_locked_until":    gorm.Expr("CASE WHEN pin_failed_attempts + 1 >= ? THEN ?::timestamptz ELSE pin_locked_until END", maxAttempts, lockUntil),
+			"pin_failed_attempts": gorm.Expr("CASE WHEN pin_failed_attempts + 1 >= ? THEN 0 ELSE pin_failed_attempts + 1 END", maxAttempts),
+		})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	return &user, result.RowsAffected > 0, nil
+}
+
+// ResetPINFailures forgets the attempt claimed for a correct PIN, and the
+// lock it may have set.
+func (r *userRepository) ResetPINFailures(userID uuid.UUID) error {
+	return r.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"pin_failed_attempts": 0,
+		"pin_locked_until":    nil,
+	}).Error
+}
+
+func (r *userRepository) UpdateKYCTier(tx *gorm.DB, userID uuid.UUID, tier models.KYCTier) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).Update("kyc_tier", tier).Error
+}
+
+// Search finds users by exact ID or by a case-insensitive match on name or
+// email, newest first.
+func (r *userRepository) Search(term string, limit int) ([]models.User, error) {
+	query := r.db.Preload("Wallet").Order("created_at DESC").Limit(limit)
+	if id, err := uuid.Parse(term); err == nil {
+		query = query.Where("id = ?", id)
+	} else if term != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term) + "%"
+		query = query.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+	}
+
+	var users []models.User
+	err := query.Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) UpdateStatus(tx *gorm.DB, userID uuid.UUID, status models.UserStatus, reason *string) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"status":        status,
+		"status_reason": reason,
+	}).Error
+}
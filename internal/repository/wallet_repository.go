@@ -6,13 +6,29 @@ import (
 	"github.com/google/uuid"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type WalletRepository interface {
-	Create(wallet *models.Wallet) error
-	FindByUserID(userID uuid.UUID) (*models.Wallet, error)
-	UpdateBalance(walletID uuid.UUID, amount float64) error
-	UpdateBalanceWithLock(tx *gorm.DB, walletID uuid.UUID, amount float64) error
+	Create(tx *gorm.DB, wallet *models.Wallet) error
+	FindByID(tx *gorm.DB, id uuid.UUID) (*models.Wallet, error)
+	FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Wallet, error)
+	FindPrimary(userID uuid.UUID) (*models.Wallet, error)
+	FindOwned(userID, walletID uuid.UUID) (*models.Wallet, error)
+	FindByUserID(userID uuid.UUID) ([]models.Wallet, error)
+	FindFrozen(tx *gorm.DB, userID uuid.UUID) ([]models.Wallet, error)
+	LockStatuses(tx *gorm.DB, userID uuid.UUID) error
+	ShareStatuses(tx *gorm.DB, userID uuid.UUID) error
+	CountByUserID(userID uuid.UUID) (int64, error)
+	NameTaken(userID uuid.UUID, name string, exceptID *uuid.UUID) (bool, error)
+	SumBalances(tx *gorm.DB, userID uuid.UUID) (map[models.Currency]models.Money, error)
+	UpdateName(tx *gorm.DB, wallet *models.Wallet) error
+	SetPrimary(tx *gorm.DB, userID, walletID uuid.UUID) error
+	UpdateBalance(walletID uuid.UUID, amount models.Money) error
+	UpdateBalanceWithLock(tx *gorm.DB, walletID uuid.UUID, amount models.Money) error
+	UpdateStatus(tx *gorm.DB, wallet *models.Wallet) error
+	CreateStatusChange(tx *gorm.DB, change *models.WalletStatusChange) error
+	FindStatusChanges(walletID uuid.UUID) ([]models.WalletStatusChange, error)
 }
 
 type walletRepository struct {
@@ -23,13 +39,19 @@ func NewWalletRepository(db *gorm.DB) WalletRepository {
 	return &walletRepository{db: db}
 }
 
-func (r *walletRepository) Create(wallet *models.Wallet) error {
-	return r.db.Create(wallet).Error
+func (r *walletRepository) Create(tx *gorm.DB, wallet *models.Wallet) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(wallet).Error
 }
 
-func (r *walletRepository) FindByUserID(userID uuid.UUID) (*models.Wallet, error) {
+func (r *walletRepository) FindByID(tx *gorm.DB, id uuid.UUID) (*models.Wallet, error) {
+	if tx == nil {
+		tx = r.db
+	}
 	var wallet models.Wallet
-	err := r.db.Where("user_id = ?", userID).First(&wallet).Error
+	err := tx.First(&wallet, "id = ?", id).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("wallet not found")
@@ -39,12 +61,179 @@ func (r *walletRepository) FindByUserID(userID uuid.UUID) (*models.Wallet, error
 	return &wallet, nil
 }
 
-func (r *walletRepository) UpdateBalance(walletID uuid.UUID, amount float64) error {
+// FindByIDForUpdate loads the wallet and locks its row until tx ends.
+func (r *walletRepository) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&wallet, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// FindPrimary returns the user's primary wallet.
+func (r *walletRepository) FindPrimary(userID uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.Where("user_id = ? AND is_primary", userID).First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// FindOwned returns the wallet if it belongs to the user; wallets of other
+// users are reported as not found.
+func (r *walletRepository) FindOwned(userID, walletID uuid.UUID) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.Where("id = ? AND user_id = ?", walletID, userID).First(&wallet).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("wallet not found")
+		}
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// FindByUserID returns all of the user's wallets, the primary one first and
+// the others in the order they were opened.
+func (r *walletRepository) FindByUserID(userID uuid.UUID) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	err := r.db.Where("user_id = ?", userID).
+		Order("is_primary DESC").
+		Order("created_at ASC").
+		Find(&wallets).Error
+	return wallets, err
+}
+
+// FindFrozen returns the user's frozen wallets.
+func (r *walletRepository) FindFrozen(tx *gorm.DB, userID uuid.UUID) ([]models.Wallet, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	var wallets []models.Wallet
+	err := tx.Where("user_id = ? AND status IN ?", userID, []models.WalletStatus{models.WalletStatusFrozenDebit, models.WalletStatusFrozenAll}).
+		Find(&wallets).Error
+	return wallets, err
+}
+
+// LockStatuses takes a transaction-scoped advisory lock on the statuses of
+// all the user's wallets. Status changes hold it alone and money movements
+// share it through ShareStatuses, so a freeze waits for the movements that
+// already checked the user's wallets to commit, and the movements after it
+// see the freeze.
+func (r *walletRepository) LockStatuses(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", "wallet_status:"+userID.String()).Error
+}
+
+// ShareStatuses takes the lock of LockStatuses in shared mode, so money
+// movements of the same user do not wait on each other.
+func (r *walletRepository) ShareStatuses(tx *gorm.DB, userID uuid.UUID) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock_shared(hashtextextended(?, 0))", "wallet_status:"+userID.String()).Error
+}
+
+func (r *walletRepository) CountByUserID(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Wallet{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// NameTaken reports whether another of the user's wallets, other than
+// exceptID, already has the name. Names are compared case-insensitively.
+func (r *walletRepository) NameTaken(userID uuid.UUID, name string, exceptID *uuid.UUID) (bool, error) {
+	query := r.db.Model(&models.Wallet{}).Where("user_id = ? AND LOWER(name) = LOWER(?)", userID, name)
+	if exceptID != nil {
+		query = query.Where("id <> ?", *exceptID)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+// SumBalances returns the total balance of the user's wallets per currency.
+func (r *walletRepository) SumBalances(tx *gorm.DB, userID uuid.UUID) (map[models.Currency]models.Money, error) {
+	if tx == nil {
+		tx = r.db
+	}
+
+	rows, err := tx.Model(&models.Wallet{}).
+		Select("currency, COALESCE(SUM(balance), 0)").
+		Where("user_id = ?", userID).
+		Group("currency").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[models.Currency]models.Money)
+	for rows.Next() {
+		var currency models.Currency
+		var total models.Money
+		if err := rows.Scan(&currency, &total); err != nil {
+			return nil, err
+		}
+		totals[currency] = total
+	}
+	return totals, rows.Err()
+}
+
+func (r *walletRepository) UpdateName(tx *gorm.DB, wallet *models.Wallet) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(wallet).Update("name", wallet.Name).Error
+}
+
+// SetPrimary makes the wallet the user's primary one. The previous primary
+// wallet is cleared first so the one-primary-per-user index holds.
+func (r *walletRepository) SetPrimary(tx *gorm.DB, userID, walletID uuid.UUID) error {
+	err := tx.Model(&models.Wallet{}).
+		Where("user_id = ? AND is_primary AND id <> ?", userID, walletID).
+		Update("is_primary", false).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.Wallet{}).
+		Where("id = ? AND user_id = ?", walletID, userID).
+		Update("is_primary", true).Error
+}
+
+func (r *walletRepository) UpdateBalance(walletID uuid.UUID, amount models.Money) error {
 	return r.db.Model(&models.Wallet{}).Where("id = ?", walletID).Update("balance", amount).Error
 }
 
-func (r *walletRepository) UpdateBalanceWithLock(tx *gorm.DB, walletID uuid.UUID, amount float64) error {
+func (r *walletRepository) UpdateBalanceWithLock(tx *gorm.DB, walletID uuid.UUID, amount models.Money) error {
 	return tx.Model(&models.Wallet{}).
 		Where("id = ?", walletID).
 		Update("balance", amount).Error
 }
+
+// UpdateStatus stores the wallet's status together with its reason and actor.
+func (r *walletRepository) UpdateStatus(tx *gorm.DB, wallet *models.Wallet) error {
+	return tx.Model(wallet).
+		Select("status", "status_reason", "status_changed_by", "status_changed_at").
+		Updates(wallet).Error
+}
+
+func (r *walletRepository) CreateStatusChange(tx *gorm.DB, change *models.WalletStatusChange) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(change).Error
+}
+
+// FindStatusChanges returns the wallet's status history, newest first.
+func (r *walletRepository) FindStatusChanges(walletID uuid.UUID) ([]models.WalletStatusChange, error) {
+	var changes []models.WalletStatusChange
+	err := r.db.Where("wallet_id = ?", walletID).Order("created_at DESC").Find(&changes).Error
+	return changes, err
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WebhookRepository interface {
+	CreateEndpoint(tx *gorm.DB, endpoint *models.WebhookEndpoint) error
+	FindEndpoint(userID, id uuid.UUID) (*models.WebhookEndpoint, error)
+	FindEndpointsByUserID(userID uuid.UUID) ([]models.WebhookEndpoint, error)
+	CountEndpoints(userID uuid.UUID) (int64, error)
+	DeleteEndpoint(tx *gorm.DB, id uuid.UUID) error
+	FindSubscribedEndpoints(userIDs []uuid.UUID, eventType string) ([]models.WebhookEndpoint, error)
+	CreateDeliveries(tx *gorm.DB, deliveries []models.WebhookDelivery) error
+	ClaimDueDeliveries(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(tx *gorm.DB, delivery *models.WebhookDelivery) error
+	UpdateLeasedDelivery(tx *gorm.DB, delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error)
+	FindDelivery(endpointID, id uuid.UUID) (*models.WebhookDelivery, error)
+	FindDeliveries(endpointID uuid.UUID, status *models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateEndpoint(tx *gorm.DB, endpoint *models.WebhookEndpoint) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Create(endpoint).Error
+}
+
+// FindEndpoint returns the user's endpoint; endpoints of other users are
+// reported as not found.
+func (r *webhookRepository) FindEndpoint(userID, id uuid.UUID) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&endpoint).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("webhook endpoint not found")
+		}
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+func (r *webhookRepository) FindEndpointsByUserID(userID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&endpoints).Error
+	return endpoints, err
+}
+
+func (r *webhookRepository) CountEndpoints(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.WebhookEndpoint{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// DeleteEndpoint removes the endpoint; its deliveries go with it.
+func (r *webhookRepository) DeleteEndpoint(tx *gorm.DB, id uuid.UUID) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Delete(&models.WebhookEndpoint{}, "id = ?", id).Error
+}
+
+// FindSubscribedEndpoints returns the endpoints of the given users that
+// subscribe to eventType.
+func (r *webhookRepository) FindSubscribedEndpoints(userIDs []uuid.UUID, eventType string) ([]models.WebhookEndpoint, error) {
+	subscription, err := json.Marshal([]string{eventType})
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []models.WebhookEndpoint
+	err = r.db.Where("user_id IN ? AND event_types @> ?::jsonb", userIDs, string(subscription)).
+		Find(&endpoints).Error
+	return endpoints, err
+}
+
+// CreateDeliveries inserts deliveries, skipping any event already queued
+// for the same endpoint, so relaying an event twice queues it once.
+func (r *webhookRepository) CreateDeliveries(tx *gorm.DB, deliveries []models.WebhookDelivery) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint_id"}, {Name: "event_id"}},
+		DoNothing: true,
+	}).Create(&deliveries).Error
+}
+
+// ClaimDueDeliveries leases up to limit pending deliveries that are due,
+// oldest first, with their endpoints, by moving their next attempt to
+// leaseUntil, and commits. Rows locked by another dispatcher are skipped; a
+// delivery whose dispatcher stops before recording the outcome is due again
+// once the lease runs out.
+func (r *webhookRepository) ClaimDueDeliveries(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var deliveries []models.WebhookDelivery
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Preload("Endpoint").
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+			deliveries[i].NextAttemptAt = leaseUntil
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Update("next_attempt_at", leaseUntil).Error
+	})
+	return deliveries, err
+}
+
+// UpdateDelivery stores the outcome of a replay.
+func (r *webhookRepository) UpdateDelivery(tx *gorm.DB, delivery *models.WebhookDelivery) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(delivery).
+		Select("status", "attempts", "last_status_code", "last_error", "next_attempt_at", "delivered_at", "updated_at").
+		Updates(delivery).Error
+}
+
+// UpdateLeasedDelivery stores the outcome of an attempt, unless the
+// delivery is no longer pending under the lease that ends at leaseUntil
+// because the lease ran out and another dispatcher took it over. It
+// reports whether the outcome was stored.
+func (r *webhookRepository) UpdateLeasedDelivery(tx *gorm.DB, delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	result := tx.Model(delivery).
+		Where("status = ? AND next_attempt_at = ?", models.WebhookDeliveryPending, leaseUntil).
+		Select("status", "attempts", "last_status_code", "last_error", "next_attempt_at", "delivered_at", "updated_at").
+		Updates(delivery)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *webhookRepository) FindDelivery(endpointID, id uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Where("id = ? AND endpoint_id = ?", id, endpointID).First(&delivery).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("webhook delivery not found")
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// FindDeliveries returns the endpoint's deliveries, newest first.
+func (r *webhookRepository) FindDeliveries(endpointID uuid.UUID, status *models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	query := r.db.Where("endpoint_id = ?", endpointID)
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
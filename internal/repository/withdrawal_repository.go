@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type WithdrawalRepository interface {
+	Create(tx *gorm.DB, withdrawal *models.Withdrawal) error
+	FindByID(id uuid.UUID) (*models.Withdrawal, error)
+	FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Withdrawal, error)
+	Find(userID uuid.UUID, status *models.WithdrawalStatus, limit int) ([]models.Withdrawal, error)
+	ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.Withdrawal, error)
+	Update(tx *gorm.DB, withdrawal *models.Withdrawal) error
+}
+
+type withdrawalRepository struct {
+	db *gorm.DB
+}
+
+func NewWithdrawalRepository(db *gorm.DB) WithdrawalRepository {
+	return &withdrawalRepository{db: db}
+}
+
+// withBankAccount preloads the destination, even if it has been removed
+// since.
+func withBankAccount(tx *gorm.DB) *gorm.DB {
+	return tx.Preload("BankAccount", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	})
+}
+
+func (r *withdrawalRepository) Create(tx *gorm.DB, withdrawal *models.Withdrawal) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Omit(clause.Associations).Create(withdrawal).Error
+}
+
+// FindByID loads a withdrawal together with its bank account.
+func (r *withdrawalRepository) FindByID(id uuid.UUID) (*models.Withdrawal, error) {
+	var withdrawal models.Withdrawal
+	err := withBankAccount(r.db).First(&withdrawal, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("withdrawal not found")
+		}
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// FindByIDForUpdate loads the withdrawal with its bank account and locks
+// its row until tx ends.
+func (r *withdrawalRepository) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Withdrawal, error) {
+	var withdrawal models.Withdrawal
+	err := withBankAccount(tx).Clauses(clause.Locking{Strength: "UPDATE"}).First(&withdrawal, "id = ?", id).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("withdrawal not found")
+		}
+		return nil, err
+	}
+	return &withdrawal, nil
+}
+
+// Find returns the user's withdrawals, newest first.
+func (r *withdrawalRepository) Find(userID uuid.UUID, status *models.WithdrawalStatus, limit int) ([]models.Withdrawal, error) {
+	query := withBankAccount(r.db).Where("user_id = ?", userID)
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+
+	var withdrawals []models.Withdrawal
+	err := query.Order("created_at DESC").Limit(limit).Find(&withdrawals).Error
+	return withdrawals, err
+}
+
+// ClaimDue leases up to limit pending withdrawals that are due for
+// submission, and unknown ones due to be looked up, oldest first, with
+// their bank accounts, by moving their next attempt to leaseUntil, and
+// commits. Rows locked by another processor, or by a result being
+// recorded, are skipped; a withdrawal whose processor stops before
+// recording the outcome is due again once the lease runs out.
+func (r *withdrawalRepository) ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.Withdrawal, error) {
+	if tx == nil {
+		tx = r.db
+	}
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var withdrawals []models.Withdrawal
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		err := withBankAccount(tx).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status IN ? AND next_attempt_at <= ?", []models.WithdrawalStatus{models.WithdrawalPending, models.WithdrawalUnknown}, now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&withdrawals).Error
+		if err != nil || len(withdrawals) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(withdrawals))
+		for i := range withdrawals {
+			ids[i] = withdrawals[i].ID
+			withdrawals[i].NextAttemptAt = leaseUntil
+		}
+		return tx.Model(&models.Withdrawal{}).Where("id IN ?", ids).Update("next_attempt_at", leaseUntil).Error
+	})
+	return withdrawals, err
+}
+
+// Update saves the withdrawal's progress.
+func (r *withdrawalRepository) Update(tx *gorm.DB, withdrawal *models.Withdrawal) error {
+	if tx == nil {
+		tx = r.db
+	}
+	return tx.Model(withdrawal).
+		Select("status", "provider", "provider_reference", "failure_reason", "last_error", "attempts", "next_attempt_at", "submitted_at", "completed_at", "updated_at").
+		Updates(withdrawal).Error
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultUserSearchLimit = 20
+	MaxUserSearchLimit     = 100
+)
+
+// AdminService backs the back-office API. Access control is done per route
+// by role; the service itself trusts its callers.
+type AdminService interface {
+	SearchUsers(term string, limit int) ([]models.User, error)
+	GetUser(userID uuid.UUID) (*models.User, error)
+	InspectWallet(userID uuid.UUID, walletID *uuid.UUID) (*models.WalletInspection, error)
+	SearchTransactions(query repository.TransactionQuery) (*models.TransactionPage, error)
+	SetUserStatus(meta models.RequestMeta, actorID, userID uuid.UUID, status models.UserStatus, reason string) (*models.User, error)
+	SetWalletStatus(meta models.RequestMeta, actorID, userID uuid.UUID, walletID *uuid.UUID, status models.WalletStatus, reason string) (*models.Wallet, error)
+	WalletStatusHistory(userID uuid.UUID, walletID *uuid.UUID) ([]models.WalletStatusChange, error)
+	ReverseTransaction(meta models.RequestMeta, actorID, transactionID uuid.UUID, amount *models.Money, reason string) (*models.Transaction, error)
+}
+
+type adminService struct {
+	userRepo           repository.UserRepository
+	walletRepo         repository.WalletRepository
+	sessionRepo        repository.SessionRepository
+	ledgerService      LedgerService
+	walletService      WalletService
+	transactionService TransactionService
+	auditService       AuditService
+	db                 *gorm.DB
+}
+
+func NewAdminService(
+	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	sessionRepo repository.SessionRepository,
+	ledgerService LedgerService,
+	walletService WalletService,
+	transactionService TransactionService,
+	auditService AuditService,
+	db *gorm.DB,
+) AdminService {
+	return &adminService{
+		userRepo:           userRepo,
+		walletRepo:         walletRepo,
+		sessionRepo:        sessionRepo,
+		ledgerService:      ledgerService,
+		walletService:      walletService,
+		transactionService: transactionService,
+		auditService:       auditService,
+		db:                 db,
+	}
+}
+
+// SearchUsers matches the term against user ID, name and email. The limit
+// defaults to DefaultUserSearchLimit and is capped at MaxUserSearchLimit.
+func (s *adminService) SearchUsers(term string, limit int) ([]models.User, error) {
+	if limit <= 0 {
+		limit = DefaultUserSearchLimit
+	}
+	if limit > MaxUserSearchLimit {
+		limit = MaxUserSearchLimit
+	}
+
+	users, err := s.userRepo.Search(strings.TrimSpace(term), limit)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+	return users, nil
+}
+
+func (s *adminService) GetUser(userID uuid.UUID) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// InspectWallet compares the stored balance of one of the user's wallets,
+// the primary one when walletID is nil, with its ledger history and shows
+// the owner's other wallets, limits and usage.
+func (s *adminService) InspectWallet(userID uuid.UUID, walletID *uuid.UUID) (*models.WalletInspection, error) {
+	wallet, err := findWallet(s.walletRepo, userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	wallets, err := s.walletRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ledgerBalance, err := s.ledgerService.DerivedBalance(nil, wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := s.walletService.GetLimits(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WalletInspection{
+		Wallet:           *wallet,
+		Wallets:          wallets,
+		LedgerBalance:    ledgerBalance,
+		LedgerConsistent: ledgerBalance == wallet.Balance,
+		Limits:           *limits,
+	}, nil
+}
+
+// SearchTransactions pages through transactions of all users, or of one
+// user when the query has a UserID.
+func (s *adminService) SearchTransactions(query repository.TransactionQuery) (*models.TransactionPage, error) {
+	return s.transactionService.GetHistory(query)
+}
+
+// SetUserStatus suspends or reactivates an account. Suspending revokes all
+// of the user's sessions in the same database transaction, so their tokens
+// stop working immediately.
+func (s *adminService) SetUserStatus(meta models.RequestMeta, actorID, userID uuid.UUID, status models.UserStatus, reason string) (*models.User, error) {
+	if status != models.UserStatusActive && status != models.UserStatusSuspended {
+		return nil, ErrInvalidUserStatus
+	}
+	if actorID == userID {
+		return nil, ErrOwnStatusChange
+	}
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	var statusReason *string
+	if reason = strings.TrimSpace(reason); reason != "" {
+		statusReason = &reason
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.userRepo.UpdateStatus(tx, userID, status, statusReason); err != nil {
+			return err
+		}
+		if status == models.UserStatusSuspended {
+			if err := s.sessionRepo.RevokeAllForUser(tx, userID, models.SessionRevokedSuspended); err != nil {
+				return err
+			}
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			ActorID:    &actorID,
+			Action:     models.AuditActionUserStatusChanged,
+			TargetType: models.AuditTargetUser,
+			TargetID:   userID.String(),
+		}, map[string]interface{}{"from": user.Status, "to": status, "reason": reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(userID)
+}
+
+func (s *adminService) SetWalletStatus(meta models.RequestMeta, actorID, userID uuid.UUID, walletID *uuid.UUID, status models.WalletStatus, reason string) (*models.Wallet, error) {
+	return s.walletService.SetStatus(meta, actorID, userID, walletID, status, reason)
+}
+
+func (s *adminService) WalletStatusHistory(userID uuid.UUID, walletID *uuid.UUID) ([]models.WalletStatusChange, error) {
+	return s.walletService.StatusHistory(userID, walletID)
+}
+
+func (s *adminService) ReverseTransaction(meta models.RequestMeta, actorID, transactionID uuid.UUID, amount *models.Money, reason string) (*models.Transaction, error) {
+	return s.transactionService.Reverse(meta, actorID, transactionID, amount, reason)
+}
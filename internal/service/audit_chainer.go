@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/repository"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAuditChainPollInterval = time.Second
+	DefaultAuditChainBatchSize    = 500
+)
+
+// AuditChainer appends queued audit events to the hash chain. It is the
+// only writer of the chain: each batch is linked under the chain lock in a
+// short transaction of its own, so the lock is never held by transactions
+// that move money. Several chainers may run against the same database; the
+// lock makes them take turns.
+type AuditChainer struct {
+	auditRepo    repository.AuditRepository
+	db           *gorm.DB
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewAuditChainer(
+	auditRepo repository.AuditRepository,
+	db *gorm.DB,
+	pollInterval time.Duration,
+	batchSize int,
+) *AuditChainer {
+	if pollInterval <= 0 {
+		pollInterval = DefaultAuditChainPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultAuditChainBatchSize
+	}
+	return &AuditChainer{
+		auditRepo:    auditRepo,
+		db:           db,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run chains events until ctx is cancelled. A full batch is followed
+// immediately by the next one; otherwise the chainer waits for the poll
+// interval.
+func (c *AuditChainer) Run(ctx context.Context) {
+	for {
+		n, err := c.ChainBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("audit chainer: %v", err)
+		}
+		if n == c.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(c.pollInterval):
+		}
+	}
+}
+
+// ChainBatch links the oldest queued events to the end of the chain, in
+// queue order, and removes them from the queue. It returns the number of
+// events chained.
+func (c *AuditChainer) ChainBatch(ctx context.Context) (int, error) {
+	chained := 0
+	err := c.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := c.auditRepo.LockChain(tx); err != nil {
+			return err
+		}
+		queued, err := c.auditRepo.FindQueued(tx, c.batchSize)
+		if err != nil || len(queued) == 0 {
+			return err
+		}
+		last, err := c.auditRepo.FindLast(tx)
+		if err != nil {
+			return err
+		}
+
+		var sequence int64
+		var prevHash string
+		if last != nil {
+			sequence = last.Sequence
+			prevHash = last.Hash
+		}
+
+		positions := make([]int64, len(queued))
+		for i := range queued {
+			event := queued[i].Event()
+			sequence++
+			event.Sequence = sequence
+			event.PrevHash = prevHash
+			event.Hash = event.ComputeHash()
+			if err := c.auditRepo.Create(tx, &event); err != nil {
+				return err
+			}
+			prevHash = event.Hash
+			positions[i] = queued[i].Position
+		}
+		chained = len(queued)
+
+		return c.auditRepo.DeleteQueued(tx, positions)
+	})
+	return chained, err
+}
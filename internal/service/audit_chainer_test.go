@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryAuditRepo keeps the audit queue and chain in memory.
+type memoryAuditRepo struct {
+	repository.AuditRepository
+
+	queue    []models.QueuedAuditEvent
+	position int64
+	chain    []models.AuditEvent
+}
+
+func (r *memoryAuditRepo) Enqueue(tx *gorm.DB, event *models.QueuedAuditEvent) error {
+	r.position++
+	event.Position = r.position
+	r.queue = append(r.queue, *event)
+	return nil
+}
+
+func (r *memoryAuditRepo) FindQueued(tx *gorm.DB, limit int) ([]models.QueuedAuditEvent, error) {
+	if len(r.queue) < limit {
+		limit = len(r.queue)
+	}
+	return append([]models.QueuedAuditEvent(nil), r.queue[:limit]...), nil
+}
+
+func (r *memoryAuditRepo) DeleteQueued(tx *gorm.DB, positions []int64) error {
+	deleted := make(map[int64]bool, len(positions))
+	for _, p := range positions {
+		deleted[p] = true
+	}
+	kept := r.queue[:0]
+	for _, q := range r.queue {
+		if !deleted[q.Position] {
+			kept = append(kept, q)
+		}
+	}
+	r.queue = kept
+	return nil
+}
+
+func (r *memoryAuditRepo) LockChain(tx *gorm.DB) error { return nil }
+
+func (r *memoryAuditRepo) FindLast(tx *gorm.DB) (*models.AuditEvent, error) {
+	if len(r.chain) == 0 {
+		return nil, nil
+	}
+	last := r.chain[len(r.chain)-1]
+	return &last, nil
+}
+
+func (r *memoryAuditRepo) Create(tx *gorm.DB, event *models.AuditEvent) error {
+	r.chain = append(r.chain, *event)
+	return nil
+}
+
+func (r *memoryAuditRepo) FindAfterSequence(sequence int64, limit int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	for _, e := range r.chain {
+		if e.Sequence > sequence && len(events) < limit {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func TestAuditChainerLinksQueuedEventsInOrder(t *testing.T) {
+	repo := &memoryAuditRepo{}
+	db := testDB(t)
+	audit := NewAuditService(repo, db)
+	chainer := NewAuditChainer(repo, db, 0, 2)
+
+	actor := uuid.New()
+	meta := models.RequestMeta{ActorID: &actor, IP: "203.0.113.7", RequestID: "req-1"}
+	actions := []string{models.AuditActionLoginSucceeded, models.AuditActionWalletToppedUp, models.AuditActionTransferSucceeded}
+	for _, action := range actions {
+		err := audit.Record(nil, meta, &models.AuditEvent{Action: action}, map[string]interface{}{"action": action})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(repo.chain) != 0 {
+		t.Fatal("Record appended to the chain; only the chainer may")
+	}
+
+	// A full batch, then the rest
+	for _, want := range []int{2, 1, 0} {
+		n, err := chainer.ChainBatch(context.Background())
+		if err != nil || n != want {
+			t.Fatalf("ChainBatch() = %d, %v, want %d", n, err, want)
+		}
+	}
+	if len(repo.queue) != 0 || len(repo.chain) != len(actions) {
+		t.Fatalf("%d queued and %d chained, want all %d chained", len(repo.queue), len(repo.chain), len(actions))
+	}
+
+	prevHash := ""
+	for i, event := range repo.chain {
+		if event.Action != actions[i] || event.Sequence != int64(i+1) || event.PrevHash != prevHash {
+			t.Errorf("event %d = %s #%d after %q, want %s #%d after %q", i, event.Action, event.Sequence, event.PrevHash, actions[i], i+1, prevHash)
+		}
+		if event.ActorID == nil || *event.ActorID != actor || event.RequestID != "req-1" {
+			t.Errorf("event %d lost its request metadata", i)
+		}
+		prevHash = event.Hash
+	}
+
+	result, err := audit.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Checked != int64(len(actions)) {
+		t.Errorf("Verify() = %+v, want a valid chain of %d", result, len(actions))
+	}
+
+	repo.chain[1].Payload = `{"action":"forged"}`
+	if result, _ := audit.Verify(); result.Valid || result.BrokenAt == nil || *result.BrokenAt != 2 {
+		t.Errorf("Verify() after tampering = %+v, want broken at 2", result)
+	}
+}
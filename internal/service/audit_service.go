@@ -0,0 +1,138 @@
+package service
+
+import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultAuditLimit = 50
+	MaxAuditLimit     = 200
+
+	auditVerifyBatchSize = 1000
+)
+
+// AuditService appends events to the tamper-evident audit log and reads it
+// back. Events describing a change must be recorded in the same database
+// transaction as the change, so one is never stored without the other.
+type AuditService interface {
+	Record(tx *gorm.DB, meta models.RequestMeta, event *models.AuditEvent, payload interface{}) error
+	Search(query repository.AuditQuery) (*models.AuditPage, error)
+	Verify() (*models.AuditVerification, error)
+}
+
+type auditService struct {
+	auditRepo repository.AuditRepository
+	db        *gorm.DB
+}
+
+func NewAuditService(auditRepo repository.AuditRepository, db *gorm.DB) AuditService {
+	return &auditService{auditRepo: auditRepo, db: db}
+}
+
+// Record fills in the request metadata and queues the event. With a nil tx
+// the event is written in its own transaction. No chain lock is taken
+// here, so audited transactions do not wait on each other; AuditChainer
+// links queued events to the end of the hash chain shortly after they
+// commit.
+func (s *auditService) Record(tx *gorm.DB, meta models.RequestMeta, event *models.AuditEvent, payload interface{}) error {
+	if tx == nil {
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			return s.Record(tx, meta, event, payload)
+		})
+	}
+
+	encoded, err := models.NewAuditPayload(payload)
+	if err != nil {
+		return err
+	}
+
+	if event.ActorID == nil {
+		event.ActorID = meta.ActorID
+	}
+	event.ID = uuid.New()
+	event.IP = meta.IP
+	event.UserAgent = meta.UserAgent
+	event.RequestID = meta.RequestID
+	event.Payload = encoded
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	return s.auditRepo.Enqueue(tx, &models.QueuedAuditEvent{
+		ID:         event.ID,
+		ActorID:    event.ActorID,
+		Action:     event.Action,
+		TargetType: event.TargetType,
+		TargetID:   event.TargetID,
+		IP:         event.IP,
+		UserAgent:  event.UserAgent,
+		RequestID:  event.RequestID,
+		Payload:    event.Payload,
+		CreatedAt:  event.CreatedAt,
+	})
+}
+
+// Search returns one page of events, newest first. The limit defaults to
+// DefaultAuditLimit and is capped at MaxAuditLimit.
+func (s *auditService) Search(query repository.AuditQuery) (*models.AuditPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultAuditLimit
+	}
+	if query.Limit > MaxAuditLimit {
+		query.Limit = MaxAuditLimit
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	pageSize := query.Limit
+	query.Limit++
+
+	events, err := s.auditRepo.Find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AuditPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextCursor = strconv.FormatInt(page.Events[pageSize-1].Sequence, 10)
+	}
+	if page.Events == nil {
+		page.Events = []models.AuditEvent{}
+	}
+	return page, nil
+}
+
+// Verify walks the whole chain in order and recomputes every hash. It stops
+// at the first event whose sequence, link or hash does not match.
+func (s *auditService) Verify() (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true}
+
+	for {
+		events, err := s.auditRepo.FindAfterSequence(result.LastSequence, auditVerifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range events {
+			event := &events[i]
+			if event.Sequence != result.LastSequence+1 ||
+				event.PrevHash != result.LastHash ||
+				event.ComputeHash() != event.Hash {
+				result.Valid = false
+				result.BrokenAt = &event.Sequence
+				return result, nil
+			}
+			result.Checked++
+			result.LastSequence = event.Sequence
+			result.LastHash = event.Hash
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			return result, nil
+		}
+	}
+}
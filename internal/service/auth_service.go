@@ -5,37 +5,65 @@ import (
 	"ewallet/internal/models"
 	"ewallet/internal/repository"
 	"ewallet/pkg/utils"
+	"time"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// TokenPair is a short-lived access token and the refresh token that can
+// be exchanged for the next pair.
+type TokenPair struct {
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
 type AuthService interface {
-	Register(name, email, password string) (*models.User, error)
-	Login(email, password string) (string, *models.User, error)
+	Register(meta models.RequestMeta, name, email, password string) (*models.User, error)
+	Login(meta models.RequestMeta, email, password string) (*TokenPair, *models.User, error)
+	Refresh(meta models.RequestMeta, refreshToken string) (*TokenPair, error)
+	Logout(meta models.RequestMeta, sessionID uuid.UUID) error
 }
 
 type authService struct {
-	userRepo   repository.UserRepository
-	walletRepo repository.WalletRepository
-	jwtUtil    *utils.JWTUtil
-	db         *gorm.DB
+	userRepo      repository.UserRepository
+	walletRepo    repository.WalletRepository
+	sessionRepo   repository.SessionRepository
+	ledgerService LedgerService
+	auditService  AuditService
+	outboxService OutboxService
+	jwtUtil       *utils.JWTUtil
+	refreshExpiry time.Duration
+	db            *gorm.DB
 }
 
 func NewAuthService(
 	userRepo repository.UserRepository,
 	walletRepo repository.WalletRepository,
+	sessionRepo repository.SessionRepository,
+	ledgerService LedgerService,
+	auditService AuditService,
+	outboxService OutboxService,
 	jwtUtil *utils.JWTUtil,
+	refreshExpiry time.Duration,
 	db *gorm.DB,
 ) AuthService {
 	return &authService{
-		userRepo:   userRepo,
-		walletRepo: walletRepo,
-		jwtUtil:    jwtUtil,
-		db:         db,
+		userRepo:      userRepo,
+		walletRepo:    walletRepo,
+		sessionRepo:   sessionRepo,
+		ledgerService: ledgerService,
+		auditService:  auditService,
+		outboxService: outboxService,
+		jwtUtil:       jwtUtil,
+		refreshExpiry: refreshExpiry,
+		db:            db,
 	}
 }
 
-func (s *authService) Register(name, email, password string) (*models.User, error) {
+func (s *authService) Register(meta models.RequestMeta, name, email, password string) (*models.User, error) {
 	// Validate input
 	if name == "" || email == "" || password == "" {
 		return nil, errors.New("all fields are required")
@@ -63,21 +91,44 @@ func (s *authService) Register(name, email, password string) (*models.User, erro
 			return err
 		}
 
-		if err := s.userRepo.Create(&user); err != nil {
+		if err := s.userRepo.Create(tx, &user); err != nil {
 			return err
 		}
 
-		// Create wallet for user
+		// Create the user's primary wallet
 		wallet := models.Wallet{
-			UserID:  user.ID,
-			Balance: 0,
+			UserID:    user.ID,
+			Name:      models.DefaultWalletName,
+			IsPrimary: true,
+			Currency:  models.DefaultCurrency,
+			Balance:   0,
+		}
+
+		if err := s.walletRepo.Create(tx, &wallet); err != nil {
+			return err
 		}
 
-		if err := s.walletRepo.Create(&wallet); err != nil {
+		// Open the wallet's ledger account
+		if _, err := s.ledgerService.OpenWalletAccount(tx, &wallet); err != nil {
 			return err
 		}
 
-		return nil
+		err := s.auditService.Record(tx, meta, &models.AuditEvent{
+			ActorID:    &user.ID,
+			Action:     models.AuditActionUserRegistered,
+			TargetType: models.AuditTargetUser,
+			TargetID:   user.ID.String(),
+		}, map[string]interface{}{"email": user.Email, "wallet_id": wallet.ID})
+		if err != nil {
+			return err
+		}
+
+		return s.outboxService.Publish(tx, models.EventUserRegistered, models.AggregateUser, user.ID, models.UserRegisteredData{
+			UserID:   user.ID,
+			Email:    user.Email,
+			Name:     user.Name,
+			WalletID: wallet.ID,
+		})
 	})
 
 	if err != nil {
@@ -87,28 +138,184 @@ func (s *authService) Register(name, email, password string) (*models.User, erro
 	return &user, nil
 }
 
-func (s *authService) Login(email, password string) (string, *models.User, error) {
+func (s *authService) Login(meta models.RequestMeta, email, password string) (*TokenPair, *models.User, error) {
 	// Validate input
 	if email == "" || password == "" {
-		return "", nil, errors.New("email and password are required")
+		return nil, nil, errors.New("email and password are required")
 	}
 
 	// Find user by email
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
-		return "", nil, errors.New("invalid email or password")
+		s.recordLoginFailure(meta, nil, email, "unknown_email")
+		return nil, nil, errors.New("invalid email or password")
 	}
 
 	// Check password
 	if err := user.CheckPassword(password); err != nil {
-		return "", nil, errors.New("invalid email or password")
+		s.recordLoginFailure(meta, &user.ID, email, "wrong_password")
+		return nil, nil, errors.New("invalid email or password")
+	}
+
+	if user.IsSuspended() {
+		s.recordLoginFailure(meta, &user.ID, email, ErrAccountSuspended.Code)
+		return nil, nil, ErrAccountSuspended
+	}
+
+	// Start a new session and issue its first token pair
+	var tokens *TokenPair
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		session := &models.Session{UserID: user.ID}
+		if err := s.sessionRepo.Create(tx, session); err != nil {
+			return err
+		}
+
+		tokens, err = s.issueTokens(tx, user, session.ID)
+		if err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			ActorID:    &user.ID,
+			Action:     models.AuditActionLoginSucceeded,
+			TargetType: models.AuditTargetSession,
+			TargetID:   session.ID.String(),
+		}, nil)
+	})
+	if err != nil {
+		return nil, nil, errors.New("failed to generate token")
+	}
+
+	return tokens, user, nil
+}
+
+// Refresh rotates a refresh token: the presented token is spent and a new
+// pair is issued for the same session. Presenting a token that was already
+// spent means it leaked, so the whole session is revoked.
+func (s *authService) Refresh(meta models.RequestMeta, refreshToken string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, errors.New("refresh token is required")
+	}
+
+	var tokens *TokenPair
+	reused := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		token, err := s.sessionRepo.FindRefreshTokenForUpdate(tx, utils.HashToken(refreshToken))
+		if err != nil {
+			return errors.New("invalid refresh token")
+		}
+
+		if token.UsedAt != nil {
+			// Commit the revocation; the error is returned after the transaction
+			reused = true
+			if err := s.sessionRepo.Revoke(tx, token.SessionID, models.SessionRevokedTokenReuse); err != nil {
+				return err
+			}
+
+			event := &models.AuditEvent{
+				Action:     models.AuditActionRefreshTokenReused,
+				TargetType: models.AuditTargetSession,
+				TargetID:   token.SessionID.String(),
+			}
+			if session, err := s.sessionRepo.FindByID(token.SessionID); err == nil {
+				event.ActorID = &session.UserID
+			}
+			return s.auditService.Record(tx, meta, event, nil)
+		}
+
+		session, err := s.sessionRepo.FindByID(token.SessionID)
+		if err != nil {
+			return errors.New("invalid refresh token")
+		}
+		if session.IsRevoked() {
+			return errors.New("session has been revoked")
+		}
+		if time.Now().After(token.ExpiresAt) {
+			return errors.New("refresh token has expired")
+		}
+
+		user, err := s.userRepo.FindByID(session.UserID)
+		if err != nil {
+			return errors.New("invalid refresh token")
+		}
+		if user.IsSuspended() {
+			return ErrAccountSuspended
+		}
+
+		if err := s.sessionRepo.MarkRefreshTokenUsed(tx, token.ID); err != nil {
+			return err
+		}
+
+		tokens, err = s.issueTokens(tx, user, session.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, errors.New("refresh token was already used; session has been revoked")
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the session, invalidating its access and refresh tokens.
+func (s *authService) Logout(meta models.RequestMeta, sessionID uuid.UUID) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.sessionRepo.Revoke(tx, sessionID, models.SessionRevokedLogout); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionLogout,
+			TargetType: models.AuditTargetSession,
+			TargetID:   sessionID.String(),
+		}, nil)
+	})
+}
+
+// recordLoginFailure audits a rejected login. Nothing else is written for
+// a failed login, so the event gets its own transaction, and a failure to
+// record it does not change the response.
+func (s *authService) recordLoginFailure(meta models.RequestMeta, userID *uuid.UUID, email, reason string) {
+	event := &models.AuditEvent{
+		ActorID: userID,
+		Action:  models.AuditActionLoginFailed,
 	}
+	if userID != nil {
+		event.TargetType = models.AuditTargetUser
+		event.TargetID = userID.String()
+	}
+	_ = s.auditService.Record(nil, meta, event, map[string]string{"email": email, "reason": reason})
+}
 
-	// Generate JWT token
-	token, err := s.jwtUtil.GenerateToken(user.ID, user.Email)
+// issueTokens signs an access token and stores a new refresh token for the session.
+func (s *authService) issueTokens(tx *gorm.DB, user *models.User, sessionID uuid.UUID) (*TokenPair, error) {
+	now := time.Now()
+
+	accessToken, err := s.jwtUtil.GenerateToken(user.ID, user.Email, string(user.Role), sessionID)
 	if err != nil {
-		return "", nil, errors.New("failed to generate token")
+		return nil, err
+	}
+
+	refreshToken, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.RefreshToken{
+		SessionID: sessionID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: now.Add(s.refreshExpiry),
+	}
+	if err := s.sessionRepo.CreateRefreshToken(tx, record); err != nil {
+		return nil, err
 	}
 
-	return token, user, nil
+	return &TokenPair{
+		AccessToken:      accessToken,
+		AccessExpiresAt:  now.Add(s.jwtUtil.Expiry()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: record.ExpiresAt,
+	}, nil
 }
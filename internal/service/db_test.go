@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+var errNoDatabase = errors.New("no database in tests")
+
+// testDB returns a *gorm.DB that never reaches a database. Transactions
+// begin and commit without effect and every query fails, so services under
+// test must use in-memory repositories; the DB is only there for
+// Transaction and WithContext.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(noDatabase{}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type noDatabase struct{}
+
+func (noDatabase) Name() string { return "none" }
+
+func (noDatabase) Initialize(db *gorm.DB) error {
+	db.ConnPool = noConnPool{}
+	return nil
+}
+
+func (noDatabase) Migrator(*gorm.DB) gorm.Migrator { return nil }
+
+func (noDatabase) DataTypeOf(*schema.Field) string { return "" }
+
+func (noDatabase) DefaultValueOf(*schema.Field) clause.Expression { return clause.Expr{} }
+
+func (noDatabase) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	_ = writer.WriteByte('?')
+}
+
+func (noDatabase) QuoteTo(writer clause.Writer, s string) { _, _ = writer.WriteString(s) }
+
+func (noDatabase) Explain(sql string, vars ...interface{}) string { return sql }
+
+func (noDatabase) SavePoint(*gorm.DB, string) error { return nil }
+
+func (noDatabase) RollbackTo(*gorm.DB, string) error { return nil }
+
+type noConnPool struct{}
+
+func (noConnPool) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errNoDatabase
+}
+
+func (noConnPool) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errNoDatabase
+}
+
+func (noConnPool) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errNoDatabase
+}
+
+func (noConnPool) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func (noConnPool) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return &noTx{}, nil
+}
+
+type noTx struct {
+	noConnPool
+}
+
+func (*noTx) Commit() error { return nil }
+
+func (*noTx) Rollback() error { return nil }
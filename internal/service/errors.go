@@ -0,0 +1,124 @@
+package service
+
+import "fmt"
+
+// Error is a business rule violation with a machine-readable code. The code
+// is stored as the failure reason of failed transactions.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrorCode returns the machine-readable code, which API responses expose.
+func (e *Error) ErrorCode() string {
+	return e.Code
+}
+
+// Is matches errors with the same code, so errors.Is works on errors built
+// with withMessage.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func newError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// withMessage returns a copy of the error with a more specific message.
+func (e *Error) withMessage(format string, args ...interface{}) *Error {
+	return &Error{Code: e.Code, Message: fmt.Sprintf(format, args...)}
+}
+
+var (
+	ErrInvalidAmount                 = newError("invalid_amount", "amount must be greater than 0")
+	ErrSelfTransfer                  = newError("self_transfer", "cannot transfer to yourself")
+	ErrReceiverNotFound              = newError("receiver_not_found", "receiver not found")
+	ErrSenderWalletNotFound          = newError("sender_wallet_not_found", "sender wallet not found")
+	ErrReceiverWalletNotFound        = newError("receiver_wallet_not_found", "receiver wallet not found")
+	ErrInsufficientBalance           = newError("insufficient_balance", "insufficient balance")
+	ErrBalanceLimitExceeded          = newError("balance_limit_exceeded", "balance would exceed the maximum allowed")
+	ErrTransactionNotFound           = newError("transaction_not_found", "transaction not found")
+	ErrPINRequired                   = newError("pin_required", "transaction PIN is required")
+	ErrPINNotSet                     = newError("pin_not_set", "transaction PIN has not been set")
+	ErrPINAlreadySet                 = newError("pin_already_set", "transaction PIN is already set")
+	ErrInvalidPINFormat              = newError("invalid_pin_format", "PIN must be exactly 6 digits")
+	ErrInvalidPIN                    = newError("invalid_pin", "invalid transaction PIN")
+	ErrPINLocked                     = newError("pin_locked", "too many wrong PIN attempts; try again later")
+	ErrBelowMinimumAmount            = newError("below_minimum_amount", "amount is below the minimum")
+	ErrAboveMaximumAmount            = newError("above_maximum_amount", "amount exceeds the per-transaction maximum")
+	ErrDailyLimitExceeded            = newError("daily_limit_exceeded", "daily limit exceeded")
+	ErrMonthlyLimitExceeded          = newError("monthly_limit_exceeded", "monthly limit exceeded")
+	ErrMaxBalanceExceeded            = newError("max_balance_exceeded", "balance would exceed the maximum")
+	ErrKYCTierRequired               = newError("kyc_tier_required", "a higher KYC tier is required")
+	ErrInvalidKYCTier                = newError("invalid_kyc_tier", "requested tier must be verified or premium")
+	ErrInvalidKYCIdentity            = newError("invalid_kyc_identity", "invalid identity data")
+	ErrKYCDocumentMissing            = newError("kyc_document_missing", "a required document is missing")
+	ErrInvalidKYCDocument            = newError("invalid_kyc_document", "invalid document")
+	ErrKYCSubmissionPending          = newError("kyc_submission_pending", "a KYC submission is already waiting for review")
+	ErrKYCSubmissionNotFound         = newError("kyc_submission_not_found", "KYC submission not found")
+	ErrKYCSubmissionNotPending       = newError("kyc_submission_not_pending", "KYC submission has already been reviewed")
+	ErrKYCReasonRequired             = newError("kyc_reason_required", "a reason is required to reject a submission")
+	ErrWalletFrozen                  = newError("wallet_frozen", "wallet is frozen")
+	ErrWalletClosed                  = newError("wallet_closed", "wallet is closed")
+	ErrReceiverWalletUnavailable     = newError("receiver_wallet_unavailable", "receiver wallet cannot accept payments")
+	ErrInvalidWalletStatus           = newError("invalid_wallet_status", "status must be active, frozen_debit, frozen_all or closed")
+	ErrWalletStatusReason            = newError("wallet_status_reason_required", "a reason is required to change the wallet status")
+	ErrWalletNotEmpty                = newError("wallet_not_empty", "wallet must have a zero balance to be closed")
+	ErrAccountSuspended              = newError("account_suspended", "account is suspended")
+	ErrInvalidUserStatus             = newError("invalid_user_status", "status must be active or suspended")
+	ErrOwnStatusChange               = newError("own_status_change", "cannot change the status of your own account")
+	ErrUserNotFound                  = newError("user_not_found", "user not found")
+	ErrKYCDocumentNotFound           = newError("kyc_document_not_found", "KYC document not found")
+	ErrWebhookNotFound               = newError("webhook_not_found", "webhook endpoint not found")
+	ErrInvalidWebhookURL             = newError("invalid_webhook_url", "webhook URL must be an absolute http or https URL")
+	ErrInvalidEventType              = newError("invalid_event_type", "unknown event type")
+	ErrWebhookLimitReached           = newError("webhook_limit_reached", "too many webhook endpoints")
+	ErrWebhookDeliveryNotFound       = newError("webhook_delivery_not_found", "webhook delivery not found")
+	ErrWebhookDeliveryPending        = newError("webhook_delivery_pending", "webhook delivery is still being retried")
+	ErrTransactionNotReversible      = newError("transaction_not_reversible", "only successful transfers can be reversed")
+	ErrAlreadyReversed               = newError("already_reversed", "transaction has already been fully reversed")
+	ErrReversalAmountExceeded        = newError("reversal_amount_exceeded", "amount exceeds what is left to reverse")
+	ErrRefundWindowExpired           = newError("refund_window_expired", "the refund window for this transaction has passed")
+	ErrRefundNotAllowed              = newError("refund_not_allowed", "only the receiver of a transfer can refund it")
+	ErrReversalReasonRequired        = newError("reversal_reason_required", "a reason is required to reverse a transaction")
+	ErrPaymentRequestNotFound        = newError("payment_request_not_found", "payment request not found")
+	ErrPaymentRequestNotPending      = newError("payment_request_not_pending", "payment request has already been answered")
+	ErrPaymentRequestExpired         = newError("payment_request_expired", "payment request has expired")
+	ErrInvalidPaymentRequestExpiry   = newError("invalid_payment_request_expiry", "invalid payment request expiry")
+	ErrSelfPaymentRequest            = newError("self_payment_request", "cannot request money from yourself")
+	ErrPayerNotFound                 = newError("payer_not_found", "payer not found")
+	ErrPaymentRequestNoteTooLong     = newError("payment_request_note_too_long", "note is too long")
+	ErrScheduledTransferNotFound     = newError("scheduled_transfer_not_found", "scheduled transfer not found")
+	ErrScheduledTransferNotActive    = newError("scheduled_transfer_not_active", "scheduled transfer is no longer active")
+	ErrScheduledTransferLimitReached = newError("scheduled_transfer_limit_reached", "too many active scheduled transfers")
+	ErrInvalidSchedule               = newError("invalid_schedule", "invalid schedule")
+	ErrWalletNotFound                = newError("wallet_not_found", "wallet not found")
+	ErrWalletLimitReached            = newError("wallet_limit_reached", "too many wallets")
+	ErrInvalidWalletName             = newError("invalid_wallet_name", "invalid wallet name")
+	ErrWalletNameTaken               = newError("wallet_name_taken", "you already have a wallet with this name")
+	ErrSameWallet                    = newError("same_wallet", "source and destination wallet must differ")
+	ErrUnsupportedCurrency           = newError("unsupported_currency", "currency is not supported")
+	ErrSameCurrency                  = newError("same_currency", "currencies must differ")
+	ErrCurrencyMismatch              = newError("currency_mismatch", "wallets hold different currencies; an FX quote is required")
+	ErrFXQuoteNotFound               = newError("fx_quote_not_found", "FX quote not found")
+	ErrFXQuoteExpired                = newError("fx_quote_expired", "FX quote has expired")
+	ErrFXQuoteUsed                   = newError("fx_quote_used", "FX quote has already been used")
+	ErrFXQuoteMismatch               = newError("fx_quote_mismatch", "FX quote does not match the transfer")
+	ErrInvalidTransactionType        = newError("invalid_transaction_type", "fees apply to top-ups and transfers only")
+	ErrBankAccountNotFound           = newError("bank_account_not_found", "bank account not found")
+	ErrBankAccountLimitReached       = newError("bank_account_limit_reached", "too many bank accounts")
+	ErrBankAccountExists             = newError("bank_account_exists", "you already added this bank account")
+	ErrInvalidBankAccount            = newError("invalid_bank_account", "invalid bank account")
+	ErrWithdrawalNotFound            = newError("withdrawal_not_found", "withdrawal not found")
+	ErrWithdrawalCompleted           = newError("withdrawal_already_completed", "withdrawal has already completed with another outcome")
+	ErrInvalidPayoutResult           = newError("invalid_payout_result", "payout result does not match the withdrawal")
+	ErrTopUpNotFound                 = newError("topup_not_found", "top-up not found")
+	ErrTopUpCompleted                = newError("topup_already_completed", "top-up has already completed with another outcome")
+	ErrInvalidPaymentResult          = newError("invalid_payment_result", "payment result does not match the top-up")
+	ErrPaymentGatewayUnavailable     = newError("payment_gateway_unavailable", "payment gateway is unavailable; try again later")
+)
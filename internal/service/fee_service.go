@@ -0,0 +1,82 @@
+package service
+
+import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FeeService prices top-ups and transfers with the rules in fee_rules. The
+// payer pays the fee on top of the amount: the sender of a transfer, or the
+// funding source of a top-up. Transactions no rule applies to are free.
+type FeeService interface {
+	Quote(tx *gorm.DB, userID uuid.UUID, txType models.TransactionType, amount models.Money, currency models.Currency) (*models.FeeQuote, error)
+	Preview(userID uuid.UUID, txType models.TransactionType, walletID *uuid.UUID, amount models.Money) (*models.FeeQuote, error)
+}
+
+type feeService struct {
+	feeRepo    repository.FeeRepository
+	userRepo   repository.UserRepository
+	walletRepo repository.WalletRepository
+}
+
+func NewFeeService(
+	feeRepo repository.FeeRepository,
+	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+) FeeService {
+	return &feeService{
+		feeRepo:    feeRepo,
+		userRepo:   userRepo,
+		walletRepo: walletRepo,
+	}
+}
+
+// Quote returns the fee the user pays on a transaction of amount in the
+// currency, using the rule for the user's current KYC tier.
+func (s *feeService) Quote(tx *gorm.DB, userID uuid.UUID, txType models.TransactionType, amount models.Money, currency models.Currency) (*models.FeeQuote, error) {
+	if txType != models.TransactionTypeTopUp && txType != models.TransactionTypeTransfer {
+		return nil, ErrInvalidTransactionType
+	}
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := s.feeRepo.FindRule(tx, txType, user.KYCTier, currency, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := &models.FeeQuote{
+		Type:     txType,
+		Amount:   amount,
+		Currency: currency,
+	}
+	if rule != nil {
+		quote.Fee = rule.FeeFor(amount)
+		quote.RuleID = &rule.ID
+	}
+	quote.Total = amount + quote.Fee
+	if quote.Total > models.MaxMoney {
+		return nil, ErrInvalidAmount.withMessage("amount plus fee is too large")
+	}
+	return quote, nil
+}
+
+// Preview quotes the fee on a top-up of, or transfer from, one of the user's
+// wallets (the primary wallet if walletID is nil) without moving any money.
+// Rules can change before the transaction is made, so the fee charged is
+// the one in effect at that time.
+func (s *feeService) Preview(userID uuid.UUID, txType models.TransactionType, walletID *uuid.UUID, amount models.Money) (*models.FeeQuote, error) {
+	wallet, err := findWallet(s.walletRepo, userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+	return s.Quote(nil, userID, txType, amount, wallet.Currency)
+}
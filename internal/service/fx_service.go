@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"ewallet/internal/fx"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FXService quotes conversions between currencies. A quote locks the
+// provider's rate for the quote TTL; transfers and moves between wallets of
+// different currencies must redeem one.
+type FXService interface {
+	Currencies() []models.Currency
+	Supports(currency models.Currency) bool
+	Quote(userID uuid.UUID, from, to models.Currency, amount models.Money) (*models.FXQuote, error)
+	GetQuote(userID, id uuid.UUID) (*models.FXQuote, error)
+	Redeem(tx *gorm.DB, userID, quoteID, transactionID uuid.UUID, from, to models.Currency, amount models.Money) (*models.FXQuote, error)
+	ToBase(amount models.Money, currency models.Currency) (models.Money, error)
+}
+
+type fxService struct {
+	fxQuoteRepo repository.FXQuoteRepository
+	rates       fx.RateProvider
+	quoteTTL    time.Duration
+}
+
+func NewFXService(
+	fxQuoteRepo repository.FXQuoteRepository,
+	rates fx.RateProvider,
+	quoteTTL time.Duration,
+) FXService {
+	return &fxService{
+		fxQuoteRepo: fxQuoteRepo,
+		rates:       rates,
+		quoteTTL:    quoteTTL,
+	}
+}
+
+// Currencies returns the currencies wallets may be opened in.
+func (s *fxService) Currencies() []models.Currency {
+	return s.rates.Currencies()
+}
+
+func (s *fxService) Supports(currency models.Currency) bool {
+	for _, c := range s.rates.Currencies() {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// Quote converts amount at the provider's current rate and stores the
+// result, so it can be redeemed at that rate until the quote expires.
+func (s *fxService) Quote(userID uuid.UUID, from, to models.Currency, amount models.Money) (*models.FXQuote, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if !s.Supports(from) {
+		return nil, ErrUnsupportedCurrency.withMessage("currency %s is not supported", from)
+	}
+	if !s.Supports(to) {
+		return nil, ErrUnsupportedCurrency.withMessage("currency %s is not supported", to)
+	}
+	if from == to {
+		return nil, ErrSameCurrency
+	}
+
+	rate, err := s.rates.Rate(from, to)
+	if err != nil {
+		if errors.Is(err, fx.ErrUnsupportedPair) {
+			return nil, ErrUnsupportedCurrency.withMessage("no rate from %s to %s", from, to)
+		}
+		return nil, err
+	}
+	target, err := rate.Convert(amount)
+	if err != nil {
+		return nil, ErrInvalidAmount.withMessage("amount is too large to convert")
+	}
+	if target <= 0 {
+		return nil, ErrInvalidAmount.withMessage("amount is too small to convert")
+	}
+
+	now := time.Now()
+	quote := &models.FXQuote{
+		UserID:       userID,
+		FromCurrency: from,
+		ToCurrency:   to,
+		Rate:         rate,
+		SourceAmount: amount,
+		TargetAmount: target,
+		ExpiresAt:    now.Add(s.quoteTTL),
+	}
+	if err := s.fxQuoteRepo.Create(nil, quote); err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+// GetQuote returns one of the user's quotes; anyone else gets
+// ErrFXQuoteNotFound.
+func (s *fxService) GetQuote(userID, id uuid.UUID) (*models.FXQuote, error) {
+	quote, err := s.fxQuoteRepo.FindByID(nil, id)
+	if err != nil || quote.UserID != userID {
+		return nil, ErrFXQuoteNotFound
+	}
+	return quote, nil
+}
+
+// Redeem uses the user's quote for the transaction, which must convert
+// amount from one currency to the other. It must run inside the database
+// transaction that moves the money, so the quote stays unused if the
+// movement rolls back.
+func (s *fxService) Redeem(tx *gorm.DB, userID, quoteID, transactionID uuid.UUID, from, to models.Currency, amount models.Money) (*models.FXQuote, error) {
+	quote, err := s.fxQuoteRepo.FindByID(tx, quoteID)
+	if err != nil || quote.UserID != userID {
+		return nil, ErrFXQuoteNotFound
+	}
+	if quote.FromCurrency != from || quote.ToCurrency != to {
+		return nil, ErrFXQuoteMismatch.withMessage("quote converts %s to %s, not %s to %s", quote.FromCurrency, quote.ToCurrency, from, to)
+	}
+	if quote.SourceAmount != amount {
+		return nil, ErrFXQuoteMismatch.withMessage("quote is for %s %s, not %s", quote.SourceAmount, quote.FromCurrency, amount)
+	}
+	if quote.UsedAt != nil {
+		return nil, ErrFXQuoteUsed
+	}
+
+	now := time.Now()
+	if quote.IsExpired(now) {
+		return nil, ErrFXQuoteExpired
+	}
+
+	redeemed, err := s.fxQuoteRepo.Redeem(tx, quote.ID, userID, transactionID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !redeemed {
+		// Another transfer used it, or it expired, in the meantime
+		if quote.IsExpired(time.Now()) {
+			return nil, ErrFXQuoteExpired
+		}
+		return nil, ErrFXQuoteUsed
+	}
+
+	quote.UsedAt = &now
+	quote.TransactionID = &transactionID
+	return quote, nil
+}
+
+// ToBase values an amount in models.DefaultCurrency at the provider's
+// current rate, for limits.
+func (s *fxService) ToBase(amount models.Money, currency models.Currency) (models.Money, error) {
+	if currency == models.DefaultCurrency {
+		return amount, nil
+	}
+	rate, err := s.rates.Rate(currency, models.DefaultCurrency)
+	if err != nil {
+		return 0, err
+	}
+	return rate.Convert(amount)
+}
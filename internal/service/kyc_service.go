@@ -0,0 +1,342 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"ewallet/pkg/storage"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxKYCDocumentSize = 5 << 20
+	MaxKYCReviewQueue  = 100
+	MinKYCAge          = 17
+)
+
+// Document formats accepted for KYC uploads, by sniffed content type
+var kycDocumentExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+// Indonesian national identity number (NIK)
+var idNumberPattern = regexp.MustCompile(`^[0-9]{16}$`)
+
+// KYCSubmissionInput is the identity data of a KYC submission.
+type KYCSubmissionInput struct {
+	Tier        models.KYCTier
+	FullName    string
+	IDNumber    string
+	DateOfBirth time.Time
+	Address     string
+}
+
+// KYCUpload is one uploaded document of a KYC submission.
+type KYCUpload struct {
+	Type models.KYCDocumentType
+	File io.Reader
+}
+
+// KYCService handles identity verification and the tier-based gating of
+// wallet features.
+type KYCService interface {
+	Submit(meta models.RequestMeta, userID uuid.UUID, input KYCSubmissionInput, uploads []KYCUpload) (*models.KYCSubmission, error)
+	GetStatus(userID uuid.UUID) (*models.KYCStatusResponse, error)
+	RequireFeature(userID uuid.UUID, feature models.Feature) error
+	ListSubmissions(status *models.KYCStatus) ([]models.KYCSubmission, error)
+	GetSubmission(id uuid.UUID) (*models.KYCSubmission, error)
+	Approve(meta models.RequestMeta, reviewerID, id uuid.UUID, note string) (*models.KYCSubmission, error)
+	Reject(meta models.RequestMeta, reviewerID, id uuid.UUID, reason string) (*models.KYCSubmission, error)
+	DocumentPath(submissionID, documentID uuid.UUID) (*models.KYCDocument, string, error)
+}
+
+type kycService struct {
+	kycRepo      repository.KYCRepository
+	userRepo     repository.UserRepository
+	storage      *storage.LocalStorage
+	auditService AuditService
+	db           *gorm.DB
+}
+
+func NewKYCService(
+	kycRepo repository.KYCRepository,
+	userRepo repository.UserRepository,
+	documentStorage *storage.LocalStorage,
+	auditService AuditService,
+	db *gorm.DB,
+) KYCService {
+	return &kycService{
+		kycRepo:      kycRepo,
+		userRepo:     userRepo,
+		storage:      documentStorage,
+		auditService: auditService,
+		db:           db,
+	}
+}
+
+// Submit validates a request for a higher tier, stores its documents on disk
+// and queues it for review. A user can have one pending submission at a time.
+func (s *kycService) Submit(meta models.RequestMeta, userID uuid.UUID, input KYCSubmissionInput, uploads []KYCUpload) (*models.KYCSubmission, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !input.Tier.IsValid() || input.Tier == models.KYCTierBasic {
+		return nil, ErrInvalidKYCTier
+	}
+	if user.KYCTier.AtLeast(input.Tier) {
+		return nil, ErrInvalidKYCTier.withMessage("account is already on the %s tier", user.KYCTier)
+	}
+	if err := validateIdentity(input, time.Now()); err != nil {
+		return nil, err
+	}
+
+	byType := make(map[models.KYCDocumentType]io.Reader, len(uploads))
+	for _, u := range uploads {
+		byType[u.Type] = u.File
+	}
+	required := models.RequiredDocuments(input.Tier)
+	for _, docType := range required {
+		if byType[docType] == nil {
+			return nil, ErrKYCDocumentMissing.withMessage("%s document is required for the %s tier", docType, input.Tier)
+		}
+	}
+
+	pending, err := s.kycRepo.HasPending(nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pending {
+		return nil, ErrKYCSubmissionPending
+	}
+
+	submission := &models.KYCSubmission{
+		ID:            uuid.New(),
+		UserID:        userID,
+		RequestedTier: input.Tier,
+		FullName:      strings.TrimSpace(input.FullName),
+		IDNumber:      input.IDNumber,
+		DateOfBirth:   input.DateOfBirth,
+		Address:       strings.TrimSpace(input.Address),
+		Status:        models.KYCStatusPending,
+	}
+
+	for _, docType := range required {
+		doc, err := s.saveDocument(submission.ID, docType, byType[docType])
+		if err != nil {
+			s.removeDocuments(submission.Documents)
+			return nil, err
+		}
+		submission.Documents = append(submission.Documents, *doc)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.kycRepo.CreateSubmission(tx, submission); err != nil {
+			return err
+		}
+
+		// Identity data stays out of the audit log
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionKYCSubmitted,
+			TargetType: models.AuditTargetKYCSubmission,
+			TargetID:   submission.ID.String(),
+		}, map[string]interface{}{"requested_tier": submission.RequestedTier, "documents": len(submission.Documents)})
+	})
+	if err != nil {
+		// The partial unique index catches a pending submission created concurrently
+		s.removeDocuments(submission.Documents)
+		if pending, _ := s.kycRepo.HasPending(nil, userID); pending {
+			return nil, ErrKYCSubmissionPending
+		}
+		return nil, err
+	}
+
+	return submission, nil
+}
+
+func validateIdentity(input KYCSubmissionInput, now time.Time) error {
+	if strings.TrimSpace(input.FullName) == "" || strings.TrimSpace(input.Address) == "" {
+		return ErrInvalidKYCIdentity.withMessage("full name and address are required")
+	}
+	if !idNumberPattern.MatchString(input.IDNumber) {
+		return ErrInvalidKYCIdentity.withMessage("id number must be 16 digits")
+	}
+	if input.DateOfBirth.IsZero() || input.DateOfBirth.AddDate(MinKYCAge, 0, 0).After(now) {
+		return ErrInvalidKYCIdentity.withMessage("applicant must be at least %d years old", MinKYCAge)
+	}
+	return nil
+}
+
+// saveDocument checks the file format from its content, not its name, and
+// writes it under a directory of the submission.
+func (s *kycService) saveDocument(submissionID uuid.UUID, docType models.KYCDocumentType, file io.Reader) (*models.KYCDocument, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrInvalidKYCDocument.withMessage("%s document is empty or unreadable", docType)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	ext, ok := kycDocumentExtensions[contentType]
+	if !ok {
+		return nil, ErrInvalidKYCDocument.withMessage("%s document must be a JPEG, PNG or PDF file", docType)
+	}
+
+	path, size, err := s.storage.Save(submissionID.String(), ext, io.MultiReader(bytes.NewReader(head), file), MaxKYCDocumentSize)
+	if err != nil {
+		if errors.Is(err, storage.ErrTooLarge) {
+			return nil, ErrInvalidKYCDocument.withMessage("%s document must be at most %d MB", docType, MaxKYCDocumentSize>>20)
+		}
+		return nil, err
+	}
+
+	return &models.KYCDocument{
+		SubmissionID: submissionID,
+		Type:         docType,
+		FilePath:     path,
+		ContentType:  contentType,
+		SizeBytes:    size,
+	}, nil
+}
+
+func (s *kycService) removeDocuments(documents []models.KYCDocument) {
+	for _, doc := range documents {
+		if err := s.storage.Remove(doc.FilePath); err != nil {
+			log.Printf("failed to remove kyc document %s: %v", doc.FilePath, err)
+		}
+	}
+}
+
+func (s *kycService) GetStatus(userID uuid.UUID) (*models.KYCStatusResponse, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	latest, err := s.kycRepo.FindLatestByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.KYCStatusResponse{Tier: user.KYCTier, LatestSubmission: latest}, nil
+}
+
+// RequireFeature returns ErrKYCTierRequired if the user's tier is below the
+// tier the feature needs.
+func (s *kycService) RequireFeature(userID uuid.UUID, feature models.Feature) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	required := models.RequiredTier(feature)
+	if !user.KYCTier.AtLeast(required) {
+		return ErrKYCTierRequired.withMessage("%s requires the %s KYC tier", feature, required)
+	}
+	return nil
+}
+
+func (s *kycService) ListSubmissions(status *models.KYCStatus) ([]models.KYCSubmission, error) {
+	submissions, err := s.kycRepo.ListSubmissions(status, MaxKYCReviewQueue)
+	if err != nil {
+		return nil, err
+	}
+	if submissions == nil {
+		submissions = []models.KYCSubmission{}
+	}
+	return submissions, nil
+}
+
+func (s *kycService) GetSubmission(id uuid.UUID) (*models.KYCSubmission, error) {
+	submission, err := s.kycRepo.FindSubmissionByID(id)
+	if err != nil {
+		return nil, ErrKYCSubmissionNotFound
+	}
+	return submission, nil
+}
+
+// Approve accepts a pending submission and moves the user to the requested
+// tier in the same database transaction.
+func (s *kycService) Approve(meta models.RequestMeta, reviewerID, id uuid.UUID, note string) (*models.KYCSubmission, error) {
+	return s.review(meta, reviewerID, id, models.KYCStatusApproved, note)
+}
+
+// Reject declines a pending submission. The reason is shown to the user.
+func (s *kycService) Reject(meta models.RequestMeta, reviewerID, id uuid.UUID, reason string) (*models.KYCSubmission, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, ErrKYCReasonRequired
+	}
+	return s.review(meta, reviewerID, id, models.KYCStatusRejected, reason)
+}
+
+func (s *kycService) review(meta models.RequestMeta, reviewerID, id uuid.UUID, status models.KYCStatus, note string) (*models.KYCSubmission, error) {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		submission, err := s.kycRepo.FindSubmissionForUpdate(tx, id)
+		if err != nil {
+			return ErrKYCSubmissionNotFound
+		}
+		if submission.Status != models.KYCStatusPending {
+			return ErrKYCSubmissionNotPending
+		}
+
+		now := time.Now()
+		submission.Status = status
+		submission.ReviewedAt = &now
+		submission.ReviewedBy = &reviewerID
+		if note = strings.TrimSpace(note); note != "" {
+			submission.ReviewNote = &note
+		}
+		if err := s.kycRepo.UpdateSubmission(tx, submission); err != nil {
+			return err
+		}
+
+		action := models.AuditActionKYCRejected
+		if status == models.KYCStatusApproved {
+			action = models.AuditActionKYCApproved
+			if err := s.userRepo.UpdateKYCTier(tx, submission.UserID, submission.RequestedTier); err != nil {
+				return err
+			}
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			ActorID:    &reviewerID,
+			Action:     action,
+			TargetType: models.AuditTargetKYCSubmission,
+			TargetID:   submission.ID.String(),
+		}, map[string]interface{}{"user_id": submission.UserID, "requested_tier": submission.RequestedTier, "note": submission.ReviewNote})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSubmission(id)
+}
+
+// DocumentPath returns a document of the submission and where it is on disk.
+func (s *kycService) DocumentPath(submissionID, documentID uuid.UUID) (*models.KYCDocument, string, error) {
+	submission, err := s.GetSubmission(submissionID)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := range submission.Documents {
+		doc := &submission.Documents[i]
+		if doc.ID != documentID {
+			continue
+		}
+		path, err := s.storage.Path(doc.FilePath)
+		if err != nil {
+			return nil, "", err
+		}
+		return doc, path, nil
+	}
+	return nil, "", ErrKYCDocumentNotFound
+}
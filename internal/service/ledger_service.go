@@ -0,0 +1,277 @@
+package service
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type LedgerService interface {
+	OpenWalletAccount(tx *gorm.DB, wallet *models.Wallet) (*models.LedgerAccount, error)
+	WalletAccount(tx *gorm.DB, walletID uuid.UUID) (*models.LedgerAccount, error)
+	SystemAccount(tx *gorm.DB, code string, currency models.Currency) (*models.LedgerAccount, error)
+	MovementPostings(tx *gorm.DB, fromWalletID, toWalletID uuid.UUID, amount, received models.Money) ([]models.Posting, error)
+	AddFee(tx *gorm.DB, postings []models.Posting, fee models.Money, currency models.Currency) ([]models.Posting, error)
+	Post(tx *gorm.DB, entry *models.JournalEntry) error
+	VerifyWallet(tx *gorm.DB, walletID uuid.UUID) error
+	DerivedBalance(tx *gorm.DB, walletID uuid.UUID) (models.Money, error)
+	WalletBalanceAfter(transactionID, walletID uuid.UUID) (*models.Money, error)
+}
+
+type ledgerService struct {
+	ledgerRepo repository.LedgerRepository
+	walletRepo repository.WalletRepository
+}
+
+func NewLedgerService(
+	ledgerRepo repository.LedgerRepository,
+	walletRepo repository.WalletRepository,
+) LedgerService {
+	return &ledgerService{
+		ledgerRepo: ledgerRepo,
+		walletRepo: walletRepo,
+	}
+}
+
+func (s *ledgerService) OpenWalletAccount(tx *gorm.DB, wallet *models.Wallet) (*models.LedgerAccount, error) {
+	account := &models.LedgerAccount{
+		Name:     "wallet " + wallet.ID.String(),
+		Type:     models.LedgerAccountTypeWallet,
+		Currency: wallet.Currency,
+		WalletID: &wallet.ID,
+	}
+	if err := s.ledgerRepo.CreateAccount(tx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (s *ledgerService) WalletAccount(tx *gorm.DB, walletID uuid.UUID) (*models.LedgerAccount, error) {
+	return s.ledgerRepo.FindAccountByWalletID(tx, walletID)
+}
+
+// SystemAccount returns the system account with the code in the currency,
+// opening it on first use.
+func (s *ledgerService) SystemAccount(tx *gorm.DB, code string, currency models.Currency) (*models.LedgerAccount, error) {
+	account, err := s.ledgerRepo.FindSystemAccount(tx, code, currency)
+	if err != nil || account != nil {
+		return account, err
+	}
+
+	err = s.ledgerRepo.CreateSystemAccount(tx, &models.LedgerAccount{
+		Code:     &code,
+		Name:     fmt.Sprintf("%s %s", code, currency),
+		Type:     models.LedgerAccountTypeSystem,
+		Currency: currency,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account, err = s.ledgerRepo.FindSystemAccount(tx, code, currency)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, errors.New("ledger account not found")
+	}
+	return account, nil
+}
+
+// MovementPostings returns the postings that take amount out of one wallet
+// and put received into another: the debit of the source wallet first, the
+// credit of the destination wallet second. Between wallets of different
+// currencies the money passes through the FX position accounts, so the
+// entry balances in both currencies.
+func (s *ledgerService) MovementPostings(tx *gorm.DB, fromWalletID, toWalletID uuid.UUID, amount, received models.Money) ([]models.Posting, error) {
+	from, err := s.ledgerRepo.FindAccountByWalletID(tx, fromWalletID)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.ledgerRepo.FindAccountByWalletID(tx, toWalletID)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := []models.Posting{
+		{AccountID: from.ID, Direction: models.PostingDirectionDebit, Amount: amount},
+		{AccountID: to.ID, Direction: models.PostingDirectionCredit, Amount: received},
+	}
+	if from.Currency == to.Currency {
+		return postings, nil
+	}
+
+	sold, err := s.SystemAccount(tx, models.LedgerAccountFXPosition, from.Currency)
+	if err != nil {
+		return nil, err
+	}
+	bought, err := s.SystemAccount(tx, models.LedgerAccountFXPosition, to.Currency)
+	if err != nil {
+		return nil, err
+	}
+	return append(postings,
+		models.Posting{AccountID: sold.ID, Direction: models.PostingDirectionCredit, Amount: amount},
+		models.Posting{AccountID: bought.ID, Direction: models.PostingDirectionDebit, Amount: received},
+	), nil
+}
+
+// AddFee charges fee on top of a movement: the first posting, the debit that
+// pays for the movement, grows by the fee, which is credited to the fee
+// revenue account of the currency. That must be the currency of the first
+// posting's account. The postings are returned unchanged when there is no
+// fee.
+func (s *ledgerService) AddFee(tx *gorm.DB, postings []models.Posting, fee models.Money, currency models.Currency) ([]models.Posting, error) {
+	if fee == 0 {
+		return postings, nil
+	}
+	if len(postings) == 0 || postings[0].Direction != models.PostingDirectionDebit {
+		return nil, errors.New("fee must be added to a debit")
+	}
+
+	revenue, err := s.SystemAccount(tx, models.LedgerAccountFeeRevenue, currency)
+	if err != nil {
+		return nil, err
+	}
+	postings[0].Amount += fee
+	return append(postings, models.Posting{AccountID: revenue.ID, Direction: models.PostingDirectionCredit, Amount: fee}), nil
+}
+
+// Post writes a journal entry that balances in every currency and applies
+// its wallet postings to the wallet balances. It must run inside the caller's database transaction; the
+// wallets involved are locked here if the caller has not done so already.
+// After posting, every touched wallet is checked against its ledger history.
+func (s *ledgerService) Post(tx *gorm.DB, entry *models.JournalEntry) error {
+	if len(entry.Postings) < 2 {
+		return errors.New("journal entry needs at least two postings")
+	}
+
+	accountIDs := make([]uuid.UUID, 0, len(entry.Postings))
+	for _, p := range entry.Postings {
+		accountIDs = append(accountIDs, p.AccountID)
+	}
+	accounts, err := s.ledgerRepo.FindAccountsByIDs(tx, accountIDs)
+	if err != nil {
+		return err
+	}
+	accountsByID := make(map[uuid.UUID]models.LedgerAccount, len(accounts))
+	for _, a := range accounts {
+		accountsByID[a.ID] = a
+	}
+	if err := validateEntry(entry, accountsByID); err != nil {
+		return err
+	}
+
+	var touchedWallets []uuid.UUID
+	for i := range entry.Postings {
+		posting := &entry.Postings[i]
+		account := accountsByID[posting.AccountID]
+		if account.Type != models.LedgerAccountTypeWallet {
+			continue
+		}
+
+		wallet, err := s.walletRepo.FindByIDForUpdate(tx, *account.WalletID)
+		if err != nil {
+			return err
+		}
+
+		newBalance := wallet.Balance + posting.CreditNormalAmount()
+		if newBalance < 0 {
+			return ErrInsufficientBalance
+		}
+		if newBalance > models.MaxMoney {
+			return ErrBalanceLimitExceeded
+		}
+		if err := s.walletRepo.UpdateBalanceWithLock(tx, wallet.ID, newBalance); err != nil {
+			return err
+		}
+
+		posting.BalanceAfter = &newBalance
+		touchedWallets = append(touchedWallets, wallet.ID)
+	}
+
+	if err := s.ledgerRepo.CreateEntry(tx, entry); err != nil {
+		return err
+	}
+
+	for _, walletID := range touchedWallets {
+		if err := s.VerifyWallet(tx, walletID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VerifyWallet checks that the stored wallet balance equals the balance
+// derived from the wallet's ledger postings.
+func (s *ledgerService) VerifyWallet(tx *gorm.DB, walletID uuid.UUID) error {
+	wallet, err := s.walletRepo.FindByID(tx, walletID)
+	if err != nil {
+		return err
+	}
+
+	derived, err := s.DerivedBalance(tx, walletID)
+	if err != nil {
+		return err
+	}
+
+	if derived != wallet.Balance {
+		return fmt.Errorf("ledger mismatch for wallet %s: balance %s, postings %s", walletID, wallet.Balance, derived)
+	}
+	return nil
+}
+
+// DerivedBalance returns the wallet balance computed from its ledger postings.
+func (s *ledgerService) DerivedBalance(tx *gorm.DB, walletID uuid.UUID) (models.Money, error) {
+	account, err := s.ledgerRepo.FindAccountByWalletID(tx, walletID)
+	if err != nil {
+		return 0, err
+	}
+	return s.ledgerRepo.SumCreditNormal(tx, account.ID)
+}
+
+// WalletBalanceAfter returns the wallet balance right after the transaction
+// was posted, or nil if the transaction never touched the wallet.
+func (s *ledgerService) WalletBalanceAfter(transactionID, walletID uuid.UUID) (*models.Money, error) {
+	posting, err := s.ledgerRepo.FindWalletPosting(transactionID, walletID)
+	if err != nil || posting == nil {
+		return nil, err
+	}
+	return posting.BalanceAfter, nil
+}
+
+// validateEntry checks that every posting is to a known account and that
+// debits equal credits in each currency.
+func validateEntry(entry *models.JournalEntry, accounts map[uuid.UUID]models.LedgerAccount) error {
+	debits := make(map[models.Currency]models.Money)
+	credits := make(map[models.Currency]models.Money)
+	for _, p := range entry.Postings {
+		account, ok := accounts[p.AccountID]
+		if !ok {
+			return errors.New("ledger account not found")
+		}
+		if p.Amount <= 0 {
+			return errors.New("posting amount must be greater than 0")
+		}
+		switch p.Direction {
+		case models.PostingDirectionDebit:
+			debits[account.Currency] += p.Amount
+		case models.PostingDirectionCredit:
+			credits[account.Currency] += p.Amount
+		default:
+			return fmt.Errorf("invalid posting direction %q", p.Direction)
+		}
+	}
+
+	for _, totals := range []map[models.Currency]models.Money{debits, credits} {
+		for currency := range totals {
+			if debits[currency] != credits[currency] {
+				return fmt.Errorf("journal entry is not balanced in %s: debits %s, credits %s", currency, debits[currency], credits[currency])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryLedgerRepo keeps ledger accounts and journal entries in memory.
+type memoryLedgerRepo struct {
+	repository.LedgerRepository
+
+	accounts map[uuid.UUID]models.LedgerAccount
+	entries  []models.JournalEntry
+}
+
+func newMemoryLedgerRepo() *memoryLedgerRepo {
+	return &memoryLedgerRepo{accounts: make(map[uuid.UUID]models.LedgerAccount)}
+}
+
+func (r *memoryLedgerRepo) CreateAccount(tx *gorm.DB, account *models.LedgerAccount) error {
+	if account.ID == uuid.Nil {
+		account.ID = uuid.New()
+	}
+	r.accounts[account.ID] = *account
+	return nil
+}
+
+func (r *memoryLedgerRepo) FindAccountsByIDs(tx *gorm.DB, ids []uuid.UUID) ([]models.LedgerAccount, error) {
+	var accounts []models.LedgerAccount
+	for _, id := range ids {
+		if account, ok := r.accounts[id]; ok {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, nil
+}
+
+func (r *memoryLedgerRepo) FindAccountByWalletID(tx *gorm.DB, walletID uuid.UUID) (*models.LedgerAccount, error) {
+	for _, account := range r.accounts {
+		if account.WalletID != nil && *account.WalletID == walletID {
+			return &account, nil
+		}
+	}
+	return nil, errors.New("ledger account not found")
+}
+
+func (r *memoryLedgerRepo) FindSystemAccount(tx *gorm.DB, code string, currency models.Currency) (*models.LedgerAccount, error) {
+	for _, account := range r.accounts {
+		if account.Code != nil && *account.Code == code && account.Currency == currency {
+			return &account, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryLedgerRepo) CreateSystemAccount(tx *gorm.DB, account *models.LedgerAccount) error {
+	return r.CreateAccount(tx, account)
+}
+
+func (r *memoryLedgerRepo) CreateEntry(tx *gorm.DB, entry *models.JournalEntry) error {
+	if entry.ID == uuid.Nil {
+		entry.ID = uuid.New()
+	}
+	stored := *entry
+	stored.Postings = append([]models.Posting(nil), entry.Postings...)
+	r.entries = append(r.entries, stored)
+	return nil
+}
+
+func (r *memoryLedgerRepo) SumCreditNormal(tx *gorm.DB, accountID uuid.UUID) (models.Money, error) {
+	var sum models.Money
+	for _, entry := range r.entries {
+		for i := range entry.Postings {
+			if entry.Postings[i].AccountID == accountID {
+				sum += entry.Postings[i].CreditNormalAmount()
+			}
+		}
+	}
+	return sum, nil
+}
+
+// memoryWalletRepo keeps wallets in memory.
+type memoryWalletRepo struct {
+	repository.WalletRepository
+
+	wallets map[uuid.UUID]*models.Wallet
+}
+
+func newMemoryWalletRepo() *memoryWalletRepo {
+	return &memoryWalletRepo{wallets: make(map[uuid.UUID]*models.Wallet)}
+}
+
+func (r *memoryWalletRepo) FindByID(tx *gorm.DB, id uuid.UUID) (*models.Wallet, error) {
+	wallet, ok := r.wallets[id]
+	if !ok {
+		return nil, errors.New("wallet not found")
+	}
+	copied := *wallet
+	return &copied, nil
+}
+
+func (r *memoryWalletRepo) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Wallet, error) {
+	return r.FindByID(tx, id)
+}
+
+func (r *memoryWalletRepo) UpdateBalanceWithLock(tx *gorm.DB, walletID uuid.UUID, amount models.Money) error {
+	r.wallets[walletID].Balance = amount
+	return nil
+}
+
+// testLedger is a ledger service over in-memory repositories.
+type testLedger struct {
+	LedgerService
+	ledgerRepo *memoryLedgerRepo
+	walletRepo *memoryWalletRepo
+}
+
+func newTestLedger() *testLedger {
+	ledgerRepo := newMemoryLedgerRepo()
+	walletRepo := newMemoryWalletRepo()
+	return &testLedger{
+		LedgerService: NewLedgerService(ledgerRepo, walletRepo),
+		ledgerRepo:    ledgerRepo,
+		walletRepo:    walletRepo,
+	}
+}
+
+// openWallet creates an empty wallet in the currency with its ledger account.
+func (l *testLedger) openWallet(t *testing.T, currency models.Currency) *models.Wallet {
+	t.Helper()
+	wallet := &models.Wallet{ID: uuid.New(), UserID: uuid.New(), Currency: currency, Status: models.WalletStatusActive}
+	l.walletRepo.wallets[wallet.ID] = wallet
+	if _, err := l.OpenWalletAccount(nil, wallet); err != nil {
+		t.Fatal(err)
+	}
+	return wallet
+}
+
+// fund credits amount to the wallet from the top-up clearing account.
+func (l *testLedger) fund(t *testing.T, wallet *models.Wallet, amount models.Money) {
+	t.Helper()
+	clearing, err := l.SystemAccount(nil, models.LedgerAccountTopUpClearing, wallet.Currency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, err := l.WalletAccount(nil, wallet.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = l.Post(nil, &models.JournalEntry{Description: "top-up", Postings: []models.Posting{
+		{AccountID: clearing.ID, Direction: models.PostingDirectionDebit, Amount: amount},
+		{AccountID: account.ID, Direction: models.PostingDirectionCredit, Amount: amount},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (l *testLedger) balance(id uuid.UUID) models.Money {
+	return l.walletRepo.wallets[id].Balance
+}
+
+func TestLedgerPostMovesMoneyAndFee(t *testing.T) {
+	ledger := newTestLedger()
+	sender := ledger.openWallet(t, models.DefaultCurrency)
+	receiver := ledger.openWallet(t, models.DefaultCurrency)
+	ledger.fund(t, sender, models.NewMoney(100))
+
+	postings, err := ledger.MovementPostings(nil, sender.ID, receiver.ID, models.NewMoney(30), models.NewMoney(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings, err = ledger.AddFee(nil, postings, models.NewMoney(2), models.DefaultCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &models.JournalEntry{Description: "transfer", Postings: postings}
+	if err := ledger.Post(nil, entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := ledger.balance(sender.ID), models.NewMoney(68); got != want {
+		t.Errorf("sender balance = %s, want %s", got, want)
+	}
+	if got, want := ledger.balance(receiver.ID), models.NewMoney(30); got != want {
+		t.Errorf("receiver balance = %s, want %s", got, want)
+	}
+	if after := entry.Postings[0].BalanceAfter; after == nil || *after != models.NewMoney(68) {
+		t.Errorf("sender balance_after = %v, want 68.00", after)
+	}
+	if after := entry.Postings[2].BalanceAfter; after != nil {
+		t.Errorf("fee revenue posting has balance_after %s, want none", after)
+	}
+
+	revenue, err := ledger.SystemAccount(nil, models.LedgerAccountFeeRevenue, models.DefaultCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, _ := ledger.ledgerRepo.SumCreditNormal(nil, revenue.ID); sum != models.NewMoney(2) {
+		t.Errorf("fee revenue = %s, want 2.00", sum)
+	}
+	for _, wallet := range []*models.Wallet{sender, receiver} {
+		if err := ledger.VerifyWallet(nil, wallet.ID); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLedgerPostRejectsInvalidEntries(t *testing.T) {
+	ledger := newTestLedger()
+	wallet := ledger.openWallet(t, models.DefaultCurrency)
+	account, _ := ledger.WalletAccount(nil, wallet.ID)
+	clearing, _ := ledger.SystemAccount(nil, models.LedgerAccountTopUpClearing, models.DefaultCurrency)
+	usdClearing, _ := ledger.SystemAccount(nil, models.LedgerAccountTopUpClearing, "USD")
+
+	debit := func(id uuid.UUID, amount models.Money) models.Posting {
+		return models.Posting{AccountID: id, Direction: models.PostingDirectionDebit, Amount: amount}
+	}
+	credit := func(id uuid.UUID, amount models.Money) models.Posting {
+		return models.Posting{AccountID: id, Direction: models.PostingDirectionCredit, Amount: amount}
+	}
+	tests := []struct {
+		name     string
+		postings []models.Posting
+		want     string
+	}{
+		{"single posting", []models.Posting{credit(account.ID, 100)}, "at least two postings"},
+		{"debits exceed credits", []models.Posting{debit(clearing.ID, 100), credit(account.ID, 99)}, "not balanced"},
+		{"balanced only across currencies", []models.Posting{debit(usdClearing.ID, 100), credit(account.ID, 100)}, "not balanced"},
+		{"zero amount", []models.Posting{debit(clearing.ID, 0), credit(account.ID, 0)}, "greater than 0"},
+		{"negative amount", []models.Posting{debit(clearing.ID, -5), credit(account.ID, -5)}, "greater than 0"},
+		{"unknown account", []models.Posting{debit(uuid.New(), 100), credit(account.ID, 100)}, "account not found"},
+		{"unknown direction", []models.Posting{debit(clearing.ID, 100), {AccountID: account.ID, Direction: "sideways", Amount: 100}}, "invalid posting direction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ledger.Post(nil, &models.JournalEntry{Postings: tt.postings})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Post() error = %v, want %q", err, tt.want)
+			}
+			if len(ledger.ledgerRepo.entries) != 0 || ledger.balance(wallet.ID) != 0 {
+				t.Fatalf("rejected entry was written")
+			}
+		})
+	}
+}
+
+func TestLedgerPostKeepsBalancesInRange(t *testing.T) {
+	ledger := newTestLedger()
+	sender := ledger.openWallet(t, models.DefaultCurrency)
+	receiver := ledger.openWallet(t, models.DefaultCurrency)
+	ledger.fund(t, sender, models.NewMoney(10))
+
+	postings, err := ledger.MovementPostings(nil, sender.ID, receiver.ID, models.NewMoney(10)+1, models.NewMoney(10)+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Post(nil, &models.JournalEntry{Postings: postings}); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("overdraft error = %v, want %v", err, ErrInsufficientBalance)
+	}
+
+	ledger.fund(t, receiver, models.MaxMoney)
+	postings, err = ledger.MovementPostings(nil, sender.ID, receiver.ID, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.Post(nil, &models.JournalEntry{Postings: postings}); !errors.Is(err, ErrBalanceLimitExceeded) {
+		t.Errorf("overflow error = %v, want %v", err, ErrBalanceLimitExceeded)
+	}
+}
+
+func TestLedgerMovementBetweenCurrenciesBalancesInEach(t *testing.T) {
+	ledger := newTestLedger()
+	idr := ledger.openWallet(t, models.DefaultCurrency)
+	usd := ledger.openWallet(t, "USD")
+	ledger.fund(t, idr, models.NewMoney(32500))
+
+	rate, err := models.ParseRate("0.00006153")
+	if err != nil {
+		t.Fatal(err)
+	}
+	amount := models.NewMoney(16250)
+	received, err := rate.Convert(amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	postings, err := ledger.MovementPostings(nil, idr.ID, usd.ID, amount, received)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(postings) != 4 {
+		t.Fatalf("got %d postings, want 4 through the FX position accounts", len(postings))
+	}
+	if err := ledger.Post(nil, &models.JournalEntry{Postings: postings}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ledger.balance(idr.ID); got != models.NewMoney(16250) {
+		t.Errorf("IDR balance = %s, want 16250.00", got)
+	}
+	if got := ledger.balance(usd.ID); got != received || received > models.NewMoney(1) {
+		t.Errorf("USD balance = %s, want %s, rounded toward zero", got, received)
+	}
+	for _, currency := range []models.Currency{models.DefaultCurrency, "USD"} {
+		position, err := ledger.SystemAccount(nil, models.LedgerAccountFXPosition, currency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, _ := ledger.ledgerRepo.SumCreditNormal(nil, position.ID)
+		if (currency == "USD" && sum != -received) || (currency != "USD" && sum != amount) {
+			t.Errorf("%s FX position = %s", currency, sum)
+		}
+	}
+}
+
+func TestLedgerVerifyWalletDetectsDrift(t *testing.T) {
+	ledger := newTestLedger()
+	wallet := ledger.openWallet(t, models.DefaultCurrency)
+	ledger.fund(t, wallet, models.NewMoney(50))
+
+	if err := ledger.VerifyWallet(nil, wallet.ID); err != nil {
+		t.Fatal(err)
+	}
+	ledger.walletRepo.wallets[wallet.ID].Balance += 1
+	if err := ledger.VerifyWallet(nil, wallet.ID); err == nil || !strings.Contains(err.Error(), "ledger mismatch") {
+		t.Fatalf("VerifyWallet() error = %v, want a ledger mismatch", err)
+	}
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LimitService enforces per-transaction, daily, monthly and balance limits.
+// The checks that depend on usage must run inside the database transaction
+// that moves the money, after the wallets have been locked. Limits and the
+// amounts checked against them are in models.DefaultCurrency.
+type LimitService interface {
+	EffectiveLimits(tx *gorm.DB, userID uuid.UUID) (*models.EffectiveLimits, error)
+	Usage(tx *gorm.DB, userID uuid.UUID) (*models.LimitUsage, error)
+	CheckTransaction(tx *gorm.DB, userID uuid.UUID, txType models.TransactionType, amount models.Money) error
+	CheckBalance(tx *gorm.DB, userID uuid.UUID, credit models.Money) error
+}
+
+type limitService struct {
+	limitRepo       repository.LimitRepository
+	transactionRepo repository.TransactionRepository
+	userRepo        repository.UserRepository
+	walletRepo      repository.WalletRepository
+	fxService       FXService
+}
+
+func NewLimitService(
+	limitRepo repository.LimitRepository,
+	transactionRepo repository.TransactionRepository,
+	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	fxService FXService,
+) LimitService {
+	return &limitService{
+		limitRepo:       limitRepo,
+		transactionRepo: transactionRepo,
+		userRepo:        userRepo,
+		walletRepo:      walletRepo,
+		fxService:       fxService,
+	}
+}
+
+// EffectiveLimits merges the global policy, the policy of the user's KYC tier
+// and the user's own overrides, in that order.
+func (s *limitService) EffectiveLimits(tx *gorm.DB, userID uuid.UUID) (*models.EffectiveLimits, error) {
+	limits := &models.EffectiveLimits{}
+
+	global, err := s.limitRepo.FindGlobal(tx)
+	if err != nil {
+		return nil, err
+	}
+	if global != nil {
+		global.ApplyTo(limits)
+	}
+
+	account, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	tier, err := s.limitRepo.FindByTier(tx, account.KYCTier)
+	if err != nil {
+		return nil, err
+	}
+	if tier != nil {
+		tier.ApplyTo(limits)
+	}
+
+	user, err := s.limitRepo.FindByUserID(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		user.ApplyTo(limits)
+	}
+
+	return limits, nil
+}
+
+func (s *limitService) Usage(tx *gorm.DB, userID uuid.UUID) (*models.LimitUsage, error) {
+	dayStart, monthStart := usagePeriods(time.Now())
+	usage := &models.LimitUsage{}
+
+	var err error
+	if usage.TopUpDaily, err = s.transactionRepo.SumUsage(tx, userID, models.TransactionTypeTopUp, dayStart); err != nil {
+		return nil, err
+	}
+	if usage.TopUpMonthly, err = s.transactionRepo.SumUsage(tx, userID, models.TransactionTypeTopUp, monthStart); err != nil {
+		return nil, err
+	}
+	if usage.TransferDaily, err = s.transactionRepo.SumUsage(tx, userID, models.TransactionTypeTransfer, dayStart); err != nil {
+		return nil, err
+	}
+	if usage.TransferMonthly, err = s.transactionRepo.SumUsage(tx, userID, models.TransactionTypeTransfer, monthStart); err != nil {
+		return nil, err
+	}
+	if usage.WithdrawalDaily, err = s.transactionRepo.SumUsage(tx, userID, models.TransactionTypeWithdrawal, dayStart); err != nil {
+		return nil, err
+	}
+	if usage.WithdrawalMonthly, err = s.transactionRepo.SumUsage(tx, userID, models.TransactionTypeWithdrawal, monthStart); err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// CheckTransaction validates the amount against the per-transaction limits
+// and the user's daily and monthly totals. It serializes concurrent checks
+// for the same user, so two debits cannot both fit under the same headroom.
+func (s *limitService) CheckTransaction(tx *gorm.DB, userID uuid.UUID, txType models.TransactionType, amount models.Money) error {
+	limits, err := s.EffectiveLimits(tx, userID)
+	if err != nil {
+		return err
+	}
+	l := limits.For(txType)
+
+	if l.Min != nil && amount < *l.Min {
+		return ErrBelowMinimumAmount.withMessage("amount is below the minimum of %s", *l.Min)
+	}
+	if l.Max != nil && amount > *l.Max {
+		return ErrAboveMaximumAmount.withMessage("amount exceeds the per-transaction maximum of %s", *l.Max)
+	}
+	if l.Daily == nil && l.Monthly == nil {
+		return nil
+	}
+
+	if err := s.limitRepo.LockUser(tx, userID); err != nil {
+		return err
+	}
+
+	dayStart, monthStart := usagePeriods(time.Now())
+	if l.Daily != nil {
+		used, err := s.transactionRepo.SumUsage(tx, userID, txType, dayStart)
+		if err != nil {
+			return err
+		}
+		if used+amount > *l.Daily {
+			return ErrDailyLimitExceeded.withMessage("daily %s limit of %s exceeded (used %s)", txType, *l.Daily, used)
+		}
+	}
+	if l.Monthly != nil {
+		used, err := s.transactionRepo.SumUsage(tx, userID, txType, monthStart)
+		if err != nil {
+			return err
+		}
+		if used+amount > *l.Monthly {
+			return ErrMonthlyLimitExceeded.withMessage("monthly %s limit of %s exceeded (used %s)", txType, *l.Monthly, used)
+		}
+	}
+	return nil
+}
+
+// CheckBalance validates the user's total balance across all wallets, after
+// credit is added to one of them, against the user's maximum balance.
+// Balances in other currencies are valued at the current rate. It
+// serializes concurrent checks for the same user, so two credits to
+// different wallets cannot both fit under the same headroom.
+func (s *limitService) CheckBalance(tx *gorm.DB, userID uuid.UUID, credit models.Money) error {
+	limits, err := s.EffectiveLimits(tx, userID)
+	if err != nil {
+		return err
+	}
+	if limits.MaxBalance == nil {
+		return nil
+	}
+
+	if err := s.limitRepo.LockUserBalance(tx, userID); err != nil {
+		return err
+	}
+	balances, err := s.walletRepo.SumBalances(tx, userID)
+	if err != nil {
+		return err
+	}
+	var total models.Money
+	for currency, balance := range balances {
+		value, err := s.fxService.ToBase(balance, currency)
+		if err != nil {
+			return err
+		}
+		total += value
+	}
+	if total+credit > *limits.MaxBalance {
+		return ErrMaxBalanceExceeded.withMessage("balance would exceed the maximum of %s", *limits.MaxBalance)
+	}
+	return nil
+}
+
+// usagePeriods returns the start of the current day and month in local time.
+func usagePeriods(now time.Time) (time.Time, time.Time) {
+	y, m, d := now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, now.Location()),
+		time.Date(y, m, 1, 0, 0, 0, 0, now.Location())
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"encoding/json"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NotificationService publishes real-time wallet notifications through
+// Postgres NOTIFY. Publish must be called with the transaction that makes
+// the change, so listeners only hear about committed changes.
+type NotificationService interface {
+	Publish(tx *gorm.DB, notification *models.WalletNotification) error
+}
+
+type notificationService struct {
+	notificationRepo repository.NotificationRepository
+}
+
+func NewNotificationService(notificationRepo repository.NotificationRepository) NotificationService {
+	return &notificationService{notificationRepo: notificationRepo}
+}
+
+func (s *notificationService) Publish(tx *gorm.DB, notification *models.WalletNotification) error {
+	if notification.OccurredAt.IsZero() {
+		notification.OccurredAt = time.Now()
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	return s.notificationRepo.Notify(tx, models.WalletNotificationChannel, string(payload))
+}
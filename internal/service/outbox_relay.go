@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/events"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultOutboxPollInterval = time.Second
+	DefaultOutboxBatchSize    = 100
+
+	outboxRetryBaseDelay = time.Second
+	outboxRetryMaxDelay  = 10 * time.Minute
+
+	// How long a claimed batch is left to one relay before other relays may
+	// claim its undelivered events again
+	outboxClaimLease = 5 * time.Minute
+)
+
+// OutboxRelay delivers outbox events to the configured sinks. An event is
+// marked delivered only after every sink accepted it; otherwise it is
+// retried with exponential backoff, so delivery is at-least-once. Several
+// relays may run against the same database: each leases its batch with
+// FOR UPDATE SKIP LOCKED and delivers it outside any database transaction.
+type OutboxRelay struct {
+	outboxRepo   repository.OutboxRepository
+	sinks        []events.Sink
+	db           *gorm.DB
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewOutboxRelay(
+	outboxRepo repository.OutboxRepository,
+	sinks []events.Sink,
+	db *gorm.DB,
+	pollInterval time.Duration,
+	batchSize int,
+) *OutboxRelay {
+	if pollInterval <= 0 {
+		pollInterval = DefaultOutboxPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultOutboxBatchSize
+	}
+	return &OutboxRelay{
+		outboxRepo:   outboxRepo,
+		sinks:        sinks,
+		db:           db,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run relays events until ctx is cancelled. A full batch is followed
+// immediately by the next one; otherwise the relay waits for the poll
+// interval.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	for {
+		n, err := r.RelayBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("outbox relay: %v", err)
+		}
+		if n == r.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// RelayBatch leases one batch of due events, hands each to every sink and
+// records each outcome as it comes. No rows stay locked while events are
+// delivered. It returns the number of events claimed.
+func (r *OutboxRelay) RelayBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := r.outboxRepo.ClaimDue(r.db.WithContext(ctx), now, now.Add(outboxClaimLease), r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		event := &due[i]
+		if err := r.deliver(ctx, event.Envelope()); err != nil {
+			delay := retryDelay(event.Attempts+1, outboxRetryBaseDelay, outboxRetryMaxDelay)
+			if err := r.outboxRepo.MarkFailed(r.db.WithContext(ctx), event.ID, err.Error(), time.Now().Add(delay)); err != nil {
+				return len(due), err
+			}
+			continue
+		}
+		if err := r.outboxRepo.MarkDelivered(r.db.WithContext(ctx), event.ID, time.Now()); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// deliver publishes the event to every sink and joins their errors.
+func (r *OutboxRelay) deliver(ctx context.Context, envelope models.EventEnvelope) error {
+	var failures []string
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, envelope); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+		}
+	}
+	if len(failures) > 0 {
+		return errors.New(strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// retryDelay is the exponential backoff after the given number of failed
+// attempts: base after the first, doubling every time, up to max.
+func retryDelay(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/events"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryOutboxRepo keeps events in memory, claiming and updating them the
+// way outboxRepository does.
+type memoryOutboxRepo struct {
+	repository.OutboxRepository
+
+	mu     sync.Mutex
+	events map[uuid.UUID]*models.OutboxEvent
+}
+
+func newMemoryOutboxRepo(events ...models.OutboxEvent) *memoryOutboxRepo {
+	r := &memoryOutboxRepo{events: make(map[uuid.UUID]*models.OutboxEvent)}
+	for i := range events {
+		e := events[i]
+		r.events[e.ID] = &e
+	}
+	return r
+}
+
+func (r *memoryOutboxRepo) ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []models.OutboxEvent
+	for _, e := range r.events {
+		if len(due) == limit {
+			break
+		}
+		if e.DeliveredAt == nil && !e.NextAttemptAt.After(now) {
+			e.NextAttemptAt = leaseUntil
+			due = append(due, *e)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryOutboxRepo) MarkDelivered(tx *gorm.DB, id uuid.UUID, deliveredAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e := r.events[id]; e.DeliveredAt == nil {
+		e.Attempts++
+		e.LastError = nil
+		e.DeliveredAt = &deliveredAt
+	}
+	return nil
+}
+
+func (r *memoryOutboxRepo) MarkFailed(tx *gorm.DB, id uuid.UUID, lastError string, nextAttemptAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e := r.events[id]; e.DeliveredAt == nil {
+		e.Attempts++
+		e.LastError = &lastError
+		e.NextAttemptAt = nextAttemptAt
+	}
+	return nil
+}
+
+// makeDue moves the event's next attempt into the past, as if its backoff
+// or lease had run out.
+func (r *memoryOutboxRepo) makeDue(id uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[id].NextAttemptAt = time.Now().Add(-time.Second)
+}
+
+func (r *memoryOutboxRepo) get(id uuid.UUID) models.OutboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.events[id]
+}
+
+// flakySink fails as many publishes as failures says, then accepts them,
+// and records every event it was handed.
+type flakySink struct {
+	failures  int
+	published []uuid.UUID
+}
+
+func (s *flakySink) Name() string {
+	return "flaky"
+}
+
+func (s *flakySink) Publish(ctx context.Context, event models.EventEnvelope) error {
+	s.published = append(s.published, event.ID)
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	return nil
+}
+
+func newOutboxEvent() models.OutboxEvent {
+	return models.OutboxEvent{
+		ID:            uuid.New(),
+		EventType:     models.EventUserRegistered,
+		AggregateType: models.AggregateUser,
+		AggregateID:   uuid.New(),
+		Payload:       []byte(`{}`),
+		NextAttemptAt: time.Now().Add(-time.Second),
+		CreatedAt:     time.Now(),
+	}
+}
+
+func TestOutboxRelayRetriesWithBackoffUntilDelivered(t *testing.T) {
+	event := newOutboxEvent()
+	repo := newMemoryOutboxRepo(event)
+	sink := &flakySink{failures: 2}
+	relay := NewOutboxRelay(repo, []events.Sink{sink}, testDB(t), time.Second, 10)
+
+	// Each failure backs off twice as long as the one before
+	for attempt, backoff := range []time.Duration{outboxRetryBaseDelay, 2 * outboxRetryBaseDelay} {
+		before := time.Now()
+		if n, err := relay.RelayBatch(context.Background()); err != nil || n != 1 {
+			t.Fatalf("attempt %d: RelayBatch() = %d, %v, want 1 claimed", attempt+1, n, err)
+		}
+		stored := repo.get(event.ID)
+		if stored.DeliveredAt != nil || stored.Attempts != attempt+1 || stored.LastError == nil {
+			t.Fatalf("attempt %d: event = %+v, want a recorded failure", attempt+1, stored)
+		}
+		if next := stored.NextAttemptAt.Sub(before); next < backoff || next > backoff+time.Second {
+			t.Errorf("attempt %d: next attempt in %s, want %s", attempt+1, next, backoff)
+		}
+
+		// Not due again until the backoff has passed
+		if n, err := relay.RelayBatch(context.Background()); err != nil || n != 0 {
+			t.Fatalf("attempt %d: RelayBatch() during backoff = %d, %v, want nothing claimed", attempt+1, n, err)
+		}
+		repo.makeDue(event.ID)
+	}
+
+	if n, err := relay.RelayBatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RelayBatch() = %d, %v, want 1 claimed", n, err)
+	}
+	stored := repo.get(event.ID)
+	if stored.DeliveredAt == nil || stored.Attempts != 3 || stored.LastError != nil {
+		t.Errorf("event = %+v, want delivered on the third attempt without an error", stored)
+	}
+	if len(sink.published) != 3 {
+		t.Errorf("sink was handed the event %d times, want 3", len(sink.published))
+	}
+
+	// A delivered event is never claimed again
+	repo.makeDue(event.ID)
+	if n, err := relay.RelayBatch(context.Background()); err != nil || n != 0 {
+		t.Errorf("RelayBatch() after delivery = %d, %v, want nothing claimed", n, err)
+	}
+}
+
+func TestOutboxRelayRedeliversAfterAnExpiredLease(t *testing.T) {
+	event := newOutboxEvent()
+	repo := newMemoryOutboxRepo(event)
+	sink := &flakySink{}
+	relay := NewOutboxRelay(repo, []events.Sink{sink}, testDB(t), time.Second, 10)
+
+	// Another relay claims the event and stops before recording an outcome
+	now := time.Now()
+	if claimed, err := repo.ClaimDue(nil, now, now.Add(outboxClaimLease), 10); err != nil || len(claimed) != 1 {
+		t.Fatalf("ClaimDue() = %d, %v, want 1 claimed", len(claimed), err)
+	}
+
+	// The lease keeps the event from everyone else
+	if n, err := relay.RelayBatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("RelayBatch() under lease = %d, %v, want nothing claimed", n, err)
+	}
+	if len(sink.published) != 0 {
+		t.Fatalf("sink was handed %d events under lease, want none", len(sink.published))
+	}
+
+	repo.makeDue(event.ID)
+	if n, err := relay.RelayBatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RelayBatch() after the lease = %d, %v, want 1 claimed", n, err)
+	}
+	if stored := repo.get(event.ID); stored.DeliveredAt == nil || stored.Attempts != 1 {
+		t.Errorf("event = %+v, want delivered", stored)
+	}
+	if len(sink.published) != 1 || sink.published[0] != event.ID {
+		t.Errorf("sink was handed %v, want the event once", sink.published)
+	}
+}
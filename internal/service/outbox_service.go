@@ -0,0 +1,44 @@
+package service
+
+import (
+	"encoding/json"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutboxService publishes domain events through the transactional outbox.
+// Publish must be called with the transaction that makes the change, so
+// the event is committed or rolled back together with it.
+type OutboxService interface {
+	Publish(tx *gorm.DB, eventType, aggregateType string, aggregateID uuid.UUID, data interface{}) error
+}
+
+type outboxService struct {
+	outboxRepo repository.OutboxRepository
+}
+
+func NewOutboxService(outboxRepo repository.OutboxRepository) OutboxService {
+	return &outboxService{outboxRepo: outboxRepo}
+}
+
+func (s *outboxService) Publish(tx *gorm.DB, eventType, aggregateType string, aggregateID uuid.UUID, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.outboxRepo.Create(tx, &models.OutboxEvent{
+		ID:            uuid.New(),
+		EventType:     eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       payload,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	})
+}
@@ -0,0 +1,298 @@
+package service
+
+import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPaymentRequestExpiry = 72 * time.Hour
+	MaxPaymentRequestExpiry     = 30 * 24 * time.Hour
+	MaxPaymentRequestNoteLength = 255
+	DefaultPaymentRequestLimit  = 50
+	MaxPaymentRequestLimit      = 100
+)
+
+// PaymentRequestService lets a user ask another user for money. The payer
+// can accept, which runs a normal transfer to the requester, or decline; the
+// requester can cancel. Pending requests expire at their expiry time.
+type PaymentRequestService interface {
+	Create(meta models.RequestMeta, requesterID, payerID uuid.UUID, amount models.Money, note string, expiresAt *time.Time) (*models.PaymentRequest, error)
+	List(userID uuid.UUID, role *models.PaymentRequestRole, status *models.PaymentRequestStatus, limit int) ([]models.PaymentRequest, error)
+	Get(userID, id uuid.UUID) (*models.PaymentRequest, error)
+	Accept(meta models.RequestMeta, payerID, id uuid.UUID, walletID *uuid.UUID, pin string) (*models.PaymentRequest, error)
+	Decline(meta models.RequestMeta, payerID, id uuid.UUID) (*models.PaymentRequest, error)
+	Cancel(meta models.RequestMeta, requesterID, id uuid.UUID) (*models.PaymentRequest, error)
+}
+
+type paymentRequestService struct {
+	paymentRequestRepo  repository.PaymentRequestRepository
+	userRepo            repository.UserRepository
+	walletRepo          repository.WalletRepository
+	transactionService  TransactionService
+	auditService        AuditService
+	notificationService NotificationService
+	db                  *gorm.DB
+}
+
+func NewPaymentRequestService(
+	paymentRequestRepo repository.PaymentRequestRepository,
+	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	transactionService TransactionService,
+	auditService AuditService,
+	notificationService NotificationService,
+	db *gorm.DB,
+) PaymentRequestService {
+	return &paymentRequestService{
+		paymentRequestRepo:  paymentRequestRepo,
+		userRepo:            userRepo,
+		walletRepo:          walletRepo,
+		transactionService:  transactionService,
+		auditService:        auditService,
+		notificationService: notificationService,
+		db:                  db,
+	}
+}
+
+// Create asks the payer for the amount. Without an expiry the request
+// expires after DefaultPaymentRequestExpiry; it may not be set further out
+// than MaxPaymentRequestExpiry.
+func (s *paymentRequestService) Create(meta models.RequestMeta, requesterID, payerID uuid.UUID, amount models.Money, note string, expiresAt *time.Time) (*models.PaymentRequest, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if requesterID == payerID {
+		return nil, ErrSelfPaymentRequest
+	}
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxPaymentRequestNoteLength {
+		return nil, ErrPaymentRequestNoteTooLong.withMessage("note must be at most %d characters", MaxPaymentRequestNoteLength)
+	}
+
+	now := time.Now()
+	expiry := now.Add(DefaultPaymentRequestExpiry)
+	if expiresAt != nil {
+		if !expiresAt.After(now) {
+			return nil, ErrInvalidPaymentRequestExpiry.withMessage("expires_at must be in the future")
+		}
+		if expiresAt.After(now.Add(MaxPaymentRequestExpiry)) {
+			return nil, ErrInvalidPaymentRequestExpiry.withMessage("expires_at must be within %d days", int(MaxPaymentRequestExpiry.Hours()/24))
+		}
+		expiry = *expiresAt
+	}
+
+	if _, err := s.userRepo.FindByID(payerID); err != nil {
+		return nil, ErrPayerNotFound
+	}
+	wallet, err := s.walletRepo.FindPrimary(requesterID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	request := &models.PaymentRequest{
+		RequesterID: requesterID,
+		PayerID:     payerID,
+		Amount:      amount,
+		Currency:    wallet.Currency,
+		Note:        note,
+		Status:      models.PaymentRequestPending,
+		ExpiresAt:   expiry,
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.paymentRequestRepo.Create(tx, request); err != nil {
+			return err
+		}
+
+		err := s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionPaymentRequested,
+			TargetType: models.AuditTargetPaymentRequest,
+			TargetID:   request.ID.String(),
+		}, map[string]interface{}{"payer_id": payerID, "amount": amount, "currency": request.Currency})
+		if err != nil {
+			return err
+		}
+
+		return s.notificationService.Publish(tx, &models.WalletNotification{
+			UserID:           payerID,
+			Type:             models.NotificationPaymentRequested,
+			Amount:           &request.Amount,
+			Currency:         &request.Currency,
+			PaymentRequestID: &request.ID,
+			RequesterID:      &requesterID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// List returns the user's requests, newest first. The limit defaults to
+// DefaultPaymentRequestLimit and is capped at MaxPaymentRequestLimit.
+func (s *paymentRequestService) List(userID uuid.UUID, role *models.PaymentRequestRole, status *models.PaymentRequestStatus, limit int) ([]models.PaymentRequest, error) {
+	if limit <= 0 {
+		limit = DefaultPaymentRequestLimit
+	}
+	if limit > MaxPaymentRequestLimit {
+		limit = MaxPaymentRequestLimit
+	}
+
+	// Settle expiries first so the status filter sees them
+	if err := s.paymentRequestRepo.ExpireDue(nil, userID, time.Now()); err != nil {
+		return nil, err
+	}
+
+	requests, err := s.paymentRequestRepo.Find(userID, role, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []models.PaymentRequest{}
+	}
+	return requests, nil
+}
+
+// Get returns a request the user made or was asked to pay; anyone else gets
+// ErrPaymentRequestNotFound.
+func (s *paymentRequestService) Get(userID, id uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := s.paymentRequestRepo.FindByID(id)
+	if err != nil {
+		return nil, ErrPaymentRequestNotFound
+	}
+	if request.RequesterID != userID && request.PayerID != userID {
+		return nil, ErrPaymentRequestNotFound
+	}
+	if request.IsExpired(time.Now()) {
+		request.Status = models.PaymentRequestExpired
+	}
+	return request, nil
+}
+
+// Accept pays the request with a transfer from one of the payer's wallets,
+// the primary one when walletID is nil, to the requester. The transfer runs
+// through TransactionService, so it is checked and recorded like any other
+// transfer; the request is marked accepted in the same database transaction.
+// The transfer does not convert, so the payer's wallet and the requester's
+// primary wallet must hold the request's currency.
+func (s *paymentRequestService) Accept(meta models.RequestMeta, payerID, id uuid.UUID, walletID *uuid.UUID, pin string) (*models.PaymentRequest, error) {
+	request, err := s.findAnswerable(payerID, id, func(r *models.PaymentRequest) uuid.UUID { return r.PayerID })
+	if err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.transactionService.TransferWith(meta, payerID, walletID, request.RequesterID, request.Amount, pin, func(tx *gorm.DB, transaction *models.Transaction) error {
+		if transaction.Currency != request.Currency {
+			return ErrCurrencyMismatch.withMessage("payment request is in %s, not %s", request.Currency, transaction.Currency)
+		}
+		return s.resolve(tx, meta, request, models.PaymentRequestAccepted, &transaction.ID, models.AuditActionPaymentAccepted, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request.TransactionID = &transaction.ID
+	return request, nil
+}
+
+// Decline refuses the request; no money moves.
+func (s *paymentRequestService) Decline(meta models.RequestMeta, payerID, id uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := s.findAnswerable(payerID, id, func(r *models.PaymentRequest) uuid.UUID { return r.PayerID })
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.resolve(tx, meta, request, models.PaymentRequestDeclined, nil, models.AuditActionPaymentDeclined, models.NotificationPaymentRequestDeclined)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// Cancel withdraws the requester's own pending request.
+func (s *paymentRequestService) Cancel(meta models.RequestMeta, requesterID, id uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := s.findAnswerable(requesterID, id, func(r *models.PaymentRequest) uuid.UUID { return r.RequesterID })
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		return s.resolve(tx, meta, request, models.PaymentRequestCancelled, nil, models.AuditActionPaymentCancelled, models.NotificationPaymentRequestCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// findAnswerable loads a pending request that the user, as the party
+// returned by owner, may act on. A request found past its expiry is marked
+// expired.
+func (s *paymentRequestService) findAnswerable(userID, id uuid.UUID, owner func(*models.PaymentRequest) uuid.UUID) (*models.PaymentRequest, error) {
+	request, err := s.paymentRequestRepo.FindByID(id)
+	if err != nil || owner(request) != userID {
+		return nil, ErrPaymentRequestNotFound
+	}
+
+	now := time.Now()
+	if request.IsExpired(now) {
+		if err := s.paymentRequestRepo.ExpireDue(nil, userID, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrPaymentRequestExpired
+	}
+	if request.Status != models.PaymentRequestPending {
+		return nil, ErrPaymentRequestNotPending
+	}
+	return request, nil
+}
+
+// resolve moves the request to its final status inside tx, records the
+// audit event and, if notificationType is set, tells the other party.
+func (s *paymentRequestService) resolve(tx *gorm.DB, meta models.RequestMeta, request *models.PaymentRequest, status models.PaymentRequestStatus, transactionID *uuid.UUID, action, notificationType string) error {
+	now := time.Now()
+	ok, err := s.paymentRequestRepo.Resolve(tx, request.ID, status, transactionID, now)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// Answered by someone else, or expired, since it was loaded
+		return ErrPaymentRequestNotPending
+	}
+	request.Status = status
+	request.RespondedAt = &now
+
+	err = s.auditService.Record(tx, meta, &models.AuditEvent{
+		Action:     action,
+		TargetType: models.AuditTargetPaymentRequest,
+		TargetID:   request.ID.String(),
+	}, map[string]interface{}{"requester_id": request.RequesterID, "payer_id": request.PayerID, "amount": request.Amount, "transaction_id": transactionID})
+	if err != nil {
+		return err
+	}
+
+	if notificationType == "" {
+		return nil
+	}
+	recipient := request.RequesterID
+	if status == models.PaymentRequestCancelled {
+		recipient = request.PayerID
+	}
+	return s.notificationService.Publish(tx, &models.WalletNotification{
+		UserID:           recipient,
+		Type:             notificationType,
+		Amount:           &request.Amount,
+		PaymentRequestID: &request.ID,
+		RequesterID:      &request.RequesterID,
+	})
+}
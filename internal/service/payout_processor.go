@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/bank"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultPayoutPollInterval = 5 * time.Second
+	DefaultPayoutBatchSize    = 20
+	DefaultPayoutMaxAttempts  = 5
+
+	payoutRetryBaseDelay = 30 * time.Second
+	payoutRetryMaxDelay  = time.Hour
+
+	// How long a claimed batch is left to one processor before other
+	// processors may claim its withdrawals again
+	payoutClaimLease = 10 * time.Minute
+)
+
+// PayoutProcessor submits pending withdrawals to the payout provider. An
+// accepted withdrawal becomes processing until the provider reports its
+// result. A rejected one fails right away; one the provider could not take
+// is submitted again with exponential backoff. After maxAttempts the
+// provider may still have accepted one of the submissions, so the
+// withdrawal becomes unknown and keeps its hold; the processor then asks
+// the provider for it, with the same backoff, until the provider answers.
+// Failed withdrawals release their hold back into the wallet. Several
+// processors may run against the same database: each leases its batch with
+// FOR UPDATE SKIP LOCKED, calls the provider outside any database
+// transaction and records each outcome in a transaction of its own.
+type PayoutProcessor struct {
+	withdrawalRepo    repository.WithdrawalRepository
+	withdrawalService WithdrawalService
+	provider          bank.PayoutProvider
+	db                *gorm.DB
+	maxAttempts       int
+	pollInterval      time.Duration
+	batchSize         int
+}
+
+func NewPayoutProcessor(
+	withdrawalRepo repository.WithdrawalRepository,
+	withdrawalService WithdrawalService,
+	provider bank.PayoutProvider,
+	db *gorm.DB,
+	maxAttempts int,
+	pollInterval time.Duration,
+	batchSize int,
+) *PayoutProcessor {
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultPayoutMaxAttempts
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultPayoutPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultPayoutBatchSize
+	}
+	return &PayoutProcessor{
+		withdrawalRepo:    withdrawalRepo,
+		withdrawalService: withdrawalService,
+		provider:          provider,
+		db:                db,
+		maxAttempts:       maxAttempts,
+		pollInterval:      pollInterval,
+		batchSize:         batchSize,
+	}
+}
+
+// Run submits withdrawals until ctx is cancelled.
+func (p *PayoutProcessor) Run(ctx context.Context) {
+	for {
+		n, err := p.ProcessBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("payout processor: %v", err)
+		}
+		if n == p.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(p.pollInterval):
+		}
+	}
+}
+
+// ProcessBatch leases one batch of due withdrawals, submits each and
+// records the outcome. It returns the number of withdrawals claimed.
+func (p *PayoutProcessor) ProcessBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := p.withdrawalRepo.ClaimDue(p.db.WithContext(ctx), now, now.Add(payoutClaimLease), p.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		if err := p.process(ctx, &due[i]); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// process submits one claimed withdrawal, or looks up an unknown one, and
+// records the outcome.
+func (p *PayoutProcessor) process(ctx context.Context, claimed *models.Withdrawal) error {
+	if claimed.Status == models.WithdrawalUnknown {
+		return p.resolve(ctx, claimed)
+	}
+
+	reference, err := p.submit(ctx, claimed)
+
+	return p.record(ctx, claimed, func(tx *gorm.DB, withdrawal *models.Withdrawal) error {
+		now := time.Now()
+		withdrawal.Attempts++
+		withdrawal.UpdatedAt = now
+		meta := models.RequestMeta{RequestID: "payout:" + withdrawal.ID.String()}
+		switch {
+		case err == nil:
+			provider := p.provider.Name()
+			withdrawal.Status = models.WithdrawalProcessing
+			withdrawal.Provider = &provider
+			withdrawal.ProviderReference = &reference
+			withdrawal.SubmittedAt = &now
+			withdrawal.LastError = nil
+		case errors.Is(err, bank.ErrPayoutRejected):
+			return p.withdrawalService.Release(tx, meta, withdrawal, models.WithdrawalFailurePayoutRejected, err.Error())
+		case withdrawal.Attempts >= p.maxAttempts:
+			message := err.Error()
+			withdrawal.Status = models.WithdrawalUnknown
+			withdrawal.LastError = &message
+			withdrawal.NextAttemptAt = now
+		default:
+			message := err.Error()
+			withdrawal.LastError = &message
+			withdrawal.NextAttemptAt = now.Add(retryDelay(withdrawal.Attempts, payoutRetryBaseDelay, payoutRetryMaxDelay))
+		}
+
+		return p.withdrawalRepo.Update(tx, withdrawal)
+	})
+}
+
+// resolve asks the provider what became of an unknown withdrawal and records
+// the answer. A payout the provider never accepted is released; one it
+// cannot tell about yet is asked for again later.
+func (p *PayoutProcessor) resolve(ctx context.Context, claimed *models.Withdrawal) error {
+	result, err := p.provider.Lookup(ctx, claimed.ID)
+
+	return p.record(ctx, claimed, func(tx *gorm.DB, withdrawal *models.Withdrawal) error {
+		now := time.Now()
+		withdrawal.Attempts++
+		withdrawal.UpdatedAt = now
+		meta := models.RequestMeta{RequestID: "payout:" + withdrawal.ID.String()}
+		switch {
+		case errors.Is(err, bank.ErrPayoutNotFound):
+			lastError := ""
+			if withdrawal.LastError != nil {
+				lastError = *withdrawal.LastError
+			}
+			return p.withdrawalService.Release(tx, meta, withdrawal, models.WithdrawalFailurePayoutUnavailable, lastError)
+		case err != nil:
+			message := err.Error()
+			withdrawal.LastError = &message
+			withdrawal.NextAttemptAt = now.Add(retryDelay(withdrawal.Attempts, payoutRetryBaseDelay, payoutRetryMaxDelay))
+		case result.Status == bank.PayoutPending:
+			provider := p.provider.Name()
+			withdrawal.Status = models.WithdrawalProcessing
+			withdrawal.Provider = &provider
+			withdrawal.ProviderReference = &result.Reference
+			withdrawal.SubmittedAt = &now
+			withdrawal.LastError = nil
+		default:
+			provider := p.provider.Name()
+			withdrawal.Provider = &provider
+			return p.withdrawalService.ApplyResult(tx, meta, withdrawal, result)
+		}
+
+		return p.withdrawalRepo.Update(tx, withdrawal)
+	})
+}
+
+// record locks the claimed withdrawal again in a new transaction and
+// applies the outcome of the attempt to it, unless it moved on while the
+// provider was called: its result callback already arrived, or the lease
+// ran out and another processor claimed it.
+func (p *PayoutProcessor) record(ctx context.Context, claimed *models.Withdrawal, apply func(tx *gorm.DB, withdrawal *models.Withdrawal) error) error {
+	return p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		withdrawal, err := p.withdrawalRepo.FindByIDForUpdate(tx, claimed.ID)
+		if err != nil {
+			return err
+		}
+		if withdrawal.Status != claimed.Status || !withdrawal.NextAttemptAt.Equal(claimed.NextAttemptAt) {
+			return nil
+		}
+		return apply(tx, withdrawal)
+	})
+}
+
+// submit hands the withdrawal to the provider and returns its reference.
+func (p *PayoutProcessor) submit(ctx context.Context, withdrawal *models.Withdrawal) (string, error) {
+	if withdrawal.BankAccount == nil {
+		return "", errors.New("bank account not found")
+	}
+	return p.provider.Submit(ctx, bank.Payout{
+		ID:            withdrawal.ID,
+		Amount:        withdrawal.Amount,
+		Currency:      withdrawal.Currency,
+		BankCode:      withdrawal.BankAccount.BankCode,
+		AccountNumber: withdrawal.BankAccount.AccountNumber,
+		AccountName:   withdrawal.BankAccount.AccountName,
+	})
+}
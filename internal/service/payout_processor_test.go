@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/bank"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryWithdrawalRepo keeps withdrawals in memory, claiming them the way
+// withdrawalRepository does.
+type memoryWithdrawalRepo struct {
+	repository.WithdrawalRepository
+
+	mu          sync.Mutex
+	withdrawals map[uuid.UUID]*models.Withdrawal
+}
+
+func newMemoryWithdrawalRepo(withdrawals ...models.Withdrawal) *memoryWithdrawalRepo {
+	r := &memoryWithdrawalRepo{withdrawals: make(map[uuid.UUID]*models.Withdrawal)}
+	for i := range withdrawals {
+		w := withdrawals[i]
+		r.withdrawals[w.ID] = &w
+	}
+	return r
+}
+
+func (r *memoryWithdrawalRepo) ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.Withdrawal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var due []models.Withdrawal
+	for _, w := range r.withdrawals {
+		if len(due) == limit {
+			break
+		}
+		if (w.Status == models.WithdrawalPending || w.Status == models.WithdrawalUnknown) && !w.NextAttemptAt.After(now) {
+			w.NextAttemptAt = leaseUntil
+			due = append(due, *w)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryWithdrawalRepo) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Withdrawal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.withdrawals[id]
+	if !ok {
+		return nil, errors.New("withdrawal not found")
+	}
+	copied := *w
+	return &copied, nil
+}
+
+func (r *memoryWithdrawalRepo) Update(tx *gorm.DB, withdrawal *models.Withdrawal) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w := *withdrawal
+	r.withdrawals[w.ID] = &w
+	return nil
+}
+
+func (r *memoryWithdrawalRepo) get(id uuid.UUID) models.Withdrawal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.withdrawals[id]
+}
+
+// stubProvider answers submissions with reference, or err, after running
+// onSubmit.
+type stubProvider struct {
+	reference string
+	err       error
+	onSubmit  func()
+	submitted int
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func (p *stubProvider) Submit(ctx context.Context, payout bank.Payout) (string, error) {
+	p.submitted++
+	if p.onSubmit != nil {
+		p.onSubmit()
+	}
+	return p.reference, p.err
+}
+
+func (p *stubProvider) Lookup(ctx context.Context, id uuid.UUID) (bank.PayoutResult, error) {
+	return bank.PayoutResult{}, bank.ErrPayoutNotFound
+}
+
+func newTestWithdrawal() models.Withdrawal {
+	return models.Withdrawal{
+		ID:            uuid.New(),
+		Amount:        models.NewMoney(100),
+		Currency:      models.DefaultCurrency,
+		Status:        models.WithdrawalPending,
+		NextAttemptAt: time.Now().Add(-time.Second),
+		BankAccount:   &models.BankAccount{BankCode: "BCA", AccountNumber: "1234567890", AccountName: "Test"},
+	}
+}
+
+func TestPayoutProcessorRecordsSubmission(t *testing.T) {
+	withdrawal := newTestWithdrawal()
+	repo := newMemoryWithdrawalRepo(withdrawal)
+	provider := &stubProvider{reference: "REF-1"}
+	processor := NewPayoutProcessor(repo, nil, provider, testDB(t), 3, time.Second, 10)
+
+	n, err := processor.ProcessBatch(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("ProcessBatch() = %d, %v, want 1 claimed", n, err)
+	}
+
+	stored := repo.get(withdrawal.ID)
+	if stored.Status != models.WithdrawalProcessing || stored.ProviderReference == nil || *stored.ProviderReference != "REF-1" {
+		t.Errorf("status = %s, reference = %v, want processing with REF-1", stored.Status, stored.ProviderReference)
+	}
+	if stored.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", stored.Attempts)
+	}
+}
+
+func TestPayoutProcessorLeavesWithdrawalsCompletedDuringSubmission(t *testing.T) {
+	withdrawal := newTestWithdrawal()
+	repo := newMemoryWithdrawalRepo(withdrawal)
+	provider := &stubProvider{reference: "REF-1"}
+	// The provider's result callback is recorded before Submit returns
+	provider.onSubmit = func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		repo.withdrawals[withdrawal.ID].Status = models.WithdrawalSuccess
+	}
+	processor := NewPayoutProcessor(repo, nil, provider, testDB(t), 3, time.Second, 10)
+
+	if _, err := processor.ProcessBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := repo.get(withdrawal.ID)
+	if stored.Status != models.WithdrawalSuccess || stored.Attempts != 0 || stored.ProviderReference != nil {
+		t.Errorf("status = %s, attempts = %d, reference = %v, want the callback's outcome kept", stored.Status, stored.Attempts, stored.ProviderReference)
+	}
+}
+
+func TestPayoutProcessorDoesNotResubmitLeasedWithdrawals(t *testing.T) {
+	withdrawal := newTestWithdrawal()
+	repo := newMemoryWithdrawalRepo(withdrawal)
+	provider := &stubProvider{reference: "REF-1"}
+	processor := NewPayoutProcessor(repo, nil, provider, testDB(t), 3, time.Second, 10)
+
+	// Another processor holds the lease until it records its own outcome
+	if _, err := repo.ClaimDue(nil, time.Now(), time.Now().Add(payoutClaimLease), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := processor.ProcessBatch(context.Background())
+	if err != nil || n != 0 || provider.submitted != 0 {
+		t.Fatalf("ProcessBatch() = %d, %v with %d submissions, want nothing claimed", n, err, provider.submitted)
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"errors"
+	"ewallet/internal/repository"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxPINAttempts wrong PINs in a row lock PIN entry for PINLockDuration
+	MaxPINAttempts  = 5
+	PINLockDuration = 30 * time.Minute
+)
+
+var pinPattern = regexp.MustCompile(`^[0-9]{6}$`)
+
+// PINService manages the transaction PIN that authorizes debits.
+type PINService interface {
+	SetPIN(userID uuid.UUID, password, pin string) error
+	ChangePIN(userID uuid.UUID, currentPIN, newPIN string) error
+	Verify(userID uuid.UUID, pin string) error
+}
+
+type pinService struct {
+	userRepo repository.UserRepository
+}
+
+func NewPINService(userRepo repository.UserRepository) PINService {
+	return &pinService{userRepo: userRepo}
+}
+
+// SetPIN sets the first PIN. The account password is asked again because a
+// bearer token alone must not be enough to authorize debits.
+func (s *pinService) SetPIN(userID uuid.UUID, password, pin string) error {
+	if !pinPattern.MatchString(pin) {
+		return ErrInvalidPINFormat
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.HasPIN() {
+		return ErrPINAlreadySet
+	}
+	if err := user.CheckPassword(password); err != nil {
+		return errors.New("invalid password")
+	}
+
+	if err := user.HashPIN(pin); err != nil {
+		return err
+	}
+	return s.userRepo.UpdatePIN(userID, *user.PINHash)
+}
+
+// ChangePIN replaces the PIN after verifying the current one. Wrong current
+// PINs count towards the lock like any other PIN entry.
+func (s *pinService) ChangePIN(userID uuid.UUID, currentPIN, newPIN string) error {
+	if !pinPattern.MatchString(newPIN) {
+		return ErrInvalidPINFormat
+	}
+
+	if err := s.Verify(userID, currentPIN); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := user.HashPIN(newPIN); err != nil {
+		return err
+	}
+	return s.userRepo.UpdatePIN(userID, *user.PINHash)
+}
+
+// Verify checks the PIN for a debit. After MaxPINAttempts wrong PINs in a
+// row, PIN entry is locked for PINLockDuration. Each attempt is counted
+// before the PIN is compared and forgotten only if it was right, so
+// concurrent guesses cannot get past the lock.
+func (s *pinService) Verify(userID uuid.UUID, pin string) error {
+	if pin == "" {
+		return ErrPINRequired
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.HasPIN() {
+		return ErrPINNotSet
+	}
+
+	now := time.Now()
+	user, claimed, err := s.userRepo.ClaimPINAttempt(userID, now, MaxPINAttempts, now.Add(PINLockDuration))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrPINLocked
+	}
+
+	if err := user.CheckPIN(pin); err != nil {
+		if user.IsPINLocked(now) {
+			return ErrPINLocked
+		}
+		return ErrInvalidPIN
+	}
+
+	return s.userRepo.ResetPINFailures(userID)
+}
@@ -0,0 +1,378 @@
+package service
+
+import (
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxActiveScheduledTransfers    = 20
+	MaxScheduleLeadTime            = 365 * 24 * time.Hour
+	MaxScheduledTransferNoteLength = 255
+	DefaultScheduledTransferLimit  = 50
+	MaxScheduledTransferLimit      = 100
+	ScheduledTransferDetailRuns    = 20
+)
+
+// ScheduledTransferService manages the one-time and recurring transfers of
+// users. The transfers themselves are made by TransferScheduler.
+type ScheduledTransferService interface {
+	Create(meta models.RequestMeta, userID uuid.UUID, input ScheduledTransferInput, pin string) (*models.ScheduledTransfer, error)
+	List(userID uuid.UUID, status *models.ScheduledTransferStatus, limit int) ([]models.ScheduledTransfer, error)
+	Get(userID, id uuid.UUID) (*models.ScheduledTransferDetail, error)
+	Update(meta models.RequestMeta, userID, id uuid.UUID, update ScheduledTransferUpdate, pin string) (*models.ScheduledTransfer, error)
+	Cancel(meta models.RequestMeta, userID, id uuid.UUID) (*models.ScheduledTransfer, error)
+}
+
+// ScheduledTransferInput describes a new scheduled transfer. A nil WalletID
+// sends from the user's primary wallet. EndAt and MaxRuns apply to recurring
+// transfers only.
+type ScheduledTransferInput struct {
+	WalletID   *uuid.UUID
+	ReceiverID uuid.UUID
+	Amount     models.Money
+	Note       string
+	Frequency  models.ScheduleFrequency
+	StartAt    time.Time
+	EndAt      *time.Time
+	MaxRuns    *int
+}
+
+// ScheduledTransferUpdate changes the fields that are set. ClearEndAt and
+// ClearMaxRuns remove the end date and the run limit.
+type ScheduledTransferUpdate struct {
+	Amount       *models.Money
+	Note         *string
+	EndAt        *time.Time
+	ClearEndAt   bool
+	MaxRuns      *int
+	ClearMaxRuns bool
+}
+
+type scheduledTransferService struct {
+	scheduledTransferRepo repository.ScheduledTransferRepository
+	userRepo              repository.UserRepository
+	walletRepo            repository.WalletRepository
+	pinService            PINService
+	auditService          AuditService
+	db                    *gorm.DB
+}
+
+func NewScheduledTransferService(
+	scheduledTransferRepo repository.ScheduledTransferRepository,
+	userRepo repository.UserRepository,
+	walletRepo repository.WalletRepository,
+	pinService PINService,
+	auditService AuditService,
+	db *gorm.DB,
+) ScheduledTransferService {
+	return &scheduledTransferService{
+		scheduledTransferRepo: scheduledTransferRepo,
+		userRepo:              userRepo,
+		walletRepo:            walletRepo,
+		pinService:            pinService,
+		auditService:          auditService,
+		db:                    db,
+	}
+}
+
+// Create schedules a transfer. The PIN is verified now and authorizes every
+// run, since nobody is there to enter it when the transfer is made. Balance,
+// limits and wallet status are checked at each run.
+func (s *scheduledTransferService) Create(meta models.RequestMeta, userID uuid.UUID, input ScheduledTransferInput, pin string) (*models.ScheduledTransfer, error) {
+	if err := s.pinService.Verify(userID, pin); err != nil {
+		return nil, err
+	}
+
+	if input.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if input.ReceiverID == userID {
+		return nil, ErrSelfTransfer
+	}
+	note, err := normalizeScheduleNote(input.Note)
+	if err != nil {
+		return nil, err
+	}
+	if !input.Frequency.IsValid() {
+		return nil, ErrInvalidSchedule.withMessage("frequency must be once, daily, weekly or monthly")
+	}
+
+	now := time.Now()
+	startAt := input.StartAt.UTC().Truncate(time.Second)
+	if !startAt.After(now) {
+		return nil, ErrInvalidSchedule.withMessage("start_at must be in the future")
+	}
+	if startAt.After(now.Add(MaxScheduleLeadTime)) {
+		return nil, ErrInvalidSchedule.withMessage("start_at must be within a year")
+	}
+
+	schedule := &models.ScheduledTransfer{
+		UserID:     userID,
+		WalletID:   input.WalletID,
+		ReceiverID: input.ReceiverID,
+		Amount:     input.Amount,
+		Note:       note,
+		Frequency:  input.Frequency,
+		StartAt:    startAt,
+		Status:     models.ScheduledTransferActive,
+		NextRunAt:  &startAt,
+	}
+	if input.Frequency == models.ScheduleOnce {
+		if input.EndAt != nil || input.MaxRuns != nil {
+			return nil, ErrInvalidSchedule.withMessage("end_at and max_runs apply to recurring transfers only")
+		}
+	} else {
+		if input.EndAt != nil {
+			endAt := input.EndAt.UTC()
+			schedule.EndAt = &endAt
+		}
+		schedule.MaxRuns = input.MaxRuns
+		if err := validateScheduleEnd(schedule); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.WalletID != nil {
+		if _, err := s.walletRepo.FindOwned(userID, *input.WalletID); err != nil {
+			return nil, ErrWalletNotFound
+		}
+	}
+	if _, err := s.userRepo.FindByID(input.ReceiverID); err != nil {
+		return nil, ErrReceiverNotFound
+	}
+
+	count, err := s.scheduledTransferRepo.CountActive(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxActiveScheduledTransfers {
+		return nil, ErrScheduledTransferLimitReached.withMessage("at most %d active scheduled transfers are allowed", MaxActiveScheduledTransfers)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.scheduledTransferRepo.Create(tx, schedule); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionScheduledTransferCreated,
+			TargetType: models.AuditTargetScheduledTransfer,
+			TargetID:   schedule.ID.String(),
+		}, map[string]interface{}{
+			"wallet_id":   schedule.WalletID,
+			"receiver_id": schedule.ReceiverID,
+			"amount":      schedule.Amount,
+			"frequency":   schedule.Frequency,
+			"start_at":    schedule.StartAt,
+			"end_at":      schedule.EndAt,
+			"max_runs":    schedule.MaxRuns,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func normalizeScheduleNote(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if utf8.RuneCountInString(note) > MaxScheduledTransferNoteLength {
+		return "", ErrInvalidSchedule.withMessage("note must be at most %d characters", MaxScheduledTransferNoteLength)
+	}
+	return note, nil
+}
+
+// validateScheduleEnd checks the end date and run limit of a recurring
+// transfer.
+func validateScheduleEnd(schedule *models.ScheduledTransfer) error {
+	if schedule.EndAt != nil && schedule.EndAt.Before(schedule.StartAt) {
+		return ErrInvalidSchedule.withMessage("end_at must not be before start_at")
+	}
+	if schedule.MaxRuns != nil && *schedule.MaxRuns < 1 {
+		return ErrInvalidSchedule.withMessage("max_runs must be at least 1")
+	}
+	return nil
+}
+
+// extendsSchedule reports whether updated may run longer than previous: its
+// end date or run limit was removed or moved later.
+func extendsSchedule(previous, updated *models.ScheduledTransfer) bool {
+	if previous.EndAt != nil && (updated.EndAt == nil || updated.EndAt.After(*previous.EndAt)) {
+		return true
+	}
+	if previous.MaxRuns != nil && (updated.MaxRuns == nil || *updated.MaxRuns > *previous.MaxRuns) {
+		return true
+	}
+	return false
+}
+
+// List returns the user's scheduled transfers, newest first. The limit
+// defaults to DefaultScheduledTransferLimit and is capped at
+// MaxScheduledTransferLimit.
+func (s *scheduledTransferService) List(userID uuid.UUID, status *models.ScheduledTransferStatus, limit int) ([]models.ScheduledTransfer, error) {
+	if limit <= 0 {
+		limit = DefaultScheduledTransferLimit
+	}
+	if limit > MaxScheduledTransferLimit {
+		limit = MaxScheduledTransferLimit
+	}
+
+	schedules, err := s.scheduledTransferRepo.FindByUserID(userID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []models.ScheduledTransfer{}
+	}
+	return schedules, nil
+}
+
+// Get returns the scheduled transfer with its ScheduledTransferDetailRuns
+// latest runs.
+func (s *scheduledTransferService) Get(userID, id uuid.UUID) (*models.ScheduledTransferDetail, error) {
+	schedule, err := s.scheduledTransferRepo.FindByID(userID, id)
+	if err != nil {
+		return nil, ErrScheduledTransferNotFound
+	}
+
+	runs, err := s.scheduledTransferRepo.FindRuns(id, ScheduledTransferDetailRuns)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []models.ScheduledTransferRun{}
+	}
+
+	return &models.ScheduledTransferDetail{ScheduledTransfer: *schedule, Runs: runs}, nil
+}
+
+// Update changes an active scheduled transfer. Changes apply from the next
+// run on; a transfer whose new end has already been reached is completed.
+// The PIN given at creation authorized the schedule as it was, so a change
+// that lets it send more money, by raising the amount or extending its end,
+// needs the PIN again.
+func (s *scheduledTransferService) Update(meta models.RequestMeta, userID, id uuid.UUID, update ScheduledTransferUpdate, pin string) (*models.ScheduledTransfer, error) {
+	if update.Amount != nil && *update.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	var schedule *models.ScheduledTransfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		schedule, err = s.scheduledTransferRepo.FindByIDForUpdate(tx, userID, id)
+		if err != nil {
+			return ErrScheduledTransferNotFound
+		}
+		if schedule.Status != models.ScheduledTransferActive {
+			return ErrScheduledTransferNotActive
+		}
+		previous := *schedule
+
+		if update.Amount != nil {
+			schedule.Amount = *update.Amount
+		}
+		if update.Note != nil {
+			note, err := normalizeScheduleNote(*update.Note)
+			if err != nil {
+				return err
+			}
+			schedule.Note = note
+		}
+
+		endChanged := update.EndAt != nil || update.ClearEndAt || update.MaxRuns != nil || update.ClearMaxRuns
+		if endChanged && schedule.Frequency == models.ScheduleOnce {
+			return ErrInvalidSchedule.withMessage("end_at and max_runs apply to recurring transfers only")
+		}
+		if update.ClearEndAt {
+			schedule.EndAt = nil
+		} else if update.EndAt != nil {
+			endAt := update.EndAt.UTC()
+			schedule.EndAt = &endAt
+		}
+		if update.ClearMaxRuns {
+			schedule.MaxRuns = nil
+		} else if update.MaxRuns != nil {
+			schedule.MaxRuns = update.MaxRuns
+		}
+		if err := validateScheduleEnd(schedule); err != nil {
+			return err
+		}
+
+		if schedule.Amount > previous.Amount || extendsSchedule(&previous, schedule) {
+			if err := s.pinService.Verify(userID, pin); err != nil {
+				return err
+			}
+		}
+
+		if endChanged {
+			next, ok := schedule.NextOccurrence(schedule.RunCount)
+			switch {
+			case !ok:
+				schedule.Status = models.ScheduledTransferCompleted
+				schedule.NextRunAt = nil
+			case schedule.ConsecutiveFailures == 0:
+				// A failing occurrence keeps its retry time
+				schedule.NextRunAt = &next
+			}
+		}
+
+		if err := s.scheduledTransferRepo.Update(tx, schedule); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionScheduledTransferUpdated,
+			TargetType: models.AuditTargetScheduledTransfer,
+			TargetID:   schedule.ID.String(),
+		}, map[string]interface{}{
+			"amount":   schedule.Amount,
+			"end_at":   schedule.EndAt,
+			"max_runs": schedule.MaxRuns,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// Cancel stops an active scheduled transfer. Runs already made stay.
+func (s *scheduledTransferService) Cancel(meta models.RequestMeta, userID, id uuid.UUID) (*models.ScheduledTransfer, error) {
+	var schedule *models.ScheduledTransfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		schedule, err = s.scheduledTransferRepo.FindByIDForUpdate(tx, userID, id)
+		if err != nil {
+			return ErrScheduledTransferNotFound
+		}
+		if schedule.Status != models.ScheduledTransferActive {
+			return ErrScheduledTransferNotActive
+		}
+
+		schedule.Status = models.ScheduledTransferCancelled
+		schedule.NextRunAt = nil
+		if err := s.scheduledTransferRepo.Update(tx, schedule); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionScheduledTransferCancelled,
+			TargetType: models.AuditTargetScheduledTransfer,
+			TargetID:   schedule.ID.String(),
+		}, map[string]interface{}{"run_count": schedule.RunCount})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTopUpExpiryPollInterval = time.Minute
+	DefaultTopUpExpiryBatchSize    = 50
+)
+
+// TopUpExpirer expires pending top-ups whose payment was not made in time,
+// failing their transactions so they stop counting against the top-up
+// limits. Several expirers may run against the same database: each claims
+// its batch with FOR UPDATE SKIP LOCKED.
+type TopUpExpirer struct {
+	topUpRepo    repository.TopUpRepository
+	topUpService TopUpService
+	db           *gorm.DB
+	pollInterval time.Duration
+	batchSize    int
+}
+
+func NewTopUpExpirer(
+	topUpRepo repository.TopUpRepository,
+	topUpService TopUpService,
+	db *gorm.DB,
+	pollInterval time.Duration,
+	batchSize int,
+) *TopUpExpirer {
+	if pollInterval <= 0 {
+		pollInterval = DefaultTopUpExpiryPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultTopUpExpiryBatchSize
+	}
+	return &TopUpExpirer{
+		topUpRepo:    topUpRepo,
+		topUpService: topUpService,
+		db:           db,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run expires top-ups until ctx is cancelled.
+func (e *TopUpExpirer) Run(ctx context.Context) {
+	for {
+		n, err := e.ExpireBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("top-up expirer: %v", err)
+		}
+		if n == e.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.pollInterval):
+		}
+	}
+}
+
+// ExpireBatch claims one batch of expired top-ups and expires each. It
+// returns the number of top-ups claimed.
+func (e *TopUpExpirer) ExpireBatch(ctx context.Context) (int, error) {
+	claimed := 0
+	err := e.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired, err := e.topUpRepo.ClaimExpired(tx, time.Now(), e.batchSize)
+		if err != nil {
+			return err
+		}
+		claimed = len(expired)
+
+		for i := range expired {
+			meta := models.RequestMeta{RequestID: "topup-expiry:" + expired[i].ID.String()}
+			if err := e.topUpService.Expire(tx, meta, &expired[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return claimed, err
+}
@@ -0,0 +1,532 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/payment"
+	"ewallet/internal/repository"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTopUpTTL   = 24 * time.Hour
+	DefaultTopUpLimit = 50
+	MaxTopUpLimit     = 100
+)
+
+// TopUpService adds money to wallets through the payment gateway. Creating a
+// top-up only opens a payment and records a pending topup transaction; the
+// wallet is credited when the gateway's signed result arrives through
+// HandleResult. Top-ups nobody paid are expired by TopUpExpirer.
+type TopUpService interface {
+	Create(ctx context.Context, meta models.RequestMeta, userID uuid.UUID, walletID *uuid.UUID, amount models.Money) (*models.TopUp, error)
+	List(userID uuid.UUID, status *models.TopUpStatus, limit int) ([]models.TopUp, error)
+	Get(userID, id uuid.UUID) (*models.TopUp, error)
+	HandleResult(meta models.RequestMeta, result payment.PaymentResult) (*models.TopUp, error)
+	Expire(tx *gorm.DB, meta models.RequestMeta, topUp *models.TopUp) error
+}
+
+type topUpService struct {
+	topUpRepo           repository.TopUpRepository
+	walletRepo          repository.WalletRepository
+	transactionRepo     repository.TransactionRepository
+	ledgerService       LedgerService
+	walletLocker        WalletLocker
+	limitService        LimitService
+	fxService           FXService
+	feeService          FeeService
+	kycService          KYCService
+	auditService        AuditService
+	outboxService       OutboxService
+	notificationService NotificationService
+	gateway             payment.PaymentGateway
+	ttl                 time.Duration
+	db                  *gorm.DB
+}
+
+// NewTopUpService creates the service. Payments stay open for ttl, or for
+// DefaultTopUpTTL if ttl is not positive.
+func NewTopUpService(
+	topUpRepo repository.TopUpRepository,
+	walletRepo repository.WalletRepository,
+	transactionRepo repository.TransactionRepository,
+	ledgerService LedgerService,
+	walletLocker WalletLocker,
+	limitService LimitService,
+	fxService FXService,
+	feeService FeeService,
+	kycService KYCService,
+	auditService AuditService,
+	outboxService OutboxService,
+	notificationService NotificationService,
+	gateway payment.PaymentGateway,
+	ttl time.Duration,
+	db *gorm.DB,
+) TopUpService {
+	if ttl <= 0 {
+		ttl = DefaultTopUpTTL
+	}
+	return &topUpService{
+		topUpRepo:           topUpRepo,
+		walletRepo:          walletRepo,
+		transactionRepo:     transactionRepo,
+		ledgerService:       ledgerService,
+		walletLocker:        walletLocker,
+		limitService:        limitService,
+		fxService:           fxService,
+		feeService:          feeService,
+		kycService:          kycService,
+		auditService:        auditService,
+		outboxService:       outboxService,
+		notificationService: notificationService,
+		gateway:             gateway,
+		ttl:                 ttl,
+		db:                  db,
+	}
+}
+
+// Create records a pending top-up of amount plus the top-up fee, in the
+// wallet's currency, then opens its payment at the gateway and returns the
+// top-up with the gateway's payment instruction. KYC, limits and the wallet
+// status are checked first; the pending top-up counts against the top-up
+// limits until it fails or expires.
+//
+// The gateway is called between two transactions so the wallet is not held
+// locked while it answers. If the call fails the top-up fails with
+// TopUpFailureGatewayUnavailable; the payment may have been opened all the
+// same, so a payment reported for it later is still credited.
+func (s *topUpService) Create(ctx context.Context, meta models.RequestMeta, userID uuid.UUID, walletID *uuid.UUID, amount models.Money) (*models.TopUp, error) {
+	// Validate amount
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	if err := s.kycService.RequireFeature(userID, models.FeatureTopUp); err != nil {
+		return nil, err
+	}
+
+	current, err := findWallet(s.walletRepo, userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	var topUp *models.TopUp
+	err = s.walletLocker.WithWallets([]uuid.UUID{current.ID}, func(tx *gorm.DB, wallets LockedWallets) error {
+		w := wallets[current.ID]
+
+		// Check the status on the locked row so a freeze cannot race the top-up
+		if err := checkCredit(w); err != nil {
+			return err
+		}
+
+		// Enforce limits under the wallet lock so usage cannot change underneath
+		base, err := s.fxService.ToBase(amount, w.Currency)
+		if err != nil {
+			return err
+		}
+		if err := s.limitService.CheckTransaction(tx, userID, models.TransactionTypeTopUp, base); err != nil {
+			return err
+		}
+		if err := s.limitService.CheckBalance(tx, userID, base); err != nil {
+			return err
+		}
+
+		// The fee is paid on top of the amount, so the wallet receives the
+		// full amount
+		fee, err := s.feeService.Quote(tx, userID, models.TransactionTypeTopUp, amount, w.Currency)
+		if err != nil {
+			return err
+		}
+
+		// The transaction stays pending until the payment is confirmed
+		transaction := &models.Transaction{
+			ReceiverID:       &userID,
+			ReceiverWalletID: &w.ID,
+			Amount:           amount,
+			Currency:         w.Currency,
+			Fee:              fee.Fee,
+			BaseAmount:       base,
+			Type:             models.TransactionTypeTopUp,
+			Status:           models.TransactionStatusPending,
+		}
+		if err := s.transactionRepo.Create(tx, transaction); err != nil {
+			return err
+		}
+
+		topUp = &models.TopUp{
+			UserID:        userID,
+			WalletID:      w.ID,
+			Amount:        amount,
+			Fee:           fee.Fee,
+			Total:         fee.Total,
+			Currency:      w.Currency,
+			Status:        models.TopUpPending,
+			TransactionID: transaction.ID,
+			Gateway:       s.gateway.Name(),
+			ExpiresAt:     time.Now().Add(s.ttl).UTC().Truncate(time.Second),
+		}
+		if err := s.topUpRepo.Create(tx, topUp); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionTopUpRequested,
+			TargetType: models.AuditTargetTopUp,
+			TargetID:   topUp.ID.String(),
+		}, map[string]interface{}{
+			"transaction_id": transaction.ID,
+			"wallet_id":      w.ID,
+			"amount":         amount,
+			"fee":            fee.Fee,
+			"currency":       w.Currency,
+			"gateway":        topUp.Gateway,
+			"expires_at":     topUp.ExpiresAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The top-up ID is the gateway's idempotency key
+	instruction, gatewayErr := s.gateway.CreatePayment(ctx, payment.Payment{
+		ID:        topUp.ID,
+		Amount:    topUp.Total,
+		Currency:  topUp.Currency,
+		ExpiresAt: topUp.ExpiresAt,
+	})
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		t, err := s.topUpRepo.FindByIDForUpdate(tx, topUp.ID)
+		if err != nil {
+			return err
+		}
+		topUp = t
+
+		if gatewayErr != nil {
+			// A result may already have ended the top-up
+			if t.Status != models.TopUpPending {
+				return nil
+			}
+			return s.fail(tx, meta, t, models.TopUpFailed, models.TopUpFailureGatewayUnavailable)
+		}
+
+		// A result that arrived first has already set the reference
+		if t.GatewayReference == nil {
+			t.GatewayReference = &instruction.Reference
+		}
+		if instruction.VirtualAccountNumber != "" {
+			t.VirtualAccountNumber = &instruction.VirtualAccountNumber
+		}
+		if instruction.PaymentURL != "" {
+			t.PaymentURL = &instruction.PaymentURL
+		}
+		t.UpdatedAt = time.Now()
+		return s.topUpRepo.Update(tx, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if gatewayErr != nil {
+		return nil, ErrPaymentGatewayUnavailable.withMessage("payment gateway is unavailable: %v", gatewayErr)
+	}
+
+	return topUp, nil
+}
+
+// List returns the user's top-ups, newest first. The limit defaults to
+// DefaultTopUpLimit and is capped at MaxTopUpLimit.
+func (s *topUpService) List(userID uuid.UUID, status *models.TopUpStatus, limit int) ([]models.TopUp, error) {
+	if limit <= 0 {
+		limit = DefaultTopUpLimit
+	}
+	if limit > MaxTopUpLimit {
+		limit = MaxTopUpLimit
+	}
+
+	topUps, err := s.topUpRepo.Find(userID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if topUps == nil {
+		topUps = []models.TopUp{}
+	}
+	return topUps, nil
+}
+
+// Get returns one of the user's top-ups; anyone else's is not found.
+func (s *topUpService) Get(userID, id uuid.UUID) (*models.TopUp, error) {
+	topUp, err := s.topUpRepo.FindByID(id)
+	if err != nil || topUp.UserID != userID {
+		return nil, ErrTopUpNotFound
+	}
+	return topUp, nil
+}
+
+// HandleResult records the payment gateway's outcome of a top-up: a paid
+// top-up is credited to its wallet, or held if the wallet can no longer take
+// it, and a failed one fails its transaction. Expiry does not cancel the
+// payment at the gateway, so a payment that arrives after its top-up
+// expired, or after opening it seemed to fail, is still credited rather than
+// lost. Gateways may report a result more than once, so a result that
+// repeats how the top-up ended, paid or not, is accepted again without
+// effect.
+func (s *topUpService) HandleResult(meta models.RequestMeta, result payment.PaymentResult) (*models.TopUp, error) {
+	var topUp *models.TopUp
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		t, err := s.topUpRepo.FindByIDForUpdate(tx, result.TopUpID)
+		if err != nil {
+			return ErrTopUpNotFound
+		}
+		topUp = t
+
+		if result.Reference != "" {
+			// The result can beat the instruction being saved by Create
+			if t.GatewayReference == nil {
+				t.GatewayReference = &result.Reference
+			} else if result.Reference != *t.GatewayReference {
+				return ErrInvalidPaymentResult.withMessage("reference %s does not match the top-up", result.Reference)
+			}
+		}
+
+		status := models.TopUpSuccess
+		if result.Status == payment.PaymentFailed {
+			status = models.TopUpFailed
+		}
+		late := status == models.TopUpSuccess && t.AcceptsLatePayment()
+		if t.Status.IsFinal() && !late {
+			if t.Status.IsPaid() == (status == models.TopUpSuccess) {
+				return nil
+			}
+			return ErrTopUpCompleted
+		}
+
+		if status == models.TopUpFailed {
+			return s.fail(tx, meta, t, models.TopUpFailed, models.TopUpFailurePaymentFailed)
+		}
+		if result.Amount != t.Total {
+			return ErrInvalidPaymentResult.withMessage("paid amount %s does not match the top-up total %s", result.Amount, t.Total)
+		}
+		return s.credit(tx, meta, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return topUp, nil
+}
+
+// Expire ends a pending top-up nobody paid in time. The top-up must be
+// locked by tx.
+func (s *topUpService) Expire(tx *gorm.DB, meta models.RequestMeta, topUp *models.TopUp) error {
+	return s.fail(tx, meta, topUp, models.TopUpExpired, models.TopUpFailureExpired)
+}
+
+// credit completes a paid top-up: external money enters through the top-up
+// clearing account and lands in the wallet, and the fee goes to fee
+// revenue. A top-up that accepts a late payment is credited the same way
+// and loses its failure reason. The payment may arrive long after the
+// top-up was created, so the wallet status and the maximum balance are
+// checked again; money the wallet cannot take is held instead. Frozen
+// wallets are still credited, and the money stays frozen with them. The
+// top-up must be locked by tx.
+func (s *topUpService) credit(tx *gorm.DB, meta models.RequestMeta, topUp *models.TopUp) error {
+	late := topUp.AcceptsLatePayment()
+	transaction, err := s.transactionRepo.FindByIDForUpdate(tx, topUp.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := s.walletRepo.FindByIDForUpdate(tx, topUp.WalletID)
+	if err != nil {
+		return err
+	}
+	if wallet.Status == models.WalletStatusClosed {
+		return s.hold(tx, meta, topUp, transaction, models.TopUpFailureWalletClosed, late)
+	}
+	if err := s.limitService.CheckBalance(tx, topUp.UserID, transaction.BaseAmount); err != nil {
+		if errors.Is(err, ErrMaxBalanceExceeded) {
+			return s.hold(tx, meta, topUp, transaction, models.TopUpFailureMaxBalance, late)
+		}
+		return err
+	}
+
+	clearing, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountTopUpClearing, topUp.Currency)
+	if err != nil {
+		return err
+	}
+	account, err := s.ledgerService.WalletAccount(tx, topUp.WalletID)
+	if err != nil {
+		return err
+	}
+	postings, err := s.ledgerService.AddFee(tx, []models.Posting{
+		{AccountID: clearing.ID, Direction: models.PostingDirectionDebit, Amount: topUp.Amount},
+		{AccountID: account.ID, Direction: models.PostingDirectionCredit, Amount: topUp.Amount},
+	}, topUp.Fee, topUp.Currency)
+	if err != nil {
+		return err
+	}
+	entry := &models.JournalEntry{
+		TransactionID: &transaction.ID,
+		Description:   "top-up",
+		Postings:      postings,
+	}
+	if err := s.ledgerService.Post(tx, entry); err != nil {
+		return err
+	}
+	balance := *entry.Postings[1].BalanceAfter
+
+	transaction.Status = models.TransactionStatusSuccess
+	transaction.FailureReason = nil
+	if err := s.transactionRepo.UpdateStatus(tx, transaction); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	topUp.Status = models.TopUpSuccess
+	topUp.FailureReason = nil
+	topUp.CompletedAt = &now
+	topUp.UpdatedAt = now
+	if err := s.topUpRepo.Update(tx, topUp); err != nil {
+		return err
+	}
+
+	err = s.auditService.Record(tx, meta, &models.AuditEvent{
+		Action:     models.AuditActionWalletToppedUp,
+		TargetType: models.AuditTargetTransaction,
+		TargetID:   transaction.ID.String(),
+	}, map[string]interface{}{"topup_id": topUp.ID, "wallet_id": topUp.WalletID, "amount": topUp.Amount, "fee": topUp.Fee, "balance_after": balance, "paid_after_expiry": late})
+	if err != nil {
+		return err
+	}
+
+	err = s.outboxService.Publish(tx, models.EventWalletToppedUp, models.AggregateTransaction, transaction.ID, models.WalletToppedUpData{
+		TopUpID:       topUp.ID,
+		TransactionID: transaction.ID,
+		UserID:        topUp.UserID,
+		WalletID:      topUp.WalletID,
+		Amount:        topUp.Amount,
+		Currency:      topUp.Currency,
+		Fee:           topUp.Fee,
+		BalanceAfter:  balance,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.notificationService.Publish(tx, &models.WalletNotification{
+		UserID:        topUp.UserID,
+		Type:          models.NotificationBalanceChanged,
+		WalletID:      &topUp.WalletID,
+		Balance:       &balance,
+		Currency:      &topUp.Currency,
+		TransactionID: &transaction.ID,
+	})
+}
+
+// hold parks a payment the top-up's wallet cannot take in the top-up
+// suspense account, for support to credit elsewhere or refund. No fee is
+// charged; the top-up is held and its transaction fails with the reason.
+// The top-up must be locked by tx.
+func (s *topUpService) hold(tx *gorm.DB, meta models.RequestMeta, topUp *models.TopUp, transaction *models.Transaction, reason string, late bool) error {
+	clearing, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountTopUpClearing, topUp.Currency)
+	if err != nil {
+		return err
+	}
+	suspense, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountTopUpSuspense, topUp.Currency)
+	if err != nil {
+		return err
+	}
+	err = s.ledgerService.Post(tx, &models.JournalEntry{
+		TransactionID: &transaction.ID,
+		Description:   "top-up held",
+		Postings: []models.Posting{
+			{AccountID: clearing.ID, Direction: models.PostingDirectionDebit, Amount: topUp.Total},
+			{AccountID: suspense.ID, Direction: models.PostingDirectionCredit, Amount: topUp.Total},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	transaction.Status = models.TransactionStatusFailed
+	transaction.FailureReason = &reason
+	if err := s.transactionRepo.UpdateStatus(tx, transaction); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	topUp.Status = models.TopUpHeld
+	topUp.FailureReason = &reason
+	topUp.CompletedAt = &now
+	topUp.UpdatedAt = now
+	if err := s.topUpRepo.Update(tx, topUp); err != nil {
+		return err
+	}
+
+	err = s.auditService.Record(tx, meta, &models.AuditEvent{
+		Action:     models.AuditActionTopUpHeld,
+		TargetType: models.AuditTargetTopUp,
+		TargetID:   topUp.ID.String(),
+	}, map[string]interface{}{"transaction_id": transaction.ID, "wallet_id": topUp.WalletID, "total": topUp.Total, "currency": topUp.Currency, "reason": reason, "paid_after_expiry": late})
+	if err != nil {
+		return err
+	}
+
+	// The wallet was not topped up, so the user hears of it as a failure
+	return s.outboxService.Publish(tx, models.EventTopUpFailed, models.AggregateTransaction, transaction.ID, models.TopUpFailedData{
+		TopUpID:       topUp.ID,
+		TransactionID: transaction.ID,
+		UserID:        topUp.UserID,
+		WalletID:      topUp.WalletID,
+		Amount:        topUp.Amount,
+		Currency:      topUp.Currency,
+		FailureReason: reason,
+	})
+}
+
+// fail ends a pending top-up with the status and reason code and fails its
+// transaction. No money has moved, so nothing is posted. The top-up must be
+// locked by tx.
+func (s *topUpService) fail(tx *gorm.DB, meta models.RequestMeta, topUp *models.TopUp, status models.TopUpStatus, reason string) error {
+	transaction, err := s.transactionRepo.FindByIDForUpdate(tx, topUp.TransactionID)
+	if err != nil {
+		return err
+	}
+	transaction.Status = models.TransactionStatusFailed
+	transaction.FailureReason = &reason
+	if err := s.transactionRepo.UpdateStatus(tx, transaction); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	topUp.Status = status
+	topUp.FailureReason = &reason
+	topUp.CompletedAt = &now
+	topUp.UpdatedAt = now
+	if err := s.topUpRepo.Update(tx, topUp); err != nil {
+		return err
+	}
+
+	err = s.auditService.Record(tx, meta, &models.AuditEvent{
+		Action:     models.AuditActionTopUpFailed,
+		TargetType: models.AuditTargetTopUp,
+		TargetID:   topUp.ID.String(),
+	}, map[string]interface{}{"transaction_id": transaction.ID, "amount": topUp.Amount, "currency": topUp.Currency, "reason": reason})
+	if err != nil {
+		return err
+	}
+
+	return s.outboxService.Publish(tx, models.EventTopUpFailed, models.AggregateTransaction, transaction.ID, models.TopUpFailedData{
+		TopUpID:       topUp.ID,
+		TransactionID: transaction.ID,
+		UserID:        topUp.UserID,
+		WalletID:      topUp.WalletID,
+		Amount:        topUp.Amount,
+		Currency:      topUp.Currency,
+		FailureReason: reason,
+	})
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/payment"
+	"ewallet/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryTopUpRepo keeps top-ups in memory.
+type memoryTopUpRepo struct {
+	repository.TopUpRepository
+
+	topUps map[uuid.UUID]*models.TopUp
+}
+
+func (r *memoryTopUpRepo) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.TopUp, error) {
+	topUp, ok := r.topUps[id]
+	if !ok {
+		return nil, errors.New("top-up not found")
+	}
+	copied := *topUp
+	return &copied, nil
+}
+
+func (r *memoryTopUpRepo) ClaimExpired(tx *gorm.DB, now time.Time, limit int) ([]models.TopUp, error) {
+	var expired []models.TopUp
+	for _, topUp := range r.topUps {
+		if topUp.Status == models.TopUpPending && !topUp.ExpiresAt.After(now) && len(expired) < limit {
+			expired = append(expired, *topUp)
+		}
+	}
+	return expired, nil
+}
+
+func (r *memoryTopUpRepo) Update(tx *gorm.DB, topUp *models.TopUp) error {
+	copied := *topUp
+	r.topUps[topUp.ID] = &copied
+	return nil
+}
+
+// memoryTransactionRepo keeps transactions in memory.
+type memoryTransactionRepo struct {
+	repository.TransactionRepository
+
+	transactions map[uuid.UUID]*models.Transaction
+}
+
+func (r *memoryTransactionRepo) FindByIDForUpdate(tx *gorm.DB, id uuid.UUID) (*models.Transaction, error) {
+	transaction, ok := r.transactions[id]
+	if !ok {
+		return nil, errors.New("transaction not found")
+	}
+	copied := *transaction
+	return &copied, nil
+}
+
+func (r *memoryTransactionRepo) UpdateStatus(tx *gorm.DB, transaction *models.Transaction) error {
+	stored := r.transactions[transaction.ID]
+	stored.Status = transaction.Status
+	stored.FailureReason = transaction.FailureReason
+	return nil
+}
+
+// stubLimits fails every balance check with balanceErr.
+type stubLimits struct {
+	LimitService
+	balanceErr error
+}
+
+func (l *stubLimits) CheckBalance(tx *gorm.DB, userID uuid.UUID, credit models.Money) error {
+	return l.balanceErr
+}
+
+// recordingAudit, recordingOutbox and recordingNotifications keep what the
+// service under test published.
+type recordingAudit struct {
+	AuditService
+	events   []models.AuditEvent
+	payloads []interface{}
+}
+
+func (a *recordingAudit) Record(tx *gorm.DB, meta models.RequestMeta, event *models.AuditEvent, payload interface{}) error {
+	a.events = append(a.events, *event)
+	a.payloads = append(a.payloads, payload)
+	return nil
+}
+
+type recordingOutbox struct {
+	eventTypes []string
+}
+
+func (o *recordingOutbox) Publish(tx *gorm.DB, eventType, aggregateType string, aggregateID uuid.UUID, data interface{}) error {
+	o.eventTypes = append(o.eventTypes, eventType)
+	return nil
+}
+
+type recordingNotifications struct {
+	notifications []models.WalletNotification
+}
+
+func (n *recordingNotifications) Publish(tx *gorm.DB, notification *models.WalletNotification) error {
+	n.notifications = append(n.notifications, *notification)
+	return nil
+}
+
+// topUpFixture is a pending top-up of 100.00 plus a 1.50 fee, with the
+// service that completes it.
+type topUpFixture struct {
+	service      TopUpService
+	db           *gorm.DB
+	ledger       *testLedger
+	topUps       *memoryTopUpRepo
+	transactions *memoryTransactionRepo
+	limits       *stubLimits
+	audit        *recordingAudit
+	outbox       *recordingOutbox
+	topUp        models.TopUp
+	wallet       *models.Wallet
+}
+
+func newTopUpFixture(t *testing.T) *topUpFixture {
+	t.Helper()
+	ledger := newTestLedger()
+	wallet := ledger.openWallet(t, models.DefaultCurrency)
+
+	transaction := &models.Transaction{
+		ID:       uuid.New(),
+		Amount:   models.NewMoney(100),
+		Currency: models.DefaultCurrency,
+		Type:     models.TransactionTypeTopUp,
+		Status:   models.TransactionStatusPending,
+	}
+	reference := "SIM-1"
+	topUp := models.TopUp{
+		ID:               uuid.New(),
+		UserID:           wallet.UserID,
+		WalletID:         wallet.ID,
+		Amount:           models.NewMoney(100),
+		Fee:              150,
+		Total:            models.NewMoney(100) + 150,
+		Currency:         models.DefaultCurrency,
+		Status:           models.TopUpPending,
+		TransactionID:    transaction.ID,
+		Gateway:          "simulator",
+		GatewayReference: &reference,
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+
+	f := &topUpFixture{
+		db:           testDB(t),
+		ledger:       ledger,
+		topUps:       &memoryTopUpRepo{topUps: map[uuid.UUID]*models.TopUp{}},
+		transactions: &memoryTransactionRepo{transactions: map[uuid.UUID]*models.Transaction{transaction.ID: transaction}},
+		limits:       &stubLimits{},
+		audit:        &recordingAudit{},
+		outbox:       &recordingOutbox{},
+		topUp:        topUp,
+		wallet:       wallet,
+	}
+	f.topUps.topUps[topUp.ID] = &topUp
+	f.service = NewTopUpService(f.topUps, ledger.walletRepo, f.transactions, ledger, nil, f.limits, nil, nil, nil,
+		f.audit, f.outbox, &recordingNotifications{}, nil, time.Hour, f.db)
+	return f
+}
+
+func (f *topUpFixture) result(status payment.PaymentStatus) payment.PaymentResult {
+	return payment.PaymentResult{TopUpID: f.topUp.ID, Reference: *f.topUp.GatewayReference, Status: status, Amount: f.topUp.Total}
+}
+
+func (f *topUpFixture) stored() (models.TopUp, models.Transaction) {
+	return *f.topUps.topUps[f.topUp.ID], *f.transactions.transactions[f.topUp.TransactionID]
+}
+
+func (f *topUpFixture) expire(t *testing.T) {
+	t.Helper()
+	f.topUps.topUps[f.topUp.ID].ExpiresAt = time.Now().Add(-time.Minute)
+	expirer := NewTopUpExpirer(f.topUps, f.service, f.db, time.Second, 10)
+	if n, err := expirer.ExpireBatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ExpireBatch() = %d, %v, want 1 expired", n, err)
+	}
+}
+
+func TestTopUpPaidResultCreditsOnce(t *testing.T) {
+	f := newTopUpFixture(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentPaid)); err != nil {
+			t.Fatalf("result %d: %v", i+1, err)
+		}
+	}
+
+	topUp, transaction := f.stored()
+	if topUp.Status != models.TopUpSuccess || topUp.CompletedAt == nil {
+		t.Errorf("top-up status = %s, completed_at = %v, want success", topUp.Status, topUp.CompletedAt)
+	}
+	if transaction.Status != models.TransactionStatusSuccess {
+		t.Errorf("transaction status = %s, want success", transaction.Status)
+	}
+	if got := f.ledger.balance(f.wallet.ID); got != models.NewMoney(100) {
+		t.Errorf("wallet balance = %s, want 100.00 after a repeated result", got)
+	}
+	if len(f.ledger.ledgerRepo.entries) != 1 || len(f.outbox.eventTypes) != 1 || f.outbox.eventTypes[0] != models.EventWalletToppedUp {
+		t.Errorf("%d entries and events %v, want one credit", len(f.ledger.ledgerRepo.entries), f.outbox.eventTypes)
+	}
+	if err := f.ledger.VerifyWallet(nil, f.wallet.ID); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentFailed)); !errors.Is(err, ErrTopUpCompleted) {
+		t.Errorf("failed result after success: error = %v, want %v", err, ErrTopUpCompleted)
+	}
+}
+
+func TestTopUpFailedResultIsIdempotent(t *testing.T) {
+	f := newTopUpFixture(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentFailed)); err != nil {
+			t.Fatalf("result %d: %v", i+1, err)
+		}
+	}
+
+	topUp, transaction := f.stored()
+	if topUp.Status != models.TopUpFailed || topUp.FailureReason == nil || *topUp.FailureReason != models.TopUpFailurePaymentFailed {
+		t.Errorf("top-up status = %s, reason = %v, want failed with %s", topUp.Status, topUp.FailureReason, models.TopUpFailurePaymentFailed)
+	}
+	if transaction.Status != models.TransactionStatusFailed {
+		t.Errorf("transaction status = %s, want failed", transaction.Status)
+	}
+	if len(f.outbox.eventTypes) != 1 || len(f.ledger.ledgerRepo.entries) != 0 {
+		t.Errorf("events %v and %d entries, want one failure and no postings", f.outbox.eventTypes, len(f.ledger.ledgerRepo.entries))
+	}
+
+	if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentPaid)); !errors.Is(err, ErrTopUpCompleted) {
+		t.Errorf("paid result after failure: error = %v, want %v", err, ErrTopUpCompleted)
+	}
+}
+
+func TestTopUpRejectsMismatchedResults(t *testing.T) {
+	f := newTopUpFixture(t)
+
+	wrongAmount := f.result(payment.PaymentPaid)
+	wrongAmount.Amount = f.topUp.Amount
+	wrongReference := f.result(payment.PaymentPaid)
+	wrongReference.Reference = "SIM-2"
+	unknown := f.result(payment.PaymentPaid)
+	unknown.TopUpID = uuid.New()
+
+	for name, tt := range map[string]struct {
+		result payment.PaymentResult
+		want   error
+	}{
+		"amount without the fee": {wrongAmount, ErrInvalidPaymentResult},
+		"another reference":      {wrongReference, ErrInvalidPaymentResult},
+		"unknown top-up":         {unknown, ErrTopUpNotFound},
+	} {
+		if _, err := f.service.HandleResult(models.RequestMeta{}, tt.result); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", name, err, tt.want)
+		}
+	}
+	if topUp, _ := f.stored(); topUp.Status != models.TopUpPending || f.ledger.balance(f.wallet.ID) != 0 {
+		t.Errorf("top-up status = %s, balance = %s, want it untouched", topUp.Status, f.ledger.balance(f.wallet.ID))
+	}
+}
+
+func TestTopUpExpiry(t *testing.T) {
+	f := newTopUpFixture(t)
+
+	// Not expired yet
+	expirer := NewTopUpExpirer(f.topUps, f.service, f.db, time.Second, 10)
+	if n, err := expirer.ExpireBatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("ExpireBatch() = %d, %v, want nothing expired", n, err)
+	}
+
+	f.expire(t)
+
+	topUp, transaction := f.stored()
+	if topUp.Status != models.TopUpExpired || topUp.FailureReason == nil || *topUp.FailureReason != models.TopUpFailureExpired {
+		t.Errorf("top-up status = %s, reason = %v, want expired", topUp.Status, topUp.FailureReason)
+	}
+	if transaction.Status != models.TransactionStatusFailed || transaction.FailureReason == nil || *transaction.FailureReason != models.TopUpFailureExpired {
+		t.Errorf("transaction status = %s, reason = %v, want failed with %s", transaction.Status, transaction.FailureReason, models.TopUpFailureExpired)
+	}
+	if len(f.outbox.eventTypes) != 1 || f.outbox.eventTypes[0] != models.EventTopUpFailed {
+		t.Errorf("events = %v, want %s", f.outbox.eventTypes, models.EventTopUpFailed)
+	}
+
+	if n, err := expirer.ExpireBatch(context.Background()); err != nil || n != 0 {
+		t.Fatalf("ExpireBatch() = %d, %v, want an expired top-up left alone", n, err)
+	}
+	// An unpaid top-up reported failed is the same outcome
+	if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentFailed)); err != nil {
+		t.Errorf("failed result after expiry: %v", err)
+	}
+	if topUp, _ := f.stored(); topUp.Status != models.TopUpExpired {
+		t.Errorf("top-up status = %s, want it still expired", topUp.Status)
+	}
+}
+
+func TestTopUpPaidAfterExpiryIsCredited(t *testing.T) {
+	f := newTopUpFixture(t)
+	f.expire(t)
+
+	for i := 0; i < 2; i++ {
+		if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentPaid)); err != nil {
+			t.Fatalf("result %d: %v", i+1, err)
+		}
+	}
+
+	topUp, transaction := f.stored()
+	if topUp.Status != models.TopUpSuccess || topUp.FailureReason != nil {
+		t.Errorf("top-up status = %s, reason = %v, want success without a failure reason", topUp.Status, topUp.FailureReason)
+	}
+	if transaction.Status != models.TransactionStatusSuccess || transaction.FailureReason != nil {
+		t.Errorf("transaction status = %s, reason = %v, want success without a failure reason", transaction.Status, transaction.FailureReason)
+	}
+	if got := f.ledger.balance(f.wallet.ID); got != models.NewMoney(100) {
+		t.Errorf("wallet balance = %s, want 100.00", got)
+	}
+
+	last := f.audit.payloads[len(f.audit.payloads)-1].(map[string]interface{})
+	if f.audit.events[len(f.audit.events)-1].Action != models.AuditActionWalletToppedUp || last["paid_after_expiry"] != true {
+		t.Errorf("audit payload = %v, want the late payment flagged", last)
+	}
+}
+
+func TestTopUpPaidAfterGatewayFailureIsCredited(t *testing.T) {
+	f := newTopUpFixture(t)
+
+	// Opening the payment timed out, so Create failed the top-up before the
+	// gateway's reference was saved
+	reason := models.TopUpFailureGatewayUnavailable
+	stored := f.topUps.topUps[f.topUp.ID]
+	stored.Status = models.TopUpFailed
+	stored.FailureReason = &reason
+	stored.GatewayReference = nil
+
+	if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentPaid)); err != nil {
+		t.Fatal(err)
+	}
+
+	topUp, transaction := f.stored()
+	if topUp.Status != models.TopUpSuccess || topUp.GatewayReference == nil || *topUp.GatewayReference != *f.topUp.GatewayReference {
+		t.Errorf("top-up status = %s, reference = %v, want success with the result's reference", topUp.Status, topUp.GatewayReference)
+	}
+	if transaction.Status != models.TransactionStatusSuccess {
+		t.Errorf("transaction status = %s, want success", transaction.Status)
+	}
+	if got := f.ledger.balance(f.wallet.ID); got != models.NewMoney(100) {
+		t.Errorf("wallet balance = %s, want 100.00", got)
+	}
+}
+
+func TestTopUpPaymentTheWalletCannotTakeIsHeld(t *testing.T) {
+	for name, setup := range map[string]func(f *topUpFixture){
+		"closed wallet": func(f *topUpFixture) {
+			f.ledger.walletRepo.wallets[f.wallet.ID].Status = models.WalletStatusClosed
+		},
+		"maximum balance reached": func(f *topUpFixture) {
+			f.limits.balanceErr = ErrMaxBalanceExceeded
+		},
+	} {
+		f := newTopUpFixture(t)
+		setup(f)
+
+		// The gateway retries, and a contradicting failure cannot undo the payment
+		for i := 0; i < 2; i++ {
+			if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentPaid)); err != nil {
+				t.Fatalf("%s: result %d: %v", name, i+1, err)
+			}
+		}
+		if _, err := f.service.HandleResult(models.RequestMeta{}, f.result(payment.PaymentFailed)); !errors.Is(err, ErrTopUpCompleted) {
+			t.Errorf("%s: failed result after the payment: error = %v, want %v", name, err, ErrTopUpCompleted)
+		}
+
+		topUp, transaction := f.stored()
+		if topUp.Status != models.TopUpHeld || topUp.FailureReason == nil {
+			t.Errorf("%s: top-up status = %s, reason = %v, want held with a reason", name, topUp.Status, topUp.FailureReason)
+		}
+		if transaction.Status != models.TransactionStatusFailed {
+			t.Errorf("%s: transaction status = %s, want failed", name, transaction.Status)
+		}
+		if got := f.ledger.balance(f.wallet.ID); got != 0 {
+			t.Errorf("%s: wallet balance = %s, want 0", name, got)
+		}
+		suspense, err := f.ledger.SystemAccount(nil, models.LedgerAccountTopUpSuspense, models.DefaultCurrency)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := f.ledger.ledgerRepo.SumCreditNormal(nil, suspense.ID); got != f.topUp.Total {
+			t.Errorf("%s: suspense balance = %s, want the paid total %s", name, got, f.topUp.Total)
+		}
+	}
+}
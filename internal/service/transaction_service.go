@@ -4,151 +4,681 @@ import (
 	"errors"
 	"ewallet/internal/models"
 	"ewallet/internal/repository"
+	"log"
+	"strings"
+	"time"
 
-	"bytes"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultHistoryLimit = 50
+	MaxHistoryLimit     = 100
+
+	// RefundWindow is how long after a transfer its receiver may refund it
+	RefundWindow = 7 * 24 * time.Hour
 )
 
 type TransactionService interface {
-	Transfer(senderID, receiverID uuid.UUID, amount float64) (*models.Transaction, error)
-	GetHistory(userID uuid.UUID, limit int) ([]models.Transaction, error)
+	Transfer(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, quoteID *uuid.UUID, pin string) (*models.Transaction, error)
+	TransferWith(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, pin string, then TransferHook) (*models.Transaction, error)
+	TransferAuthorized(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, then TransferHook) (*models.Transaction, error)
+	GetHistory(query repository.TransactionQuery) (*models.TransactionPage, error)
+	GetDetail(userID, transactionID uuid.UUID) (*models.TransactionDetailResponse, error)
+	Reverse(meta models.RequestMeta, actorID, transactionID uuid.UUID, amount *models.Money, reason string) (*models.Transaction, error)
+	Refund(meta models.RequestMeta, userID, transactionID uuid.UUID, amount *models.Money, pin, reason string) (*models.Transaction, error)
 }
 
+// TransferHook runs inside a transfer's database transaction once the money
+// has moved, with both wallets still locked. An error from it rolls the
+// transfer back and is recorded as the transfer's failure.
+type TransferHook func(tx *gorm.DB, transaction *models.Transaction) error
+
 type transactionService struct {
-	walletRepo      repository.WalletRepository
-	transactionRepo repository.TransactionRepository
-	userRepo        repository.UserRepository
-	db              *gorm.DB
+	walletRepo          repository.WalletRepository
+	transactionRepo     repository.TransactionRepository
+	userRepo            repository.UserRepository
+	ledgerService       LedgerService
+	walletLocker        WalletLocker
+	pinService          PINService
+	limitService        LimitService
+	fxService           FXService
+	feeService          FeeService
+	kycService          KYCService
+	auditService        AuditService
+	outboxService       OutboxService
+	notificationService NotificationService
+	db                  *gorm.DB
 }
 
 func NewTransactionService(
 	walletRepo repository.WalletRepository,
 	transactionRepo repository.TransactionRepository,
 	userRepo repository.UserRepository,
+	ledgerService LedgerService,
+	walletLocker WalletLocker,
+	pinService PINService,
+	limitService LimitService,
+	fxService FXService,
+	feeService FeeService,
+	kycService KYCService,
+	auditService AuditService,
+	outboxService OutboxService,
+	notificationService NotificationService,
 	db *gorm.DB,
 ) TransactionService {
 	return &transactionService{
-		walletRepo:      walletRepo,
-		transactionRepo: transactionRepo,
-		userRepo:        userRepo,
-		db:              db,
+		walletRepo:          walletRepo,
+		transactionRepo:     transactionRepo,
+		userRepo:            userRepo,
+		ledgerService:       ledgerService,
+		walletLocker:        walletLocker,
+		pinService:          pinService,
+		limitService:        limitService,
+		fxService:           fxService,
+		feeService:          feeService,
+		kycService:          kycService,
+		auditService:        auditService,
+		outboxService:       outboxService,
+		notificationService: notificationService,
+		db:                  db,
 	}
 }
 
-func (s *transactionService) Transfer(senderID uuid.UUID, receiverID uuid.UUID, amount float64) (*models.Transaction, error) {
+// Transfer moves money from one of the sender's wallets, the primary one
+// when walletID is nil, to the receiver's primary wallet. The amount is in
+// the sender wallet's currency; if the receiver's wallet holds another
+// currency, the sender's FX quote converts it. The sender pays the fee, if
+// a fee rule applies, on top of the amount. Every failed attempt,
+// whether it fails validation or inside the database transaction, is
+// recorded as a failed transaction carrying the reason code.
+func (s *transactionService) Transfer(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, quoteID *uuid.UUID, pin string) (*models.Transaction, error) {
+	return s.transferRecorded(meta, senderID, walletID, receiverID, amount, quoteID, &pin, nil)
+}
+
+// TransferWith is Transfer, without conversion, with a hook that commits or
+// rolls back together with the transfer. It lets other flows build on the
+// transfer's validation and locking.
+func (s *transactionService) TransferWith(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, pin string, then TransferHook) (*models.Transaction, error) {
+	return s.transferRecorded(meta, senderID, walletID, receiverID, amount, nil, &pin, then)
+}
+
+// TransferAuthorized is TransferWith without the PIN check, for transfers
+// the sender authorized earlier, such as scheduled transfers whose PIN was
+// verified when they were set up.
+func (s *transactionService) TransferAuthorized(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, then TransferHook) (*models.Transaction, error) {
+	return s.transferRecorded(meta, senderID, walletID, receiverID, amount, nil, nil, then)
+}
+
+func (s *transactionService) transferRecorded(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, quoteID *uuid.UUID, pin *string, then TransferHook) (*models.Transaction, error) {
+	transaction, err := s.transfer(meta, senderID, walletID, receiverID, amount, quoteID, pin, then)
+	if err != nil {
+		s.recordFailedTransfer(meta, senderID, walletID, receiverID, amount, err)
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// transfer verifies the PIN unless it is nil, meaning the transfer was
+// authorized beforehand.
+func (s *transactionService) transfer(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, quoteID *uuid.UUID, pin *string, then TransferHook) (*models.Transaction, error) {
+	// Authorize the debit before revealing anything about the receiver
+	if pin != nil {
+		if err := s.pinService.Verify(senderID, *pin); err != nil {
+			return nil, err
+		}
+	}
+
+	// Sending money needs a verified identity
+	if err := s.kycService.RequireFeature(senderID, models.FeatureTransfer); err != nil {
+		return nil, err
+	}
+
 	// Validate amount
 	if amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
+		return nil, ErrInvalidAmount
 	}
 
 	// Validate not transferring to self
 	if senderID == receiverID {
-		return nil, errors.New("cannot transfer to yourself")
+		return nil, ErrSelfTransfer
 	}
 
 	// Check if receiver exists
 	receiver, err := s.userRepo.FindByID(receiverID)
+	if err != nil || receiver == nil {
+		return nil, ErrReceiverNotFound
+	}
+
+	// Resolve wallets before locking them. Money from other users always
+	// lands in the receiver's primary wallet.
+	senderWallet, err := findWallet(s.walletRepo, senderID, walletID)
 	if err != nil {
-		return nil, errors.New("receiver not found")
+		return nil, ErrSenderWalletNotFound
 	}
-	if receiver == nil {
-		return nil, errors.New("receiver not found")
+	receiverWallet, err := s.walletRepo.FindPrimary(receiverID)
+	if err != nil {
+		return nil, ErrReceiverWalletNotFound
 	}
 
 	var transaction *models.Transaction
 
-	// Use database transaction to ensure atomicity and handle race conditions
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		// Get sender wallet with row lock (FOR UPDATE)
-		var senderWallet models.Wallet
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("user_id = ?", senderID).
-			First(&senderWallet).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("sender wallet not found")
+	// Lock both wallets in a deterministic order inside one database
+	// transaction to keep the transfer atomic and deadlock-free
+	walletIDs := []uuid.UUID{senderWallet.ID, receiverWallet.ID}
+	err = s.walletLocker.WithWallets(walletIDs, func(tx *gorm.DB, wallets LockedWallets) error {
+		senderWallet := wallets[senderWallet.ID]
+		receiverWallet := wallets[receiverWallet.ID]
+
+		// Check wallet statuses on the locked rows so a freeze cannot race
+		// the transfer. The receiver's exact state is not revealed.
+		if err := checkDebit(senderWallet); err != nil {
+			return err
+		}
+		if err := checkCredit(receiverWallet); err != nil {
+			return ErrReceiverWalletUnavailable
+		}
+		if err := checkUserDebit(tx, s.walletRepo, senderID); err != nil {
+			return err
+		}
+		if err := checkUserCredit(tx, s.walletRepo, receiverID); err != nil {
+			if errors.Is(err, ErrWalletFrozen) {
+				return ErrReceiverWalletUnavailable
 			}
 			return err
 		}
 
+		// The sender pays the fee on top of the amount
+		fee, err := s.feeService.Quote(tx, senderID, models.TransactionTypeTransfer, amount, senderWallet.Currency)
+		if err != nil {
+			return err
+		}
+
 		// Check sufficient balance
-		if senderWallet.Balance < amount {
-			return errors.New("insufficient balance")
-		}
-
-		// Get receiver wallet with row lock (FOR UPDATE)
-		var receiverWallet models.Wallet
-		// Lock wallets in consistent order to prevent deadlock (lower ID first)
-		if bytes.Compare(senderID[:], receiverID[:]) < 0 {
-			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-				Where("user_id = ?", receiverID).
-				First(&receiverWallet).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return errors.New("receiver wallet not found")
-				}
-				return err
-			}
-		} else {
-			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-				Where("user_id = ?", receiverID).
-				First(&receiverWallet).Error; err != nil {
-				if errors.Is(err, gorm.ErrRecordNotFound) {
-					return errors.New("receiver wallet not found")
-				}
-				return err
-			}
+		if senderWallet.Balance < fee.Total {
+			return ErrInsufficientBalance
 		}
 
-		// Update sender balance
-		newSenderBalance := senderWallet.Balance - amount
-		if err := s.walletRepo.UpdateBalanceWithLock(tx, senderWallet.ID, newSenderBalance); err != nil {
+		// Create transaction record, converting through the sender's quote
+		// when the wallets hold different currencies
+		transaction = &models.Transaction{
+			ID:               uuid.New(),
+			SenderID:         &senderID,
+			SenderWalletID:   &senderWallet.ID,
+			ReceiverID:       &receiverID,
+			ReceiverWalletID: &receiverWallet.ID,
+			Amount:           amount,
+			Currency:         senderWallet.Currency,
+			Fee:              fee.Fee,
+			Type:             models.TransactionTypeTransfer,
+			Status:           models.TransactionStatusSuccess,
+		}
+		if err := convertWith(tx, s.fxService, senderID, quoteID, transaction, receiverWallet.Currency); err != nil {
 			return err
 		}
 
-		// Update receiver balance
-		newReceiverBalance := receiverWallet.Balance + amount
-		if err := s.walletRepo.UpdateBalanceWithLock(tx, receiverWallet.ID, newReceiverBalance); err != nil {
+		// Enforce limits under the wallet locks so usage cannot change underneath
+		base, err := s.fxService.ToBase(amount, senderWallet.Currency)
+		if err != nil {
 			return err
 		}
-
-		// Create transaction record
-		transaction = &models.Transaction{
-			SenderID:   &senderID,
-			ReceiverID: receiverID,
-			Amount:     amount,
-			Type:       models.TransactionTypeTransfer,
-			Status:     models.TransactionStatusSuccess,
+		receivedBase, err := s.fxService.ToBase(transaction.ReceivedAmount(), receiverWallet.Currency)
+		if err != nil {
+			return err
+		}
+		if err := s.limitService.CheckTransaction(tx, senderID, models.TransactionTypeTransfer, base); err != nil {
+			return err
+		}
+		if err := s.limitService.CheckBalance(tx, receiverID, receivedBase); err != nil {
+			return err
 		}
+		transaction.BaseAmount = base
 
 		if err := s.transactionRepo.Create(tx, transaction); err != nil {
 			return err
 		}
 
+		// Post the movement to the ledger: debit the sender the amount and
+		// the fee, credit the receiver
+		postings, err := s.ledgerService.MovementPostings(tx, senderWallet.ID, receiverWallet.ID, amount, transaction.ReceivedAmount())
+		if err != nil {
+			return err
+		}
+		postings, err = s.ledgerService.AddFee(tx, postings, fee.Fee, senderWallet.Currency)
+		if err != nil {
+			return err
+		}
+		entry := &models.JournalEntry{
+			TransactionID: &transaction.ID,
+			Description:   "transfer",
+			Postings:      postings,
+		}
+		if err := s.ledgerService.Post(tx, entry); err != nil {
+			return err
+		}
+
+		err = s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionTransferSucceeded,
+			TargetType: models.AuditTargetTransaction,
+			TargetID:   transaction.ID.String(),
+		}, map[string]interface{}{"receiver_id": receiverID, "amount": amount, "currency": transaction.Currency, "fee": fee.Fee, "fx_quote_id": quoteID})
+		if err != nil {
+			return err
+		}
+
+		err = s.outboxService.Publish(tx, models.EventTransferCompleted, models.AggregateTransaction, transaction.ID, models.TransferData{
+			TransactionID:     transaction.ID,
+			SenderID:          senderID,
+			ReceiverID:        receiverID,
+			Amount:            amount,
+			Currency:          transaction.Currency,
+			ConvertedAmount:   transaction.ConvertedAmount,
+			ConvertedCurrency: transaction.ConvertedCurrency,
+			Fee:               fee.Fee,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = s.notifyTransfer(tx, transaction, senderWallet.ID, *entry.Postings[0].BalanceAfter, receiverWallet.ID, *entry.Postings[1].BalanceAfter)
+		if err != nil {
+			return err
+		}
+
+		if then != nil {
+			return then(tx, transaction)
+		}
 		return nil
 	})
 
 	if err != nil {
-		// Create failed transaction record
-		failedTransaction := &models.Transaction{
-			SenderID:   &senderID,
-			ReceiverID: receiverID,
-			Amount:     amount,
-			Type:       models.TransactionTypeTransfer,
-			Status:     models.TransactionStatusFailed,
+		return nil, err
+	}
+
+	return transaction, nil
+}
+
+// notifyTransfer pushes the new balances to both parties and tells the
+// receiver about the incoming money, in the receiver's currency.
+func (s *transactionService) notifyTransfer(tx *gorm.DB, transaction *models.Transaction, senderWalletID uuid.UUID, senderBalance models.Money, receiverWalletID uuid.UUID, receiverBalance models.Money) error {
+	received := transaction.ReceivedAmount()
+	receivedCurrency := transaction.ReceivedCurrency()
+	notifications := []*models.WalletNotification{
+		{
+			UserID:        *transaction.SenderID,
+			Type:          models.NotificationBalanceChanged,
+			WalletID:      &senderWalletID,
+			Balance:       &senderBalance,
+			Currency:      &transaction.Currency,
+			TransactionID: &transaction.ID,
+		},
+		{
+			UserID:        *transaction.ReceiverID,
+			Type:          models.NotificationBalanceChanged,
+			WalletID:      &receiverWalletID,
+			Balance:       &receiverBalance,
+			Currency:      &receivedCurrency,
+			TransactionID: &transaction.ID,
+		},
+		{
+			UserID:        *transaction.ReceiverID,
+			Type:          models.NotificationTransferReceived,
+			WalletID:      &receiverWalletID,
+			TransactionID: &transaction.ID,
+			Amount:        &received,
+			Currency:      &receivedCurrency,
+			SenderID:      transaction.SenderID,
+		},
+	}
+	for _, n := range notifications {
+		if err := s.notificationService.Publish(tx, n); err != nil {
+			return err
 		}
-		s.transactionRepo.Create(nil, failedTransaction)
+	}
+	return nil
+}
 
+// Reverse moves money of a successful transfer back from its receiver to its
+// sender, on behalf of an admin. A nil amount reverses whatever is left of the
+// transfer. Frozen wallets do not block a reversal, closed wallets do.
+func (s *transactionService) Reverse(meta models.RequestMeta, actorID, transactionID uuid.UUID, amount *models.Money, reason string) (*models.Transaction, error) {
+	if reason = strings.TrimSpace(reason); reason == "" {
+		return nil, ErrReversalReasonRequired
+	}
+
+	original, err := s.transactionRepo.FindByID(transactionID)
+	if err != nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	return s.reverse(meta, &actorID, original, models.TransactionTypeReversal, amount, reason, func(debit, credit *models.Wallet) error {
+		if debit.Status == models.WalletStatusClosed || credit.Status == models.WalletStatusClosed {
+			return ErrWalletClosed
+		}
+		return nil
+	})
+}
+
+// Refund lets the receiver of a transfer send all or part of it back to the
+// sender within RefundWindow. It is authorized by the receiver's PIN.
+func (s *transactionService) Refund(meta models.RequestMeta, userID, transactionID uuid.UUID, amount *models.Money, pin, reason string) (*models.Transaction, error) {
+	if err := s.pinService.Verify(userID, pin); err != nil {
+		return nil, err
+	}
+
+	original, err := s.transactionRepo.FindByID(transactionID)
+	if err != nil {
+		return nil, ErrTransactionNotFound
+	}
+	isSender := original.SenderID != nil && *original.SenderID == userID
+	if !isSender && !original.IsReceiver(userID) {
+		return nil, ErrTransactionNotFound
+	}
+	if !original.IsReceiver(userID) {
+		return nil, ErrRefundNotAllowed
+	}
+	if time.Since(original.CreatedAt) > RefundWindow {
+		return nil, ErrRefundWindowExpired
+	}
+
+	return s.reverse(meta, nil, original, models.TransactionTypeRefund, amount, strings.TrimSpace(reason), func(debit, credit *models.Wallet) error {
+		if err := checkDebit(debit); err != nil {
+			return err
+		}
+		if err := checkCredit(credit); err != nil {
+			return ErrReceiverWalletUnavailable
+		}
+		return nil
+	})
+}
+
+// reverse creates a transaction of type txType linked to the original
+// transfer and moves the amount back in the same database transaction. The
+// amount is in the currency the receiver got; a converted transfer is
+// converted back at its original rate. The original row is locked while the
+// already reversed total is summed, so concurrent reversals cannot together
+// exceed the original amount. checkWallets decides whether the locked
+// wallets may take part.
+func (s *transactionService) reverse(meta models.RequestMeta, actorID *uuid.UUID, original *models.Transaction, txType models.TransactionType, amount *models.Money, reason string, checkWallets func(debit, credit *models.Wallet) error) (*models.Transaction, error) {
+	if original.Type != models.TransactionTypeTransfer || original.Status != models.TransactionStatusSuccess || original.SenderID == nil || original.ReceiverID == nil {
+		return nil, ErrTransactionNotReversible
+	}
+	if amount != nil && *amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	// The receiver of the original transfer pays the money back to its
+	// sender, between the same wallets the transfer used
+	senderID := *original.ReceiverID
+	receiverID := *original.SenderID
+
+	senderWallet, err := s.transferWallet(senderID, original.ReceiverWalletID)
+	if err != nil {
+		return nil, ErrSenderWalletNotFound
+	}
+	receiverWallet, err := s.transferWallet(receiverID, original.SenderWalletID)
+	if err != nil {
+		return nil, ErrReceiverWalletNotFound
+	}
+
+	var transaction *models.Transaction
+
+	walletIDs := []uuid.UUID{senderWallet.ID, receiverWallet.ID}
+	err = s.walletLocker.WithWallets(walletIDs, func(tx *gorm.DB, wallets LockedWallets) error {
+		senderWallet := wallets[senderWallet.ID]
+		receiverWallet := wallets[receiverWallet.ID]
+
+		if err := checkWallets(senderWallet, receiverWallet); err != nil {
+			return err
+		}
+
+		if _, err := s.transactionRepo.FindByIDForUpdate(tx, original.ID); err != nil {
+			return err
+		}
+		taken, returned, err := s.transactionRepo.SumReversed(tx, original.ID)
+		if err != nil {
+			return err
+		}
+		remaining := original.ReceivedAmount() - taken
+		if remaining <= 0 {
+			return ErrAlreadyReversed
+		}
+
+		value := remaining
+		if amount != nil {
+			if *amount > remaining {
+				return ErrReversalAmountExceeded
+			}
+			value = *amount
+		}
+
+		if senderWallet.Balance < value {
+			return ErrInsufficientBalance
+		}
+
+		transaction = &models.Transaction{
+			SenderID:         &senderID,
+			SenderWalletID:   &senderWallet.ID,
+			ReceiverID:       &receiverID,
+			ReceiverWalletID: &receiverWallet.ID,
+			Amount:           value,
+			Currency:         original.ReceivedCurrency(),
+			Type:             txType,
+			Status:           models.TransactionStatusSuccess,
+			ReversalOf:       &original.ID,
+		}
+		if original.FXRate != nil {
+			// The last reversal returns exactly what is left, so rounding
+			// never adds up to more or less than the original amount
+			rate := original.FXRate.Inverse()
+			back := original.Amount - returned
+			if value != remaining {
+				converted, err := rate.Convert(value)
+				if err != nil {
+					return err
+				}
+				if converted < back {
+					back = converted
+				}
+			}
+			if back <= 0 {
+				return ErrInvalidAmount.withMessage("amount is too small to convert back")
+			}
+			transaction.ConvertedAmount = &back
+			transaction.ConvertedCurrency = &original.Currency
+			transaction.FXRate = &rate
+		}
+		if transaction.BaseAmount, err = s.fxService.ToBase(value, transaction.Currency); err != nil {
+			return err
+		}
+		if err := s.transactionRepo.Create(tx, transaction); err != nil {
+			return err
+		}
+
+		postings, err := s.ledgerService.MovementPostings(tx, senderWallet.ID, receiverWallet.ID, value, transaction.ReceivedAmount())
+		if err != nil {
+			return err
+		}
+		entry := &models.JournalEntry{
+			TransactionID: &transaction.ID,
+			Description:   string(txType),
+			Postings:      postings,
+		}
+		if err := s.ledgerService.Post(tx, entry); err != nil {
+			return err
+		}
+
+		action := models.AuditActionTransferRefunded
+		if txType == models.TransactionTypeReversal {
+			action = models.AuditActionTransferReversed
+		}
+		err = s.auditService.Record(tx, meta, &models.AuditEvent{
+			ActorID:    actorID,
+			Action:     action,
+			TargetType: models.AuditTargetTransaction,
+			TargetID:   transaction.ID.String(),
+		}, map[string]interface{}{"original_transaction_id": original.ID, "amount": value, "currency": transaction.Currency, "reason": reason})
+		if err != nil {
+			return err
+		}
+
+		err = s.outboxService.Publish(tx, models.EventTransferReversed, models.AggregateTransaction, transaction.ID, models.TransferReversedData{
+			TransactionID:         transaction.ID,
+			OriginalTransactionID: original.ID,
+			Type:                  txType,
+			SenderID:              senderID,
+			ReceiverID:            receiverID,
+			Amount:                value,
+			Currency:              transaction.Currency,
+			ConvertedAmount:       transaction.ConvertedAmount,
+			ConvertedCurrency:     transaction.ConvertedCurrency,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.notifyTransfer(tx, transaction, senderWallet.ID, *entry.Postings[0].BalanceAfter, receiverWallet.ID, *entry.Postings[1].BalanceAfter)
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
 	return transaction, nil
 }
 
-func (s *transactionService) GetHistory(userID uuid.UUID, limit int) ([]models.Transaction, error) {
-	transactions, err := s.transactionRepo.FindByUserID(userID, limit)
+// transferWallet returns the wallet a recorded transaction used, or the
+// user's primary wallet if the transaction does not name one.
+func (s *transactionService) transferWallet(userID uuid.UUID, walletID *uuid.UUID) (*models.Wallet, error) {
+	if walletID != nil {
+		return s.walletRepo.FindByID(nil, *walletID)
+	}
+	return s.walletRepo.FindPrimary(userID)
+}
+
+// recordFailedTransfer stores a failed attempt, and its audit event,
+// outside the rolled-back transaction so the failure and its reason survive.
+// The attempt belongs to the sender alone: the receiver is kept as the
+// attempted receiver, so it stays out of the receiver's history.
+// The amount is recorded in the currency of the wallet the sender chose,
+// or the default currency if that wallet does not exist.
+func (s *transactionService) recordFailedTransfer(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, cause error) {
+	reason := failureReason(cause)
+	currency := models.DefaultCurrency
+	if wallet, err := findWallet(s.walletRepo, senderID, walletID); err == nil {
+		currency = wallet.Currency
+	}
+	failedTransaction := &models.Transaction{
+		SenderID:            &senderID,
+		AttemptedReceiverID: &receiverID,
+		Amount:              amount,
+		Currency:            currency,
+		Type:                models.TransactionTypeTransfer,
+		Status:              models.TransactionStatusFailed,
+		FailureReason:       &reason,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.transactionRepo.Create(tx, failedTransaction); err != nil {
+			return err
+		}
+
+		err := s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionTransferFailed,
+			TargetType: models.AuditTargetTransaction,
+			TargetID:   failedTransaction.ID.String(),
+		}, map[string]interface{}{"receiver_id": receiverID, "amount": amount, "reason": reason})
+		if err != nil {
+			return err
+		}
+
+		return s.outboxService.Publish(tx, models.EventTransferFailed, models.AggregateTransaction, failedTransaction.ID, models.TransferData{
+			TransactionID: failedTransaction.ID,
+			SenderID:      senderID,
+			ReceiverID:    receiverID,
+			Amount:        amount,
+			Currency:      currency,
+			FailureReason: &reason,
+		})
+	})
 	if err != nil {
+		log.Printf("failed to record failed transfer from %s to %s (%s): %v", senderID, receiverID, reason, err)
+	}
+}
+
+// failureReason maps an error to the code stored on failed transactions.
+func failureReason(err error) string {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Code
+	}
+	return models.FailureReasonInternalError
+}
+
+// GetHistory returns one page of the user's transaction history. The limit
+// defaults to DefaultHistoryLimit and is capped at MaxHistoryLimit.
+func (s *transactionService) GetHistory(query repository.TransactionQuery) (*models.TransactionPage, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultHistoryLimit
+	}
+	if query.Limit > MaxHistoryLimit {
+		query.Limit = MaxHistoryLimit
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	pageSize := query.Limit
+	query.Limit++
+
+	transactions, err := s.transactionRepo.Find(query)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TransactionPage{Transactions: transactions}
+	if len(transactions) > pageSize {
+		page.Transactions = transactions[:pageSize]
+		page.NextCursor = repository.CursorAfter(page.Transactions[pageSize-1]).Encode()
+	}
+	if page.Transactions == nil {
+		page.Transactions = []models.Transaction{}
+	}
+	return page, nil
+}
+
+// GetDetail returns a transaction as seen by the user. Only the sender or the
+// receiver may see it; anyone else gets ErrTransactionNotFound.
+func (s *transactionService) GetDetail(userID, transactionID uuid.UUID) (*models.TransactionDetailResponse, error) {
+	transaction, err := s.transactionRepo.FindByID(transactionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransactionNotFound
+		}
 		return nil, err
 	}
-	return transactions, nil
+
+	isSender := transaction.SenderID != nil && *transaction.SenderID == userID
+	if !isSender && !transaction.IsReceiver(userID) {
+		return nil, ErrTransactionNotFound
+	}
+
+	var balanceAfter *models.Money
+	if transaction.Status == models.TransactionStatusSuccess {
+		// A move touches two of the user's wallets; report the source one
+		walletID := transaction.ReceiverWalletID
+		if isSender {
+			walletID = transaction.SenderWalletID
+		}
+		wallet, err := s.transferWallet(userID, walletID)
+		if err != nil {
+			return nil, err
+		}
+		balanceAfter, err = s.ledgerService.WalletBalanceAfter(transaction.ID, wallet.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	detail := transaction.ToDetailResponse(userID, balanceAfter)
+	return &detail, nil
 }
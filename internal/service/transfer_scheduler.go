@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultSchedulerPollInterval = 10 * time.Second
+	DefaultSchedulerBatchSize    = 20
+	DefaultSchedulerMaxFailures  = 3
+
+	schedulerRetryBaseDelay = time.Minute
+	schedulerRetryMaxDelay  = time.Hour
+
+	// How long a claimed batch is left to one scheduler before other
+	// schedulers may claim its transfers again
+	schedulerClaimLease = 10 * time.Minute
+)
+
+// TransferScheduler makes the transfers of due scheduled transfers through
+// TransactionService, so they get the same checks, limits and records as
+// transfers made by hand. A failed run is retried with exponential backoff;
+// after maxFailures failures in a row the occurrence is skipped. Several
+// schedulers may run against the same database: each leases its batch with
+// FOR UPDATE SKIP LOCKED and runs it outside any database transaction.
+type TransferScheduler struct {
+	scheduledTransferRepo repository.ScheduledTransferRepository
+	transactionService    TransactionService
+	db                    *gorm.DB
+	pollInterval          time.Duration
+	batchSize             int
+	maxFailures           int
+}
+
+func NewTransferScheduler(
+	scheduledTransferRepo repository.ScheduledTransferRepository,
+	transactionService TransactionService,
+	db *gorm.DB,
+	pollInterval time.Duration,
+	batchSize int,
+	maxFailures int,
+) *TransferScheduler {
+	if pollInterval <= 0 {
+		pollInterval = DefaultSchedulerPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultSchedulerBatchSize
+	}
+	if maxFailures <= 0 {
+		maxFailures = DefaultSchedulerMaxFailures
+	}
+	return &TransferScheduler{
+		scheduledTransferRepo: scheduledTransferRepo,
+		transactionService:    transactionService,
+		db:                    db,
+		pollInterval:          pollInterval,
+		batchSize:             batchSize,
+		maxFailures:           maxFailures,
+	}
+}
+
+// Run makes due transfers until ctx is cancelled. A full batch is followed
+// immediately by the next one; otherwise the scheduler waits for the poll
+// interval.
+func (s *TransferScheduler) Run(ctx context.Context) {
+	for {
+		n, err := s.RunBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("transfer scheduler: %v", err)
+		}
+		if n == s.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.pollInterval):
+		}
+	}
+}
+
+// RunBatch leases one batch of due scheduled transfers, runs each and
+// records each outcome as it comes. No rows stay locked while the transfers
+// run. It returns the number of transfers claimed.
+func (s *TransferScheduler) RunBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := s.scheduledTransferRepo.ClaimDue(s.db.WithContext(ctx), now, now.Add(schedulerClaimLease), s.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		// Transfers left unrun are claimed again when their lease runs out
+		if err := ctx.Err(); err != nil {
+			return len(due), nil
+		}
+		if err := s.runOne(ctx, &due[i]); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// runOne makes the transfer for the current occurrence of a claimed
+// schedule and records the outcome. The success run is written in the
+// transfer's own database transaction, so an occurrence that was paid is
+// never paid again, even if the outcome is lost afterwards.
+func (s *TransferScheduler) runOne(ctx context.Context, claimed *models.ScheduledTransfer) error {
+	occurrence := claimed.OccurrenceAt(claimed.RunCount)
+	attempt := claimed.ConsecutiveFailures + 1
+
+	paid, err := s.scheduledTransferRepo.HasSucceeded(s.db.WithContext(ctx), claimed.ID, occurrence)
+	if err != nil {
+		return err
+	}
+	if paid {
+		return s.record(ctx, claimed, func(tx *gorm.DB, schedule *models.ScheduledTransfer) error {
+			s.advance(schedule, false)
+			return nil
+		})
+	}
+
+	meta := models.RequestMeta{ActorID: &claimed.UserID, RequestID: "scheduled-transfer:" + claimed.ID.String()}
+	_, transferErr := s.transactionService.TransferAuthorized(meta, claimed.UserID, claimed.WalletID, claimed.ReceiverID, claimed.Amount, func(transferTx *gorm.DB, transaction *models.Transaction) error {
+		return s.scheduledTransferRepo.CreateRun(transferTx, &models.ScheduledTransferRun{
+			ScheduledTransferID: claimed.ID,
+			ScheduledFor:        occurrence,
+			Attempt:             attempt,
+			Status:              models.ScheduledTransferRunSuccess,
+			TransactionID:       &transaction.ID,
+		})
+	})
+
+	return s.record(ctx, claimed, func(tx *gorm.DB, schedule *models.ScheduledTransfer) error {
+		now := time.Now()
+		schedule.LastRunAt = &now
+		if transferErr == nil {
+			s.advance(schedule, false)
+			return nil
+		}
+
+		reason := failureReason(transferErr)
+		skip := attempt >= s.maxFailures
+		run := &models.ScheduledTransferRun{
+			ScheduledTransferID: schedule.ID,
+			ScheduledFor:        occurrence,
+			Attempt:             attempt,
+			Status:              models.ScheduledTransferRunFailed,
+			FailureReason:       &reason,
+			Skipped:             skip,
+		}
+		if err := s.scheduledTransferRepo.CreateRun(tx, run); err != nil {
+			return err
+		}
+
+		if skip {
+			log.Printf("transfer scheduler: skipping occurrence %s of %s after %d failures (%s)", occurrence.Format(time.RFC3339), schedule.ID, attempt, reason)
+			s.advance(schedule, true)
+		} else {
+			retryAt := now.Add(retryDelay(attempt, schedulerRetryBaseDelay, schedulerRetryMaxDelay))
+			schedule.ConsecutiveFailures = attempt
+			schedule.NextRunAt = &retryAt
+		}
+		return nil
+	})
+}
+
+// record locks the claimed schedule again in a new transaction, applies the
+// outcome of the run to it and ends the lease. Nothing is recorded if the
+// lease ran out and another scheduler claimed the schedule. A schedule the
+// user cancelled or ended while it ran only loses its lease.
+func (s *TransferScheduler) record(ctx context.Context, claimed *models.ScheduledTransfer, apply func(tx *gorm.DB, schedule *models.ScheduledTransfer) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		schedule, err := s.scheduledTransferRepo.FindByIDForUpdate(tx, claimed.UserID, claimed.ID)
+		if err != nil {
+			return err
+		}
+		if schedule.LeasedUntil == nil || !schedule.LeasedUntil.Equal(*claimed.LeasedUntil) {
+			return nil
+		}
+
+		schedule.LeasedUntil = nil
+		if schedule.Status == models.ScheduledTransferActive && schedule.RunCount == claimed.RunCount {
+			if err := apply(tx, schedule); err != nil {
+				return err
+			}
+		}
+		return s.scheduledTransferRepo.Update(tx, schedule)
+	})
+}
+
+// advance moves the schedule past its current occurrence and ends it when
+// there is no next one. A one-time transfer whose occurrence was skipped
+// ends as failed.
+func (s *TransferScheduler) advance(schedule *models.ScheduledTransfer, skipped bool) {
+	schedule.RunCount++
+	schedule.ConsecutiveFailures = 0
+
+	next, ok := schedule.NextOccurrence(schedule.RunCount)
+	if ok {
+		schedule.NextRunAt = &next
+		return
+	}
+
+	schedule.NextRunAt = nil
+	schedule.Status = models.ScheduledTransferCompleted
+	if skipped && schedule.Frequency == models.ScheduleOnce {
+		schedule.Status = models.ScheduledTransferFailed
+	}
+}
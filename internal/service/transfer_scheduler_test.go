@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryScheduleRepo keeps scheduled transfers and their runs in memory,
+// claiming them the way scheduledTransferRepository does.
+type memoryScheduleRepo struct {
+	repository.ScheduledTransferRepository
+
+	mu        sync.Mutex
+	schedules map[uuid.UUID]*models.ScheduledTransfer
+	runs      []models.ScheduledTransferRun
+}
+
+func newMemoryScheduleRepo(schedules ...models.ScheduledTransfer) *memoryScheduleRepo {
+	r := &memoryScheduleRepo{schedules: make(map[uuid.UUID]*models.ScheduledTransfer)}
+	for i := range schedules {
+		s := schedules[i]
+		r.schedules[s.ID] = &s
+	}
+	return r
+}
+
+func (r *memoryScheduleRepo) ClaimDue(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.ScheduledTransfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var due []models.ScheduledTransfer
+	for _, s := range r.schedules {
+		if len(due) == limit {
+			break
+		}
+		leased := s.LeasedUntil != nil && s.LeasedUntil.After(now)
+		if s.Status == models.ScheduledTransferActive && !s.NextRunAt.After(now) && !leased {
+			lease := leaseUntil
+			s.LeasedUntil = &lease
+			due = append(due, *s)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryScheduleRepo) FindByIDForUpdate(tx *gorm.DB, userID, id uuid.UUID) (*models.ScheduledTransfer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.schedules[id]
+	if !ok || s.UserID != userID {
+		return nil, errors.New("scheduled transfer not found")
+	}
+	copied := *s
+	return &copied, nil
+}
+
+func (r *memoryScheduleRepo) Update(tx *gorm.DB, schedule *models.ScheduledTransfer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := *schedule
+	r.schedules[s.ID] = &s
+	return nil
+}
+
+func (r *memoryScheduleRepo) CreateRun(tx *gorm.DB, run *models.ScheduledTransferRun) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.runs = append(r.runs, *run)
+	return nil
+}
+
+func (r *memoryScheduleRepo) HasSucceeded(tx *gorm.DB, scheduleID uuid.UUID, scheduledFor time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, run := range r.runs {
+		if run.ScheduledTransferID == scheduleID && run.ScheduledFor.Equal(scheduledFor) && run.Status == models.ScheduledTransferRunSuccess {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *memoryScheduleRepo) get(id uuid.UUID) models.ScheduledTransfer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.schedules[id]
+}
+
+// stubTransfers answers transfers with err, after running onTransfer. A
+// transfer that succeeds runs its hook.
+type stubTransfers struct {
+	TransactionService
+	db         *gorm.DB
+	err        error
+	onTransfer func()
+	transfers  int
+}
+
+func (s *stubTransfers) TransferAuthorized(meta models.RequestMeta, senderID uuid.UUID, walletID *uuid.UUID, receiverID uuid.UUID, amount models.Money, then TransferHook) (*models.Transaction, error) {
+	s.transfers++
+	if s.onTransfer != nil {
+		s.onTransfer()
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	transaction := &models.Transaction{ID: uuid.New(), Amount: amount, Status: models.TransactionStatusSuccess}
+	if err := then(s.db, transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+func newTestSchedule() models.ScheduledTransfer {
+	start := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	return models.ScheduledTransfer{
+		ID:         uuid.New(),
+		UserID:     uuid.New(),
+		ReceiverID: uuid.New(),
+		Amount:     models.NewMoney(100),
+		Frequency:  models.ScheduleDaily,
+		StartAt:    start,
+		Status:     models.ScheduledTransferActive,
+		NextRunAt:  &start,
+	}
+}
+
+func TestTransferSchedulerRecordsEachOutcomeAndEndsTheLease(t *testing.T) {
+	schedule := newTestSchedule()
+	repo := newMemoryScheduleRepo(schedule)
+	db := testDB(t)
+	transfers := &stubTransfers{db: db, err: ErrInsufficientBalance}
+	scheduler := NewTransferScheduler(repo, transfers, db, time.Second, 10, 3)
+
+	if n, err := scheduler.RunBatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RunBatch() = %d, %v, want 1 claimed", n, err)
+	}
+	stored := repo.get(schedule.ID)
+	if stored.LeasedUntil != nil || stored.ConsecutiveFailures != 1 || !stored.NextRunAt.After(time.Now()) {
+		t.Errorf("schedule = %+v, want one failure retried later without a lease", stored)
+	}
+	if len(repo.runs) != 1 || repo.runs[0].Status != models.ScheduledTransferRunFailed {
+		t.Fatalf("runs = %+v, want one failed run", repo.runs)
+	}
+
+	// The retry succeeds and moves on to the next day
+	transfers.err = nil
+	repo.schedules[schedule.ID].NextRunAt = &schedule.StartAt
+	if n, err := scheduler.RunBatch(context.Background()); err != nil || n != 1 {
+		t.Fatalf("RunBatch() = %d, %v, want 1 claimed", n, err)
+	}
+	stored = repo.get(schedule.ID)
+	if stored.LeasedUntil != nil || stored.RunCount != 1 || stored.ConsecutiveFailures != 0 || !stored.NextRunAt.Equal(schedule.OccurrenceAt(1)) {
+		t.Errorf("schedule = %+v, want the next occurrence without a lease", stored)
+	}
+	if len(repo.runs) != 2 || repo.runs[1].Status != models.ScheduledTransferRunSuccess || repo.runs[1].Attempt != 2 {
+		t.Errorf("runs = %+v, want the second attempt to succeed", repo.runs)
+	}
+}
+
+func TestTransferSchedulerKeepsChangesMadeWhileRunning(t *testing.T) {
+	schedule := newTestSchedule()
+	repo := newMemoryScheduleRepo(schedule)
+	db := testDB(t)
+	transfers := &stubTransfers{db: db}
+	// The user cancels the schedule while its transfer runs
+	transfers.onTransfer = func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+		repo.schedules[schedule.ID].Status = models.ScheduledTransferCancelled
+		repo.schedules[schedule.ID].NextRunAt = nil
+	}
+	scheduler := NewTransferScheduler(repo, transfers, db, time.Second, 10, 3)
+
+	if _, err := scheduler.RunBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stored := repo.get(schedule.ID)
+	if stored.Status != models.ScheduledTransferCancelled || stored.NextRunAt != nil || stored.LeasedUntil != nil {
+		t.Errorf("schedule = %+v, want it cancelled without a lease", stored)
+	}
+}
+
+func TestTransferSchedulerDoesNotRunLeasedSchedules(t *testing.T) {
+	schedule := newTestSchedule()
+	repo := newMemoryScheduleRepo(schedule)
+	db := testDB(t)
+	transfers := &stubTransfers{db: db}
+	scheduler := NewTransferScheduler(repo, transfers, db, time.Second, 10, 3)
+
+	// Another scheduler holds the lease until it records its own outcome
+	if _, err := repo.ClaimDue(nil, time.Now(), time.Now().Add(schedulerClaimLease), 10); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := scheduler.RunBatch(context.Background())
+	if err != nil || n != 0 || transfers.transfers != 0 {
+		t.Fatalf("RunBatch() = %d, %v with %d transfers, want nothing claimed", n, err, transfers.transfers)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+const (
+	// Postgres SQLSTATE codes that mean the transaction can simply be retried
+	pgDeadlockDetected     = "40P01"
+	pgSerializationFailure = "40001"
+
+	defaultLockAttempts = 3
+	lockRetryBaseDelay  = 20 * time.Millisecond
+)
+
+// LockedWallets maps wallet IDs to the wallet rows locked for the current
+// database transaction.
+type LockedWallets map[uuid.UUID]*models.Wallet
+
+// WalletLocker runs work that needs one or more wallet rows locked. Wallets
+// are always locked in ascending ID order, so two operations touching the
+// same wallets can never wait on each other in a cycle. If Postgres still
+// aborts the transaction with a deadlock or serialization failure (for
+// example because of locks taken outside the locker), the whole transaction
+// is retried with a short jittered backoff.
+type WalletLocker interface {
+	WithWallets(walletIDs []uuid.UUID, fn func(tx *gorm.DB, wallets LockedWallets) error) error
+}
+
+type walletLocker struct {
+	walletRepo  repository.WalletRepository
+	db          *gorm.DB
+	maxAttempts int
+}
+
+func NewWalletLocker(walletRepo repository.WalletRepository, db *gorm.DB) WalletLocker {
+	return &walletLocker{
+		walletRepo:  walletRepo,
+		db:          db,
+		maxAttempts: defaultLockAttempts,
+	}
+}
+
+// WithWallets opens a database transaction, locks the given wallets in a
+// deterministic order and calls fn. fn may be called more than once, so it
+// must not keep state from a previous attempt.
+func (l *walletLocker) WithWallets(walletIDs []uuid.UUID, fn func(tx *gorm.DB, wallets LockedWallets) error) error {
+	ordered := lockOrder(walletIDs)
+
+	var err error
+	for attempt := 1; attempt <= l.maxAttempts; attempt++ {
+		err = l.db.Transaction(func(tx *gorm.DB) error {
+			locked := make(LockedWallets, len(ordered))
+			for _, id := range ordered {
+				wallet, err := l.walletRepo.FindByIDForUpdate(tx, id)
+				if err != nil {
+					return err
+				}
+				locked[id] = wallet
+			}
+			return fn(tx, locked)
+		})
+
+		if err == nil || !isRetryableTxError(err) {
+			return err
+		}
+
+		log.Printf("wallet lock attempt %d/%d aborted, retrying: %v", attempt, l.maxAttempts, err)
+		time.Sleep(lockRetryBaseDelay*time.Duration(attempt) + time.Duration(rand.Int63n(int64(lockRetryBaseDelay))))
+	}
+	return err
+}
+
+// lockOrder removes duplicates and sorts IDs the same way Postgres orders UUIDs.
+func lockOrder(walletIDs []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(walletIDs))
+	ordered := make([]uuid.UUID, 0, len(walletIDs))
+	for _, id := range walletIDs {
+		if !seen[id] {
+			seen[id] = true
+			ordered = append(ordered, id)
+		}
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return bytes.Compare(ordered[i][:], ordered[j][:]) < 0
+	})
+	return ordered
+}
+
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgDeadlockDetected || pgErr.Code == pgSerializationFailure
+	}
+	return false
+}
@@ -1,94 +1,546 @@
 package service
 
 import (
-	"errors"
 	"ewallet/internal/models"
 	"ewallet/internal/repository"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
+const (
+	MaxWalletsPerUser   = 10
+	MaxWalletNameLength = 50
+)
+
+// WalletService manages a user's wallets. Methods that take an optional
+// walletID use the user's primary wallet when it is nil.
 type WalletService interface {
-	GetBalance(userID uuid.UUID) (*models.Wallet, error)
-	TopUp(userID uuid.UUID, amount float64) (*models.Wallet, error)
+	ListWallets(userID uuid.UUID) ([]models.Wallet, error)
+	CreateWallet(meta models.RequestMeta, userID uuid.UUID, name string, currency models.Currency) (*models.Wallet, error)
+	UpdateWallet(meta models.RequestMeta, userID, walletID uuid.UUID, update WalletUpdate) (*models.Wallet, error)
+	GetBalance(userID uuid.UUID, walletID *uuid.UUID) (*models.Wallet, error)
+	GetLimits(userID uuid.UUID) (*models.LimitsOverview, error)
+	Move(meta models.RequestMeta, userID, fromWalletID, toWalletID uuid.UUID, amount models.Money, quoteID *uuid.UUID) (*models.Transaction, error)
+	SetStatus(meta models.RequestMeta, actorID, userID uuid.UUID, walletID *uuid.UUID, status models.WalletStatus, reason string) (*models.Wallet, error)
+	StatusHistory(userID uuid.UUID, walletID *uuid.UUID) ([]models.WalletStatusChange, error)
+}
+
+// WalletUpdate changes the fields that are set. A wallet stops being primary
+// only when another one is made primary.
+type WalletUpdate struct {
+	Name        *string
+	MakePrimary bool
 }
 
 type walletService struct {
-	walletRepo      repository.WalletRepository
-	transactionRepo repository.TransactionRepository
-	db              *gorm.DB
+	walletRepo          repository.WalletRepository
+	transactionRepo     repository.TransactionRepository
+	ledgerService       LedgerService
+	walletLocker        WalletLocker
+	limitService        LimitService
+	fxService           FXService
+	auditService        AuditService
+	notificationService NotificationService
+	db                  *gorm.DB
 }
 
 func NewWalletService(
 	walletRepo repository.WalletRepository,
 	transactionRepo repository.TransactionRepository,
+	ledgerService LedgerService,
+	walletLocker WalletLocker,
+	limitService LimitService,
+	fxService FXService,
+	auditService AuditService,
+	notificationService NotificationService,
 	db *gorm.DB,
 ) WalletService {
 	return &walletService{
-		walletRepo:      walletRepo,
-		transactionRepo: transactionRepo,
-		db:              db,
+		walletRepo:          walletRepo,
+		transactionRepo:     transactionRepo,
+		ledgerService:       ledgerService,
+		walletLocker:        walletLocker,
+		limitService:        limitService,
+		fxService:           fxService,
+		auditService:        auditService,
+		notificationService: notificationService,
+		db:                  db,
 	}
 }
 
-func (s *walletService) GetBalance(userID uuid.UUID) (*models.Wallet, error) {
-	wallet, err := s.walletRepo.FindByUserID(userID)
+// ListWallets returns the user's wallets, the primary one first.
+func (s *walletService) ListWallets(userID uuid.UUID) ([]models.Wallet, error) {
+	wallets, err := s.walletRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if wallets == nil {
+		wallets = []models.Wallet{}
+	}
+	return wallets, nil
+}
+
+// CreateWallet opens another, non-primary wallet for the user together with
+// its ledger account. An empty currency opens it in
+// models.DefaultCurrency.
+func (s *walletService) CreateWallet(meta models.RequestMeta, userID uuid.UUID, name string, currency models.Currency) (*models.Wallet, error) {
+	name, err := normalizeWalletName(name)
+	if err != nil {
+		return nil, err
+	}
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+	if !s.fxService.Supports(currency) {
+		return nil, ErrUnsupportedCurrency.withMessage("currency %s is not supported", currency)
+	}
+
+	count, err := s.walletRepo.CountByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxWalletsPerUser {
+		return nil, ErrWalletLimitReached.withMessage("at most %d wallets are allowed", MaxWalletsPerUser)
+	}
+	if err := s.checkWalletName(userID, name, nil); err != nil {
+		return nil, err
+	}
+
+	wallet := &models.Wallet{
+		UserID:   userID,
+		Name:     name,
+		Currency: currency,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// A new wallet would not carry the freeze of the others
+		if err := checkUserDebit(tx, s.walletRepo, userID); err != nil {
+			return err
+		}
+		if err := s.walletRepo.Create(tx, wallet); err != nil {
+			return err
+		}
+		if _, err := s.ledgerService.OpenWalletAccount(tx, wallet); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionWalletCreated,
+			TargetType: models.AuditTargetWallet,
+			TargetID:   wallet.ID.String(),
+		}, map[string]interface{}{"name": wallet.Name, "currency": wallet.Currency})
+	})
 	if err != nil {
 		return nil, err
 	}
+
 	return wallet, nil
 }
 
-func (s *walletService) TopUp(userID uuid.UUID, amount float64) (*models.Wallet, error) {
-	// Validate amount
-	if amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
+// UpdateWallet renames the wallet or makes it the user's primary wallet.
+// All of the user's wallets are locked while the primary one changes, so
+// two concurrent changes cannot leave the user with two primary wallets.
+func (s *walletService) UpdateWallet(meta models.RequestMeta, userID, walletID uuid.UUID, update WalletUpdate) (*models.Wallet, error) {
+	current, err := s.walletRepo.FindOwned(userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	var name *string
+	if update.Name != nil {
+		normalized, err := normalizeWalletName(*update.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkWalletName(userID, normalized, &current.ID); err != nil {
+			return nil, err
+		}
+		name = &normalized
+	}
+
+	owned, err := s.walletRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	walletIDs := make([]uuid.UUID, len(owned))
+	for i := range owned {
+		walletIDs[i] = owned[i].ID
 	}
 
 	var wallet *models.Wallet
+	err = s.walletLocker.WithWallets(walletIDs, func(tx *gorm.DB, wallets LockedWallets) error {
+		w := wallets[walletID]
 
-	// Use transaction to ensure atomicity
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// Get wallet with lock
-		var w models.Wallet
-		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-			Where("user_id = ?", userID).
-			First(&w).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errors.New("wallet not found")
+		if name != nil && *name != w.Name {
+			w.Name = *name
+			if err := s.walletRepo.UpdateName(tx, w); err != nil {
+				return err
 			}
+		}
+
+		becamePrimary := false
+		if update.MakePrimary && !w.IsPrimary {
+			// The primary wallet receives transfers, so it must accept them,
+			// and it cannot change while any wallet is frozen
+			if err := checkCredit(w); err != nil {
+				return err
+			}
+			for _, owned := range wallets {
+				if owned.Status == models.WalletStatusFrozenDebit || owned.Status == models.WalletStatusFrozenAll {
+					return ErrWalletFrozen
+				}
+			}
+			if err := s.walletRepo.SetPrimary(tx, userID, w.ID); err != nil {
+				return err
+			}
+			w.IsPrimary = true
+			becamePrimary = true
+		}
+
+		wallet = w
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionWalletUpdated,
+			TargetType: models.AuditTargetWallet,
+			TargetID:   w.ID.String(),
+		}, map[string]interface{}{"name": w.Name, "made_primary": becamePrimary})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+func normalizeWalletName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ErrInvalidWalletName.withMessage("name is required")
+	}
+	if utf8.RuneCountInString(name) > MaxWalletNameLength {
+		return "", ErrInvalidWalletName.withMessage("name must be at most %d characters", MaxWalletNameLength)
+	}
+	return name, nil
+}
+
+// checkWalletName rejects a name the user already gave another wallet.
+func (s *walletService) checkWalletName(userID uuid.UUID, name string, exceptID *uuid.UUID) error {
+	taken, err := s.walletRepo.NameTaken(userID, name, exceptID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrWalletNameTaken
+	}
+	return nil
+}
+
+func (s *walletService) GetBalance(userID uuid.UUID, walletID *uuid.UUID) (*models.Wallet, error) {
+	wallet, err := findWallet(s.walletRepo, userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+	return wallet, nil
+}
+
+// GetLimits returns the user's effective limits and how much of them is used.
+func (s *walletService) GetLimits(userID uuid.UUID) (*models.LimitsOverview, error) {
+	limits, err := s.limitService.EffectiveLimits(nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.limitService.Usage(nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LimitsOverview{Limits: *limits, Usage: *usage}, nil
+}
+
+// Move moves money between two of the user's own wallets. It needs no PIN
+// and counts against no transfer limit, since the money stays with the
+// user, but the source wallet must allow debits and the destination
+// credits. Between wallets of different currencies it redeems the FX quote,
+// which must convert amount from the source to the destination currency.
+func (s *walletService) Move(meta models.RequestMeta, userID, fromWalletID, toWalletID uuid.UUID, amount models.Money, quoteID *uuid.UUID) (*models.Transaction, error) {
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if fromWalletID == toWalletID {
+		return nil, ErrSameWallet
+	}
+	if _, err := s.walletRepo.FindOwned(userID, fromWalletID); err != nil {
+		return nil, ErrWalletNotFound
+	}
+	if _, err := s.walletRepo.FindOwned(userID, toWalletID); err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	var transaction *models.Transaction
+	err := s.walletLocker.WithWallets([]uuid.UUID{fromWalletID, toWalletID}, func(tx *gorm.DB, wallets LockedWallets) error {
+		source := wallets[fromWalletID]
+		destination := wallets[toWalletID]
+
+		if err := checkDebit(source); err != nil {
 			return err
 		}
+		if err := checkCredit(destination); err != nil {
+			return err
+		}
+		if err := checkUserDebit(tx, s.walletRepo, userID); err != nil {
+			return err
+		}
+		if source.Balance < amount {
+			return ErrInsufficientBalance
+		}
 
-		// Update balance
-		newBalance := w.Balance + amount
-		if err := s.walletRepo.UpdateBalanceWithLock(tx, w.ID, newBalance); err != nil {
+		base, err := s.fxService.ToBase(amount, source.Currency)
+		if err != nil {
+			return err
+		}
+		transaction = &models.Transaction{
+			ID:               uuid.New(),
+			SenderID:         &userID,
+			SenderWalletID:   &source.ID,
+			ReceiverID:       &userID,
+			ReceiverWalletID: &destination.ID,
+			Amount:           amount,
+			Currency:         source.Currency,
+			BaseAmount:       base,
+			Type:             models.TransactionTypeMove,
+			Status:           models.TransactionStatusSuccess,
+		}
+		if err := convertWith(tx, s.fxService, userID, quoteID, transaction, destination.Currency); err != nil {
+			return err
+		}
+		if err := s.transactionRepo.Create(tx, transaction); err != nil {
 			return err
 		}
 
-		// Create transaction record
-		transaction := &models.Transaction{
-			ReceiverID: userID,
-			Amount:     amount,
-			Type:       models.TransactionTypeTopUp,
-			Status:     models.TransactionStatusSuccess,
+		postings, err := s.ledgerService.MovementPostings(tx, source.ID, destination.ID, amount, transaction.ReceivedAmount())
+		if err != nil {
+			return err
+		}
+		entry := &models.JournalEntry{
+			TransactionID: &transaction.ID,
+			Description:   "move",
+			Postings:      postings,
+		}
+		if err := s.ledgerService.Post(tx, entry); err != nil {
+			return err
 		}
+		source.Balance = *entry.Postings[0].BalanceAfter
+		destination.Balance = *entry.Postings[1].BalanceAfter
 
-		if err := s.transactionRepo.Create(tx, transaction); err != nil {
+		err = s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionWalletMoved,
+			TargetType: models.AuditTargetTransaction,
+			TargetID:   transaction.ID.String(),
+		}, map[string]interface{}{"from_wallet_id": source.ID, "to_wallet_id": destination.ID, "amount": amount, "currency": source.Currency, "fx_quote_id": quoteID})
+		if err != nil {
 			return err
 		}
 
-		// Get updated wallet
-		w.Balance = newBalance
-		wallet = &w
+		for _, w := range []*models.Wallet{source, destination} {
+			err := s.notificationService.Publish(tx, &models.WalletNotification{
+				UserID:        userID,
+				Type:          models.NotificationBalanceChanged,
+				WalletID:      &w.ID,
+				Balance:       &w.Balance,
+				Currency:      &w.Currency,
+				TransactionID: &transaction.ID,
+			})
+			if err != nil {
+				return err
+			}
+		}
 		return nil
 	})
-
 	if err != nil {
 		return nil, err
 	}
 
+	return transaction, nil
+}
+
+// SetStatus freezes, unfreezes or closes one of the user's wallets. The
+// change is made under the wallet's row lock, so it takes effect between two
+// money movements, and is recorded in the wallet's status history. Closing
+// needs a zero balance and is final.
+func (s *walletService) SetStatus(meta models.RequestMeta, actorID, userID uuid.UUID, walletID *uuid.UUID, status models.WalletStatus, reason string) (*models.Wallet, error) {
+	if !status.IsValid() {
+		return nil, ErrInvalidWalletStatus
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrWalletStatusReason
+	}
+
+	current, err := findWallet(s.walletRepo, userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	var wallet *models.Wallet
+	err = s.walletLocker.WithWallets([]uuid.UUID{current.ID}, func(tx *gorm.DB, wallets LockedWallets) error {
+		w := wallets[current.ID]
+
+		if w.Status == models.WalletStatusClosed {
+			return ErrWalletClosed
+		}
+		if status == models.WalletStatusClosed && w.Balance != 0 {
+			return ErrWalletNotEmpty
+		}
+
+		// Wait for movements that checked the user's other wallets, and
+		// keep new ones out until the change commits. The wallet row is
+		// locked first, as the movements lock theirs, so no one waits in a
+		// circle.
+		if err := s.walletRepo.LockStatuses(tx, w.UserID); err != nil {
+			return err
+		}
+
+		change := &models.WalletStatusChange{
+			WalletID:   w.ID,
+			FromStatus: w.Status,
+			ToStatus:   status,
+			Reason:     reason,
+			ActorID:    actorID,
+		}
+
+		now := time.Now()
+		w.Status = status
+		w.StatusReason = &reason
+		w.StatusChangedBy = &actorID
+		w.StatusChangedAt = &now
+		if err := s.walletRepo.UpdateStatus(tx, w); err != nil {
+			return err
+		}
+		if err := s.walletRepo.CreateStatusChange(tx, change); err != nil {
+			return err
+		}
+
+		wallet = w
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionWalletStatusChanged,
+			TargetType: models.AuditTargetWallet,
+			TargetID:   w.ID.String(),
+		}, map[string]interface{}{"from": change.FromStatus, "to": status, "reason": reason})
+	})
+	if err != nil {
+		return nil, err
+	}
 	return wallet, nil
 }
+
+func (s *walletService) StatusHistory(userID uuid.UUID, walletID *uuid.UUID) ([]models.WalletStatusChange, error) {
+	wallet, err := findWallet(s.walletRepo, userID, walletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+	changes, err := s.walletRepo.FindStatusChanges(wallet.ID)
+	if err != nil {
+		return nil, err
+	}
+	if changes == nil {
+		changes = []models.WalletStatusChange{}
+	}
+	return changes, nil
+}
+
+// findWallet returns the user's wallet with the given ID, or the user's
+// primary wallet when walletID is nil.
+func findWallet(walletRepo repository.WalletRepository, userID uuid.UUID, walletID *uuid.UUID) (*models.Wallet, error) {
+	if walletID == nil {
+		return walletRepo.FindPrimary(userID)
+	}
+	return walletRepo.FindOwned(userID, *walletID)
+}
+
+// convertWith prepares a transaction whose money enters a wallet in the
+// currency to. Between different currencies it redeems the sender's FX
+// quote for the transaction, whose ID must already be set, and records the
+// conversion; in a single currency there must be no quote.
+func convertWith(tx *gorm.DB, fxService FXService, senderID uuid.UUID, quoteID *uuid.UUID, transaction *models.Transaction, to models.Currency) error {
+	if transaction.Currency == to {
+		if quoteID != nil {
+			return ErrFXQuoteMismatch.withMessage("no conversion is needed between %s wallets", to)
+		}
+		return nil
+	}
+	if quoteID == nil {
+		return ErrCurrencyMismatch.withMessage("converting %s to %s needs an FX quote", transaction.Currency, to)
+	}
+
+	quote, err := fxService.Redeem(tx, senderID, *quoteID, transaction.ID, transaction.Currency, to, transaction.Amount)
+	if err != nil {
+		return err
+	}
+	transaction.ConvertedAmount = &quote.TargetAmount
+	transaction.ConvertedCurrency = &quote.ToCurrency
+	transaction.FXRate = &quote.Rate
+	transaction.FXQuoteID = &quote.ID
+	return nil
+}
+
+// checkUserDebit returns ErrWalletFrozen if any of the user's wallets is
+// frozen. A freeze applies to the user, not just the wallet it was set on:
+// otherwise the money could be moved to, or received in, another of the
+// user's wallets and spent from there. The user's status lock is shared
+// until tx ends, so no freeze can commit between the check and the
+// movement. Take it after the wallet row locks, as SetStatus does.
+func checkUserDebit(tx *gorm.DB, walletRepo repository.WalletRepository, userID uuid.UUID) error {
+	if err := walletRepo.ShareStatuses(tx, userID); err != nil {
+		return err
+	}
+	frozen, err := walletRepo.FindFrozen(tx, userID)
+	if err != nil {
+		return err
+	}
+	if len(frozen) > 0 {
+		return ErrWalletFrozen
+	}
+	return nil
+}
+
+// checkUserCredit returns ErrWalletFrozen if any of the user's wallets is
+// frozen for incoming money, which then applies to all of them. Like
+// checkUserDebit, it shares the user's status lock until tx ends.
+func checkUserCredit(tx *gorm.DB, walletRepo repository.WalletRepository, userID uuid.UUID) error {
+	if err := walletRepo.ShareStatuses(tx, userID); err != nil {
+		return err
+	}
+	frozen, err := walletRepo.FindFrozen(tx, userID)
+	if err != nil {
+		return err
+	}
+	for i := range frozen {
+		if !frozen[i].CanCredit() {
+			return ErrWalletFrozen
+		}
+	}
+	return nil
+}
+
+// checkDebit returns why money may not leave the wallet, if it may not.
+func checkDebit(w *models.Wallet) error {
+	if w.Status == models.WalletStatusClosed {
+		return ErrWalletClosed
+	}
+	if !w.CanDebit() {
+		return ErrWalletFrozen
+	}
+	return nil
+}
+
+// checkCredit returns why money may not enter the wallet, if it may not.
+func checkCredit(w *models.Wallet) error {
+	if w.Status == models.WalletStatusClosed {
+		return ErrWalletClosed
+	}
+	if !w.CanCredit() {
+		return ErrWalletFrozen
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errWebhookAddressBlocked is returned when a webhook endpoint resolves to
+// an address webhooks must not reach.
+var errWebhookAddressBlocked = errors.New("endpoint address is not allowed")
+
+// Networks webhooks must not reach beyond those the net.IP predicates cover
+var blockedWebhookNetworks = mustParseCIDRs(
+	"0.0.0.0/8",          // "this" network
+	"100.64.0.0/10",      // carrier-grade NAT
+	"192.0.0.0/24",       // IETF protocol assignments
+	"198.18.0.0/15",      // benchmarking
+	"240.0.0.0/4",        // reserved
+	"64:ff9b::/96",       // NAT64, which can map onto any IPv4 address
+	"64:ff9b:1::/48",     // local-use NAT64
+	"2002::/16",          // 6to4, which embeds an IPv4 address
+	"fec0::/10",          // deprecated site-local
+	"100::/64",           // discard-only
+	"2001:db8::/32",      // documentation
+	"255.255.255.255/32", // broadcast
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// webhookAddressAllowed reports whether webhooks may connect to ip. Private,
+// loopback, link-local and other non-public addresses are refused, so an
+// endpoint cannot be used to reach services inside the network; loopback
+// is allowed only with allowLoopback.
+func webhookAddressAllowed(ip net.IP, allowLoopback bool) bool {
+	if ip.IsLoopback() {
+		return allowLoopback
+	}
+	if ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range blockedWebhookNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// webhookHostAllowed rejects hosts that are known to be internal without
+// resolving them. The dialer checks every resolved address again, so this
+// only gives early feedback when an endpoint is registered.
+func webhookHostAllowed(host string, allowLoopback bool) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return allowLoopback
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return webhookAddressAllowed(ip, allowLoopback)
+	}
+	return true
+}
+
+// webhookDialer resolves the endpoint's host itself and connects only to
+// addresses webhookAddressAllowed accepts. Checking the addresses it
+// actually dials, rather than the URL, means DNS cannot point an endpoint
+// at an internal address after it was registered.
+type webhookDialer struct {
+	dialer        net.Dialer
+	resolver      *net.Resolver
+	allowLoopback bool
+}
+
+func (d *webhookDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := d.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	// Refuse the host if any of its addresses is internal, so a name that
+	// resolves both ways cannot be used to reach the internal one
+	for _, addr := range addrs {
+		if !webhookAddressAllowed(addr.IP, d.allowLoopback) {
+			return nil, errWebhookAddressBlocked
+		}
+	}
+
+	var lastErr error = errWebhookAddressBlocked
+	for _, addr := range addrs {
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// NewWebhookClient creates the client webhook deliveries are sent with. It
+// connects only to public addresses (see webhookDialer); allowLoopback also
+// permits loopback, for local receivers such as httptest servers. It ignores
+// proxy settings, which would bypass the address check, and does not follow
+// redirects, which count as failed deliveries.
+func NewWebhookClient(timeout time.Duration, allowLoopback bool) *http.Client {
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	dialer := &webhookDialer{
+		dialer:        net.Dialer{Timeout: timeout},
+		resolver:      net.DefaultResolver,
+		allowLoopback: allowLoopback,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
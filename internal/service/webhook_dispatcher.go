@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"ewallet/pkg/utils"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultWebhookMaxAttempts  = 6
+	DefaultWebhookPollInterval = time.Second
+	DefaultWebhookBatchSize    = 50
+	DefaultWebhookTimeout      = 10 * time.Second
+
+	webhookRetryBaseDelay = 10 * time.Second
+	webhookRetryMaxDelay  = time.Hour
+
+	// How long a claimed batch is left to one dispatcher before other
+	// dispatchers may claim its unsent deliveries again
+	webhookClaimLease = 15 * time.Minute
+
+	// How much of a response body is read so the connection can be reused
+	webhookDrainLimit = 64 << 10
+)
+
+// Headers sent with every webhook delivery
+const (
+	WebhookHeaderID        = "X-Webhook-ID"
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+// WebhookDispatcher sends queued webhook deliveries. Each request is signed
+// with the endpoint's secret (see utils.SignPayload). Any 2xx response
+// counts as delivered; anything else is retried with exponential backoff
+// until maxAttempts, after which the delivery is dead-lettered. Several
+// dispatchers may run against the same database: each leases its batch and
+// sends it outside any database transaction.
+type WebhookDispatcher struct {
+	webhookRepo  repository.WebhookRepository
+	db           *gorm.DB
+	client       *http.Client
+	maxAttempts  int
+	pollInterval time.Duration
+	batchSize    int
+}
+
+// NewWebhookDispatcher creates a dispatcher that sends with client, or with
+// NewWebhookClient(DefaultWebhookTimeout, false) if client is nil.
+func NewWebhookDispatcher(
+	webhookRepo repository.WebhookRepository,
+	db *gorm.DB,
+	client *http.Client,
+	maxAttempts int,
+	pollInterval time.Duration,
+	batchSize int,
+) *WebhookDispatcher {
+	if client == nil {
+		client = NewWebhookClient(DefaultWebhookTimeout, false)
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultWebhookMaxAttempts
+	}
+	if pollInterval <= 0 {
+		pollInterval = DefaultWebhookPollInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultWebhookBatchSize
+	}
+	return &WebhookDispatcher{
+		webhookRepo:  webhookRepo,
+		db:           db,
+		client:       client,
+		maxAttempts:  maxAttempts,
+		pollInterval: pollInterval,
+		batchSize:    batchSize,
+	}
+}
+
+// Run sends deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	for {
+		n, err := d.DispatchBatch(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("webhook dispatcher: %v", err)
+		}
+		if n == d.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.pollInterval):
+		}
+	}
+}
+
+// DispatchBatch leases one batch of due deliveries, sends each and records
+// each outcome as it comes. No rows stay locked while requests are sent. It
+// returns the number of deliveries claimed.
+func (d *WebhookDispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	due, err := d.webhookRepo.ClaimDueDeliveries(d.db.WithContext(ctx), now, now.Add(webhookClaimLease), d.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range due {
+		delivery := &due[i]
+		leaseUntil := delivery.NextAttemptAt
+		statusCode, err := d.send(ctx, delivery)
+
+		now := time.Now()
+		delivery.Attempts++
+		delivery.LastStatusCode = statusCode
+		delivery.UpdatedAt = now
+		switch {
+		case err == nil:
+			delivery.Status = models.WebhookDeliveryDelivered
+			delivery.DeliveredAt = &now
+			delivery.LastError = nil
+		case delivery.Attempts >= d.maxAttempts:
+			message := err.Error()
+			delivery.Status = models.WebhookDeliveryDead
+			delivery.LastError = &message
+		default:
+			message := err.Error()
+			delivery.LastError = &message
+			delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts, webhookRetryBaseDelay, webhookRetryMaxDelay))
+		}
+
+		// A delivery another dispatcher took over keeps that one's outcome
+		if _, err := d.webhookRepo.UpdateLeasedDelivery(d.db.WithContext(ctx), delivery, leaseUntil); err != nil {
+			return len(due), err
+		}
+	}
+	return len(due), nil
+}
+
+// send POSTs the delivery's payload to its endpoint. The status code is nil
+// if no response was received.
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) (*int, error) {
+	if delivery.Endpoint == nil {
+		return nil, errors.New("webhook endpoint not found")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Endpoint.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ewallet-webhooks/1.0")
+	req.Header.Set(WebhookHeaderID, delivery.ID.String())
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(WebhookHeaderSignature, utils.SignPayload(delivery.Endpoint.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// The body is never stored: last_error is shown to the endpoint's
+	// owner, who must not be able to read responses through the dispatcher
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, webhookDrainLimit))
+
+	statusCode := resp.StatusCode
+	if statusCode < 200 || statusCode > 299 {
+		return &statusCode, fmt.Errorf("endpoint responded with status %d", statusCode)
+	}
+	return &statusCode, nil
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"ewallet/pkg/utils"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryWebhookRepo keeps deliveries in memory, claiming and updating them
+// the way webhookRepository does.
+type memoryWebhookRepo struct {
+	repository.WebhookRepository
+
+	mu         sync.Mutex
+	deliveries map[uuid.UUID]*models.WebhookDelivery
+}
+
+func newMemoryWebhookRepo(deliveries ...models.WebhookDelivery) *memoryWebhookRepo {
+	r := &memoryWebhookRepo{deliveries: make(map[uuid.UUID]*models.WebhookDelivery)}
+	for i := range deliveries {
+		d := deliveries[i]
+		r.deliveries[d.ID] = &d
+	}
+	return r
+}
+
+func (r *memoryWebhookRepo) ClaimDueDeliveries(tx *gorm.DB, now, leaseUntil time.Time, limit int) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	leaseUntil = leaseUntil.Truncate(time.Microsecond)
+
+	var due []models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if len(due) == limit {
+			break
+		}
+		if d.Status == models.WebhookDeliveryPending && !d.NextAttemptAt.After(now) {
+			d.NextAttemptAt = leaseUntil
+			due = append(due, *d)
+		}
+	}
+	return due, nil
+}
+
+func (r *memoryWebhookRepo) UpdateLeasedDelivery(tx *gorm.DB, delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.deliveries[delivery.ID]
+	if !ok || stored.Status != models.WebhookDeliveryPending || !stored.NextAttemptAt.Equal(leaseUntil) {
+		return false, nil
+	}
+	d := *delivery
+	r.deliveries[d.ID] = &d
+	return true, nil
+}
+
+func (r *memoryWebhookRepo) get(id uuid.UUID) models.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.deliveries[id]
+}
+
+// receivedRequest is what a test receiver saw of a delivery.
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver starts a server that answers every request with status and
+// sends what it received on the returned channel.
+func newReceiver(t *testing.T, status int) (*httptest.Server, <-chan receivedRequest) {
+	t.Helper()
+	received := make(chan receivedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte("receiver response"))
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func newTestDelivery(url string, attempts int) models.WebhookDelivery {
+	payload, _ := json.Marshal(map[string]string{"type": models.EventTransferCompleted})
+	return models.WebhookDelivery{
+		ID:            uuid.New(),
+		EventID:       uuid.New(),
+		EventType:     models.EventTransferCompleted,
+		Payload:       payload,
+		Status:        models.WebhookDeliveryPending,
+		Attempts:      attempts,
+		NextAttemptAt: time.Now().Add(-time.Second),
+		Endpoint:      &models.WebhookEndpoint{ID: uuid.New(), URL: url, Secret: "whsec_test"},
+	}
+}
+
+func newTestDispatcher(t *testing.T, repo repository.WebhookRepository, maxAttempts int) *WebhookDispatcher {
+	return NewWebhookDispatcher(repo, testDB(t), NewWebhookClient(5*time.Second, true), maxAttempts, time.Second, 10)
+}
+
+func TestWebhookDispatcherSignsDeliveries(t *testing.T) {
+	server, received := newReceiver(t, http.StatusNoContent)
+	delivery := newTestDelivery(server.URL, 0)
+	repo := newMemoryWebhookRepo(delivery)
+
+	n, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("DispatchBatch() claimed %d, want 1", n)
+	}
+
+	req := <-received
+	if got := req.header.Get(WebhookHeaderID); got != delivery.ID.String() {
+		t.Errorf("%s = %q, want %q", WebhookHeaderID, got, delivery.ID)
+	}
+	if got := req.header.Get(WebhookHeaderEvent); got != delivery.EventType {
+		t.Errorf("%s = %q, want %q", WebhookHeaderEvent, got, delivery.EventType)
+	}
+	if string(req.body) != string(delivery.Payload) {
+		t.Errorf("body = %s, want %s", req.body, delivery.Payload)
+	}
+	timestamp, signature := req.header.Get(WebhookHeaderTimestamp), req.header.Get(WebhookHeaderSignature)
+	if !utils.VerifyPayloadSignature("whsec_test", timestamp, signature, req.body, 5*time.Minute) {
+		t.Errorf("signature %q does not verify with the endpoint secret", signature)
+	}
+	if utils.VerifyPayloadSignature("other", timestamp, signature, req.body, 5*time.Minute) {
+		t.Error("signature verifies with another secret")
+	}
+
+	stored := repo.get(delivery.ID)
+	if stored.Status != models.WebhookDeliveryDelivered || stored.DeliveredAt == nil {
+		t.Errorf("status = %s, delivered_at = %v, want delivered", stored.Status, stored.DeliveredAt)
+	}
+	if stored.Attempts != 1 || stored.LastStatusCode == nil || *stored.LastStatusCode != http.StatusNoContent {
+		t.Errorf("attempts = %d, last status = %v, want 1 attempt with %d", stored.Attempts, stored.LastStatusCode, http.StatusNoContent)
+	}
+}
+
+func TestWebhookDispatcherRetriesWithBackoff(t *testing.T) {
+	server, received := newReceiver(t, http.StatusInternalServerError)
+	delivery := newTestDelivery(server.URL, 1)
+	repo := newMemoryWebhookRepo(delivery)
+
+	before := time.Now()
+	if _, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-received
+
+	stored := repo.get(delivery.ID)
+	if stored.Status != models.WebhookDeliveryPending {
+		t.Fatalf("status = %s, want pending", stored.Status)
+	}
+	if stored.Attempts != 2 {
+		t.Errorf("attempts = %d, want 2", stored.Attempts)
+	}
+	// The second failure waits twice the base delay
+	wantNext := before.Add(2 * webhookRetryBaseDelay)
+	if stored.NextAttemptAt.Before(wantNext) || stored.NextAttemptAt.After(time.Now().Add(2*webhookRetryBaseDelay)) {
+		t.Errorf("next attempt at %s, want about %s", stored.NextAttemptAt, wantNext)
+	}
+	if stored.LastStatusCode == nil || *stored.LastStatusCode != http.StatusInternalServerError {
+		t.Errorf("last status = %v, want %d", stored.LastStatusCode, http.StatusInternalServerError)
+	}
+	if stored.LastError == nil || *stored.LastError != "endpoint responded with status 500" {
+		t.Errorf("last error = %v, want the status only, never the response body", stored.LastError)
+	}
+
+	// Not due yet, so the next batch sends nothing
+	n, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background())
+	if err != nil || n != 0 {
+		t.Fatalf("DispatchBatch() = %d, %v, want nothing due", n, err)
+	}
+}
+
+func TestWebhookDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	server, received := newReceiver(t, http.StatusServiceUnavailable)
+	delivery := newTestDelivery(server.URL, 2)
+	repo := newMemoryWebhookRepo(delivery)
+
+	if _, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-received
+
+	stored := repo.get(delivery.ID)
+	if stored.Status != models.WebhookDeliveryDead {
+		t.Fatalf("status = %s, want dead", stored.Status)
+	}
+	if stored.Attempts != 3 || stored.DeliveredAt != nil {
+		t.Errorf("attempts = %d, delivered_at = %v, want 3 attempts and no delivery", stored.Attempts, stored.DeliveredAt)
+	}
+
+	n, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background())
+	if err != nil || n != 0 {
+		t.Fatalf("DispatchBatch() = %d, %v, want dead deliveries left alone", n, err)
+	}
+}
+
+func TestWebhookDispatcherRefusesRedirects(t *testing.T) {
+	target, received := newReceiver(t, http.StatusOK)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusFound))
+	t.Cleanup(redirect.Close)
+	delivery := newTestDelivery(redirect.URL, 0)
+	repo := newMemoryWebhookRepo(delivery)
+
+	if _, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-received:
+		t.Fatal("redirect was followed")
+	default:
+	}
+	stored := repo.get(delivery.ID)
+	if stored.Status != models.WebhookDeliveryPending || stored.Attempts != 1 {
+		t.Errorf("status = %s, attempts = %d, want a failed attempt to retry", stored.Status, stored.Attempts)
+	}
+}
+
+func TestWebhookDispatcherRefusesInternalAddresses(t *testing.T) {
+	server, received := newReceiver(t, http.StatusOK)
+	delivery := newTestDelivery(server.URL, 0)
+	repo := newMemoryWebhookRepo(delivery)
+	dispatcher := NewWebhookDispatcher(repo, testDB(t), NewWebhookClient(5*time.Second, false), 3, time.Second, 10)
+
+	if _, err := dispatcher.DispatchBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-received:
+		t.Fatal("request reached a loopback address")
+	default:
+	}
+	stored := repo.get(delivery.ID)
+	if stored.Status != models.WebhookDeliveryPending || stored.LastStatusCode != nil || stored.LastError == nil {
+		t.Errorf("status = %s, last status = %v, last error = %v, want a failed attempt without a response", stored.Status, stored.LastStatusCode, stored.LastError)
+	}
+}
+
+func TestWebhookDispatcherKeepsOutcomeOfNewerLease(t *testing.T) {
+	var repo *memoryWebhookRepo
+	var delivery models.WebhookDelivery
+	takenOver := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Another dispatcher claims the delivery while this one is sending
+		repo.mu.Lock()
+		repo.deliveries[delivery.ID].NextAttemptAt = takenOver
+		repo.mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	delivery = newTestDelivery(server.URL, 0)
+	repo = newMemoryWebhookRepo(delivery)
+
+	if _, err := newTestDispatcher(t, repo, 3).DispatchBatch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := repo.get(delivery.ID)
+	if stored.Attempts != 0 || stored.LastError != nil || !stored.NextAttemptAt.Equal(takenOver) {
+		t.Errorf("attempts = %d, last error = %v, next attempt at %s, want the newer lease untouched", stored.Attempts, stored.LastError, stored.NextAttemptAt)
+	}
+}
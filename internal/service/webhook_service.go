@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"ewallet/internal/events"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"ewallet/pkg/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxWebhookEndpoints         = 10
+	MaxWebhookURLLength         = 2048
+	DefaultWebhookDeliveryLimit = 50
+	MaxWebhookDeliveryLimit     = 100
+	webhookSecretPrefix         = "whsec_"
+)
+
+// WebhookService manages the webhook endpoints of users and queues
+// deliveries for them. It is an event sink: the outbox relay hands it every
+// domain event, and it queues one delivery per subscribed endpoint of the
+// users the event is about. WebhookDispatcher sends the queued deliveries.
+type WebhookService interface {
+	events.Sink
+	CreateEndpoint(userID uuid.UUID, rawURL string, eventTypes []string) (*models.WebhookEndpointCreated, error)
+	ListEndpoints(userID uuid.UUID) ([]models.WebhookEndpoint, error)
+	DeleteEndpoint(userID, id uuid.UUID) error
+	ListDeliveries(userID, endpointID uuid.UUID, status *models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	ReplayDelivery(userID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error)
+}
+
+type webhookService struct {
+	webhookRepo   repository.WebhookRepository
+	allowLoopback bool
+}
+
+// NewWebhookService creates the service. allowLoopback accepts endpoints on
+// loopback addresses, for local testing; it must match the dispatcher's
+// client (see NewWebhookClient).
+func NewWebhookService(webhookRepo repository.WebhookRepository, allowLoopback bool) WebhookService {
+	return &webhookService{webhookRepo: webhookRepo, allowLoopback: allowLoopback}
+}
+
+// CreateEndpoint registers a URL for the given event types and generates
+// its signing secret, which is returned only here.
+func (s *webhookService) CreateEndpoint(userID uuid.UUID, rawURL string, eventTypes []string) (*models.WebhookEndpointCreated, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if err := validateWebhookURL(rawURL, s.allowLoopback); err != nil {
+		return nil, err
+	}
+
+	subscribed, err := normalizeEventTypes(eventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := s.webhookRepo.CountEndpoints(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxWebhookEndpoints {
+		return nil, ErrWebhookLimitReached.withMessage("at most %d webhook endpoints are allowed", MaxWebhookEndpoints)
+	}
+
+	token, err := utils.GenerateOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := models.WebhookEndpoint{
+		UserID:     userID,
+		URL:        rawURL,
+		Secret:     webhookSecretPrefix + token,
+		EventTypes: subscribed,
+	}
+	if err := s.webhookRepo.CreateEndpoint(nil, &endpoint); err != nil {
+		return nil, err
+	}
+
+	return &models.WebhookEndpointCreated{WebhookEndpoint: endpoint, Secret: endpoint.Secret}, nil
+}
+
+func validateWebhookURL(rawURL string, allowLoopback bool) error {
+	if rawURL == "" || len(rawURL) > MaxWebhookURLLength {
+		return ErrInvalidWebhookURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidWebhookURL
+	}
+	if !webhookHostAllowed(u.Hostname(), allowLoopback) {
+		return ErrInvalidWebhookURL.withMessage("webhook URL must not point to a private or internal address")
+	}
+	return nil
+}
+
+// normalizeEventTypes checks the subscription and removes duplicates.
+func normalizeEventTypes(eventTypes []string) ([]string, error) {
+	if len(eventTypes) == 0 {
+		return nil, ErrInvalidEventType.withMessage("at least one event type is required")
+	}
+
+	seen := make(map[string]bool, len(eventTypes))
+	var result []string
+	for _, t := range eventTypes {
+		if !models.IsEventType(t) {
+			return nil, ErrInvalidEventType.withMessage("unknown event type %q", t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (s *webhookService) ListEndpoints(userID uuid.UUID) ([]models.WebhookEndpoint, error) {
+	endpoints, err := s.webhookRepo.FindEndpointsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if endpoints == nil {
+		endpoints = []models.WebhookEndpoint{}
+	}
+	return endpoints, nil
+}
+
+// DeleteEndpoint removes the endpoint together with its queued and
+// dead-lettered deliveries.
+func (s *webhookService) DeleteEndpoint(userID, id uuid.UUID) error {
+	if _, err := s.webhookRepo.FindEndpoint(userID, id); err != nil {
+		return ErrWebhookNotFound
+	}
+	return s.webhookRepo.DeleteEndpoint(nil, id)
+}
+
+// ListDeliveries returns the endpoint's deliveries, newest first. Filter by
+// the dead status to see the dead-letter queue.
+func (s *webhookService) ListDeliveries(userID, endpointID uuid.UUID, status *models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.webhookRepo.FindEndpoint(userID, endpointID); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	if limit <= 0 {
+		limit = DefaultWebhookDeliveryLimit
+	}
+	if limit > MaxWebhookDeliveryLimit {
+		limit = MaxWebhookDeliveryLimit
+	}
+
+	deliveries, err := s.webhookRepo.FindDeliveries(endpointID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return deliveries, nil
+}
+
+// ReplayDelivery queues a dead-lettered or delivered delivery again, with a
+// fresh set of attempts. The same payload is sent again.
+func (s *webhookService) ReplayDelivery(userID, endpointID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	if _, err := s.webhookRepo.FindEndpoint(userID, endpointID); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	delivery, err := s.webhookRepo.FindDelivery(endpointID, deliveryID)
+	if err != nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	if delivery.Status == models.WebhookDeliveryPending {
+		return nil, ErrWebhookDeliveryPending
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.LastError = nil
+	delivery.LastStatusCode = nil
+	delivery.DeliveredAt = nil
+	delivery.NextAttemptAt = time.Now()
+	if err := s.webhookRepo.UpdateDelivery(nil, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) Name() string {
+	return "webhooks"
+}
+
+// Publish queues the event for every subscribed endpoint of the users it is
+// about. Queuing is idempotent per endpoint and event, so the relay may
+// hand over the same event more than once.
+func (s *webhookService) Publish(ctx context.Context, event models.EventEnvelope) error {
+	subjects, err := event.Subjects()
+	if err != nil || len(subjects) == 0 {
+		return err
+	}
+
+	endpoints, err := s.webhookRepo.FindSubscribedEndpoints(subjects, event.Type)
+	if err != nil || len(endpoints) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deliveries := make([]models.WebhookDelivery, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		deliveries = append(deliveries, models.WebhookDelivery{
+			ID:            uuid.New(),
+			EndpointID:    endpoint.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	return s.webhookRepo.CreateDeliveries(nil, deliveries)
+}
@@ -0,0 +1,579 @@
+package service
+
+import (
+	"ewallet/internal/bank"
+	"ewallet/internal/models"
+	"ewallet/internal/repository"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	MaxBankAccountsPerUser     = 5
+	MaxBankAccountNameLength   = 100
+	DefaultWithdrawalLimit     = 50
+	MaxWithdrawalLimit         = 100
+	withdrawalLastErrorMaxSize = 1000
+)
+
+var (
+	bankCodePattern      = regexp.MustCompile(`^[A-Z0-9]{3,11}$`)
+	accountNumberPattern = regexp.MustCompile(`^[0-9]{5,20}$`)
+)
+
+// WithdrawalService manages users' bank accounts and pays money out of
+// their wallets into them. Requesting a withdrawal holds the money; the
+// PayoutProcessor submits it to the payout provider, whose result arrives
+// through HandleResult.
+type WithdrawalService interface {
+	ListBankAccounts(userID uuid.UUID) ([]models.BankAccount, error)
+	AddBankAccount(meta models.RequestMeta, userID uuid.UUID, input BankAccountInput) (*models.BankAccount, error)
+	RemoveBankAccount(meta models.RequestMeta, userID, id uuid.UUID) error
+	Request(meta models.RequestMeta, userID uuid.UUID, input WithdrawalInput, pin string) (*models.Withdrawal, error)
+	List(userID uuid.UUID, status *models.WithdrawalStatus, limit int) ([]models.Withdrawal, error)
+	Get(userID, id uuid.UUID) (*models.Withdrawal, error)
+	HandleResult(meta models.RequestMeta, result bank.PayoutResult) (*models.Withdrawal, error)
+	ApplyResult(tx *gorm.DB, meta models.RequestMeta, withdrawal *models.Withdrawal, result bank.PayoutResult) error
+	Release(tx *gorm.DB, meta models.RequestMeta, withdrawal *models.Withdrawal, reason, lastError string) error
+}
+
+// BankAccountInput describes a bank account to add.
+type BankAccountInput struct {
+	BankCode      string
+	AccountNumber string
+	AccountName   string
+}
+
+// WithdrawalInput describes a withdrawal. A nil WalletID withdraws from the
+// user's primary wallet.
+type WithdrawalInput struct {
+	WalletID      *uuid.UUID
+	BankAccountID uuid.UUID
+	Amount        models.Money
+}
+
+type withdrawalService struct {
+	bankAccountRepo     repository.BankAccountRepository
+	withdrawalRepo      repository.WithdrawalRepository
+	walletRepo          repository.WalletRepository
+	transactionRepo     repository.TransactionRepository
+	ledgerService       LedgerService
+	walletLocker        WalletLocker
+	limitService        LimitService
+	fxService           FXService
+	pinService          PINService
+	kycService          KYCService
+	auditService        AuditService
+	outboxService       OutboxService
+	notificationService NotificationService
+	db                  *gorm.DB
+}
+
+func NewWithdrawalService(
+	bankAccountRepo repository.BankAccountRepository,
+	withdrawalRepo repository.WithdrawalRepository,
+	walletRepo repository.WalletRepository,
+	transactionRepo repository.TransactionRepository,
+	ledgerService LedgerService,
+	walletLocker WalletLocker,
+	limitService LimitService,
+	fxService FXService,
+	pinService PINService,
+	kycService KYCService,
+	auditService AuditService,
+	outboxService OutboxService,
+	notificationService NotificationService,
+	db *gorm.DB,
+) WithdrawalService {
+	return &withdrawalService{
+		bankAccountRepo:     bankAccountRepo,
+		withdrawalRepo:      withdrawalRepo,
+		walletRepo:          walletRepo,
+		transactionRepo:     transactionRepo,
+		ledgerService:       ledgerService,
+		walletLocker:        walletLocker,
+		limitService:        limitService,
+		fxService:           fxService,
+		pinService:          pinService,
+		kycService:          kycService,
+		auditService:        auditService,
+		outboxService:       outboxService,
+		notificationService: notificationService,
+		db:                  db,
+	}
+}
+
+// ListBankAccounts returns the user's bank accounts, oldest first.
+func (s *withdrawalService) ListBankAccounts(userID uuid.UUID) ([]models.BankAccount, error) {
+	accounts, err := s.bankAccountRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if accounts == nil {
+		accounts = []models.BankAccount{}
+	}
+	return accounts, nil
+}
+
+// AddBankAccount registers a bank account the user can withdraw to. The
+// bank code is upper-cased; the account itself is not checked with the bank
+// until a payout is made to it.
+func (s *withdrawalService) AddBankAccount(meta models.RequestMeta, userID uuid.UUID, input BankAccountInput) (*models.BankAccount, error) {
+	account, err := normalizeBankAccount(input)
+	if err != nil {
+		return nil, err
+	}
+	account.UserID = userID
+
+	count, err := s.bankAccountRepo.CountByUserID(nil, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxBankAccountsPerUser {
+		return nil, ErrBankAccountLimitReached.withMessage("at most %d bank accounts are allowed", MaxBankAccountsPerUser)
+	}
+	exists, err := s.bankAccountRepo.Exists(nil, userID, account.BankCode, account.AccountNumber)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrBankAccountExists
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.bankAccountRepo.Create(tx, account); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionBankAccountAdded,
+			TargetType: models.AuditTargetBankAccount,
+			TargetID:   account.ID.String(),
+		}, map[string]interface{}{"bank_code": account.BankCode, "account_number": account.AccountNumber})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+func normalizeBankAccount(input BankAccountInput) (*models.BankAccount, error) {
+	bankCode := strings.ToUpper(strings.TrimSpace(input.BankCode))
+	if !bankCodePattern.MatchString(bankCode) {
+		return nil, ErrInvalidBankAccount.withMessage("bank_code must be 3 to 11 letters or digits")
+	}
+	accountNumber := strings.TrimSpace(input.AccountNumber)
+	if !accountNumberPattern.MatchString(accountNumber) {
+		return nil, ErrInvalidBankAccount.withMessage("account_number must be 5 to 20 digits")
+	}
+	accountName := strings.TrimSpace(input.AccountName)
+	if accountName == "" {
+		return nil, ErrInvalidBankAccount.withMessage("account_name is required")
+	}
+	if utf8.RuneCountInString(accountName) > MaxBankAccountNameLength {
+		return nil, ErrInvalidBankAccount.withMessage("account_name must be at most %d characters", MaxBankAccountNameLength)
+	}
+
+	return &models.BankAccount{
+		BankCode:      bankCode,
+		AccountNumber: accountNumber,
+		AccountName:   accountName,
+	}, nil
+}
+
+// RemoveBankAccount removes the bank account. Withdrawals already requested
+// to it are still paid out.
+func (s *withdrawalService) RemoveBankAccount(meta models.RequestMeta, userID, id uuid.UUID) error {
+	account, err := s.bankAccountRepo.FindOwned(userID, id)
+	if err != nil {
+		return ErrBankAccountNotFound
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.bankAccountRepo.Delete(tx, account.ID); err != nil {
+			return err
+		}
+
+		return s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionBankAccountRemoved,
+			TargetType: models.AuditTargetBankAccount,
+			TargetID:   account.ID.String(),
+		}, map[string]interface{}{"bank_code": account.BankCode, "account_number": account.AccountNumber})
+	})
+}
+
+// Request holds amount of the wallet for a payout into one of the user's
+// bank accounts, in the wallet's currency. The money leaves the wallet
+// right away and the withdrawal is left pending for the PayoutProcessor.
+// Withdrawals are free and count against the withdrawal limits.
+func (s *withdrawalService) Request(meta models.RequestMeta, userID uuid.UUID, input WithdrawalInput, pin string) (*models.Withdrawal, error) {
+	// Authorize the debit before anything else
+	if err := s.pinService.Verify(userID, pin); err != nil {
+		return nil, err
+	}
+
+	// Paying out to a bank needs a verified identity
+	if err := s.kycService.RequireFeature(userID, models.FeatureWithdrawal); err != nil {
+		return nil, err
+	}
+
+	if input.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	bankAccount, err := s.bankAccountRepo.FindOwned(userID, input.BankAccountID)
+	if err != nil {
+		return nil, ErrBankAccountNotFound
+	}
+	current, err := findWallet(s.walletRepo, userID, input.WalletID)
+	if err != nil {
+		return nil, ErrWalletNotFound
+	}
+
+	var withdrawal *models.Withdrawal
+	err = s.walletLocker.WithWallets([]uuid.UUID{current.ID}, func(tx *gorm.DB, wallets LockedWallets) error {
+		w := wallets[current.ID]
+
+		// Check the status on the locked row so a freeze cannot race the
+		// withdrawal
+		if err := checkDebit(w); err != nil {
+			return err
+		}
+		if err := checkUserDebit(tx, s.walletRepo, userID); err != nil {
+			return err
+		}
+		if w.Balance < input.Amount {
+			return ErrInsufficientBalance
+		}
+
+		// Enforce limits under the wallet lock so usage cannot change underneath
+		base, err := s.fxService.ToBase(input.Amount, w.Currency)
+		if err != nil {
+			return err
+		}
+		if err := s.limitService.CheckTransaction(tx, userID, models.TransactionTypeWithdrawal, base); err != nil {
+			return err
+		}
+
+		// The transaction stays pending until the payout completes
+		transaction := &models.Transaction{
+			SenderID:       &userID,
+			SenderWalletID: &w.ID,
+			ReceiverID:     &userID,
+			Amount:         input.Amount,
+			Currency:       w.Currency,
+			BaseAmount:     base,
+			Type:           models.TransactionTypeWithdrawal,
+			Status:         models.TransactionStatusPending,
+		}
+		if err := s.transactionRepo.Create(tx, transaction); err != nil {
+			return err
+		}
+
+		// Post the hold to the ledger: the money moves out of the wallet
+		// into the withdrawal hold account
+		hold, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountWithdrawalHold, w.Currency)
+		if err != nil {
+			return err
+		}
+		account, err := s.ledgerService.WalletAccount(tx, w.ID)
+		if err != nil {
+			return err
+		}
+		entry := &models.JournalEntry{
+			TransactionID: &transaction.ID,
+			Description:   "withdrawal hold",
+			Postings: []models.Posting{
+				{AccountID: account.ID, Direction: models.PostingDirectionDebit, Amount: input.Amount},
+				{AccountID: hold.ID, Direction: models.PostingDirectionCredit, Amount: input.Amount},
+			},
+		}
+		if err := s.ledgerService.Post(tx, entry); err != nil {
+			return err
+		}
+		w.Balance = *entry.Postings[0].BalanceAfter
+
+		withdrawal = &models.Withdrawal{
+			UserID:        userID,
+			WalletID:      w.ID,
+			BankAccountID: bankAccount.ID,
+			Amount:        input.Amount,
+			Currency:      w.Currency,
+			Status:        models.WithdrawalPending,
+			TransactionID: transaction.ID,
+			NextAttemptAt: time.Now(),
+		}
+		if err := s.withdrawalRepo.Create(tx, withdrawal); err != nil {
+			return err
+		}
+		withdrawal.BankAccount = bankAccount
+
+		err = s.auditService.Record(tx, meta, &models.AuditEvent{
+			Action:     models.AuditActionWithdrawalRequested,
+			TargetType: models.AuditTargetWithdrawal,
+			TargetID:   withdrawal.ID.String(),
+		}, map[string]interface{}{
+			"transaction_id":  transaction.ID,
+			"wallet_id":       w.ID,
+			"bank_account_id": bankAccount.ID,
+			"amount":          input.Amount,
+			"currency":        w.Currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.notificationService.Publish(tx, &models.WalletNotification{
+			UserID:        userID,
+			Type:          models.NotificationBalanceChanged,
+			WalletID:      &w.ID,
+			Balance:       &w.Balance,
+			Currency:      &w.Currency,
+			TransactionID: &transaction.ID,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return withdrawal, nil
+}
+
+// List returns the user's withdrawals, newest first. The limit defaults to
+// DefaultWithdrawalLimit and is capped at MaxWithdrawalLimit.
+func (s *withdrawalService) List(userID uuid.UUID, status *models.WithdrawalStatus, limit int) ([]models.Withdrawal, error) {
+	if limit <= 0 {
+		limit = DefaultWithdrawalLimit
+	}
+	if limit > MaxWithdrawalLimit {
+		limit = MaxWithdrawalLimit
+	}
+
+	withdrawals, err := s.withdrawalRepo.Find(userID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if withdrawals == nil {
+		withdrawals = []models.Withdrawal{}
+	}
+	return withdrawals, nil
+}
+
+// Get returns one of the user's withdrawals; anyone else's is not found.
+func (s *withdrawalService) Get(userID, id uuid.UUID) (*models.Withdrawal, error) {
+	withdrawal, err := s.withdrawalRepo.FindByID(id)
+	if err != nil || withdrawal.UserID != userID {
+		return nil, ErrWithdrawalNotFound
+	}
+	return withdrawal, nil
+}
+
+// HandleResult records the payout provider's outcome of a withdrawal: a
+// successful payout settles the hold, a failed one releases it back into
+// the wallet. Providers may report a result more than once, so the result
+// a withdrawal already ended with is accepted again without effect.
+func (s *withdrawalService) HandleResult(meta models.RequestMeta, result bank.PayoutResult) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		w, err := s.withdrawalRepo.FindByIDForUpdate(tx, result.WithdrawalID)
+		if err != nil {
+			return ErrWithdrawalNotFound
+		}
+		withdrawal = w
+		return s.ApplyResult(tx, meta, w, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return withdrawal, nil
+}
+
+// ApplyResult records a final payout result for a withdrawal locked by tx.
+// A result the withdrawal already ended with is accepted again without
+// effect.
+func (s *withdrawalService) ApplyResult(tx *gorm.DB, meta models.RequestMeta, w *models.Withdrawal, result bank.PayoutResult) error {
+	if w.ProviderReference != nil && result.Reference != "" && *w.ProviderReference != result.Reference {
+		return ErrInvalidPayoutResult.withMessage("reference %s does not match the withdrawal", result.Reference)
+	}
+
+	status := models.WithdrawalSuccess
+	if result.Status == bank.PayoutFailed {
+		status = models.WithdrawalFailed
+	}
+	if w.Status.IsFinal() {
+		if w.Status == status {
+			return nil
+		}
+		return ErrWithdrawalCompleted
+	}
+
+	// The result can arrive before the submission was recorded
+	if w.ProviderReference == nil && result.Reference != "" {
+		reference := result.Reference
+		w.ProviderReference = &reference
+	}
+
+	if status == models.WithdrawalSuccess {
+		return s.settle(tx, meta, w)
+	}
+	return s.Release(tx, meta, w, models.WithdrawalFailurePayoutFailed, result.FailureReason)
+}
+
+// settle completes a paid out withdrawal: the held money moves on to the
+// payout clearing account. The withdrawal must be locked by tx.
+func (s *withdrawalService) settle(tx *gorm.DB, meta models.RequestMeta, withdrawal *models.Withdrawal) error {
+	transaction, err := s.transactionRepo.FindByIDForUpdate(tx, withdrawal.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	hold, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountWithdrawalHold, withdrawal.Currency)
+	if err != nil {
+		return err
+	}
+	clearing, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountPayoutClearing, withdrawal.Currency)
+	if err != nil {
+		return err
+	}
+	entry := &models.JournalEntry{
+		TransactionID: &transaction.ID,
+		Description:   "withdrawal payout",
+		Postings: []models.Posting{
+			{AccountID: hold.ID, Direction: models.PostingDirectionDebit, Amount: withdrawal.Amount},
+			{AccountID: clearing.ID, Direction: models.PostingDirectionCredit, Amount: withdrawal.Amount},
+		},
+	}
+	if err := s.ledgerService.Post(tx, entry); err != nil {
+		return err
+	}
+
+	transaction.Status = models.TransactionStatusSuccess
+	if err := s.transactionRepo.UpdateStatus(tx, transaction); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	withdrawal.Status = models.WithdrawalSuccess
+	withdrawal.CompletedAt = &now
+	withdrawal.UpdatedAt = now
+	if err := s.withdrawalRepo.Update(tx, withdrawal); err != nil {
+		return err
+	}
+
+	err = s.auditService.Record(tx, meta, &models.AuditEvent{
+		Action:     models.AuditActionWithdrawalCompleted,
+		TargetType: models.AuditTargetWithdrawal,
+		TargetID:   withdrawal.ID.String(),
+	}, map[string]interface{}{
+		"transaction_id":     transaction.ID,
+		"amount":             withdrawal.Amount,
+		"currency":           withdrawal.Currency,
+		"provider_reference": withdrawal.ProviderReference,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.outboxService.Publish(tx, models.EventWithdrawalCompleted, models.AggregateWithdrawal, withdrawal.ID, withdrawalData(withdrawal))
+}
+
+// Release fails a withdrawal with the reason code and returns the held
+// money to the wallet, whatever the wallet's status. lastError is the
+// provider's explanation, if any. The withdrawal must be locked by tx.
+func (s *withdrawalService) Release(tx *gorm.DB, meta models.RequestMeta, withdrawal *models.Withdrawal, reason, lastError string) error {
+	transaction, err := s.transactionRepo.FindByIDForUpdate(tx, withdrawal.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	hold, err := s.ledgerService.SystemAccount(tx, models.LedgerAccountWithdrawalHold, withdrawal.Currency)
+	if err != nil {
+		return err
+	}
+	account, err := s.ledgerService.WalletAccount(tx, withdrawal.WalletID)
+	if err != nil {
+		return err
+	}
+	entry := &models.JournalEntry{
+		TransactionID: &transaction.ID,
+		Description:   "withdrawal release",
+		Postings: []models.Posting{
+			{AccountID: hold.ID, Direction: models.PostingDirectionDebit, Amount: withdrawal.Amount},
+			{AccountID: account.ID, Direction: models.PostingDirectionCredit, Amount: withdrawal.Amount},
+		},
+	}
+	if err := s.ledgerService.Post(tx, entry); err != nil {
+		return err
+	}
+	balance := *entry.Postings[1].BalanceAfter
+
+	transaction.Status = models.TransactionStatusFailed
+	transaction.FailureReason = &reason
+	if err := s.transactionRepo.UpdateStatus(tx, transaction); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	withdrawal.Status = models.WithdrawalFailed
+	withdrawal.FailureReason = &reason
+	if lastError != "" {
+		if len(lastError) > withdrawalLastErrorMaxSize {
+			lastError = lastError[:withdrawalLastErrorMaxSize]
+		}
+		withdrawal.LastError = &lastError
+	}
+	withdrawal.CompletedAt = &now
+	withdrawal.UpdatedAt = now
+	if err := s.withdrawalRepo.Update(tx, withdrawal); err != nil {
+		return err
+	}
+
+	err = s.auditService.Record(tx, meta, &models.AuditEvent{
+		Action:     models.AuditActionWithdrawalFailed,
+		TargetType: models.AuditTargetWithdrawal,
+		TargetID:   withdrawal.ID.String(),
+	}, map[string]interface{}{
+		"transaction_id": transaction.ID,
+		"amount":         withdrawal.Amount,
+		"currency":       withdrawal.Currency,
+		"reason":         reason,
+		"balance_after":  balance,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.outboxService.Publish(tx, models.EventWithdrawalFailed, models.AggregateWithdrawal, withdrawal.ID, withdrawalData(withdrawal))
+	if err != nil {
+		return err
+	}
+
+	return s.notificationService.Publish(tx, &models.WalletNotification{
+		UserID:        withdrawal.UserID,
+		Type:          models.NotificationBalanceChanged,
+		WalletID:      &withdrawal.WalletID,
+		Balance:       &balance,
+		Currency:      &withdrawal.Currency,
+		TransactionID: &transaction.ID,
+	})
+}
+
+func withdrawalData(withdrawal *models.Withdrawal) models.WithdrawalData {
+	return models.WithdrawalData{
+		WithdrawalID:  withdrawal.ID,
+		TransactionID: withdrawal.TransactionID,
+		UserID:        withdrawal.UserID,
+		WalletID:      withdrawal.WalletID,
+		BankAccountID: withdrawal.BankAccountID,
+		Amount:        withdrawal.Amount,
+		Currency:      withdrawal.Currency,
+		FailureReason: withdrawal.FailureReason,
+	}
+}
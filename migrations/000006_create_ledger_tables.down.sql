@@ -0,0 +1,9 @@
+-- Drop ledger indexes and tables
+DROP INDEX IF EXISTS idx_postings_account_id;
+DROP INDEX IF EXISTS idx_postings_journal_entry_id;
+DROP TABLE IF EXISTS postings;
+DROP INDEX IF EXISTS idx_journal_entries_transaction_id;
+DROP TABLE IF EXISTS journal_entries;
+DROP INDEX IF EXISTS idx_ledger_accounts_wallet_id;
+DROP INDEX IF EXISTS idx_ledger_accounts_code;
+DROP TABLE IF EXISTS ledger_accounts;
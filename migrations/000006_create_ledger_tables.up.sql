@@ -0,0 +1,79 @@
+-- Ledger accounts: one per wallet plus system accounts for external money
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  code VARCHAR(50),
+  name VARCHAR(100) NOT NULL,
+  type VARCHAR(20) NOT NULL,
+  wallet_id UUID,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_ledger_account_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id),
+  CONSTRAINT chk_ledger_account_owner CHECK (
+    (type = 'wallet' AND wallet_id IS NOT NULL) OR
+    (type = 'system' AND code IS NOT NULL)
+  )
+);
+
+CREATE UNIQUE INDEX idx_ledger_accounts_code ON ledger_accounts(code);
+CREATE UNIQUE INDEX idx_ledger_accounts_wallet_id ON ledger_accounts(wallet_id);
+
+-- Journal entries group the balanced postings of one movement
+CREATE TABLE IF NOT EXISTS journal_entries (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  transaction_id UUID,
+  description VARCHAR(255) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_journal_entry_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_journal_entries_transaction_id ON journal_entries(transaction_id);
+
+CREATE TABLE IF NOT EXISTS postings (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  journal_entry_id UUID NOT NULL,
+  account_id UUID NOT NULL,
+  direction VARCHAR(6) NOT NULL,
+  amount DECIMAL(15,2) NOT NULL,
+  balance_after DECIMAL(15,2),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_posting_journal_entry FOREIGN KEY (journal_entry_id) REFERENCES journal_entries(id),
+  CONSTRAINT fk_posting_account FOREIGN KEY (account_id) REFERENCES ledger_accounts(id),
+  CONSTRAINT chk_posting_direction CHECK (direction IN ('debit', 'credit')),
+  CONSTRAINT chk_posting_amount CHECK (amount > 0)
+);
+
+CREATE INDEX idx_postings_journal_entry_id ON postings(journal_entry_id);
+CREATE INDEX idx_postings_account_id ON postings(account_id);
+
+-- System accounts
+INSERT INTO ledger_accounts (code, name, type) VALUES
+  ('topup_clearing', 'Top-up clearing', 'system'),
+  ('opening_balance', 'Opening balance', 'system');
+
+-- Ledger accounts for existing wallets
+INSERT INTO ledger_accounts (name, type, wallet_id)
+SELECT 'wallet ' || id, 'wallet', id FROM wallets;
+
+-- Opening entries so existing balances are backed by postings
+DO $$
+DECLARE
+  w RECORD;
+  entry_id UUID;
+  opening_id UUID;
+BEGIN
+  SELECT id INTO opening_id FROM ledger_accounts WHERE code = 'opening_balance';
+
+  FOR w IN
+    SELECT wl.balance, la.id AS account_id
+    FROM wallets wl
+    JOIN ledger_accounts la ON la.wallet_id = wl.id
+    WHERE wl.balance <> 0
+  LOOP
+    INSERT INTO journal_entries (description) VALUES ('opening balance')
+    RETURNING id INTO entry_id;
+
+    INSERT INTO postings (journal_entry_id, account_id, direction, amount, balance_after) VALUES
+      (entry_id, opening_id, CASE WHEN w.balance > 0 THEN 'debit' ELSE 'credit' END, ABS(w.balance), NULL),
+      (entry_id, w.account_id, CASE WHEN w.balance > 0 THEN 'credit' ELSE 'debit' END, ABS(w.balance), w.balance);
+  END LOOP;
+END $$;
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_transactions_status;
+
+-- Failed attempts against unknown receivers cannot take a receiver_id
+UPDATE transactions
+SET receiver_id = attempted_receiver_id
+WHERE receiver_id IS NULL AND attempted_receiver_id IN (SELECT id FROM users);
+DELETE FROM transactions WHERE receiver_id IS NULL;
+
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transactions_receiver;
+ALTER TABLE transactions ALTER COLUMN receiver_id SET NOT NULL;
+ALTER TABLE transactions DROP COLUMN IF EXISTS attempted_receiver_id;
+
+ALTER TABLE transactions DROP COLUMN IF EXISTS failure_reason;
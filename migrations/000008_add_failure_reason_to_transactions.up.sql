@@ -0,0 +1,13 @@
+-- Machine-readable reason for failed transactions (e.g. insufficient_balance)
+ALTER TABLE transactions ADD COLUMN failure_reason VARCHAR(50);
+
+-- Failed transfers are recorded even when the requested receiver does not
+-- exist. They keep the user they were attempted to in attempted_receiver_id,
+-- which only the sender sees, and leave receiver_id unset, so fk_receiver
+-- still holds for every receiver
+ALTER TABLE transactions ADD COLUMN attempted_receiver_id UUID;
+ALTER TABLE transactions ALTER COLUMN receiver_id DROP NOT NULL;
+ALTER TABLE transactions ADD CONSTRAINT chk_transactions_receiver
+  CHECK (receiver_id IS NOT NULL OR (type = 'transfer' AND status = 'failed'));
+
+CREATE INDEX idx_transactions_status ON transactions(status);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_receiver_created_at_id;
+DROP INDEX IF EXISTS idx_transactions_sender_created_at_id;
@@ -0,0 +1,3 @@
+-- Keyset pagination walks each party's history by (created_at, id)
+CREATE INDEX idx_transactions_sender_created_at_id ON transactions(sender_id, created_at DESC, id DESC);
+CREATE INDEX idx_transactions_receiver_created_at_id ON transactions(receiver_id, created_at DESC, id DESC);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS pin_locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS pin_failed_attempts;
+ALTER TABLE users DROP COLUMN IF EXISTS pin_hash;
@@ -0,0 +1,4 @@
+-- Transaction PIN (bcrypt hash) and lockout after repeated wrong PINs
+ALTER TABLE users ADD COLUMN pin_hash VARCHAR(255);
+ALTER TABLE users ADD COLUMN pin_failed_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN pin_locked_until TIMESTAMPTZ;
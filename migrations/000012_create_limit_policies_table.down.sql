@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_limit_policies_global;
+DROP INDEX IF EXISTS idx_limit_policies_user_id;
+DROP TABLE IF EXISTS limit_policies;
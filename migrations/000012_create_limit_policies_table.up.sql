@@ -0,0 +1,33 @@
+-- Transaction and balance limits. The row with user_id NULL is the global
+-- policy; a user's row overrides only the columns it sets. NULL = unlimited.
+CREATE TABLE IF NOT EXISTS limit_policies (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID,
+  topup_min DECIMAL(15,2),
+  topup_max DECIMAL(15,2),
+  topup_daily DECIMAL(15,2),
+  topup_monthly DECIMAL(15,2),
+  transfer_min DECIMAL(15,2),
+  transfer_max DECIMAL(15,2),
+  transfer_daily DECIMAL(15,2),
+  transfer_monthly DECIMAL(15,2),
+  max_balance DECIMAL(15,2),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_limit_policy_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX idx_limit_policies_user_id ON limit_policies(user_id);
+-- At most one global policy
+CREATE UNIQUE INDEX idx_limit_policies_global ON limit_policies((user_id IS NULL)) WHERE user_id IS NULL;
+
+-- Default global policy
+INSERT INTO limit_policies (
+  topup_min, topup_max, topup_daily, topup_monthly,
+  transfer_min, transfer_max, transfer_daily, transfer_monthly,
+  max_balance
+) VALUES (
+  10000, 10000000, 20000000, 100000000,
+  1000, 10000000, 20000000, 100000000,
+  20000000
+);
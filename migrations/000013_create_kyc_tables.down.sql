@@ -0,0 +1,13 @@
+DELETE FROM limit_policies WHERE kyc_tier IS NOT NULL;
+DROP INDEX IF EXISTS idx_limit_policies_kyc_tier;
+DROP INDEX IF EXISTS idx_limit_policies_global;
+CREATE UNIQUE INDEX idx_limit_policies_global ON limit_policies((user_id IS NULL)) WHERE user_id IS NULL;
+ALTER TABLE limit_policies DROP CONSTRAINT IF EXISTS chk_limit_policies_scope;
+ALTER TABLE limit_policies DROP COLUMN IF EXISTS kyc_tier;
+
+DROP TABLE IF EXISTS kyc_documents;
+DROP TABLE IF EXISTS kyc_submissions;
+
+DROP INDEX IF EXISTS idx_users_role;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+ALTER TABLE users DROP COLUMN IF EXISTS kyc_tier;
@@ -0,0 +1,80 @@
+-- KYC tier of every user; new users start on basic
+ALTER TABLE users ADD COLUMN kyc_tier VARCHAR(20) NOT NULL DEFAULT 'basic'
+  CHECK (kyc_tier IN ('basic', 'verified', 'premium'));
+
+-- Back-office role of every user; admins review KYC submissions
+ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'customer'
+  CONSTRAINT chk_users_role CHECK (role IN ('customer', 'admin'));
+
+CREATE INDEX idx_users_role ON users(role) WHERE role <> 'customer';
+
+-- Demo users keep the ability to transfer
+UPDATE users SET kyc_tier = 'verified' WHERE id IN (
+  '11111111-1111-1111-1111-111111111111',
+  '22222222-2222-2222-2222-222222222222',
+  '33333333-3333-3333-3333-333333333333',
+  '44444444-4444-4444-4444-444444444444',
+  '55555555-5555-5555-5555-555555555555'
+);
+
+CREATE TABLE IF NOT EXISTS kyc_submissions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  requested_tier VARCHAR(20) NOT NULL CHECK (requested_tier IN ('verified', 'premium')),
+  full_name VARCHAR(100) NOT NULL,
+  id_number VARCHAR(32) NOT NULL,
+  date_of_birth DATE NOT NULL,
+  address TEXT NOT NULL,
+  status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  review_note TEXT,
+  reviewed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_kyc_submission_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_kyc_submissions_user_id ON kyc_submissions(user_id, created_at DESC);
+CREATE INDEX idx_kyc_submissions_status ON kyc_submissions(status, created_at);
+-- At most one submission per user waiting for review
+CREATE UNIQUE INDEX idx_kyc_submissions_pending ON kyc_submissions(user_id) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS kyc_documents (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  submission_id UUID NOT NULL,
+  type VARCHAR(30) NOT NULL CHECK (type IN ('id_card', 'selfie', 'proof_of_address')),
+  file_path VARCHAR(500) NOT NULL,
+  content_type VARCHAR(100) NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_kyc_document_submission FOREIGN KEY (submission_id) REFERENCES kyc_submissions(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_kyc_documents_submission_id ON kyc_documents(submission_id);
+
+-- Limit policies per KYC tier, applied between the global and the user policy
+ALTER TABLE limit_policies ADD COLUMN kyc_tier VARCHAR(20)
+  CHECK (kyc_tier IN ('basic', 'verified', 'premium'));
+ALTER TABLE limit_policies ADD CONSTRAINT chk_limit_policies_scope
+  CHECK (user_id IS NULL OR kyc_tier IS NULL);
+
+DROP INDEX IF EXISTS idx_limit_policies_global;
+CREATE UNIQUE INDEX idx_limit_policies_global ON limit_policies((user_id IS NULL))
+  WHERE user_id IS NULL AND kyc_tier IS NULL;
+CREATE UNIQUE INDEX idx_limit_policies_kyc_tier ON limit_policies(kyc_tier) WHERE kyc_tier IS NOT NULL;
+
+-- Unverified accounts hold little money; verified accounts use the global
+-- policy; premium accounts get higher limits
+INSERT INTO limit_policies (kyc_tier, topup_daily, topup_monthly, max_balance)
+VALUES ('basic', 2000000, 10000000, 2000000);
+
+INSERT INTO limit_policies (
+  kyc_tier,
+  topup_max, topup_daily, topup_monthly,
+  transfer_max, transfer_daily, transfer_monthly,
+  max_balance
+) VALUES (
+  'premium',
+  50000000, 100000000, 500000000,
+  50000000, 100000000, 500000000,
+  100000000
+);
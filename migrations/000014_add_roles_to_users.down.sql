@@ -0,0 +1,9 @@
+ALTER TABLE kyc_submissions DROP CONSTRAINT IF EXISTS fk_kyc_submission_reviewer;
+ALTER TABLE kyc_submissions DROP COLUMN IF EXISTS reviewed_by;
+
+ALTER TABLE users DROP COLUMN IF EXISTS status_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS status;
+
+UPDATE users SET role = 'customer' WHERE role IN ('support', 'auditor');
+ALTER TABLE users DROP CONSTRAINT IF EXISTS chk_users_role;
+ALTER TABLE users ADD CONSTRAINT chk_users_role CHECK (role IN ('customer', 'admin'));
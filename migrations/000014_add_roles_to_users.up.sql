@@ -0,0 +1,14 @@
+-- Support and auditor staff roles next to admin
+ALTER TABLE users DROP CONSTRAINT chk_users_role;
+ALTER TABLE users ADD CONSTRAINT chk_users_role
+  CHECK (role IN ('customer', 'support', 'admin', 'auditor'));
+
+-- Account status of every user
+ALTER TABLE users ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'
+  CHECK (status IN ('active', 'suspended'));
+ALTER TABLE users ADD COLUMN status_reason TEXT;
+
+-- Staff member who reviewed a KYC submission
+ALTER TABLE kyc_submissions ADD COLUMN reviewed_by UUID;
+ALTER TABLE kyc_submissions ADD CONSTRAINT fk_kyc_submission_reviewer
+  FOREIGN KEY (reviewed_by) REFERENCES users(id);
@@ -0,0 +1,24 @@
+-- Wallet state: frozen_debit blocks outgoing money, frozen_all blocks every
+-- movement, closed is final
+ALTER TABLE wallets ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'
+  CHECK (status IN ('active', 'frozen_debit', 'frozen_all', 'closed'));
+ALTER TABLE wallets ADD COLUMN status_reason TEXT;
+ALTER TABLE wallets ADD COLUMN status_changed_by UUID;
+ALTER TABLE wallets ADD COLUMN status_changed_at TIMESTAMPTZ;
+ALTER TABLE wallets ADD CONSTRAINT fk_wallet_status_changed_by
+  FOREIGN KEY (status_changed_by) REFERENCES users(id);
+
+-- Append-only history of wallet status changes
+CREATE TABLE IF NOT EXISTS wallet_status_changes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  wallet_id UUID NOT NULL,
+  from_status VARCHAR(20) NOT NULL,
+  to_status VARCHAR(20) NOT NULL,
+  reason TEXT NOT NULL,
+  actor_id UUID NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_wallet_status_change_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id),
+  CONSTRAINT fk_wallet_status_change_actor FOREIGN KEY (actor_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_wallet_status_changes_wallet_id ON wallet_status_changes(wallet_id, created_at DESC);
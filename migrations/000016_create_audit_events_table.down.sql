@@ -0,0 +1,4 @@
+DROP TRIGGER IF EXISTS trg_audit_events_no_truncate ON audit_events;
+DROP TRIGGER IF EXISTS trg_audit_events_no_update_delete ON audit_events;
+DROP TABLE IF EXISTS audit_events;
+DROP FUNCTION IF EXISTS audit_events_append_only();
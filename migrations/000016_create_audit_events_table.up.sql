@@ -0,0 +1,40 @@
+-- Append-only audit log. Each row's hash covers its fields and the previous
+-- row's hash, so edits and deletions are detectable. There are no foreign
+-- keys: the log must outlive anything it refers to.
+CREATE TABLE IF NOT EXISTS audit_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  sequence BIGINT NOT NULL UNIQUE,
+  actor_id UUID,
+  action VARCHAR(100) NOT NULL,
+  target_type VARCHAR(50),
+  target_id VARCHAR(255),
+  ip VARCHAR(45),
+  user_agent TEXT,
+  request_id VARCHAR(100),
+  -- JSON rather than JSONB keeps the payload byte-for-byte as hashed
+  payload JSON,
+  prev_hash VARCHAR(64) NOT NULL,
+  hash VARCHAR(64) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_audit_events_actor_id ON audit_events(actor_id, sequence DESC);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id, sequence DESC);
+CREATE INDEX idx_audit_events_action ON audit_events(action, sequence DESC);
+CREATE INDEX idx_audit_events_request_id ON audit_events(request_id);
+CREATE INDEX idx_audit_events_created_at ON audit_events(created_at);
+
+-- Reject every change to stored events, including TRUNCATE
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_events_no_update_delete
+  BEFORE UPDATE OR DELETE ON audit_events
+  FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER trg_audit_events_no_truncate
+  BEFORE TRUNCATE ON audit_events
+  FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_reversal_of;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transaction_reversal_of;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transaction_reversal_of;
+ALTER TABLE transactions DROP COLUMN IF EXISTS reversal_of;
@@ -0,0 +1,7 @@
+-- Reversals and refunds point at the transfer they move money back for
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS reversal_of UUID;
+ALTER TABLE transactions ADD CONSTRAINT fk_transaction_reversal_of FOREIGN KEY (reversal_of) REFERENCES transactions(id);
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_reversal_of
+  CHECK ((type IN ('reversal', 'refund')) = (reversal_of IS NOT NULL));
+
+CREATE INDEX IF NOT EXISTS idx_transactions_reversal_of ON transactions(reversal_of) WHERE reversal_of IS NOT NULL;
@@ -0,0 +1,24 @@
+-- Requests for money from another user; accepting one creates a transfer
+CREATE TABLE IF NOT EXISTS payment_requests (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  requester_id UUID NOT NULL,
+  payer_id UUID NOT NULL,
+  amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+  note VARCHAR(255),
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'accepted', 'declined', 'cancelled', 'expired')),
+  expires_at TIMESTAMPTZ NOT NULL,
+  transaction_id UUID,
+  responded_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_payment_request_requester FOREIGN KEY (requester_id) REFERENCES users(id),
+  CONSTRAINT fk_payment_request_payer FOREIGN KEY (payer_id) REFERENCES users(id),
+  CONSTRAINT fk_payment_request_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+  CONSTRAINT chk_payment_request_parties CHECK (requester_id <> payer_id),
+  CONSTRAINT chk_payment_request_transaction CHECK ((status = 'accepted') = (transaction_id IS NOT NULL))
+);
+
+CREATE INDEX idx_payment_requests_requester ON payment_requests(requester_id, created_at DESC);
+CREATE INDEX idx_payment_requests_payer ON payment_requests(payer_id, created_at DESC);
+CREATE INDEX idx_payment_requests_expiry ON payment_requests(expires_at) WHERE status = 'pending';
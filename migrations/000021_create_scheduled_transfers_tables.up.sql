@@ -0,0 +1,47 @@
+-- One-time and recurring transfers made by the scheduler
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  receiver_id UUID NOT NULL,
+  amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+  note VARCHAR(255),
+  frequency VARCHAR(20) NOT NULL CHECK (frequency IN ('once', 'daily', 'weekly', 'monthly')),
+  start_at TIMESTAMPTZ NOT NULL,
+  end_at TIMESTAMPTZ,
+  max_runs INTEGER CHECK (max_runs > 0),
+  status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'completed', 'cancelled', 'failed')),
+  next_run_at TIMESTAMPTZ,
+  run_count INTEGER NOT NULL DEFAULT 0,
+  consecutive_failures INTEGER NOT NULL DEFAULT 0,
+  last_run_at TIMESTAMPTZ,
+  -- Set while a scheduler is running the current occurrence
+  leased_until TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_scheduled_transfer_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_scheduled_transfer_receiver FOREIGN KEY (receiver_id) REFERENCES users(id),
+  CONSTRAINT chk_scheduled_transfer_parties CHECK (user_id <> receiver_id),
+  CONSTRAINT chk_scheduled_transfer_next_run CHECK ((status = 'active') = (next_run_at IS NOT NULL))
+);
+
+CREATE INDEX idx_scheduled_transfers_user ON scheduled_transfers(user_id, created_at DESC);
+CREATE INDEX idx_scheduled_transfers_due ON scheduled_transfers(next_run_at) WHERE status = 'active';
+
+-- Every attempt at an occurrence; an occurrence is paid at most once
+CREATE TABLE IF NOT EXISTS scheduled_transfer_runs (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  scheduled_transfer_id UUID NOT NULL,
+  scheduled_for TIMESTAMPTZ NOT NULL,
+  attempt INTEGER NOT NULL,
+  status VARCHAR(20) NOT NULL CHECK (status IN ('success', 'failed')),
+  transaction_id UUID,
+  failure_reason VARCHAR(50),
+  skipped BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_scheduled_transfer_run_schedule FOREIGN KEY (scheduled_transfer_id) REFERENCES scheduled_transfers(id),
+  CONSTRAINT fk_scheduled_transfer_run_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+);
+
+CREATE INDEX idx_scheduled_transfer_runs_schedule ON scheduled_transfer_runs(scheduled_transfer_id, created_at DESC);
+CREATE UNIQUE INDEX uq_scheduled_transfer_runs_paid ON scheduled_transfer_runs(scheduled_transfer_id, scheduled_for) WHERE status = 'success';
@@ -0,0 +1,18 @@
+ALTER TABLE scheduled_transfers DROP CONSTRAINT IF EXISTS fk_scheduled_transfer_wallet;
+ALTER TABLE scheduled_transfers DROP COLUMN IF EXISTS wallet_id;
+
+DROP INDEX IF EXISTS idx_transactions_receiver_wallet_created_at_id;
+DROP INDEX IF EXISTS idx_transactions_sender_wallet_created_at_id;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transaction_receiver_wallet;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transaction_sender_wallet;
+ALTER TABLE transactions DROP COLUMN IF EXISTS receiver_wallet_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS sender_wallet_id;
+
+DROP INDEX IF EXISTS uq_wallets_user_name;
+DROP INDEX IF EXISTS uq_wallets_primary;
+ALTER TABLE wallets DROP COLUMN IF EXISTS is_primary;
+ALTER TABLE wallets DROP COLUMN IF EXISTS name;
+
+-- Fails while any user still has more than one wallet
+DROP INDEX IF EXISTS idx_wallets_user_id;
+CREATE UNIQUE INDEX idx_wallets_user_id ON wallets(user_id);
@@ -0,0 +1,36 @@
+-- Users may have several named wallets; exactly one of them is primary and
+-- receives transfers from other users
+DROP INDEX IF EXISTS idx_wallets_user_id;
+CREATE INDEX idx_wallets_user_id ON wallets(user_id);
+
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS name VARCHAR(50) NOT NULL DEFAULT 'Main';
+ALTER TABLE wallets ALTER COLUMN name DROP DEFAULT;
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS is_primary BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Every existing wallet is the only wallet of its user
+UPDATE wallets SET is_primary = TRUE;
+
+CREATE UNIQUE INDEX uq_wallets_primary ON wallets(user_id) WHERE is_primary AND deleted_at IS NULL;
+CREATE UNIQUE INDEX uq_wallets_user_name ON wallets(user_id, LOWER(name)) WHERE deleted_at IS NULL;
+
+-- The wallets a transaction moved money out of and into
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS sender_wallet_id UUID;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS receiver_wallet_id UUID;
+ALTER TABLE transactions ADD CONSTRAINT fk_transaction_sender_wallet FOREIGN KEY (sender_wallet_id) REFERENCES wallets(id);
+ALTER TABLE transactions ADD CONSTRAINT fk_transaction_receiver_wallet FOREIGN KEY (receiver_wallet_id) REFERENCES wallets(id);
+
+UPDATE transactions t SET sender_wallet_id = w.id
+  FROM wallets w
+  WHERE w.user_id = t.sender_id AND t.status = 'success';
+UPDATE transactions t SET receiver_wallet_id = w.id
+  FROM wallets w
+  WHERE w.user_id = t.receiver_id AND t.status = 'success';
+
+CREATE INDEX idx_transactions_sender_wallet_created_at_id ON transactions(sender_wallet_id, created_at DESC, id DESC)
+  WHERE sender_wallet_id IS NOT NULL;
+CREATE INDEX idx_transactions_receiver_wallet_created_at_id ON transactions(receiver_wallet_id, created_at DESC, id DESC)
+  WHERE receiver_wallet_id IS NOT NULL;
+
+-- Scheduled transfers may be paid from a wallet other than the primary one
+ALTER TABLE scheduled_transfers ADD COLUMN IF NOT EXISTS wallet_id UUID;
+ALTER TABLE scheduled_transfers ADD CONSTRAINT fk_scheduled_transfer_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id);
@@ -0,0 +1,20 @@
+ALTER TABLE payment_requests DROP COLUMN IF EXISTS currency;
+
+DROP INDEX IF EXISTS uq_transactions_fx_quote_id;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS chk_transaction_conversion;
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS fk_transaction_fx_quote;
+ALTER TABLE transactions DROP COLUMN IF EXISTS base_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_quote_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS fx_rate;
+ALTER TABLE transactions DROP COLUMN IF EXISTS converted_currency;
+ALTER TABLE transactions DROP COLUMN IF EXISTS converted_amount;
+ALTER TABLE transactions DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS fx_quotes;
+
+-- Fails while system accounts exist in more than one currency
+DROP INDEX IF EXISTS idx_ledger_accounts_code_currency;
+CREATE UNIQUE INDEX idx_ledger_accounts_code ON ledger_accounts(code);
+ALTER TABLE ledger_accounts DROP COLUMN IF EXISTS currency;
+
+ALTER TABLE wallets DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,50 @@
+-- Every wallet holds one currency; existing wallets hold IDR
+ALTER TABLE wallets ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
+
+-- Ledger accounts hold one currency, and there is one system account per
+-- code and currency
+ALTER TABLE ledger_accounts ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
+DROP INDEX IF EXISTS idx_ledger_accounts_code;
+CREATE UNIQUE INDEX idx_ledger_accounts_code_currency ON ledger_accounts(code, currency);
+
+-- Locked exchange rates, each redeemable once before it expires
+CREATE TABLE IF NOT EXISTS fx_quotes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  from_currency VARCHAR(3) NOT NULL,
+  to_currency VARCHAR(3) NOT NULL,
+  rate NUMERIC(20,8) NOT NULL CHECK (rate > 0),
+  source_amount DECIMAL(15,2) NOT NULL CHECK (source_amount > 0),
+  target_amount DECIMAL(15,2) NOT NULL CHECK (target_amount > 0),
+  expires_at TIMESTAMPTZ NOT NULL,
+  used_at TIMESTAMPTZ,
+  transaction_id UUID,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_fx_quote_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_fx_quote_currencies CHECK (from_currency <> to_currency),
+  CONSTRAINT chk_fx_quote_used CHECK ((used_at IS NULL) = (transaction_id IS NULL))
+);
+
+CREATE INDEX idx_fx_quotes_user_id ON fx_quotes(user_id);
+
+-- Transactions record the currency of the amount and, when the money was
+-- converted, what the receiver got. base_amount values the amount in IDR
+-- for limits.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS converted_amount DECIMAL(15,2);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS converted_currency VARCHAR(3);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_rate NUMERIC(20,8);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fx_quote_id UUID;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS base_amount DECIMAL(15,2) NOT NULL DEFAULT 0;
+ALTER TABLE transactions ADD CONSTRAINT fk_transaction_fx_quote FOREIGN KEY (fx_quote_id) REFERENCES fx_quotes(id);
+ALTER TABLE transactions ADD CONSTRAINT chk_transaction_conversion CHECK (
+  (converted_amount IS NULL) = (converted_currency IS NULL) AND
+  (converted_amount IS NULL) = (fx_rate IS NULL)
+);
+
+UPDATE transactions SET base_amount = amount;
+
+CREATE UNIQUE INDEX uq_transactions_fx_quote_id ON transactions(fx_quote_id) WHERE fx_quote_id IS NOT NULL;
+
+-- Payment requests ask for an amount in the requester's currency
+ALTER TABLE payment_requests ADD COLUMN IF NOT EXISTS currency VARCHAR(3) NOT NULL DEFAULT 'IDR';
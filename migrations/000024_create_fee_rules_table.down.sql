@@ -0,0 +1,4 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS fee;
+
+DROP INDEX IF EXISTS idx_fee_rules_type_currency;
+DROP TABLE IF EXISTS fee_rules;
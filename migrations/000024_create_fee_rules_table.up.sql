@@ -0,0 +1,23 @@
+-- Fee rules price top-ups and transfers by type, currency, amount band
+-- (min_amount inclusive, max_amount exclusive, NULL = no upper bound) and
+-- optionally KYC tier. The fee is flat_fee plus percent_bps basis points of
+-- the amount, capped at max_fee. Transactions no rule matches are free.
+CREATE TABLE IF NOT EXISTS fee_rules (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  transaction_type VARCHAR(20) NOT NULL CHECK (transaction_type IN ('topup', 'transfer')),
+  kyc_tier VARCHAR(20) CHECK (kyc_tier IN ('basic', 'verified', 'premium')),
+  currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+  min_amount DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (min_amount >= 0),
+  max_amount DECIMAL(15,2),
+  flat_fee DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (flat_fee >= 0),
+  percent_bps BIGINT NOT NULL DEFAULT 0 CHECK (percent_bps BETWEEN 0 AND 10000),
+  max_fee DECIMAL(15,2) CHECK (max_fee >= 0),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT chk_fee_rule_band CHECK (max_amount IS NULL OR max_amount > min_amount)
+);
+
+CREATE INDEX idx_fee_rules_type_currency ON fee_rules(transaction_type, currency);
+
+-- Fee charged to the payer on top of the amount, in the transaction's currency
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fee DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (fee >= 0);
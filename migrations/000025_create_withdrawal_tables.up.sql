@@ -0,0 +1,49 @@
+-- Bank accounts users withdraw to; removed accounts are soft-deleted so
+-- earlier withdrawals keep their destination
+CREATE TABLE IF NOT EXISTS bank_accounts (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  bank_code VARCHAR(11) NOT NULL,
+  account_number VARCHAR(20) NOT NULL,
+  account_name VARCHAR(100) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMPTZ,
+  CONSTRAINT fk_bank_account_user FOREIGN KEY (user_id) REFERENCES users(id)
+);
+
+CREATE INDEX idx_bank_accounts_user_id ON bank_accounts(user_id);
+CREATE INDEX idx_bank_accounts_deleted_at ON bank_accounts(deleted_at);
+CREATE UNIQUE INDEX uq_bank_accounts_user_account ON bank_accounts(user_id, bank_code, account_number) WHERE deleted_at IS NULL;
+
+-- Payouts from a wallet into a bank account. The money is held by the
+-- withdrawal transaction until the payout provider reports the result.
+CREATE TABLE IF NOT EXISTS withdrawals (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  wallet_id UUID NOT NULL,
+  bank_account_id UUID NOT NULL,
+  amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+  currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'processing', 'success', 'failed')),
+  transaction_id UUID NOT NULL UNIQUE,
+  provider VARCHAR(50),
+  provider_reference VARCHAR(100),
+  failure_reason VARCHAR(50),
+  last_error TEXT,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  submitted_at TIMESTAMPTZ,
+  completed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_withdrawal_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_withdrawal_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id),
+  CONSTRAINT fk_withdrawal_bank_account FOREIGN KEY (bank_account_id) REFERENCES bank_accounts(id),
+  CONSTRAINT fk_withdrawal_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+  CONSTRAINT chk_withdrawal_failure CHECK ((status = 'failed') = (failure_reason IS NOT NULL))
+);
+
+CREATE INDEX idx_withdrawals_user ON withdrawals(user_id, created_at DESC);
+CREATE INDEX idx_withdrawals_due ON withdrawals(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,32 @@
+-- Top-ups paid through the payment gateway. The wallet is credited by the
+-- top-up transaction only when the gateway confirms the payment.
+CREATE TABLE IF NOT EXISTS top_ups (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  user_id UUID NOT NULL,
+  wallet_id UUID NOT NULL,
+  amount DECIMAL(15,2) NOT NULL CHECK (amount > 0),
+  fee DECIMAL(15,2) NOT NULL DEFAULT 0 CHECK (fee >= 0),
+  total DECIMAL(15,2) NOT NULL CHECK (total = amount + fee),
+  currency VARCHAR(3) NOT NULL DEFAULT 'IDR',
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'success', 'failed', 'expired', 'held')),
+  transaction_id UUID NOT NULL UNIQUE,
+  gateway VARCHAR(50) NOT NULL,
+  -- Set once the gateway has opened the payment
+  gateway_reference VARCHAR(100),
+  virtual_account_number VARCHAR(50),
+  payment_url TEXT,
+  failure_reason VARCHAR(50),
+  expires_at TIMESTAMPTZ NOT NULL,
+  completed_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  CONSTRAINT fk_top_up_user FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT fk_top_up_wallet FOREIGN KEY (wallet_id) REFERENCES wallets(id),
+  CONSTRAINT fk_top_up_transaction FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+  CONSTRAINT chk_top_up_failure CHECK ((status IN ('failed', 'expired', 'held')) = (failure_reason IS NOT NULL))
+);
+
+CREATE INDEX idx_top_ups_user ON top_ups(user_id, created_at DESC);
+CREATE INDEX idx_top_ups_expiry ON top_ups(expires_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX uq_top_ups_gateway_reference ON top_ups(gateway, gateway_reference);
@@ -0,0 +1,2 @@
+-- The redacted response bodies cannot be restored
+SELECT 1;
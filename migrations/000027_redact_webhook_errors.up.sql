@@ -0,0 +1,5 @@
+-- Response bodies were kept in last_error, which is shown to the endpoint's
+-- owner; keep only the status code
+UPDATE webhook_deliveries
+SET last_error = substring(last_error from '^endpoint responded with status [0-9]+')
+WHERE last_error LIKE 'endpoint responded with status %';
@@ -0,0 +1,4 @@
+ALTER TABLE limit_policies DROP COLUMN IF EXISTS withdrawal_monthly;
+ALTER TABLE limit_policies DROP COLUMN IF EXISTS withdrawal_daily;
+ALTER TABLE limit_policies DROP COLUMN IF EXISTS withdrawal_max;
+ALTER TABLE limit_policies DROP COLUMN IF EXISTS withdrawal_min;
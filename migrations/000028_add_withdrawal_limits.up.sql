@@ -0,0 +1,12 @@
+-- Withdrawals count against their own limits, like top ups and transfers
+ALTER TABLE limit_policies ADD COLUMN withdrawal_min DECIMAL(15,2);
+ALTER TABLE limit_policies ADD COLUMN withdrawal_max DECIMAL(15,2);
+ALTER TABLE limit_policies ADD COLUMN withdrawal_daily DECIMAL(15,2);
+ALTER TABLE limit_policies ADD COLUMN withdrawal_monthly DECIMAL(15,2);
+
+-- Start from the transfer limits of each policy
+UPDATE limit_policies SET
+  withdrawal_min = transfer_min,
+  withdrawal_max = transfer_max,
+  withdrawal_daily = transfer_daily,
+  withdrawal_monthly = transfer_monthly;
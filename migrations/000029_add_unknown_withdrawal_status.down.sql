@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS idx_withdrawals_due;
+CREATE INDEX idx_withdrawals_due ON withdrawals(next_attempt_at) WHERE status = 'pending';
+
+-- Unknown withdrawals still hold their money; they go back to pending and
+-- are submitted again, which the provider treats idempotently
+UPDATE withdrawals SET status = 'pending' WHERE status = 'unknown';
+ALTER TABLE withdrawals DROP CONSTRAINT IF EXISTS withdrawals_status_check;
+ALTER TABLE withdrawals ADD CONSTRAINT withdrawals_status_check
+  CHECK (status IN ('pending', 'processing', 'success', 'failed'));
@@ -0,0 +1,9 @@
+-- A withdrawal whose submission kept failing may still have been accepted
+-- by the provider; it stays unknown, with the money held, until the
+-- provider says what became of it
+ALTER TABLE withdrawals DROP CONSTRAINT IF EXISTS withdrawals_status_check;
+ALTER TABLE withdrawals ADD CONSTRAINT withdrawals_status_check
+  CHECK (status IN ('pending', 'processing', 'unknown', 'success', 'failed'));
+
+DROP INDEX IF EXISTS idx_withdrawals_due;
+CREATE INDEX idx_withdrawals_due ON withdrawals(next_attempt_at) WHERE status IN ('pending', 'unknown');
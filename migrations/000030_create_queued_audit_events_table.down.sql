@@ -0,0 +1,2 @@
+-- Events still queued are lost; let the audit chainer empty the queue first
+DROP TABLE IF EXISTS queued_audit_events;
@@ -0,0 +1,17 @@
+-- Audited changes write their events here, in the same transaction as the
+-- change, and the audit chainer later moves them to audit_events in order.
+-- Only the chainer takes the chain lock, so money-moving transactions no
+-- longer wait on each other to append to the chain.
+CREATE TABLE IF NOT EXISTS queued_audit_events (
+  position BIGSERIAL PRIMARY KEY,
+  id UUID NOT NULL UNIQUE,
+  actor_id UUID,
+  action VARCHAR(100) NOT NULL,
+  target_type VARCHAR(50),
+  target_id VARCHAR(255),
+  ip VARCHAR(45),
+  user_agent TEXT,
+  request_id VARCHAR(100),
+  payload JSON,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidPath = errors.New("invalid storage path")
+	ErrTooLarge    = errors.New("file is too large")
+)
+
+// LocalStorage keeps files in a directory on local disk. Paths handed out
+// and accepted are relative to that directory.
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) *LocalStorage {
+	return &LocalStorage{root: root}
+}
+
+// Save writes r to a new, randomly named file in dir and returns its path
+// and size. At most maxSize bytes are accepted; a larger file is removed and
+// reported as ErrTooLarge.
+func (s *LocalStorage) Save(dir, ext string, r io.Reader, maxSize int64) (string, int64, error) {
+	rel := filepath.Join(dir, uuid.New().String()+ext)
+	full, err := s.resolve(rel)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(full), 0o750); err != nil {
+		return "", 0, err
+	}
+	f, err := os.OpenFile(full, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// Read one byte past the limit to detect oversized files
+	size, err := io.Copy(f, io.LimitReader(r, maxSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > maxSize {
+		err = ErrTooLarge
+	}
+	if err != nil {
+		os.Remove(full)
+		return "", 0, err
+	}
+	return rel, size, nil
+}
+
+// Path returns the absolute location of a stored file.
+func (s *LocalStorage) Path(rel string) (string, error) {
+	return s.resolve(rel)
+}
+
+// Remove deletes a stored file. Missing files are not an error.
+func (s *LocalStorage) Remove(rel string) error {
+	full, err := s.resolve(rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// resolve joins rel onto the root and refuses paths that escape it.
+func (s *LocalStorage) resolve(rel string) (string, error) {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", err
+	}
+	full := filepath.Join(root, rel)
+	if full == root || !strings.HasPrefix(full, root+string(filepath.Separator)) {
+		return "", ErrInvalidPath
+	}
+	return full, nil
+}
@@ -9,8 +9,10 @@ import (
 )
 
 type JWTClaims struct {
-	UserID uuid.UUID `json:"user_id"`
-	Email  string    `json:"email"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	SessionID uuid.UUID `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -26,10 +28,17 @@ func NewJWTUtil(secretKey string, expiry time.Duration) *JWTUtil {
 	}
 }
 
-func (j *JWTUtil) GenerateToken(userID uuid.UUID, email string) (string, error) {
+// Expiry returns the lifetime of access tokens.
+func (j *JWTUtil) Expiry() time.Duration {
+	return j.expiry
+}
+
+func (j *JWTUtil) GenerateToken(userID uuid.UUID, email, role string, sessionID uuid.UUID) (string, error) {
 	claims := JWTClaims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"errors"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -9,6 +11,12 @@ type Response struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Code    string      `json:"code,omitempty"`
+}
+
+// codedError is implemented by errors that carry a machine-readable code.
+type codedError interface {
+	ErrorCode() string
 }
 
 func SuccessResponse(c *gin.Context, statusCode int, message string, data interface{}) {
@@ -27,6 +35,11 @@ func ErrorResponse(c *gin.Context, statusCode int, message string, err error) {
 
 	if err != nil {
 		response.Error = err.Error()
+
+		var coded codedError
+		if errors.As(err, &coded) {
+			response.Code = coded.ErrorCode()
+		}
 	}
 
 	c.JSON(statusCode, response)
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignaturePrefix tags signatures with the algorithm used.
+const SignaturePrefix = "sha256="
+
+// SignPayload returns the HMAC-SHA256 signature of a timestamped payload:
+// "sha256=" followed by the hex MAC of "<unix timestamp>.<body>".
+// Including the timestamp lets receivers reject replayed requests.
+func SignPayload(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPayloadSignature checks a signature made by SignPayload. It fails if
+// the timestamp is further than tolerance from now.
+func VerifyPayloadSignature(secret, timestamp, signature string, body []byte, tolerance time.Duration) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	signedAt := time.Unix(unix, 0)
+	if age := time.Since(signedAt); age > tolerance || age < -tolerance {
+		return false
+	}
+	if !strings.HasPrefix(signature, SignaturePrefix) {
+		return false
+	}
+
+	expected := SignPayload(secret, signedAt, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
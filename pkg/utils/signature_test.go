@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignPayloadFormat(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"1"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if got := SignPayload("secret", timestamp, body); got != want {
+		t.Fatalf("SignPayload() = %s, want %s", got, want)
+	}
+}
+
+func TestVerifyPayloadSignature(t *testing.T) {
+	const tolerance = 5 * time.Minute
+	body := []byte(`{"event":"transfer.completed"}`)
+	now := time.Now()
+	unix := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+	valid := SignPayload("secret", now, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		signature string
+		body      []byte
+		want      bool
+	}{
+		{"valid", "secret", unix(now), valid, body, true},
+		{"slightly in the future", "secret", unix(now.Add(time.Minute)), SignPayload("secret", now.Add(time.Minute), body), body, true},
+		{"wrong secret", "other", unix(now), valid, body, false},
+		{"changed body", "secret", unix(now), valid, []byte(`{"event":"transfer.failed"}`), false},
+		{"timestamp not signed", "secret", unix(now.Add(-time.Second)), valid, body, false},
+		{"too old", "secret", unix(now.Add(-tolerance - time.Minute)), SignPayload("secret", now.Add(-tolerance-time.Minute), body), body, false},
+		{"too far in the future", "secret", unix(now.Add(tolerance + time.Minute)), SignPayload("secret", now.Add(tolerance+time.Minute), body), body, false},
+		{"timestamp not a number", "secret", "yesterday", valid, body, false},
+		{"missing prefix", "secret", unix(now), strings.TrimPrefix(valid, SignaturePrefix), body, false},
+		{"uppercase hex", "secret", unix(now), SignaturePrefix + strings.ToUpper(strings.TrimPrefix(valid, SignaturePrefix)), body, false},
+		{"empty signature", "secret", unix(now), "", body, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPayloadSignature(tt.secret, tt.timestamp, tt.signature, tt.body, tolerance); got != tt.want {
+				t.Errorf("VerifyPayloadSignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+-- Demo staff accounts for local development only (password: password123);
+-- they have no wallet. Never run this against a shared or production
+-- database: staff accounts there are created by promoting real users.
+INSERT INTO users (id, name, email, password, role, created_at, updated_at) VALUES
+  ('aaaaaaaa-0000-0000-0000-000000000001', 'admin', 'admin@example.com', crypt('password123', gen_salt('bf')), 'admin', NOW(), NOW()),
+  ('aaaaaaaa-0000-0000-0000-000000000002', 'support', 'support@example.com', crypt('password123', gen_salt('bf')), 'support', NOW(), NOW()),
+  ('aaaaaaaa-0000-0000-0000-000000000003', 'auditor', 'auditor@example.com', crypt('password123', gen_salt('bf')), 'auditor', NOW(), NOW())
+ON CONFLICT (id) DO UPDATE SET status = 'active', status_reason = NULL;